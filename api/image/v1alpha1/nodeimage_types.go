@@ -33,6 +33,17 @@ type NodeImageSpec struct {
 	Name string `json:"name"`
 	// Provider is the provider that the image is going to be used for
 	Provider string `json:"provider"`
+	// URL is imported instead of the image in the S3 bucket, e.g. from an
+	// artifact server or a CDN mirror. It must be an S3 URL or on a host
+	// allowed by the operator.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Locations restricts the provider locations the image is distributed
+	// to, e.g. datacenters with GPUs for GPU node images. The image is
+	// distributed to all locations of the provider if empty.
+	// +optional
+	// +listType=set
+	Locations []string `json:"locations,omitempty"`
 }
 
 // NodeImageState is the state of the image
@@ -47,6 +58,45 @@ const (
 	NodeImageDeleted          NodeImageState = "Deleted"
 	NodeImageMissing          NodeImageState = "Missing"
 	NodeImageAwaitingDeletion NodeImageState = "AwaitingDeletion"
+	NodeImageScheduled        NodeImageState = "Scheduled"
+)
+
+const (
+	// NodeImageConditionDistributed reports whether the image is present in the provider
+	NodeImageConditionDistributed = "Distributed"
+
+	// NodeImageReasonUploaded means the image was uploaded by the operator
+	NodeImageReasonUploaded = "Uploaded"
+	// NodeImageReasonAlreadyPresent means the image already existed in the provider and the upload was skipped
+	NodeImageReasonAlreadyPresent = "AlreadyPresent"
+	// NodeImageReasonUnsupportedProvider means no provider is configured for spec.provider
+	NodeImageReasonUnsupportedProvider = "UnsupportedProvider"
+	// NodeImageReasonUnknownLocation means spec.locations names a location the provider is not configured with
+	NodeImageReasonUnknownLocation = "UnknownLocation"
+	// NodeImageReasonUploadAborted means an upload was aborted because the operator shut down
+	NodeImageReasonUploadAborted = "UploadAborted"
+	// NodeImageReasonTimeout means an upload was aborted because it exceeded the operation timeout
+	NodeImageReasonTimeout = "Timeout"
+	// NodeImageReasonBucketNotAllowed means the S3 bucket annotation names a bucket that is not allowed
+	NodeImageReasonBucketNotAllowed = "BucketNotAllowed"
+
+	// NodeImageConditionVerified reports whether a freshly uploaded image was found in the provider again
+	NodeImageConditionVerified = "Verified"
+
+	// NodeImageReasonVerificationPending means the image was uploaded and is checked again shortly
+	NodeImageReasonVerificationPending = "VerificationPending"
+	// NodeImageReasonPresent means the uploaded image was found in all locations
+	NodeImageReasonPresent = "Present"
+	// NodeImageReasonNotPresent means the uploaded image was missing in at least one location
+	NodeImageReasonNotPresent = "NotPresent"
+
+	// NodeImageConditionProviderAvailable reports whether the provider of the image is reachable
+	NodeImageConditionProviderAvailable = "ProviderAvailable"
+
+	// NodeImageReasonProviderUnavailable means the provider is unreachable in all locations and reconciles are paused
+	NodeImageReasonProviderUnavailable = "ProviderUnavailable"
+	// NodeImageReasonProviderReachable means the provider is reachable again after an outage
+	NodeImageReasonProviderReachable = "ProviderReachable"
 )
 
 // NodeImageStatus defines the observed state of NodeImage.
@@ -57,12 +107,96 @@ type NodeImageStatus struct {
 	// Releases is the list of releases that the image is used in
 	Releases []string `json:"releases"`
 
+	// ReleaseCount is the number of releases that the image is used in, kept
+	// in sync with Releases to be shown by kubectl get
+	// +optional
+	ReleaseCount int32 `json:"releaseCount"`
+
 	// State is the state that the image is currently in
 	State NodeImageState `json:"state"`
+
+	// LastTransitionTime is when the image last changed its state
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Message gives human-readable context on the state, e.g. the progress
+	// of an upload
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ProviderTaskRef references the provider task of the upload in
+	// progress, e.g. the URL of a Cloud Director task, to look into a stuck
+	// upload on the provider side. It is cleared once the upload succeeded.
+	// +optional
+	ProviderTaskRef string `json:"providerTaskRef,omitempty"`
+
+	// Conditions describe the latest observations of the image
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ConsecutiveFailures counts the failed uploads since the last successful
+	// reconcile. The next attempt is delayed exponentially based on it.
+	// +optional
+	ConsecutiveFailures int32 `json:"consecutiveFailures,omitempty"`
+
+	// Locations lists the name and state the image has in each provider
+	// location it is distributed to. While the NodeImage is deleted, the
+	// locations the image could not be deleted from yet remain.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Locations []NodeImageLocation `json:"locations,omitempty"`
+
+	// SourceURL is the URL the image is imported from, spec.url or the URL
+	// of the image in the S3 bucket. Locations with their own bucket import
+	// the same key from it.
+	// +optional
+	SourceURL string `json:"sourceURL,omitempty"`
+
+	// LastReconcileTime is when the image was last reconciled successfully,
+	// to alert on NodeImages that stopped reconciling. It is updated by the
+	// periodic reconciles, even if the state stays the same.
+	// +optional
+	LastReconcileTime metav1.Time `json:"lastReconcileTime,omitempty"`
+}
+
+// NodeImageLocation records the image in a single provider location
+type NodeImageLocation struct {
+	// Name is the name of the provider location
+	Name string `json:"name"`
+
+	// ImageName is the name of the image in the location, including any
+	// suffix the location appends
+	ImageName string `json:"imageName"`
+
+	// ProviderImageID is the ID the provider gave the image in the location,
+	// e.g. the managed object ID of a vSphere template or the URN of a Cloud
+	// Director vApp template. The image is looked up by it instead of its
+	// name where the provider supports it.
+	// +optional
+	ProviderImageID string `json:"providerImageID,omitempty"`
+
+	// State is the state of the image in the location, e.g. Uploading while
+	// it is uploaded to the location
+	// +optional
+	State NodeImageState `json:"state,omitempty"`
+
+	// Message gives human-readable context on the state, e.g. the progress
+	// of the upload to the location
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.provider`
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Releases",type=integer,JSONPath=`.status.releaseCount`
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.status.sourceURL`,priority=1
+// +kubebuilder:printcolumn:name="Last Reconcile",type=date,JSONPath=`.status.lastReconcileTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // NodeImage is the Schema for the nodeimages API.
 type NodeImage struct {
@@ -21,6 +21,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -29,7 +30,7 @@ func (in *NodeImage) DeepCopyInto(out *NodeImage) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -83,9 +84,29 @@ func (in *NodeImageList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeImageLocation) DeepCopyInto(out *NodeImageLocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeImageLocation.
+func (in *NodeImageLocation) DeepCopy() *NodeImageLocation {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeImageLocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeImageSpec) DeepCopyInto(out *NodeImageSpec) {
 	*out = *in
+	if in.Locations != nil {
+		in, out := &in.Locations, &out.Locations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeImageSpec.
@@ -106,6 +127,20 @@ func (in *NodeImageStatus) DeepCopyInto(out *NodeImageStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Locations != nil {
+		in, out := &in.Locations, &out.Locations
+		*out = make([]NodeImageLocation, len(*in))
+		copy(*out, *in)
+	}
+	in.LastReconcileTime.DeepCopyInto(&out.LastReconcileTime)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeImageStatus.
@@ -19,9 +19,14 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -29,12 +34,15 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/giantswarm/releases/sdk/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -43,12 +51,22 @@ import (
 
 	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
 	imagecontroller "github.com/giantswarm/image-distribution-operator/internal/controller/image"
+	"github.com/giantswarm/image-distribution-operator/internal/controller/locations"
 	"github.com/giantswarm/image-distribution-operator/internal/controller/release"
+	webhookimagev1alpha1 "github.com/giantswarm/image-distribution-operator/internal/webhook/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/cleanup"
 	clouddirector "github.com/giantswarm/image-distribution-operator/pkg/cloud-director"
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+	"github.com/giantswarm/image-distribution-operator/pkg/gcs"
+	"github.com/giantswarm/image-distribution-operator/pkg/httpcheck"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/notify"
 	"github.com/giantswarm/image-distribution-operator/pkg/provider"
 	"github.com/giantswarm/image-distribution-operator/pkg/proxmox"
 	"github.com/giantswarm/image-distribution-operator/pkg/s3"
+	"github.com/giantswarm/image-distribution-operator/pkg/storage"
 	"github.com/giantswarm/image-distribution-operator/pkg/vsphere"
+	"github.com/giantswarm/image-distribution-operator/pkg/window"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -57,6 +75,10 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// defaultGracefulShutdownTimeout is the time controller-runtime gives the
+// manager to stop by default
+const defaultGracefulShutdownTimeout = 30 * time.Second
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(v1alpha1.AddToScheme(scheme))
@@ -67,6 +89,13 @@ func init() {
 
 // nolint:gocyclo
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == seedCommand {
+		exitSeed(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == validateCommand {
+		exitValidate(os.Args[2:])
+	}
+
 	var namespace string
 	var metricsAddr string
 	var metricsCertPath, metricsCertName, metricsCertKey string
@@ -75,44 +104,132 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var logFormat string
+	var enableWebhooks bool
 	var tlsOpts []func(*tls.Config)
 
 	var s3Bucket, s3Region string
 	var s3TimeoutSeconds int
 	var s3HTTP bool
+	var s3VerifyObject bool
+	var s3RegionMismatchPolicy string
+	var s3DownloadDir string
+	var imageStore string
+	var gcsBucket, gcsCredentialsFile string
+	var allowedImageHostPatterns string
+	var s3AllowedBuckets string
+	var downloadFallbackDir string
+	var staleDownloadMaxAge time.Duration
+	var downloadProxyURL, downloadNoProxy string
 
 	var clientSetupRetryDuration time.Duration
 	var clientSetupRetrySteps int
 
+	var dryRun bool
+
 	var enableCloudDirector bool
 	var enableProxmox bool
 	var enableVsphere bool
 
 	var vsphereCredentials string
 	var vsphereLocations string
+	var vsphereLocationsConfigMap string
+	var vsphereCACertFile string
 	var vspherePullFromURL bool
+	var vsphereVerifyChecksum bool
+	var vspherePullRetries int
+	var vsphereMaxConcurrentImports int
+	var vsphereTagCategory string
 
 	var vcdCredentials string
 	var vcdLocations string
+	var vcdLocationsConfigMap string
+	var locationsConfigMapNamespace string
 	var vcdDownloadDir string
 	var vcdSessionRefreshThreshold time.Duration
+	var vcdDownloadTimeout time.Duration
+	var vcdDownloadStallTimeout time.Duration
+	var vcdUploadPieceSizeMB int64
+	var vcdOverwritePolicy string
 
 	var proxmoxCredentials string
 	var proxmoxLocations string
 
 	var imageRetentionPeriod time.Duration
+	var maxImageSizeBytes int64
+	var maxTransferBytesPerSecond int64
+	var locationConcurrency int
+	var orphanedImageCollectionInterval time.Duration
+	var resyncInterval time.Duration
+	var maxUnusedImagesPerProvider int
+	var uploadVerificationDelay time.Duration
+	var providerProbeInterval time.Duration
+	var failureBackoff, maxFailureBackoff time.Duration
+	var existsCacheTTL time.Duration
+	var requeueInterval time.Duration
+	var requeueJitter float64
+	var shutdownGracePeriod time.Duration
+	var operationTimeout time.Duration
+	var imageReadinessTimeout time.Duration
+
+	var distributionWindow string
+	var imageNameTemplate string
+	var imageKeyTemplate string
+	var imageNameCollisionPolicy string
+	var truncateLongImageNames bool
+	var disableFinalizer bool
+
+	var notificationWebhookURLFile string
+	var notifyOnAvailable bool
 
 	flag.StringVar(&namespace, "namespace", "giantswarm", "The namespace where node image objects are managed.")
 	flag.StringVar(&s3Bucket, "s3-bucket", "", "The S3 bucket where images are stored.")
 	flag.StringVar(&s3Region, "s3-region", "", "The region where the S3 bucket is located.")
 	flag.IntVar(&s3TimeoutSeconds, "s3-timeout-seconds", 90, "The timeout in seconds for S3 pull operations.")
 	flag.BoolVar(&s3HTTP, "s3-http", false, "Use HTTP instead of HTTPS for S3 operations.")
+	flag.BoolVar(&s3VerifyObject, "s3-verify-object", false,
+		"Check with an authenticated request that an image is in the S3 bucket before uploading it. Requires S3 credentials.")
+	flag.StringVar(&s3DownloadDir, "s3-download-dir", s3.Directory, "The directory where images pulled from S3 are stored.")
+	flag.StringVar(&s3RegionMismatchPolicy, "s3-region-mismatch-policy", s3.RegionMismatchFail,
+		"What to do at startup if the S3 region does not match the region of the bucket or of signed requests: "+
+			"\"fail\" exits, \"warn\" only logs.")
+	flag.StringVar(&imageStore, "image-store", storage.S3,
+		"The object store images are imported from: \"s3\" or \"gcs\". The S3 timeout, download directory and "+
+			"object verification flags apply to GCS as well.")
+	flag.StringVar(&gcsBucket, "gcs-bucket", "", "The GCS bucket where images are stored, with --image-store=gcs.")
+	flag.StringVar(&gcsCredentialsFile, "gcs-credentials-file", "",
+		"The service account key file GCS is accessed with. Application Default Credentials are used if empty.")
+	flag.StringVar(&allowedImageHostPatterns, "allowed-image-host-patterns", "",
+		"Comma separated shell patterns of the hosts, e.g. \"*.cdn.example.com\", NodeImages may set spec.url to besides S3. "+
+			"Only S3 URLs are accepted if empty.")
+	flag.StringVar(&s3AllowedBuckets, "s3-allowed-buckets", "",
+		"Comma separated S3 buckets NodeImages may import their image from with the "+image.S3BucketAnnotation+" annotation. "+
+			"The annotation is rejected if empty.")
+	flag.StringVar(&downloadFallbackDir, "download-fallback-dir", "",
+		"The directory images are downloaded to if the S3 or VCD download directory is not writable. Disabled if empty.")
+	flag.DurationVar(&staleDownloadMaxAge, "stale-download-max-age", 6*time.Hour,
+		"Image files in the download directories older than this are removed on startup. Disabled if 0.")
+	flag.StringVar(&downloadProxyURL, "download-proxy-url", "",
+		"The HTTP proxy the operator downloads images and reaches S3 through, e.g. \"http://proxy.example.com:3128\". "+
+			"Falls back to HTTPS_PROXY and HTTP_PROXY if empty.")
+	flag.StringVar(&downloadNoProxy, "download-no-proxy", "",
+		"Comma separated hosts, domain suffixes, IPs and CIDRs reached without the download proxy, in addition to NO_PROXY.")
 
 	flag.DurationVar(&clientSetupRetryDuration, "client-setup-retry-duration", 5*time.Second,
 		"The initial duration to wait between retries when setting up provider clients.")
 	flag.IntVar(&clientSetupRetrySteps, "client-setup-retry-steps", 5,
 		"The number of retry attempts when setting up provider clients.")
 
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Only log the uploads and deletions the providers would perform, without touching the infrastructure.")
+
+	flag.BoolVar(&truncateLongImageNames, "truncate-long-image-names", false,
+		"Truncate image names exceeding a provider's length limit and append a hash instead of failing the upload.")
+
+	flag.BoolVar(&disableFinalizer, "disable-finalizer", false,
+		"Don't add the finalizer to NodeImages and delete their images on a best-effort basis. "+
+			"Only meant for test and dev environments, images of NodeImages deleted by users are left behind.")
+
 	flag.BoolVar(&enableCloudDirector, "enable-cloud-director", false, "Enable the Cloud Director provider.")
 	flag.BoolVar(&enableProxmox, "enable-proxmox", false, "Enable the Proxmox provider.")
 	flag.BoolVar(&enableVsphere, "enable-vsphere", false, "Enable the vSphere provider.")
@@ -121,17 +238,43 @@ func main() {
 		"The file containing the credentials for vSphere resources.")
 	flag.StringVar(&vsphereLocations, "vsphere-locations", "/home/.vsphere/locations",
 		"The file containing the locations for vSphere resources")
+	flag.StringVar(&vsphereLocationsConfigMap, "vsphere-locations-configmap", "",
+		"The ConfigMap the vSphere locations are loaded from instead of --vsphere-locations, reloaded when it changes.")
+	flag.StringVar(&vsphereCACertFile, "vsphere-ca-cert-file", "",
+		"A PEM bundle of the CAs the vCenter certificate is verified against instead of the system's CAs.")
 	flag.BoolVar(&vspherePullFromURL, "vsphere-pull-from-url", false,
 		"Use pull mode for vSphere images. This will pull the image from the URL instead of uploading to vSphere.")
+	flag.BoolVar(&vsphereVerifyChecksum, "vsphere-verify-checksum", false,
+		"Verify vSphere images against the checksums in the OVA manifest while uploading. Disables pull mode.")
+	flag.IntVar(&vspherePullRetries, "vsphere-pull-retries", 2,
+		"How often a failed vSphere pull task is retried with a new lease and exponential backoff in pull mode. Disabled if 0.")
+	flag.IntVar(&vsphereMaxConcurrentImports, "vsphere-max-concurrent-imports", 2,
+		"The maximum number of image imports running against the vCenter at the same time.")
+	flag.StringVar(&vsphereTagCategory, "vsphere-tag-category", "",
+		"The vSphere tag category of the tag attached to imported templates, created if missing. Disabled if empty.")
 
 	flag.StringVar(&vcdCredentials, "vcd-credentials", "/home/.vcd/credentials",
 		"The file containing the credentials for VMware Cloud Director resources.")
 	flag.StringVar(&vcdLocations, "vcd-locations", "/home/.vcd/locations",
 		"The file containing the locations for VMware Cloud Director resources.")
+	flag.StringVar(&vcdLocationsConfigMap, "vcd-locations-configmap", "",
+		"The ConfigMap the VMware Cloud Director location is loaded from instead of --vcd-locations, reloaded when it changes.")
+	flag.StringVar(&locationsConfigMapNamespace, "locations-configmap-namespace", "",
+		"The namespace of the locations ConfigMaps. Defaults to --namespace.")
 	flag.StringVar(&vcdDownloadDir, "vcd-download-dir", "/tmp/images",
 		"The directory where VCD images are downloaded.")
 	flag.DurationVar(&vcdSessionRefreshThreshold, "vcd-session-refresh-threshold", 20*time.Hour,
 		"The age at which the Cloud Director session is proactively refreshed. Should be kept below VCD's session lifetime.")
+	flag.DurationVar(&vcdDownloadTimeout, "vcd-download-timeout", 30*time.Minute,
+		"The maximum duration of downloading an image before uploading it to Cloud Director.")
+	flag.DurationVar(&vcdDownloadStallTimeout, "vcd-download-stall-timeout", 2*time.Minute,
+		"Abort an image download for Cloud Director that receives no data for this duration.")
+	flag.Int64Var(&vcdUploadPieceSizeMB, "vcd-upload-piece-size-mb", 10,
+		"The size in MB of the chunks images are uploaded to Cloud Director in, between 1 and 1024. "+
+			"Larger chunks upload faster over high-latency links but use more memory.")
+	flag.StringVar(&vcdOverwritePolicy, "vcd-overwrite-policy", "error",
+		"What to do when an image is uploaded to Cloud Director under the name of a vApp template with different content: "+
+			"error fails the upload, skip keeps the existing template and replace deletes it and uploads the image.")
 
 	flag.StringVar(&proxmoxCredentials, "proxmox-credentials", "/home/.proxmox/credentials",
 		"The file containing the credentials for Proxmox resources.")
@@ -140,6 +283,69 @@ func main() {
 
 	flag.DurationVar(&imageRetentionPeriod, "image-retention-period", 0,
 		"The duration for which unused images are retained before deletion.")
+	flag.Int64Var(&maxImageSizeBytes, "max-image-size-bytes", 0,
+		"The size in bytes vSphere and Cloud Director images may have, larger ones fail before they are imported. "+
+			"Pushed images are checked by the size of the OVA, pulled ones by the capacity of their disks. Unlimited if 0.")
+	flag.Int64Var(&maxTransferBytesPerSecond, "max-transfer-bytes-per-second", 0,
+		"The bandwidth in bytes per second the images pulled from S3 or GCS, downloaded and pushed to Cloud Director "+
+			"may use together, per operator replica. Unlimited if 0.")
+	flag.IntVar(&maxUnusedImagesPerProvider, "max-unused-images-per-provider", 0,
+		"The number of unused images retained per provider, the least recently used ones beyond it are deleted "+
+			"before their retention period expired. Requires --image-retention-period. Disabled if 0.")
+	flag.IntVar(&locationConcurrency, "location-concurrency", imagecontroller.DefaultLocationConcurrency,
+		"The number of provider locations a single node image is created in or deleted from in parallel.")
+	flag.DurationVar(&orphanedImageCollectionInterval, "orphaned-image-collection-interval", 0,
+		"How often images without a NodeImage are deleted from providers that can list their images (currently vSphere). "+
+			"Disabled if 0.")
+	flag.DurationVar(&resyncInterval, "resync-interval", 0,
+		"How often all NodeImages are reconciled, e.g. to notice images deleted from a provider out-of-band, "+
+			"independent of the requeue of each NodeImage. Disabled if 0.")
+	flag.DurationVar(&imageReadinessTimeout, "image-readiness-timeout", 10*time.Minute,
+		"How long to wait after an upload for the image to become ready in the provider before marking it as Error. "+
+			"Disabled if 0.")
+	flag.DurationVar(&uploadVerificationDelay, "upload-verification-delay", 30*time.Second,
+		"How long after an upload a node image is checked again to verify the image is present in every location. "+
+			"Disabled if 0.")
+	flag.DurationVar(&providerProbeInterval, "provider-probe-interval", imagecontroller.DefaultProviderProbeInterval,
+		"How often a provider unreachable in all of its locations is tried again. Reconciles of its node images are "+
+			"paused in between.")
+	flag.DurationVar(&failureBackoff, "failure-backoff", imagecontroller.DefaultFailureBackoff,
+		"How long to wait before retrying a failed upload. Doubled with every consecutive failure.")
+	flag.DurationVar(&maxFailureBackoff, "max-failure-backoff", imagecontroller.DefaultMaxFailureBackoff,
+		"The longest wait before retrying a failed upload.")
+	flag.DurationVar(&existsCacheTTL, "exists-cache-ttl", imagecontroller.DefaultExistsCacheTTL,
+		"How long an image found in a provider location is trusted to still be there before the provider is asked "+
+			"again. Disabled if 0.")
+	flag.DurationVar(&requeueInterval, "requeue-interval", imagecontroller.DefaultRequeueInterval,
+		"How often a node image is reconciled again to check its image in the providers.")
+	flag.Float64Var(&requeueJitter, "requeue-jitter", imagecontroller.DefaultRequeueJitter,
+		"The fraction of the requeue interval the periodic reconciles are randomly spread by in either direction, "+
+			"between 0 and 1. Disabled if 0.")
+	flag.DurationVar(&shutdownGracePeriod, "shutdown-grace-period", 0,
+		"How long uploads in flight when the operator shuts down may continue before they are aborted. Aborted "+
+			"uploads are replaced by the next reconcile. Uploads are aborted right away if 0.")
+	flag.DurationVar(&operationTimeout, "operation-timeout", 0,
+		"How long a single import into or deletion from a provider location may take before its provider task is "+
+			"aborted and the NodeImage is marked as Error and retried. Unbounded if 0.")
+
+	flag.StringVar(&distributionWindow, "distribution-window", "",
+		"Comma separated daily time ranges in UTC during which images are uploaded, e.g. 22:00-06:00. "+
+			"Uploads are allowed at any time if empty.")
+
+	flag.StringVar(&imageNameTemplate, "image-name-template", image.DefaultNameTemplate,
+		"The Go text/template used to name Flatcar images. Available fields are "+
+			"{{.Channel}}, {{.FlatcarVersion}}, {{.KubernetesVersion}} and {{.ToolingVersion}}.")
+	flag.StringVar(&imageKeyTemplate, "image-key-template", image.DefaultKeyTemplate,
+		"The Go text/template of the S3 key images are imported from. Available fields are "+
+			"{{.Provider}}, {{.Name}} and {{.File}}.")
+	flag.StringVar(&imageNameCollisionPolicy, "image-name-collision-policy", string(image.NameCollisionHash),
+		"How node images are named whose <provider>-<image> object name is ambiguous or invalid: "+
+			"\"hash\" appends a hash of the provider and image name, \"reject\" fails the release.")
+
+	flag.StringVar(&notificationWebhookURLFile, "notification-webhook-url-file", "",
+		"The file containing the webhook URL node image state transitions are posted to. Notifications are disabled if empty.")
+	flag.BoolVar(&notifyOnAvailable, "notify-on-available", false,
+		"Also send a notification when a node image becomes available, not only when it fails.")
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -149,6 +355,8 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", true,
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the defaulting and validating webhooks for NodeImages. Requires a webhook certificate, see --webhook-cert-path.")
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook certificate.")
 	flag.StringVar(&webhookCertName, "webhook-cert-name", "tls.crt", "The name of the webhook certificate file.")
 	flag.StringVar(&webhookCertKey, "webhook-cert-key", "tls.key", "The name of the webhook key file.")
@@ -158,13 +366,27 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&logFormat, "log-format", "",
+		"The format of the logs, \"json\" or \"console\". If empty, the format follows --zap-devel and --zap-encoder.")
 	opts := zap.Options{
 		Development: true,
 	}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	logOpts := []zap.Opts{zap.UseFlagOptions(&opts)}
+	switch logFormat {
+	case "":
+	case "json":
+		logOpts = append(logOpts, zap.JSONEncoder())
+	case "console":
+		logOpts = append(logOpts, zap.ConsoleEncoder())
+	default:
+		ctrl.SetLogger(zap.New(logOpts...))
+		setupLog.Error(fmt.Errorf("log format %q is neither json nor console", logFormat), "invalid log format")
+		os.Exit(1)
+	}
+	ctrl.SetLogger(zap.New(logOpts...))
 
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
@@ -255,13 +477,35 @@ func main() {
 		})
 	}
 
+	// the manager waits for the controllers to stop, which includes the uploads
+	// running until the end of the shutdown grace period, before it gives up
+	var gracefulShutdownTimeout *time.Duration
+	if shutdownGracePeriod > 0 {
+		timeout := shutdownGracePeriod + defaultGracefulShutdownTimeout
+		gracefulShutdownTimeout = &timeout
+	}
+
+	// only the locations ConfigMaps are watched, so the cache is restricted
+	// to their namespace
+	if locationsConfigMapNamespace == "" {
+		locationsConfigMapNamespace = namespace
+	}
+	var cacheOptions cache.Options
+	if vsphereLocationsConfigMap != "" || vcdLocationsConfigMap != "" {
+		cacheOptions.ByObject = map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Namespaces: map[string]cache.Config{locationsConfigMapNamespace: {}}},
+		}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		Metrics:                metricsServerOptions,
-		WebhookServer:          webhookServer,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "fcf824fa.giantswarm.io",
+		Scheme:                  scheme,
+		Cache:                   cacheOptions,
+		Metrics:                 metricsServerOptions,
+		WebhookServer:           webhookServer,
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        "fcf824fa.giantswarm.io",
+		GracefulShutdownTimeout: gracefulShutdownTimeout,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -279,43 +523,189 @@ func main() {
 		os.Exit(1)
 	}
 
-	s3Client, err := s3.New(s3.Config{
-		BucketName: s3Bucket,
-		Region:     s3Region,
-		Timeout:    time.Duration(s3TimeoutSeconds) * time.Second,
-		HTTP:       s3HTTP,
-	}, context.Background())
+	var hostPatterns []string
+	for pattern := range strings.SplitSeq(allowedImageHostPatterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			hostPatterns = append(hostPatterns, pattern)
+		}
+	}
+	var allowedBuckets []string
+	for bucket := range strings.SplitSeq(s3AllowedBuckets, ",") {
+		if bucket = strings.TrimSpace(bucket); bucket != "" {
+			allowedBuckets = append(allowedBuckets, bucket)
+		}
+	}
+	downloadProxy := download.Proxy{URL: downloadProxyURL}
+	for host := range strings.SplitSeq(downloadNoProxy, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			downloadProxy.NoProxy = append(downloadProxy.NoProxy, host)
+		}
+	}
+	if err := downloadProxy.Validate(); err != nil {
+		setupLog.Error(err, "invalid download proxy")
+		os.Exit(1)
+	}
+
+	transferLimiter := download.NewLimiter(maxTransferBytesPerSecond)
+
+	storeKind, err := storage.ParseKind(imageStore)
 	if err != nil {
-		setupLog.Error(err, "unable to create S3 client")
+		setupLog.Error(err, "unable to parse image store")
 		os.Exit(1)
 	}
 
+	var objectStore storage.ObjectStore
+	switch storeKind {
+	case storage.GCS:
+		objectStore, err = gcs.New(gcs.Config{
+			BucketName:          gcsBucket,
+			CredentialsFile:     gcsCredentialsFile,
+			Timeout:             time.Duration(s3TimeoutSeconds) * time.Second,
+			Directory:           s3DownloadDir,
+			FallbackDirectory:   downloadFallbackDir,
+			AllowedHostPatterns: hostPatterns,
+			Proxy:               downloadProxy,
+			Limiter:             transferLimiter,
+		}, context.Background())
+		if err != nil {
+			setupLog.Error(err, "unable to create GCS client")
+			os.Exit(1)
+		}
+	default:
+		s3Client, err := s3.New(s3.Config{
+			BucketName:          s3Bucket,
+			Region:              s3Region,
+			Timeout:             time.Duration(s3TimeoutSeconds) * time.Second,
+			HTTP:                s3HTTP,
+			Directory:           s3DownloadDir,
+			FallbackDirectory:   downloadFallbackDir,
+			AllowedHostPatterns: hostPatterns,
+			Proxy:               downloadProxy,
+			Limiter:             transferLimiter,
+		}, context.Background())
+		if err != nil {
+			setupLog.Error(err, "unable to create S3 client")
+			os.Exit(1)
+		}
+
+		regionMismatchPolicy, err := s3.ParseRegionMismatchPolicy(s3RegionMismatchPolicy)
+		if err != nil {
+			setupLog.Error(err, "unable to parse S3 region mismatch policy")
+			os.Exit(1)
+		}
+		if err := s3Client.CheckRegion(context.Background()); err != nil {
+			switch {
+			case errors.Is(err, s3.ErrRegionMismatch) && regionMismatchPolicy == s3.RegionMismatchFail:
+				setupLog.Error(err, "S3 region is inconsistent")
+				os.Exit(1)
+			case errors.Is(err, s3.ErrRegionMismatch):
+				setupLog.Info("S3 region is inconsistent, image downloads may fail", "error", err.Error())
+			default:
+				setupLog.Info("Unable to verify the S3 region", "error", err.Error())
+			}
+		}
+		objectStore = s3Client
+	}
+
+	downloadDirs := []string{s3DownloadDir}
+	if enableCloudDirector && vcdDownloadDir != s3DownloadDir {
+		downloadDirs = append(downloadDirs, vcdDownloadDir)
+	}
+
+	// Fail fast on a read-only or inaccessible download directory instead of
+	// deep inside the first import
+	for _, dir := range downloadDirs {
+		usedDir, err := download.Dir(ctrl.LoggerInto(context.Background(), setupLog), dir, downloadFallbackDir)
+		if err != nil {
+			setupLog.Error(err, "download directory is not usable, check the volume mount and its permissions", "directory", dir)
+			os.Exit(1)
+		}
+		if usedDir != dir {
+			setupLog.Info("Images are downloaded to the fallback directory", "directory", dir, "fallback", usedDir)
+		}
+	}
+
+	// Remove downloads left behind by a previous process killed mid-import
+	if staleDownloadMaxAge > 0 {
+		if downloadFallbackDir != "" && !slices.Contains(downloadDirs, downloadFallbackDir) {
+			downloadDirs = append(downloadDirs, downloadFallbackDir)
+		}
+		for _, dir := range downloadDirs {
+			removed, err := cleanup.RemoveStaleImages(ctrl.LoggerInto(context.Background(), setupLog), dir, staleDownloadMaxAge)
+			if err != nil {
+				setupLog.Error(err, "unable to remove stale downloads", "directory", dir)
+			}
+			if removed > 0 {
+				setupLog.Info("Removed stale downloads", "directory", dir, "count", removed)
+			}
+		}
+	}
+
 	backoff := wait.Backoff{
 		Duration: clientSetupRetryDuration,
 		Factor:   2.0,
 		Steps:    clientSetupRetrySteps,
 	}
 
+	if dryRun {
+		setupLog.Info("Dry run enabled - providers will not upload or delete images")
+	}
+
 	// Initialize provider registry - providers that fail to initialize are logged but don't stop startup
 	providers := make(map[string]provider.Provider)
+	locationsConfigMaps := make(map[string]provider.LocationReloader)
+
+	// locations loaded from ConfigMaps are read before the manager's cache
+	// is started
+	var vsphereLocationsData, vcdLocationsData []byte
+	if enableVsphere && vsphereLocationsConfigMap != "" {
+		vsphereLocationsData, err = locations.Load(context.Background(), mgr.GetAPIReader(),
+			client.ObjectKey{Namespace: locationsConfigMapNamespace, Name: vsphereLocationsConfigMap})
+		if err != nil {
+			setupLog.Error(err, "unable to load vSphere locations")
+			os.Exit(1)
+		}
+	}
+	if enableCloudDirector && vcdLocationsConfigMap != "" {
+		vcdLocationsData, err = locations.Load(context.Background(), mgr.GetAPIReader(),
+			client.ObjectKey{Namespace: locationsConfigMapNamespace, Name: vcdLocationsConfigMap})
+		if err != nil {
+			setupLog.Error(err, "unable to load Cloud Director locations")
+			os.Exit(1)
+		}
+	}
 
 	if enableVsphere {
 		setupLog.Info("Initializing vSphere provider")
 
 		// Try to initialize vSphere provider
 		vsphereClient, err := vsphere.New(vsphere.Config{
-			CredentialsFile: vsphereCredentials,
-			LocationsFile:   vsphereLocations,
-			PullMode:        vspherePullFromURL,
-			Backoff:         backoff,
+			CredentialsFile:      vsphereCredentials,
+			LocationsFile:        vsphereLocations,
+			Locations:            vsphereLocationsData,
+			CACertFile:           vsphereCACertFile,
+			PullMode:             vspherePullFromURL,
+			VerifyChecksum:       vsphereVerifyChecksum,
+			PullRetries:          vspherePullRetries,
+			MaxConcurrentImports: vsphereMaxConcurrentImports,
+			TagCategory:          vsphereTagCategory,
+			MaxImageSizeBytes:    maxImageSizeBytes,
+			DryRun:               dryRun,
+			Backoff:              backoff,
 		}, context.Background())
 		if err != nil {
 			setupLog.Info("vSphere provider not successfully initialized", "error", err)
 			// Exit with an error if the provider wasn't successfully initialized
 			os.Exit(1)
 		} else {
-			providers["capv"] = vsphereClient
-			setupLog.Info("vSphere provider initialized successfully", "provider", "capv")
+			providers[provider.VSphere] = vsphereClient
+			if vsphereLocationsConfigMap != "" {
+				locationsConfigMaps[vsphereLocationsConfigMap] = vsphereClient
+			}
+			setupLog.Info("vSphere provider initialized successfully", "provider", provider.VSphere)
+		}
+		if downloadProxyURL != "" && vspherePullFromURL && !vsphereVerifyChecksum {
+			setupLog.Info("vSphere images are pulled by the ESXi hosts, which don't use the download proxy - they must reach the image URLs themselves")
 		}
 	}
 
@@ -326,9 +716,19 @@ func main() {
 		vcdClient, err := clouddirector.New(clouddirector.Config{
 			CredentialsFile:         vcdCredentials,
 			LocationsFile:           vcdLocations,
+			Locations:               vcdLocationsData,
 			DownloadDir:             vcdDownloadDir,
+			DownloadFallbackDir:     downloadFallbackDir,
 			SessionRefreshThreshold: vcdSessionRefreshThreshold,
+			DownloadTimeout:         vcdDownloadTimeout,
+			DownloadStallTimeout:    vcdDownloadStallTimeout,
+			UploadPieceSize:         vcdUploadPieceSizeMB << 20,
+			OverwritePolicy:         vcdOverwritePolicy,
+			MaxImageSizeBytes:       maxImageSizeBytes,
+			Limiter:                 transferLimiter,
+			DryRun:                  dryRun,
 			Backoff:                 backoff,
+			Proxy:                   downloadProxy,
 		}, context.Background())
 		if err != nil {
 			setupLog.Info(
@@ -338,8 +738,11 @@ func main() {
 			// Exit with an error if the provider wasn't successfully initialized
 			os.Exit(1)
 		} else {
-			providers["capvcd"] = vcdClient
-			setupLog.Info("Cloud Director provider initialized successfully", "provider", "capvcd")
+			providers[provider.CloudDirector] = vcdClient
+			if vcdLocationsConfigMap != "" {
+				locationsConfigMaps[vcdLocationsConfigMap] = vcdClient
+			}
+			setupLog.Info("Cloud Director provider initialized successfully", "provider", provider.CloudDirector)
 		}
 	}
 
@@ -350,6 +753,7 @@ func main() {
 		proxmoxClient, err := proxmox.New(proxmox.Config{
 			CredentialsFile: proxmoxCredentials,
 			LocationsFile:   proxmoxLocations,
+			DryRun:          dryRun,
 			Backoff:         backoff,
 		}, context.Background())
 		if err != nil {
@@ -360,8 +764,11 @@ func main() {
 			// Exit with an error if the provider wasn't successfully initialized
 			os.Exit(1)
 		} else {
-			providers["capmox"] = proxmoxClient
-			setupLog.Info("Proxmox provider initialized successfully", "provider", "capmox")
+			providers[provider.Proxmox] = proxmoxClient
+			setupLog.Info("Proxmox provider initialized successfully", "provider", provider.Proxmox)
+		}
+		if downloadProxyURL != "" {
+			setupLog.Info("Proxmox images are downloaded by the Proxmox nodes, which don't use the download proxy - they must reach the image URLs themselves")
 		}
 	}
 
@@ -372,23 +779,156 @@ func main() {
 	}
 
 	if err = (&release.ReleaseReconciler{
-		Namespace:            namespace,
-		Client:               mgr.GetClient(),
-		Providers:            configuredProviders,
-		ImageRetentionPeriod: imageRetentionPeriod,
+		Namespace:                namespace,
+		Client:                   mgr.GetClient(),
+		Providers:                configuredProviders,
+		ImageRetentionPeriod:     imageRetentionPeriod,
+		ImageNameTemplate:        imageNameTemplate,
+		ImageNameCollisionPolicy: imageNameCollisionPolicy,
+		Recorder:                 mgr.GetEventRecorder("release-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Release")
 		os.Exit(1)
 	}
-	if err = (&imagecontroller.NodeImageReconciler{
-		S3Client:             s3Client,
-		Providers:            providers,
-		Client:               mgr.GetClient(),
-		ImageRetentionPeriod: imageRetentionPeriod,
-	}).SetupWithManager(mgr); err != nil {
+	if len(locationsConfigMaps) > 0 {
+		if err = (&locations.LocationsReconciler{
+			Client:     mgr.GetClient(),
+			Namespace:  locationsConfigMapNamespace,
+			ConfigMaps: locationsConfigMaps,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Locations")
+			os.Exit(1)
+		}
+		setupLog.Info("Locations are reloaded from ConfigMaps", "namespace", locationsConfigMapNamespace)
+	}
+	if _, err := image.ParseNameTemplate(imageNameTemplate); err != nil {
+		setupLog.Error(err, "unable to parse image name template")
+		os.Exit(1)
+	}
+	if _, err := image.ParseNameCollisionPolicy(imageNameCollisionPolicy); err != nil {
+		setupLog.Error(err, "unable to parse image name collision policy")
+		os.Exit(1)
+	}
+
+	if requeueJitter < 0 || requeueJitter >= 1 {
+		setupLog.Error(fmt.Errorf("requeue jitter %v is not between 0 and 1", requeueJitter), "invalid requeue jitter")
+		os.Exit(1)
+	}
+
+	if maxUnusedImagesPerProvider > 0 && imageRetentionPeriod <= 0 {
+		setupLog.Error(fmt.Errorf("--max-unused-images-per-provider requires --image-retention-period"),
+			"invalid image retention")
+		os.Exit(1)
+	}
+
+	uploadWindow, err := window.Parse(distributionWindow)
+	if err != nil {
+		setupLog.Error(err, "unable to parse distribution window")
+		os.Exit(1)
+	}
+
+	var notifier notify.Notifier
+	if notificationWebhookURLFile != "" {
+		webhookURL, err := os.ReadFile(notificationWebhookURLFile) // #nosec G304
+		if err != nil {
+			setupLog.Error(err, "unable to read notification webhook URL")
+			os.Exit(1)
+		}
+		webhook, err := notify.NewWebhook(notify.WebhookConfig{
+			URL: strings.TrimSpace(string(webhookURL)),
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create webhook notifier")
+			os.Exit(1)
+		}
+		// notifications are sent in the background, a slow webhook must not
+		// hold up the reconciles
+		queue := notify.NewQueue(webhook, notify.DefaultQueueSize)
+		if err := mgr.Add(queue); err != nil {
+			setupLog.Error(err, "unable to add notification queue to manager")
+			os.Exit(1)
+		}
+		notifier = queue
+		setupLog.Info("Webhook notifications enabled", "notifyOnAvailable", notifyOnAvailable)
+	}
+
+	parsedImageKeyTemplate, err := image.ParseKeyTemplate(imageKeyTemplate)
+	if err != nil {
+		setupLog.Error(err, "unable to parse image key template")
+		os.Exit(1)
+	}
+
+	nodeImageReconciler := &imagecontroller.NodeImageReconciler{
+		ObjectStore:           objectStore,
+		ImageKeyTemplate:      parsedImageKeyTemplate,
+		VerifyS3Object:        s3VerifyObject,
+		AllowedBuckets:        allowedBuckets,
+		Providers:             providers,
+		Client:                mgr.GetClient(),
+		ImageRetentionPeriod:  imageRetentionPeriod,
+		LocationConcurrency:   locationConcurrency,
+		Notifier:              notifier,
+		NotifyOnAvailable:     notifyOnAvailable,
+		DistributionWindow:    uploadWindow,
+		TruncateLongNames:     truncateLongImageNames,
+		DisableFinalizer:      disableFinalizer,
+		VerificationDelay:     uploadVerificationDelay,
+		DryRun:                dryRun,
+		ReadinessTimeout:      imageReadinessTimeout,
+		ProviderProbeInterval: providerProbeInterval,
+		FailureBackoff:        failureBackoff,
+		MaxFailureBackoff:     maxFailureBackoff,
+		ExistsCacheTTL:        existsCacheTTL,
+		RequeueInterval:       requeueInterval,
+		RequeueJitter:         requeueJitter,
+		ShutdownGracePeriod:   shutdownGracePeriod,
+		OperationTimeout:      operationTimeout,
+		ImageChecker: &httpcheck.Checker{
+			Client:  &http.Client{Timeout: 30 * time.Second, Transport: downloadProxy.Transport()},
+			Backoff: imagecontroller.DefaultImageCheckBackoff,
+		},
+	}
+	if err = nodeImageReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NodeImage")
 		os.Exit(1)
 	}
+	if orphanedImageCollectionInterval > 0 {
+		if err := mgr.Add(&imagecontroller.OrphanCollector{
+			Reconciler: nodeImageReconciler,
+			Interval:   orphanedImageCollectionInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to add orphaned image collector")
+			os.Exit(1)
+		}
+		setupLog.Info("Orphaned image collection enabled", "interval", orphanedImageCollectionInterval)
+	}
+	if resyncInterval > 0 {
+		if err := mgr.Add(&imagecontroller.Resyncer{
+			Reconciler: nodeImageReconciler,
+			Interval:   resyncInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to add resyncer")
+			os.Exit(1)
+		}
+		setupLog.Info("Periodic resync enabled", "interval", resyncInterval)
+	}
+	if maxUnusedImagesPerProvider > 0 {
+		if err := mgr.Add(&imagecontroller.RetentionCollector{
+			Reconciler:      nodeImageReconciler,
+			Interval:        imagecontroller.DefaultRetentionInterval,
+			MaxUnusedImages: maxUnusedImagesPerProvider,
+		}); err != nil {
+			setupLog.Error(err, "unable to add retention collector")
+			os.Exit(1)
+		}
+		setupLog.Info("Unused image limit enabled", "maxUnusedImagesPerProvider", maxUnusedImagesPerProvider)
+	}
+	if enableWebhooks {
+		if err = webhookimagev1alpha1.SetupNodeImageWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NodeImage")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	clouddirector "github.com/giantswarm/image-distribution-operator/pkg/cloud-director"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+	"github.com/giantswarm/image-distribution-operator/pkg/vsphere"
+)
+
+// seedCommand is the subcommand uploading a single image out-of-band of any
+// Release, e.g. to test a new location before using it in production
+const seedCommand = "seed"
+
+// runSeed runs the seed subcommand with the arguments following it. It
+// builds the provider client from the same configuration files as the
+// operator and uploads the image to the location, without the controllers.
+func runSeed(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet(seedCommand, flag.ContinueOnError)
+
+	var providerName, loc, name, imageURL string
+	fs.StringVar(&providerName, "provider", "", fmt.Sprintf("The provider to seed the image into, %s or %s.",
+		provider.VSphere, provider.CloudDirector))
+	fs.StringVar(&loc, "location", "", "The provider location to seed the image into.")
+	fs.StringVar(&name, "name", "", "The name of the image in the provider.")
+	fs.StringVar(&imageURL, "url", "", "The URL of the OVA to seed, e.g. in the S3 bucket.")
+
+	var vsphereCredentials, vsphereLocations, vsphereCACertFile string
+	var vspherePullFromURL bool
+	fs.StringVar(&vsphereCredentials, "vsphere-credentials", "/home/.vsphere/credentials",
+		"The file containing the credentials for vSphere resources.")
+	fs.StringVar(&vsphereLocations, "vsphere-locations", "/home/.vsphere/locations",
+		"The file containing the locations for vSphere resources")
+	fs.StringVar(&vsphereCACertFile, "vsphere-ca-cert-file", "",
+		"A PEM bundle of the CAs the vCenter certificate is verified against instead of the system's CAs.")
+	fs.BoolVar(&vspherePullFromURL, "vsphere-pull-from-url", false,
+		"Use pull mode for vSphere images. This will pull the image from the URL instead of uploading to vSphere.")
+
+	var vcdCredentials, vcdLocations, vcdDownloadDir string
+	fs.StringVar(&vcdCredentials, "vcd-credentials", "/home/.vcd/credentials",
+		"The file containing the credentials for VMware Cloud Director resources.")
+	fs.StringVar(&vcdLocations, "vcd-locations", "/home/.vcd/locations",
+		"The file containing the locations for VMware Cloud Director resources.")
+	fs.StringVar(&vcdDownloadDir, "vcd-download-dir", "/tmp/images",
+		"The directory where VCD images are downloaded.")
+
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	for _, required := range []string{"provider", "location", "name", "url"} {
+		if fs.Lookup(required).Value.String() == "" {
+			return fmt.Errorf("--%s is required", required)
+		}
+	}
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = log.IntoContext(ctx, ctrl.Log.WithName(seedCommand))
+
+	backoff := wait.Backoff{
+		Duration: 5 * time.Second,
+		Factor:   2.0,
+		Steps:    5,
+	}
+
+	var prov provider.Provider
+	var err error
+	switch providerName {
+	case provider.VSphere:
+		prov, err = vsphere.New(vsphere.Config{
+			CredentialsFile:      vsphereCredentials,
+			LocationsFile:        vsphereLocations,
+			CACertFile:           vsphereCACertFile,
+			PullMode:             vspherePullFromURL,
+			MaxConcurrentImports: 1,
+			Backoff:              backoff,
+		}, ctx)
+	case provider.CloudDirector:
+		prov, err = clouddirector.New(clouddirector.Config{
+			CredentialsFile: vcdCredentials,
+			LocationsFile:   vcdLocations,
+			DownloadDir:     vcdDownloadDir,
+			Backoff:         backoff,
+		}, ctx)
+	default:
+		return fmt.Errorf("unsupported provider %q, must be %s or %s", providerName, provider.VSphere, provider.CloudDirector)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create %s client: %w", providerName, err)
+	}
+
+	return seed(ctx, prov, loc, name, imageURL, out)
+}
+
+// seed uploads the image to the location unless it already exists there,
+// printing the progress to out
+func seed(ctx context.Context, prov provider.Provider, loc string, name string, imageURL string, out io.Writer) error {
+	if _, ok := prov.GetLocations()[loc]; !ok {
+		locations := make([]string, 0, len(prov.GetLocations()))
+		for l := range prov.GetLocations() {
+			locations = append(locations, l)
+		}
+		slices.Sort(locations)
+		return fmt.Errorf("unknown location %s, configured locations: %v", loc, locations)
+	}
+
+	if limiter, ok := prov.(provider.NameLimiter); ok {
+		if _, err := image.FitName(name, limiter.MaxNameLength(loc), false); err != nil {
+			return fmt.Errorf("invalid image name for location %s: %w", loc, err)
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "Checking if image %s exists in location %s\n", name, loc)
+	exists, err := prov.Exists(ctx, name, loc)
+	if err != nil {
+		return fmt.Errorf("failed to check if image exists: %w", err)
+	}
+	if exists {
+		_, _ = fmt.Fprintf(out, "Image %s already exists in location %s, nothing to do\n", name, loc)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(out, "Uploading image %s from %s to location %s\n", name, imageURL, loc)
+	progressCtx := provider.WithProgress(ctx, func(transferred int64, total int64) {
+		if total > 0 {
+			_, _ = fmt.Fprintf(out, "Uploaded %d of %d bytes (%d%%)\n", transferred, total, transferred*100/total)
+			return
+		}
+		_, _ = fmt.Fprintf(out, "Uploaded %d bytes\n", transferred)
+	})
+	if err := prov.Create(progressCtx, imageURL, name, loc); err != nil {
+		return fmt.Errorf("failed to import image: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(out, "Processing image %s\n", name)
+	if err := prov.Process(ctx, name, loc); err != nil {
+		return fmt.Errorf("failed to process image: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(out, "Image %s seeded in location %s\n", name, loc)
+	return nil
+}
+
+// exitSeed runs the seed subcommand and exits with its result
+func exitSeed(args []string) {
+	err := runSeed(args, os.Stdout)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case err != nil:
+		_, _ = fmt.Fprintf(os.Stderr, "%s failed: %v\n", seedCommand, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// seedProvider is an in-memory provider with a single location
+type seedProvider struct {
+	images  map[string]bool
+	created []string
+}
+
+func (p *seedProvider) Exists(ctx context.Context, name string, loc string) (bool, error) {
+	return p.images[name], nil
+}
+
+func (p *seedProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	provider.ReportProgress(ctx, 50, 200)
+	p.images[imageName] = true
+	p.created = append(p.created, imageName)
+	return nil
+}
+
+func (p *seedProvider) Process(ctx context.Context, name string, loc string) error {
+	return nil
+}
+
+func (p *seedProvider) Delete(ctx context.Context, name string, loc string) error {
+	return nil
+}
+
+func (p *seedProvider) GetLocations() map[string]interface{} {
+	return map[string]interface{}{"dc1": struct{}{}}
+}
+
+func TestSeed(t *testing.T) {
+	testCases := []struct {
+		name            string
+		location        string
+		existing        bool
+		expectedCreated []string
+		expectedOutput  string
+		expectedError   string
+	}{
+		{
+			name:            "case 0: image is uploaded",
+			location:        "dc1",
+			expectedCreated: []string{"test-image"},
+			expectedOutput:  "Uploaded 50 of 200 bytes (25%)\n",
+		},
+		{
+			name:           "case 1: existing image is not uploaded again",
+			location:       "dc1",
+			existing:       true,
+			expectedOutput: "Image test-image already exists in location dc1, nothing to do\n",
+		},
+		{
+			name:          "case 2: unknown location",
+			location:      "dc2",
+			expectedError: "unknown location dc2, configured locations: [dc1]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prov := &seedProvider{images: map[string]bool{"test-image": tc.existing}}
+			out := &bytes.Buffer{}
+
+			err := seed(context.TODO(), prov, tc.location, "test-image", "https://example.com/image.ova", out)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCreated, prov.created)
+			assert.Contains(t, out.String(), tc.expectedOutput)
+		})
+	}
+}
+
+func TestRunSeedRequiredFlags(t *testing.T) {
+	err := runSeed([]string{"--provider", "capv", "--location", "dc1"}, &bytes.Buffer{})
+	require.EqualError(t, err, "--name is required")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	clouddirector "github.com/giantswarm/image-distribution-operator/pkg/cloud-director"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+	"github.com/giantswarm/image-distribution-operator/pkg/vsphere"
+)
+
+// validateCommand is the subcommand checking the configuration files of the
+// providers against their endpoints, e.g. as a pre-deploy check in CI
+const validateCommand = "validate-config"
+
+// runValidate runs the validate-config subcommand with the arguments
+// following it. It loads the same configuration files as the operator, logs
+// in to every enabled provider and prints a report of the checks to out.
+func runValidate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet(validateCommand, flag.ContinueOnError)
+
+	var enableVsphere, enableCloudDirector bool
+	fs.BoolVar(&enableVsphere, "enable-vsphere", false, "Validate the vSphere configuration.")
+	fs.BoolVar(&enableCloudDirector, "enable-cloud-director", false, "Validate the Cloud Director configuration.")
+
+	var vsphereCredentials, vsphereLocations, vsphereCACertFile string
+	fs.StringVar(&vsphereCredentials, "vsphere-credentials", "/home/.vsphere/credentials",
+		"The file containing the credentials for vSphere resources.")
+	fs.StringVar(&vsphereLocations, "vsphere-locations", "/home/.vsphere/locations",
+		"The file containing the locations for vSphere resources")
+	fs.StringVar(&vsphereCACertFile, "vsphere-ca-cert-file", "",
+		"A PEM bundle of the CAs the vCenter certificate is verified against instead of the system's CAs.")
+
+	var vcdCredentials, vcdLocations string
+	fs.StringVar(&vcdCredentials, "vcd-credentials", "/home/.vcd/credentials",
+		"The file containing the credentials for VMware Cloud Director resources.")
+	fs.StringVar(&vcdLocations, "vcd-locations", "/home/.vcd/locations",
+		"The file containing the locations for VMware Cloud Director resources.")
+
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !enableVsphere && !enableCloudDirector {
+		return errors.New("no provider to validate, set --enable-vsphere or --enable-cloud-director")
+	}
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = log.IntoContext(ctx, ctrl.Log.WithName(validateCommand))
+
+	failed := 0
+	if enableVsphere {
+		failed += report(out, provider.VSphere, vsphere.ValidateConfig(ctx, vsphere.Config{
+			CredentialsFile: vsphereCredentials,
+			LocationsFile:   vsphereLocations,
+			CACertFile:      vsphereCACertFile,
+		}))
+	}
+	if enableCloudDirector {
+		failed += report(out, provider.CloudDirector, clouddirector.ValidateConfig(ctx, clouddirector.Config{
+			CredentialsFile: vcdCredentials,
+			LocationsFile:   vcdLocations,
+		}))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	_, _ = fmt.Fprintln(out, "Configuration is valid")
+	return nil
+}
+
+// report prints the checks of a provider to out and returns the number of
+// failed ones
+func report(out io.Writer, providerName string, checks []provider.ConfigCheck) int {
+	failed := 0
+	_, _ = fmt.Fprintf(out, "%s:\n", providerName)
+	for _, check := range checks {
+		if check.Err != nil {
+			failed++
+			// indent the further lines of errors spanning several lines
+			_, _ = fmt.Fprintf(out, "  FAILED  %s: %s\n", check.Name, strings.ReplaceAll(check.Err.Error(), "\n", "\n          "))
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "  OK      %s\n", check.Name)
+	}
+	return failed
+}
+
+// exitValidate runs the validate-config subcommand and exits with its result
+func exitValidate(args []string) {
+	err := runValidate(args, os.Stdout)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case err != nil:
+		_, _ = fmt.Fprintf(os.Stderr, "%s failed: %v\n", validateCommand, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+func TestReport(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	failed := report(out, provider.VSphere, []provider.ConfigCheck{
+		{Name: "credentials file /etc/credentials"},
+		{Name: "location dc1", Err: errors.New("failed to find datastore missing")},
+	})
+
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, "capv:\n"+
+		"  OK      credentials file /etc/credentials\n"+
+		"  FAILED  location dc1: failed to find datastore missing\n", out.String())
+}
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	out := &bytes.Buffer{}
+
+	err := runValidate([]string{"--enable-cloud-director", "--vcd-credentials", dir + "/missing", "--vcd-locations", dir + "/missing"}, out)
+	require.EqualError(t, err, "2 checks failed")
+	assert.Contains(t, out.String(), "FAILED  credentials file "+dir+"/missing: failed to read credentials file")
+
+	err = runValidate(nil, out)
+	require.EqualError(t, err, "no provider to validate, set --enable-vsphere or --enable-cloud-director")
+}
@@ -0,0 +1,61 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+const (
+	// DefaultFailureBackoff is the requeue interval after the first failed
+	// upload when FailureBackoff is unset. It doubles with every further
+	// consecutive failure.
+	DefaultFailureBackoff = 30 * time.Second
+	// DefaultMaxFailureBackoff caps the requeue interval after failed uploads
+	// when MaxFailureBackoff is unset.
+	DefaultMaxFailureBackoff = 30 * time.Minute
+)
+
+// failureBackoff returns how long to wait before retrying after the given
+// number of consecutive failures: base after the first one, doubled after
+// every further one and capped at max.
+func failureBackoff(failures int32, base time.Duration, max time.Duration) time.Duration {
+	backoff := base
+	for i := int32(1); i < failures; i++ {
+		if backoff >= max/2 {
+			return max
+		}
+		backoff *= 2
+	}
+	return min(backoff, max)
+}
+
+// failureRequeue requeues a NodeImage after a failed upload, backing off
+// exponentially with its consecutive failures.
+func (r *NodeImageReconciler) failureRequeue(nodeImage *imagev1alpha1.NodeImage) ctrl.Result {
+	base := r.FailureBackoff
+	if base <= 0 {
+		base = DefaultFailureBackoff
+	}
+	max := r.MaxFailureBackoff
+	if max <= 0 {
+		max = DefaultMaxFailureBackoff
+	}
+	return ctrl.Result{RequeueAfter: failureBackoff(nodeImage.Status.ConsecutiveFailures, base, max)}
+}
+
+// recordFailure counts a failed upload in the status.
+func (r *NodeImageReconciler) recordFailure(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	nodeImage.Status.ConsecutiveFailures++
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
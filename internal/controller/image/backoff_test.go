@@ -0,0 +1,155 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+func TestFailureBackoff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		failures int32
+		base     time.Duration
+		max      time.Duration
+		expected time.Duration
+	}{
+		{
+			name:     "case 0: no failures yet",
+			failures: 0,
+			base:     30 * time.Second,
+			max:      30 * time.Minute,
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "case 1: first failure",
+			failures: 1,
+			base:     30 * time.Second,
+			max:      30 * time.Minute,
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "case 2: doubled with every failure",
+			failures: 4,
+			base:     30 * time.Second,
+			max:      30 * time.Minute,
+			expected: 4 * time.Minute,
+		},
+		{
+			name:     "case 3: capped at max",
+			failures: 8,
+			base:     30 * time.Second,
+			max:      30 * time.Minute,
+			expected: 30 * time.Minute,
+		},
+		{
+			name:     "case 4: many failures don't overflow",
+			failures: 1000,
+			base:     30 * time.Second,
+			max:      30 * time.Minute,
+			expected: 30 * time.Minute,
+		},
+		{
+			name:     "case 5: base above max",
+			failures: 1,
+			base:     time.Hour,
+			max:      30 * time.Minute,
+			expected: 30 * time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, failureBackoff(tc.failures, tc.base, tc.max))
+		})
+	}
+}
+
+func TestDistributeFailureBackoff(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := newFakeProvider("dc1")
+	prov.createErr["dc1"] = errors.New("datastore full")
+	r := &NodeImageReconciler{
+		Client:            newFakeClient(t, nodeImage),
+		FailureBackoff:    time.Minute,
+		MaxFailureBackoff: 5 * time.Minute,
+	}
+
+	// every consecutive failure doubles the requeue interval up to the max
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		result, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+		require.NoError(t, err)
+		assert.Equal(t, ctrl.Result{RequeueAfter: expected}, result)
+		assert.Equal(t, imagev1alpha1.NodeImageError, nodeImage.Status.State)
+	}
+	assert.Equal(t, int32(4), nodeImage.Status.ConsecutiveFailures)
+
+	// a successful upload resets the backoff
+	delete(prov.createErr, "dc1")
+	result, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRequeue(), result)
+	assert.Equal(t, imagev1alpha1.NodeImageAvailable, nodeImage.Status.State)
+	assert.Zero(t, nodeImage.Status.ConsecutiveFailures)
+}
+
+func TestDistributeLastReconcileTime(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := newFakeProvider("dc1")
+	c := newFakeClient(t, nodeImage)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	r := &NodeImageReconciler{
+		Client: c,
+		now:    func() time.Time { return now },
+	}
+
+	_, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(nodeImage.Status.LastReconcileTime.Time))
+
+	// the time is stamped again although the state stays Available
+	now = now.Add(5 * time.Minute)
+	_, err = r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, imagev1alpha1.NodeImageAvailable, nodeImage.Status.State)
+
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	assert.True(t, now.Equal(stored.Status.LastReconcileTime.Time))
+
+	// the reconcile triggered by the status update doesn't write it again
+	resourceVersion := stored.ResourceVersion
+	_, err = r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	assert.Equal(t, resourceVersion, stored.ResourceVersion)
+
+	// a failed reconcile leaves the time of the last successful one
+	prov.createErr["dc1"] = errors.New("datastore full")
+	prov.images = map[string]bool{}
+	_, err = r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, imagev1alpha1.NodeImageError, nodeImage.Status.State)
+	assert.True(t, now.Equal(nodeImage.Status.LastReconcileTime.Time))
+}
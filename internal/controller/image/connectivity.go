@@ -0,0 +1,156 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// DefaultProviderProbeInterval is how often an unreachable provider is probed
+// by a single reconcile when ProviderProbeInterval is unset.
+const DefaultProviderProbeInterval = time.Minute
+
+// isConnectivityError reports whether err was caused by the provider not
+// being reachable over the network, as opposed to the provider rejecting a
+// request.
+func isConnectivityError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) ||
+		errors.As(err, &dnsErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH)
+}
+
+// outage is a provider found unreachable in all of its locations
+type outage struct {
+	since time.Time
+	// probeAt is when the next reconcile may try the provider again
+	probeAt time.Time
+}
+
+// connectivityTracker is shared by all reconciles and remembers which
+// providers are unreachable, so a lost provider doesn't fail every NodeImage
+// on its own. Its zero value tracks no outages.
+type connectivityTracker struct {
+	mu      sync.Mutex
+	outages map[string]*outage
+}
+
+// allow reports whether a reconcile may use the provider. While the provider
+// is unreachable only one reconcile per interval is allowed, as a probe, and
+// the others are told how long to wait.
+func (t *connectivityTracker) allow(name string, now time.Time, interval time.Duration) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	o, ok := t.outages[name]
+	if !ok {
+		return 0, true
+	}
+	if !now.Before(o.probeAt) {
+		o.probeAt = now.Add(interval)
+		return 0, true
+	}
+	return o.probeAt.Sub(now), false
+}
+
+// lost records the provider as unreachable and reports whether it was
+// reachable before
+func (t *connectivityTracker) lost(name string, now time.Time, interval time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.outages == nil {
+		t.outages = make(map[string]*outage)
+	}
+	if o, ok := t.outages[name]; ok {
+		o.probeAt = now.Add(interval)
+		return false
+	}
+	t.outages[name] = &outage{since: now, probeAt: now.Add(interval)}
+	providerUnavailable.WithLabelValues(name).Set(1)
+	return true
+}
+
+// restored records the provider as reachable and reports whether it was
+// unreachable before
+func (t *connectivityTracker) restored(name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.outages[name]; !ok {
+		return false
+	}
+	delete(t.outages, name)
+	providerUnavailable.WithLabelValues(name).Set(0)
+	return true
+}
+
+// since returns when the provider became unreachable
+func (t *connectivityTracker) since(name string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if o, ok := t.outages[name]; ok {
+		return o.since
+	}
+	return time.Time{}
+}
+
+func (r *NodeImageReconciler) providerProbeInterval() time.Duration {
+	if r.ProviderProbeInterval > 0 {
+		return r.ProviderProbeInterval
+	}
+	return DefaultProviderProbeInterval
+}
+
+// providerUnavailable marks the NodeImage with the shared ProviderAvailable
+// condition of the outage and requeues it. The state is left unchanged, and
+// the condition only changes once per outage, so a lost provider doesn't
+// churn the status of every NodeImage.
+func (r *NodeImageReconciler) providerUnavailable(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, requeueAfter time.Duration) (ctrl.Result, error) {
+	since := r.connectivity.since(nodeImage.Spec.Provider)
+	if err := r.updateStatus(ctx, nodeImage, nodeImage.Status.State, metav1.Condition{
+		Type:    imagev1alpha1.NodeImageConditionProviderAvailable,
+		Status:  metav1.ConditionFalse,
+		Reason:  imagev1alpha1.NodeImageReasonProviderUnavailable,
+		Message: fmt.Sprintf("Provider %s is unreachable since %s", nodeImage.Spec.Provider, since.UTC().Format(time.RFC3339)),
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// providerReachable clears the ProviderAvailable condition left by an
+// outage. NodeImages never affected by an outage are not updated.
+func (r *NodeImageReconciler) providerReachable(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) error {
+	if r.connectivity.restored(nodeImage.Spec.Provider) {
+		log.FromContext(ctx).Info("Provider reachable again")
+	}
+	if !meta.IsStatusConditionFalse(nodeImage.Status.Conditions, imagev1alpha1.NodeImageConditionProviderAvailable) {
+		return nil
+	}
+	return r.updateStatus(ctx, nodeImage, nodeImage.Status.State, metav1.Condition{
+		Type:    imagev1alpha1.NodeImageConditionProviderAvailable,
+		Status:  metav1.ConditionTrue,
+		Reason:  imagev1alpha1.NodeImageReasonProviderReachable,
+		Message: fmt.Sprintf("Provider %s is reachable", nodeImage.Spec.Provider),
+	})
+}
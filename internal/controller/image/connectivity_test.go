@@ -0,0 +1,184 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+	"github.com/giantswarm/image-distribution-operator/pkg/s3"
+)
+
+// unreachableProvider fails Exists with a connectivity error in the
+// unreachable locations
+type unreachableProvider struct {
+	*fakeProvider
+	unreachableMu sync.Mutex
+	unreachable   map[string]bool
+	calls         int
+}
+
+func (p *unreachableProvider) Exists(ctx context.Context, name string, loc string) (bool, error) {
+	p.unreachableMu.Lock()
+	p.calls++
+	unreachable := p.unreachable[loc]
+	p.unreachableMu.Unlock()
+	if unreachable {
+		return false, fmt.Errorf("failed to find VM: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+	}
+	return p.fakeProvider.Exists(ctx, name, loc)
+}
+
+func (p *unreachableProvider) setUnreachable(locs ...string) {
+	p.unreachableMu.Lock()
+	defer p.unreachableMu.Unlock()
+	p.unreachable = make(map[string]bool)
+	for _, loc := range locs {
+		p.unreachable[loc] = true
+	}
+}
+
+func TestIsConnectivityError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name: "case 0: no error",
+		},
+		{
+			name:     "case 1: dial error",
+			err:      fmt.Errorf("failed to import image: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("i/o timeout")}),
+			expected: true,
+		},
+		{
+			name:     "case 2: DNS error",
+			err:      fmt.Errorf("failed to log in: %w", &net.DNSError{Err: "no such host", Name: "vcenter.example.com"}),
+			expected: true,
+		},
+		{
+			name:     "case 3: connection reset",
+			err:      fmt.Errorf("failed to upload: %w", syscall.ECONNRESET),
+			expected: true,
+		},
+		{
+			name: "case 4: provider error",
+			err:  errors.New("datastore not found"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isConnectivityError(tc.err))
+		})
+	}
+}
+
+func TestProviderUnavailable(t *testing.T) {
+	ctx := context.TODO()
+	interval := time.Minute
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	newNodeImage := func(name string) *imagev1alpha1.NodeImage {
+		return &imagev1alpha1.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace", Finalizers: []string{NodeImageFinalizer}},
+			Spec:       imagev1alpha1.NodeImageSpec{Name: name, Provider: provider.VSphere},
+			Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+		}
+	}
+	first := newNodeImage("first-image")
+	second := newNodeImage("second-image")
+
+	s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1"}, ctx)
+	require.NoError(t, err)
+
+	prov := &unreachableProvider{fakeProvider: newFakeProvider("dc1", "dc2")}
+	prov.setUnreachable("dc1", "dc2")
+	r := &NodeImageReconciler{
+		Client:                newFakeClient(t, first, second),
+		ObjectStore:           s3Client,
+		Providers:             map[string]provider.Provider{provider.VSphere: prov},
+		ProviderProbeInterval: interval,
+		now:                   func() time.Time { return now },
+	}
+
+	// the provider is lost in all locations, the image is not marked as Error
+	result, err := r.distribute(ctx, first, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: interval}, result)
+	assert.Equal(t, imagev1alpha1.NodeImagePending, first.Status.State)
+	assert.True(t, meta.IsStatusConditionFalse(first.Status.Conditions, imagev1alpha1.NodeImageConditionProviderAvailable))
+	assert.Equal(t, 1.0, testutil.ToFloat64(providerUnavailable.WithLabelValues(provider.VSphere)))
+
+	// other reconciles are short-circuited without calling the provider
+	calls := prov.calls
+	now = now.Add(interval / 2)
+	result, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: second.Name, Namespace: second.Namespace}})
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: interval / 2}, result)
+	assert.Equal(t, calls, prov.calls)
+
+	updated := &imagev1alpha1.NodeImage{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: second.Name, Namespace: second.Namespace}, updated))
+	assert.Equal(t, imagev1alpha1.NodeImagePending, updated.Status.State)
+	condition := meta.FindStatusCondition(updated.Status.Conditions, imagev1alpha1.NodeImageConditionProviderAvailable)
+	require.NotNil(t, condition)
+	assert.Equal(t, imagev1alpha1.NodeImageReasonProviderUnavailable, condition.Reason)
+
+	// a single reconcile probes the provider after the interval
+	now = now.Add(interval / 2)
+	_, ok := r.connectivity.allow(provider.VSphere, now, interval)
+	assert.True(t, ok)
+	_, ok = r.connectivity.allow(provider.VSphere, now, interval)
+	assert.False(t, ok)
+
+	// the provider recovers
+	prov.setUnreachable()
+	result, err = r.distribute(ctx, first, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRequeue(), result)
+	assert.Equal(t, imagev1alpha1.NodeImageAvailable, first.Status.State)
+	assert.True(t, meta.IsStatusConditionTrue(first.Status.Conditions, imagev1alpha1.NodeImageConditionProviderAvailable))
+	assert.Equal(t, 0.0, testutil.ToFloat64(providerUnavailable.WithLabelValues(provider.VSphere)))
+	_, ok = r.connectivity.allow(provider.VSphere, now, interval)
+	assert.True(t, ok)
+}
+
+func TestProviderPartiallyUnreachable(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: provider.VSphere},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := &unreachableProvider{fakeProvider: newFakeProvider("dc1", "dc2")}
+	prov.setUnreachable("dc1")
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+	// a single unreachable location fails the image as before
+	result, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{RequeueAfter: DefaultFailureBackoff}, result)
+	assert.Equal(t, imagev1alpha1.NodeImageError, nodeImage.Status.State)
+	assert.Equal(t, int32(1), nodeImage.Status.ConsecutiveFailures)
+	assert.Nil(t, meta.FindStatusCondition(nodeImage.Status.Conditions, imagev1alpha1.NodeImageConditionProviderAvailable))
+
+	_, ok := r.connectivity.allow(provider.VSphere, time.Now(), time.Minute)
+	assert.True(t, ok)
+}
@@ -0,0 +1,108 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+func TestHandleDeletionPartialFailure(t *testing.T) {
+	testCases := []struct {
+		name              string
+		deleteErr         map[string]error
+		expectedError     bool
+		expectedLocations []string
+		expectedFinalizer bool
+	}{
+		{
+			name:              "case 0: images deleted or already absent in all locations",
+			expectedLocations: nil,
+		},
+		{
+			name:              "case 1: failed location keeps the finalizer",
+			deleteErr:         map[string]error{"dc2": errors.New("connection refused")},
+			expectedError:     true,
+			expectedLocations: []string{"dc2"},
+			expectedFinalizer: true,
+		},
+		{
+			name:              "case 2: failed location not recorded before is added to the status",
+			deleteErr:         map[string]error{"dc3": errors.New("connection refused")},
+			expectedError:     true,
+			expectedLocations: []string{"dc3"},
+			expectedFinalizer: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			now := metav1.Now()
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "capv-test-image",
+					Namespace:         "test-namespace",
+					DeletionTimestamp: &now,
+					Finalizers:        []string{NodeImageFinalizer},
+				},
+				Spec: imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases: []string{"v1.0.0"},
+					State:    imagev1alpha1.NodeImageAvailable,
+					Locations: []imagev1alpha1.NodeImageLocation{
+						{Name: "dc1", ImageName: "test-image"},
+						{Name: "dc2", ImageName: "test-image"},
+					},
+				},
+			}
+
+			prov := newFakeProvider("dc1", "dc2", "dc3")
+			// the image is missing in dc3, which is not an error
+			prov.images["dc1/test-image"] = true
+			prov.images["dc2/test-image"] = true
+			for loc, err := range tc.deleteErr {
+				prov.deleteErr[loc] = err
+			}
+
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{
+				Client:    c,
+				Providers: map[string]provider.Provider{"capv": prov},
+			}
+
+			_, err := r.handleDeletion(ctx, nodeImage)
+			if tc.expectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			var locations []string
+			for _, location := range nodeImage.Status.Locations {
+				locations = append(locations, location.Name)
+			}
+			assert.Equal(t, tc.expectedLocations, locations)
+
+			stored := &imagev1alpha1.NodeImage{}
+			err = c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored)
+			if !tc.expectedFinalizer {
+				// without the finalizer the object is gone
+				assert.True(t, apierrors.IsNotFound(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, stored.Finalizers, NodeImageFinalizer)
+			assert.Equal(t, imagev1alpha1.NodeImageError, stored.Status.State)
+		})
+	}
+}
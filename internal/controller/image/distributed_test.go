@@ -0,0 +1,94 @@
+package image
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+func TestCreateProviderDistributedReason(t *testing.T) {
+	testCases := []struct {
+		name             string
+		location         string
+		existing         bool
+		conditions       []metav1.Condition
+		expectedReason   string
+		expectedUploaded float64
+		expectedSkipped  float64
+	}{
+		{
+			name:             "case 0: fresh upload is recorded as Uploaded",
+			location:         "dc-upload",
+			expectedReason:   imagev1alpha1.NodeImageReasonUploaded,
+			expectedUploaded: 1,
+		},
+		{
+			name:            "case 1: existing image is recorded as AlreadyPresent",
+			location:        "dc-skip",
+			existing:        true,
+			expectedReason:  imagev1alpha1.NodeImageReasonAlreadyPresent,
+			expectedSkipped: 1,
+		},
+		{
+			name:     "case 2: rechecking a distributed image keeps its reason and is not a skip",
+			location: "dc-recheck",
+			existing: true,
+			conditions: []metav1.Condition{{
+				Type:               imagev1alpha1.NodeImageConditionDistributed,
+				Status:             metav1.ConditionTrue,
+				Reason:             imagev1alpha1.NodeImageReasonUploaded,
+				Message:            "Image uploaded to location dc-recheck",
+				LastTransitionTime: metav1.Now(),
+			}},
+			expectedReason: imagev1alpha1.NodeImageReasonUploaded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases:   []string{"v1.0.0"},
+					State:      imagev1alpha1.NodeImagePending,
+					Conditions: tc.conditions,
+				},
+			}
+
+			prov := newFakeProvider(tc.location)
+			if tc.existing {
+				prov.images[tc.location+"/test-image"] = true
+			}
+
+			r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+			state, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/test-image.ova", tc.location, prov)
+			require.NoError(t, err)
+			assert.Equal(t, imagev1alpha1.NodeImageAvailable, state)
+
+			condition := meta.FindStatusCondition(nodeImage.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+			require.NotNil(t, condition)
+			assert.Equal(t, metav1.ConditionTrue, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+
+			// the condition is persisted, not only set on the in-memory object
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+			assert.True(t, meta.IsStatusConditionPresentAndEqual(stored.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed, metav1.ConditionTrue))
+
+			assert.Equal(t, tc.expectedUploaded, testutil.ToFloat64(uploadsTotal.WithLabelValues("capv", tc.location, uploadResultUploaded)))
+			assert.Equal(t, tc.expectedSkipped, testutil.ToFloat64(uploadsTotal.WithLabelValues("capv", tc.location, uploadResultSkipped)))
+		})
+	}
+}
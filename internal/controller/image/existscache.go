@@ -0,0 +1,67 @@
+package image
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultExistsCacheTTL is how long an image found in a location is trusted to
+// still be there by default.
+const DefaultExistsCacheTTL = time.Minute
+
+// existsCache is shared by all reconciles and remembers the images recently
+// found in a location, so steady-state reconciles don't query the provider
+// every time. Its zero value remembers nothing.
+type existsCache struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// existsKey identifies an image in a provider location
+func existsKey(providerName string, loc string, name string) string {
+	return providerName + "/" + loc + "/" + name
+}
+
+// fresh reports whether the image was found in the location recently enough
+// to skip asking the provider again
+func (c *existsCache) fresh(key string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.expires[key]
+	if !ok {
+		return false
+	}
+	if !now.Before(expires) {
+		delete(c.expires, key)
+		return false
+	}
+	return true
+}
+
+// remember records the image as present in the location until expires
+func (c *existsCache) remember(key string, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.expires == nil {
+		c.expires = make(map[string]time.Time)
+	}
+	c.expires[key] = expires
+}
+
+// forget drops the image, so the next reconcile asks the provider again
+func (c *existsCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.expires, key)
+}
+
+// clear drops all images, so the next reconciles ask the providers again
+func (c *existsCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.expires)
+}
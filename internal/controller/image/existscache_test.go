@@ -0,0 +1,111 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// existsCountingProvider counts the calls of Exists
+type existsCountingProvider struct {
+	*fakeProvider
+	existsCalls int
+}
+
+func (p *existsCountingProvider) Exists(ctx context.Context, name string, loc string) (bool, error) {
+	p.existsCalls++
+	return p.fakeProvider.Exists(ctx, name, loc)
+}
+
+func TestCreateProviderExistsCache(t *testing.T) {
+	testCases := []struct {
+		name string
+		ttl  time.Duration
+		// between runs between the two calls of CreateProvider
+		between             func(t *testing.T, r *NodeImageReconciler, nodeImage *imagev1alpha1.NodeImage, prov *existsCountingProvider, now *time.Time)
+		expectedExistsCalls int
+	}{
+		{
+			name: "case 0: image found within the TTL is not checked again",
+			ttl:  time.Minute,
+			between: func(*testing.T, *NodeImageReconciler, *imagev1alpha1.NodeImage, *existsCountingProvider, *time.Time) {
+			},
+			expectedExistsCalls: 1,
+		},
+		{
+			name: "case 1: image is checked again after the TTL",
+			ttl:  time.Minute,
+			between: func(_ *testing.T, _ *NodeImageReconciler, _ *imagev1alpha1.NodeImage, _ *existsCountingProvider, now *time.Time) {
+				*now = now.Add(time.Minute)
+			},
+			expectedExistsCalls: 2,
+		},
+		{
+			name: "case 2: cache disabled",
+			between: func(*testing.T, *NodeImageReconciler, *imagev1alpha1.NodeImage, *existsCountingProvider, *time.Time) {
+			},
+			expectedExistsCalls: 2,
+		},
+		{
+			name: "case 3: deletion invalidates the cache",
+			ttl:  time.Minute,
+			between: func(t *testing.T, r *NodeImageReconciler, nodeImage *imagev1alpha1.NodeImage, prov *existsCountingProvider, _ *time.Time) {
+				require.NoError(t, r.DeleteProvider(context.TODO(), nodeImage, nodeImage, "dc1", prov))
+			},
+			expectedExistsCalls: 2,
+		},
+		{
+			name: "case 4: failed verification invalidates the cache",
+			ttl:  time.Minute,
+			between: func(t *testing.T, r *NodeImageReconciler, nodeImage *imagev1alpha1.NodeImage, prov *existsCountingProvider, _ *time.Time) {
+				prov.mu.Lock()
+				delete(prov.images, "dc1/test-image")
+				prov.mu.Unlock()
+
+				_, err := r.verify(context.TODO(), nodeImage, prov.locations, prov)
+				require.Error(t, err)
+			},
+			// the verification asks the provider as well
+			expectedExistsCalls: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases: []string{"v1.0.0"},
+					State:    imagev1alpha1.NodeImagePending,
+				},
+			}
+
+			prov := &existsCountingProvider{fakeProvider: newFakeProvider("dc1")}
+			prov.images["dc1/test-image"] = true
+
+			now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			r := &NodeImageReconciler{
+				Client:         newFakeClient(t, nodeImage),
+				ExistsCacheTTL: tc.ttl,
+				now:            func() time.Time { return now },
+			}
+
+			_, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			require.NoError(t, err)
+			tc.between(t, r, nodeImage, prov, &now)
+			_, err = r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedExistsCalls, prov.existsCalls)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// deleteNodeImage deletes a NodeImage the controller no longer needs. With
+// DisableFinalizer set a NodeImage without the finalizer is gone right away,
+// so its images are deleted from the provider inline on a best-effort basis.
+func (r *NodeImageReconciler) deleteNodeImage(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, opts ...client.DeleteOption) error {
+	if err := r.Delete(ctx, nodeImage, opts...); err != nil {
+		return err
+	}
+	// a NodeImage still carrying the finalizer is cleaned up by handleDeletion
+	if r.DisableFinalizer && !controllerutil.ContainsFinalizer(nodeImage, NodeImageFinalizer) {
+		r.deleteImagesBestEffort(ctx, nodeImage)
+	}
+	return nil
+}
+
+// deleteImagesBestEffort deletes the images of a NodeImage from the
+// locations of its provider, logging the locations that fail instead of
+// retrying them. The images left behind are removed by the orphan collector,
+// if enabled.
+func (r *NodeImageReconciler) deleteImagesBestEffort(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) {
+	log := log.FromContext(ctx)
+
+	prov, ok := r.Providers[nodeImage.Spec.Provider]
+	if !ok {
+		return
+	}
+
+	if err := r.forEachLocation(deletionLocations(nodeImage, prov), func(loc string) error {
+		name, err := r.providerImageName(nodeImage, loc, prov)
+		if err != nil {
+			// an image whose name is not valid can never have been created
+			return nil
+		}
+		r.existsCache.forget(existsKey(nodeImage.Spec.Provider, loc, name))
+		if err := prov.Delete(provider.WithLogValues(ctx, provider.LogKeyLocation, loc), name, loc); err != nil {
+			return fmt.Errorf("failed to delete image: %w", err)
+		}
+		log.Info("Node image deleted", provider.LogKeyLocation, loc)
+		return nil
+	}); err != nil {
+		log.Error(err, "Failed to delete node image from provider - leaving it behind")
+	}
+}
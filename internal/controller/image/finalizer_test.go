@@ -0,0 +1,160 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+	"github.com/giantswarm/image-distribution-operator/pkg/s3"
+)
+
+func TestReconcileDisableFinalizer(t *testing.T) {
+	testCases := []struct {
+		name              string
+		disableFinalizer  bool
+		expectedFinalizer bool
+	}{
+		{
+			name:              "case 0: finalizer added by default",
+			expectedFinalizer: true,
+		},
+		{
+			name:             "case 1: finalizer not added if disabled",
+			disableFinalizer: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "capmox-test-image",
+					Namespace: "test-namespace",
+				},
+				Spec:   imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: provider.Proxmox},
+				Status: imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+
+			s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1"}, ctx)
+			require.NoError(t, err)
+
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{
+				Client:           c,
+				ObjectStore:      s3Client,
+				DisableFinalizer: tc.disableFinalizer,
+			}
+
+			// the provider is not configured, so the reconcile stops right
+			// after the finalizer
+			_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}})
+			require.NoError(t, err)
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+			assert.Equal(t, tc.expectedFinalizer, stored.Finalizers != nil)
+		})
+	}
+}
+
+func TestDeletionDisableFinalizer(t *testing.T) {
+	testCases := []struct {
+		name             string
+		disableFinalizer bool
+		finalizer        bool
+		deleteErr        map[string]error
+		expectedDeleted  []string
+		expectedImages   map[string]bool
+		expectedGone     bool
+	}{
+		{
+			name:           "case 0: default leaves the image deletion to the finalizer",
+			finalizer:      true,
+			expectedImages: map[string]bool{"dc1/test-image": true, "dc2/test-image": true},
+		},
+		{
+			name:             "case 1: disabled finalizer deletes the images inline",
+			disableFinalizer: true,
+			expectedDeleted:  []string{"dc1", "dc2"},
+			expectedImages:   map[string]bool{},
+			expectedGone:     true,
+		},
+		{
+			name:             "case 2: disabled finalizer ignores failed locations",
+			disableFinalizer: true,
+			deleteErr:        map[string]error{"dc2": errors.New("connection refused")},
+			expectedDeleted:  []string{"dc1"},
+			expectedImages:   map[string]bool{"dc2/test-image": true},
+			expectedGone:     true,
+		},
+		{
+			name:             "case 3: disabled finalizer releases an existing finalizer despite failed locations",
+			disableFinalizer: true,
+			finalizer:        true,
+			deleteErr:        map[string]error{"dc1": errors.New("connection refused"), "dc2": errors.New("connection refused")},
+			expectedImages:   map[string]bool{"dc1/test-image": true, "dc2/test-image": true},
+			expectedGone:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "capv-test-image",
+					Namespace: "test-namespace",
+				},
+				Spec:   imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{State: imagev1alpha1.NodeImageAvailable},
+			}
+			if tc.finalizer {
+				nodeImage.Finalizers = []string{NodeImageFinalizer}
+			}
+
+			prov := newFakeProvider("dc1", "dc2")
+			prov.images["dc1/test-image"] = true
+			prov.images["dc2/test-image"] = true
+			for loc, err := range tc.deleteErr {
+				prov.deleteErr[loc] = err
+			}
+
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{
+				Client:           c,
+				Providers:        map[string]provider.Provider{"capv": prov},
+				DisableFinalizer: tc.disableFinalizer,
+			}
+
+			// no release references the image, so it is deleted
+			_, handled, err := r.handleNoReleases(ctx, nodeImage)
+			require.True(t, handled)
+			require.NoError(t, err)
+
+			// a finalizer still set is handled by the next reconcile
+			stored := &imagev1alpha1.NodeImage{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored); err == nil && tc.disableFinalizer {
+				_, err = r.handleDeletion(ctx, stored)
+				require.NoError(t, err)
+			}
+
+			err = c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored)
+			assert.Equal(t, tc.expectedGone, apierrors.IsNotFound(err))
+			assert.ElementsMatch(t, tc.expectedDeleted, prov.deleted)
+			assert.Equal(t, tc.expectedImages, prov.images)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package image
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// resetInterruptedUpload prepares the distribution of a NodeImage with
+// locations whose upload is recorded as in flight in the status. Reconciles of
+// a NodeImage don't overlap and its uploads finish before the reconcile
+// returns, so no upload is in flight for it anymore: the operator crashed or
+// was killed during the upload. The locations are uploaded to concurrently,
+// so others may have completed before. The provider task is cleared, and an
+// image an interrupted upload left behind in its location is deleted unless
+// it is ready, so it is imported again from scratch instead of being taken as
+// present.
+func (r *NodeImageReconciler) resetInterruptedUpload(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, locations []string, prov provider.Provider) error {
+	interrupted := interruptedLocations(nodeImage, locations)
+	if len(interrupted) == 0 {
+		return nil
+	}
+	log.FromContext(ctx).Info("Upload interrupted by an operator restart - checking the provider again", "locations", interrupted, "task", nodeImage.Status.ProviderTaskRef)
+
+	for _, loc := range interrupted {
+		name, err := r.providerImageName(nodeImage, loc, prov)
+		if err != nil {
+			return err
+		}
+		r.existsCache.forget(existsKey(nodeImage.Spec.Provider, loc, name))
+		if err := r.deleteUnfinishedImage(ctx, name, loc, prov); err != nil {
+			return err
+		}
+	}
+	return r.setProviderTaskRef(ctx, nodeImage, "", "")
+}
+
+// interruptedLocations returns the locations whose upload is recorded as in
+// flight in the status
+func interruptedLocations(nodeImage *imagev1alpha1.NodeImage, locations []string) []string {
+	var interrupted []string
+	for _, loc := range locations {
+		if statusLocation(nodeImage, loc).State == imagev1alpha1.NodeImageUploading {
+			interrupted = append(interrupted, loc)
+		}
+	}
+	return interrupted
+}
+
+// deleteUnfinishedImage deletes the image in the location if it exists but
+// is not ready. Images of providers without a readiness check are complete
+// once they exist and are kept.
+func (r *NodeImageReconciler) deleteUnfinishedImage(ctx context.Context, name string, loc string, prov provider.Provider) error {
+	checker, ok := prov.(provider.ReadinessChecker)
+	if !ok {
+		return nil
+	}
+
+	exists, err := prov.Exists(ctx, name, loc)
+	if err != nil {
+		return fmt.Errorf("failed to check if image exists: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	ready, err := checker.Ready(ctx, name, loc)
+	if err != nil {
+		return fmt.Errorf("failed to check if image is ready: %w", err)
+	}
+	if ready {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Deleting node image left unfinished by the interrupted upload", "name", name)
+	if err := prov.Delete(ctx, name, loc); err != nil {
+		return fmt.Errorf("failed to delete unfinished image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package image
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// unfinishedProvider is a fakeProvider whose images are only ready once
+// marked as ready, which Create does when it completes
+type unfinishedProvider struct {
+	*fakeProvider
+	ready map[string]bool
+}
+
+func (p *unfinishedProvider) Ready(ctx context.Context, name string, loc string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.images[loc+"/"+name] && p.ready[loc+"/"+name], nil
+}
+
+func (p *unfinishedProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	if err := p.fakeProvider.Create(ctx, imageURL, imageName, loc); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ready[loc+"/"+imageName] = true
+	return nil
+}
+
+func TestDistributeInterruptedUpload(t *testing.T) {
+	testCases := []struct {
+		name            string
+		state           imagev1alpha1.NodeImageState
+		existing        bool
+		ready           bool
+		expectedCreated []string
+		expectedDeleted []string
+		expectedReason  string
+	}{
+		{
+			name:            "case 0: image missing after an interrupted upload is imported again",
+			state:           imagev1alpha1.NodeImageUploading,
+			expectedCreated: []string{"dc1"},
+			expectedReason:  imagev1alpha1.NodeImageReasonUploaded,
+		},
+		{
+			name:            "case 1: unfinished image left by an interrupted upload is deleted and imported again",
+			state:           imagev1alpha1.NodeImageUploading,
+			existing:        true,
+			expectedCreated: []string{"dc1"},
+			expectedDeleted: []string{"dc1"},
+			expectedReason:  imagev1alpha1.NodeImageReasonUploaded,
+		},
+		{
+			name:           "case 2: image completed before the upload was interrupted is kept",
+			state:          imagev1alpha1.NodeImageUploading,
+			existing:       true,
+			ready:          true,
+			expectedReason: imagev1alpha1.NodeImageReasonAlreadyPresent,
+		},
+		{
+			name:           "case 3: image in a location that is not uploading is not checked for readiness",
+			state:          imagev1alpha1.NodeImageAvailable,
+			existing:       true,
+			expectedReason: imagev1alpha1.NodeImageReasonAlreadyPresent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases:        []string{"v1.0.0"},
+					State:           imagev1alpha1.NodeImageAvailable,
+					ProviderTaskRef: "task-42",
+					Locations:       []imagev1alpha1.NodeImageLocation{{Name: "dc1", ImageName: "test-image", State: tc.state}},
+				},
+			}
+			prov := &unfinishedProvider{fakeProvider: newFakeProvider("dc1"), ready: map[string]bool{}}
+			if tc.existing {
+				prov.images["dc1/test-image"] = true
+				prov.ready["dc1/test-image"] = tc.ready
+			}
+
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{Client: c}
+
+			_, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCreated, prov.created)
+			assert.Equal(t, tc.expectedDeleted, prov.deleted)
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+			assert.Equal(t, imagev1alpha1.NodeImageAvailable, stored.Status.State)
+			condition := meta.FindStatusCondition(stored.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+			require.NotNil(t, condition)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+			if tc.state == imagev1alpha1.NodeImageUploading {
+				assert.Empty(t, stored.Status.ProviderTaskRef)
+			}
+		})
+	}
+}
+
+func TestDistributeInterruptedLocation(t *testing.T) {
+	ctx := context.TODO()
+
+	// the upload to dc1 completed before the operator crashed, the one to
+	// dc2 was still running
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status: imagev1alpha1.NodeImageStatus{
+			Releases: []string{"v1.0.0"},
+			State:    imagev1alpha1.NodeImagePending,
+			Locations: []imagev1alpha1.NodeImageLocation{
+				{Name: "dc1", ImageName: "test-image", State: imagev1alpha1.NodeImageAvailable},
+				{Name: "dc2", ImageName: "test-image", State: imagev1alpha1.NodeImageUploading},
+			},
+		},
+	}
+	prov := &unfinishedProvider{fakeProvider: newFakeProvider("dc1", "dc2"), ready: map[string]bool{"dc1/test-image": true}}
+	prov.images["dc1/test-image"] = true
+	prov.images["dc2/test-image"] = true
+
+	c := newFakeClient(t, nodeImage)
+	r := &NodeImageReconciler{Client: c}
+
+	_, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dc2"}, prov.deleted)
+	assert.Equal(t, []string{"dc2"}, prov.created)
+
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	assert.Equal(t, imagev1alpha1.NodeImageAvailable, stored.Status.State)
+	for _, loc := range prov.locations {
+		assert.Equal(t, imagev1alpha1.NodeImageAvailable, statusLocation(stored, loc).State, loc)
+	}
+}
@@ -0,0 +1,309 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/httpcheck"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+	"github.com/giantswarm/image-distribution-operator/pkg/s3"
+)
+
+// fakeProvider is an in-memory provider.Provider used to drive the reconciler
+// without any real infrastructure behind it.
+type fakeProvider struct {
+	mu        sync.Mutex
+	locations []string
+	images    map[string]bool
+	createErr map[string]error
+	deleteErr map[string]error
+	created   []string
+	processed []string
+	deleted   []string
+}
+
+func newFakeProvider(locations ...string) *fakeProvider {
+	return &fakeProvider{
+		locations: locations,
+		images:    make(map[string]bool),
+		createErr: make(map[string]error),
+		deleteErr: make(map[string]error),
+	}
+}
+
+func (f *fakeProvider) Exists(ctx context.Context, name string, loc string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.images[loc+"/"+name], nil
+}
+
+func (f *fakeProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.createErr[loc]; err != nil {
+		return err
+	}
+	f.images[loc+"/"+imageName] = true
+	f.created = append(f.created, loc)
+	return nil
+}
+
+func (f *fakeProvider) Process(ctx context.Context, name string, loc string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.processed = append(f.processed, loc)
+	return nil
+}
+
+func (f *fakeProvider) Delete(ctx context.Context, name string, loc string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.deleteErr[loc]; err != nil {
+		return err
+	}
+	delete(f.images, loc+"/"+name)
+	f.deleted = append(f.deleted, loc)
+	return nil
+}
+
+func (f *fakeProvider) GetLocations() map[string]interface{} {
+	locations := make(map[string]interface{})
+	for _, loc := range f.locations {
+		locations[loc] = struct{}{}
+	}
+	return locations
+}
+
+func TestForEachLocation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		locations      []string
+		failing        []string
+		concurrency    int
+		expectedCalls  int
+		expectedErrMsg string
+	}{
+		{
+			name:          "case 0: all locations succeed",
+			locations:     []string{"dc1", "dc2", "dc3"},
+			expectedCalls: 3,
+		},
+		{
+			name:           "case 1: a failing location does not stop the others",
+			locations:      []string{"dc1", "dc2", "dc3", "dc4"},
+			failing:        []string{"dc3"},
+			expectedCalls:  4,
+			expectedErrMsg: "failed in locations dc3: location dc3: boom",
+		},
+		{
+			name:           "case 2: all failed locations are named in a stable order",
+			locations:      []string{"dc3", "dc1", "dc2"},
+			failing:        []string{"dc3", "dc1"},
+			concurrency:    1,
+			expectedCalls:  3,
+			expectedErrMsg: "failed in locations dc1, dc3: location dc1: boom\nlocation dc3: boom",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &NodeImageReconciler{LocationConcurrency: tc.concurrency}
+			prov := newFakeProvider(tc.locations...)
+
+			failing := make(map[string]bool)
+			for _, loc := range tc.failing {
+				failing[loc] = true
+			}
+
+			var calls atomic.Int32
+			err := r.forEachLocation(prov.locations, func(loc string) error {
+				calls.Add(1)
+				if failing[loc] {
+					return fmt.Errorf("boom")
+				}
+				return nil
+			})
+
+			assert.Equal(t, tc.expectedCalls, int(calls.Load()))
+			if tc.expectedErrMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+			}
+		})
+	}
+}
+
+func TestForEachLocationConcurrencyLimit(t *testing.T) {
+	testCases := []struct {
+		name        string
+		concurrency int
+		expectedMax int32
+	}{
+		{
+			name:        "case 0: unset concurrency falls back to the default",
+			concurrency: 0,
+			expectedMax: DefaultLocationConcurrency,
+		},
+		{
+			name:        "case 1: configured concurrency is respected",
+			concurrency: 2,
+			expectedMax: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &NodeImageReconciler{LocationConcurrency: tc.concurrency}
+			prov := newFakeProvider("dc1", "dc2", "dc3", "dc4", "dc5", "dc6")
+
+			var inFlight, maxInFlight atomic.Int32
+			err := r.forEachLocation(prov.locations, func(loc string) error {
+				current := inFlight.Add(1)
+				for {
+					seen := maxInFlight.Load()
+					if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				inFlight.Add(-1)
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedMax, maxInFlight.Load())
+		})
+	}
+}
+
+func TestReconcileSpecLocations(t *testing.T) {
+	testCases := []struct {
+		name              string
+		locations         []string
+		expectedState     imagev1alpha1.NodeImageState
+		expectedCreated   []string
+		expectedReason    string
+		expectedCondition string
+	}{
+		{
+			name:            "case 0: no locations distributes to all locations",
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1", "dc2", "dc3"},
+		},
+		{
+			name:            "case 1: the image is only distributed to the listed locations",
+			locations:       []string{"dc3", "dc1"},
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1", "dc3"},
+		},
+		{
+			name:              "case 2: unknown locations mark the image as Error",
+			locations:         []string{"dc1", "dc4", "dc5"},
+			expectedState:     imagev1alpha1.NodeImageError,
+			expectedReason:    imagev1alpha1.NodeImageReasonUnknownLocation,
+			expectedCondition: "locations dc4, dc5 are not configured for provider capv",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer server.Close()
+			serverURL, err := url.Parse(server.URL)
+			require.NoError(t, err)
+
+			s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1", HTTP: true, AllowedHostPatterns: []string{serverURL.Hostname()}}, ctx)
+			require.NoError(t, err)
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace", Finalizers: []string{NodeImageFinalizer}},
+				Spec: imagev1alpha1.NodeImageSpec{
+					Name:      "test-image",
+					Provider:  "capv",
+					URL:       server.URL + "/capv/test-image.ova",
+					Locations: tc.locations,
+				},
+				Status: imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			prov := newFakeProvider("dc1", "dc2", "dc3")
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{
+				Client:       c,
+				ObjectStore:  s3Client,
+				Providers:    map[string]provider.Provider{"capv": prov},
+				ImageChecker: &httpcheck.Checker{},
+			}
+
+			_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(nodeImage)})
+			require.NoError(t, err)
+
+			sort.Strings(prov.created)
+			assert.Equal(t, tc.expectedCreated, prov.created)
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+			assert.Equal(t, tc.expectedState, stored.Status.State)
+			if tc.expectedReason != "" {
+				condition := meta.FindStatusCondition(stored.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+				require.NotNil(t, condition)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+				assert.Equal(t, tc.expectedCondition, condition.Message)
+			}
+		})
+	}
+}
+
+func TestHandleDeletionSpecLocations(t *testing.T) {
+	ctx := context.TODO()
+
+	now := metav1.Now()
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "capv-test-image",
+			Namespace:         "test-namespace",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{NodeImageFinalizer},
+		},
+		Spec: imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv", Locations: []string{"dc1"}},
+		Status: imagev1alpha1.NodeImageStatus{
+			Releases: []string{"v1.0.0"},
+			State:    imagev1alpha1.NodeImageAvailable,
+			// dc2 was distributed to before it was removed from spec.locations
+			Locations: []imagev1alpha1.NodeImageLocation{
+				{Name: "dc1", ImageName: "test-image"},
+				{Name: "dc2", ImageName: "test-image"},
+			},
+		},
+	}
+
+	prov := newFakeProvider("dc1", "dc2", "dc3")
+	r := &NodeImageReconciler{
+		Client:    newFakeClient(t, nodeImage),
+		Providers: map[string]provider.Provider{"capv": prov},
+	}
+
+	_, err := r.handleDeletion(ctx, nodeImage)
+	require.NoError(t, err)
+
+	sort.Strings(prov.deleted)
+	assert.Equal(t, []string{"dc1", "dc2"}, prov.deleted)
+}
@@ -0,0 +1,77 @@
+package image
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// loggingProvider logs every deletion with the logger of the context, like
+// the real providers do
+type loggingProvider struct {
+	*fakeProvider
+}
+
+func (p loggingProvider) Delete(ctx context.Context, name string, loc string) error {
+	log.FromContext(ctx).Info("Deleting image", "name", name)
+	return p.fakeProvider.Delete(ctx, name, loc)
+}
+
+func TestReconcileLogFields(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+	logger := funcr.New(func(prefix, args string) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, args)
+	}, funcr.Options{})
+	ctx := log.IntoContext(context.TODO(), logger)
+
+	now := metav1.Now()
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "capv-test-image",
+			Namespace:         "test-namespace",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{NodeImageFinalizer},
+		},
+		Spec:   imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status: imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImageAvailable},
+	}
+
+	prov := newFakeProvider("dc1")
+	prov.images["dc1/test-image"] = true
+	r := &NodeImageReconciler{
+		Client:    newFakeClient(t, nodeImage),
+		Providers: map[string]provider.Provider{"capv": loggingProvider{prov}},
+	}
+
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dc1"}, prov.deleted)
+
+	// the provider logs without knowing the node image, the reconciler put
+	// the fields into the context
+	var providerLine string
+	for _, line := range lines {
+		assert.Contains(t, line, `"nodeImage"="capv-test-image"`)
+		assert.Contains(t, line, `"provider"="capv"`)
+		if strings.Contains(line, `"msg"="Deleting image"`) {
+			providerLine = line
+		}
+	}
+	require.NotEmpty(t, providerLine)
+	assert.Contains(t, providerLine, `"location"="dc1"`)
+}
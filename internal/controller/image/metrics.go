@@ -0,0 +1,45 @@
+package image
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	uploadResultUploaded = "uploaded"
+	uploadResultSkipped  = "skipped"
+)
+
+// uploadsTotal counts how often a node image was uploaded to a location versus
+// skipped because it was already present there.
+var uploadsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "image_distribution_operator_uploads_total",
+		Help: "Number of node image uploads per provider and location, by result (uploaded or skipped).",
+	},
+	[]string{"provider", "location", "result"},
+)
+
+// orphansDeletedTotal counts the images deleted from a location because no
+// NodeImage accounted for them anymore.
+var orphansDeletedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "image_distribution_operator_orphaned_images_deleted_total",
+		Help: "Number of orphaned images deleted per provider and location.",
+	},
+	[]string{"provider", "location"},
+)
+
+// providerUnavailable is 1 while a provider is unreachable in all of its
+// locations and reconciles of its NodeImages are short-circuited.
+var providerUnavailable = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "image_distribution_operator_provider_unavailable",
+		Help: "Whether the provider is unreachable and reconciles of its node images are paused (1) or not (0).",
+	},
+	[]string{"provider"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(uploadsTotal, orphansDeletedTotal, providerUnavailable)
+}
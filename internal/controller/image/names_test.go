@@ -0,0 +1,211 @@
+package image
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// limitedProvider is a fakeProvider with a name length limit.
+type limitedProvider struct {
+	*fakeProvider
+	maxNameLength int
+}
+
+func (l *limitedProvider) MaxNameLength(loc string) int {
+	return l.maxNameLength
+}
+
+func TestCreateProviderNameLength(t *testing.T) {
+	const longName = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-with-a-very-long-custom-naming-scheme"
+
+	testCases := []struct {
+		name          string
+		truncate      bool
+		expectError   bool
+		expectedImage string
+	}{
+		{
+			name:        "case 0: too long name fails before uploading",
+			expectError: true,
+		},
+		{
+			name:          "case 1: too long name is uploaded truncated",
+			truncate:      true,
+			expectedImage: image.TruncateName(longName, 80),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-" + longName, Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: longName, Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			prov := &limitedProvider{fakeProvider: newFakeProvider("dc1"), maxNameLength: 80}
+
+			r := &NodeImageReconciler{
+				Client:            newFakeClient(t, nodeImage),
+				TruncateLongNames: tc.truncate,
+			}
+
+			_, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			if tc.expectError {
+				assert.ErrorContains(t, err, "exceeding the limit of 80")
+				assert.Empty(t, prov.created)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, prov.images["dc1/"+tc.expectedImage])
+
+			// the truncated name is found again and deleted
+			prov.created = nil
+			_, err = r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			require.NoError(t, err)
+			assert.Empty(t, prov.created)
+
+			require.NoError(t, r.DeleteProvider(ctx, nodeImage, nodeImage, "dc1", prov))
+			assert.Equal(t, []string{"dc1"}, prov.deleted)
+			assert.False(t, prov.images["dc1/"+tc.expectedImage])
+		})
+	}
+}
+
+func TestDeleteProviderNameTooLong(t *testing.T) {
+	const longName = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-with-a-very-long-custom-naming-scheme"
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-too-long", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: longName, Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImageError},
+	}
+	prov := &limitedProvider{fakeProvider: newFakeProvider("dc1"), maxNameLength: 80}
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+	// an image that could never be created must not block the deletion
+	require.NoError(t, r.DeleteProvider(context.TODO(), nodeImage, nodeImage, "dc1", prov))
+	assert.Empty(t, prov.deleted)
+}
+
+// suffixProvider is a fakeProvider appending a suffix per location to the
+// image names.
+type suffixProvider struct {
+	*fakeProvider
+	suffixes map[string]string
+}
+
+func (p *suffixProvider) ImageName(name string, loc string) string {
+	if suffix := p.suffixes[loc]; suffix != "" {
+		return name + "-" + suffix
+	}
+	return name
+}
+
+func TestCreateProviderRecordsLocations(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := &suffixProvider{
+		fakeProvider: newFakeProvider("dc1", "dc2", "dc3"),
+		suffixes:     map[string]string{"dc1": "efi", "dc2": "bios"},
+	}
+	// the image is already present in dc3
+	prov.images["dc3/test-image"] = true
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+	require.NoError(t, r.forEachImageLocation(nodeImage, prov.locations, func(worker *imagev1alpha1.NodeImage, loc string) error {
+		_, err := r.CreateProvider(ctx, worker, nodeImage, "https://example.com/image.ova", loc, prov)
+		return err
+	}))
+
+	expected := []imagev1alpha1.NodeImageLocation{
+		{Name: "dc1", ImageName: "test-image-efi", State: imagev1alpha1.NodeImageAvailable},
+		{Name: "dc2", ImageName: "test-image-bios", State: imagev1alpha1.NodeImageAvailable},
+		{Name: "dc3", ImageName: "test-image", State: imagev1alpha1.NodeImageAvailable},
+	}
+	assert.Equal(t, expected, nodeImage.Status.Locations)
+
+	// the recorded locations are written to the status
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	assert.Equal(t, expected, stored.Status.Locations)
+}
+
+// idProvider is a fakeProvider reporting IDs for the images it creates and
+// finds, and recording the IDs it is asked to delete images by.
+type idProvider struct {
+	*fakeProvider
+	deletedIDs map[string]string
+}
+
+func (p *idProvider) Exists(ctx context.Context, name string, loc string) (bool, error) {
+	exists, err := p.fakeProvider.Exists(ctx, name, loc)
+	if exists {
+		provider.ReportImageID(ctx, "found-"+loc)
+	}
+	return exists, err
+}
+
+func (p *idProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	if err := p.fakeProvider.Create(ctx, imageURL, imageName, loc); err != nil {
+		return err
+	}
+	provider.ReportImageID(ctx, "created-"+loc)
+	return nil
+}
+
+func (p *idProvider) Delete(ctx context.Context, name string, loc string) error {
+	p.mu.Lock()
+	p.deletedIDs[loc] = provider.ImageIDFrom(ctx)
+	p.mu.Unlock()
+	return p.fakeProvider.Delete(ctx, name, loc)
+}
+
+func TestCreateProviderRecordsImageIDs(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := &idProvider{fakeProvider: newFakeProvider("dc1", "dc2"), deletedIDs: map[string]string{}}
+	// the image is already present in dc2
+	prov.images["dc2/test-image"] = true
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+	require.NoError(t, r.forEachImageLocation(nodeImage, prov.locations, func(worker *imagev1alpha1.NodeImage, loc string) error {
+		_, err := r.CreateProvider(ctx, worker, nodeImage, "https://example.com/image.ova", loc, prov)
+		return err
+	}))
+
+	expected := []imagev1alpha1.NodeImageLocation{
+		{Name: "dc1", ImageName: "test-image", ProviderImageID: "created-dc1", State: imagev1alpha1.NodeImageAvailable},
+		{Name: "dc2", ImageName: "test-image", ProviderImageID: "found-dc2", State: imagev1alpha1.NodeImageAvailable},
+	}
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	assert.Equal(t, expected, stored.Status.Locations)
+
+	// the images are deleted by their recorded IDs
+	require.NoError(t, r.forEachImageLocation(nodeImage, prov.locations, func(worker *imagev1alpha1.NodeImage, loc string) error {
+		return r.DeleteProvider(ctx, worker, nodeImage, loc, prov)
+	}))
+	assert.Equal(t, map[string]string{"dc1": "created-dc1", "dc2": "found-dc2"}, prov.deletedIDs)
+	assert.Empty(t, nodeImage.Status.Locations)
+}
@@ -18,32 +18,166 @@ package image
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/httpcheck"
 	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/notify"
+	"github.com/giantswarm/image-distribution-operator/pkg/project"
 	"github.com/giantswarm/image-distribution-operator/pkg/provider"
-	"github.com/giantswarm/image-distribution-operator/pkg/s3"
+	"github.com/giantswarm/image-distribution-operator/pkg/storage"
+	"github.com/giantswarm/image-distribution-operator/pkg/window"
 
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	NodeImageFinalizer = "image-distribution-operator.finalizers.giantswarm.io/node-image-controller"
+
+	// DefaultLocationConcurrency is the number of locations a single NodeImage
+	// is created in or deleted from in parallel when LocationConcurrency is unset.
+	DefaultLocationConcurrency = 3
 )
 
 // NodeImageReconciler reconciles a NodeImage object
 type NodeImageReconciler struct {
 	client.Client
-	S3Client             *s3.Client
+	// ObjectStore is the bucket the node images are imported from
+	ObjectStore storage.ObjectStore
+	// ImageKeyTemplate renders the S3 key of the node images, the default
+	// key template is used if nil
+	ImageKeyTemplate     *template.Template
 	Providers            map[string]provider.Provider
 	ImageRetentionPeriod time.Duration
+	LocationConcurrency  int
+
+	// Notifier, when set, is told about transitions into the Error state and,
+	// if NotifyOnAvailable is set, into the Available state.
+	Notifier          notify.Notifier
+	NotifyOnAvailable bool
+
+	// TruncateLongNames shortens image names exceeding a provider's length
+	// limit with a hash suffix instead of failing the upload
+	TruncateLongNames bool
+
+	// DistributionWindow restricts when images are uploaded. Outside of it the
+	// image is marked as Scheduled. Nil means uploads are allowed at any time.
+	DistributionWindow *window.Window
+
+	// ReadinessTimeout, when set, is how long to wait after an upload for the
+	// image to become ready in the provider before it is declared Available.
+	// An image that is not ready in time fails the location.
+	ReadinessTimeout time.Duration
+
+	// readinessInterval is how often readiness is polled, overridden in tests
+	readinessInterval time.Duration
+
+	// progressInterval is how often upload progress is written to the
+	// status at most, overridden in tests
+	progressInterval time.Duration
+
+	// VerificationDelay, when set, requeues a NodeImage this long after an
+	// upload to check that the image is still present in every location
+	// before trusting it. A missing image flips the NodeImage to Error.
+	VerificationDelay time.Duration
+
+	// DryRun is set when the providers only log their uploads. Nothing is
+	// uploaded, so uploads are neither waited for to become ready nor
+	// verified.
+	DryRun bool
+
+	// ProviderProbeInterval is how often a provider unreachable in all of its
+	// locations is tried again by a single reconcile, defaults to
+	// DefaultProviderProbeInterval. The other reconciles of its NodeImages
+	// are short-circuited until it is reachable again.
+	ProviderProbeInterval time.Duration
+
+	// FailureBackoff is the requeue interval after a failed upload, doubled
+	// with every consecutive failure up to MaxFailureBackoff. They default to
+	// DefaultFailureBackoff and DefaultMaxFailureBackoff.
+	FailureBackoff    time.Duration
+	MaxFailureBackoff time.Duration
+
+	// VerifyS3Object, when set, checks with an authenticated request that the
+	// image is in the S3 bucket right before uploading it, and marks the
+	// NodeImage as Missing if it is not. Requires S3 credentials.
+	VerifyS3Object bool
+
+	// AllowedBuckets are the S3 buckets a NodeImage may import its image from
+	// with image.S3BucketAnnotation instead of the bucket of the object store.
+	// The annotation is rejected if empty.
+	AllowedBuckets []string
+
+	// objectExists checks that an image is in the S3 bucket, overridden in tests
+	objectExists func(ctx context.Context, bucket string, region string, imageKey string) (bool, error)
+
+	// ImageChecker checks that the image of a NodeImage can be downloaded,
+	// defaults to a check retrying transient errors with DefaultImageCheckBackoff
+	ImageChecker *httpcheck.Checker
+
+	// RequeueInterval is how often a NodeImage is reconciled again, defaults
+	// to DefaultRequeueInterval. RequeueJitter spreads the reconciles by up to
+	// that fraction of the interval in either direction. Disabled if 0.
+	RequeueInterval time.Duration
+	RequeueJitter   float64
+
+	// ShutdownGracePeriod is how long uploads in flight when the operator
+	// shuts down may continue before they are aborted. Aborted uploads are
+	// replaced by the next reconcile. Uploads are aborted right away if 0.
+	ShutdownGracePeriod time.Duration
+
+	// OperationTimeout, when set, bounds every import into and deletion from
+	// a single location. The provider task is aborted once it expires, the
+	// NodeImage is marked as Error and retried with the failure backoff.
+	OperationTimeout time.Duration
+
+	// ExistsCacheTTL is how long an image found in a location is trusted to
+	// still be there before the provider is asked again. Disabled if 0.
+	ExistsCacheTTL time.Duration
+
+	// DisableFinalizer skips adding NodeImageFinalizer, for test and dev
+	// environments whose providers may be gone before their NodeImages. The
+	// images of NodeImages the controller deletes are deleted inline on a
+	// best-effort basis; a NodeImage deleted by anyone else leaves its images
+	// behind. NodeImages still carrying the finalizer are released even if
+	// their images can't be deleted.
+	DisableFinalizer bool
+
+	// connectivity tracks the providers that are currently unreachable
+	connectivity connectivityTracker
+
+	// existsCache remembers the images recently found in a location
+	existsCache existsCache
+
+	// resync enqueues the NodeImages sent to it, see Resync
+	resync chan event.GenericEvent
+
+	// now returns the current time, overridden in tests
+	now func() time.Time
+
+	// statusMu serializes status writes, which the per-location workers of a
+	// single reconcile issue concurrently against the same object.
+	statusMu sync.Mutex
 }
 
 // +kubebuilder:rbac:groups=image.giantswarm.io,resources=nodeimages,verbs=get;list;watch;create;update;patch;delete
@@ -59,25 +193,28 @@ type NodeImageReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.20.0/pkg/reconcile
 func (r *NodeImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
-
 	// Fetch the NodeImage instance
 	nodeImage := &imagev1alpha1.NodeImage{}
 	if err := r.Get(ctx, req.NamespacedName, nodeImage); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// every log line below, including those of the providers, names the
+	// node image
+	ctx = provider.WithLogValues(ctx, provider.LogKeyNodeImage, nodeImage.Name, provider.LogKeyProvider, nodeImage.Spec.Provider)
+	log := log.FromContext(ctx)
+
 	if IsDeleted(nodeImage) {
 		return r.handleDeletion(ctx, nodeImage)
 	}
 
 	// Add finalizer
-	if !controllerutil.ContainsFinalizer(nodeImage, NodeImageFinalizer) {
+	if !r.DisableFinalizer && !controllerutil.ContainsFinalizer(nodeImage, NodeImageFinalizer) {
 		controllerutil.AddFinalizer(nodeImage, NodeImageFinalizer)
 		if err := r.Update(ctx, nodeImage); err != nil {
 			return ctrl.Result{}, err
 		}
-		log.Info("Finalizer added to NodeImage", "finalizer", NodeImageFinalizer, "nodeImage", nodeImage.Name)
+		log.Info("Finalizer added to NodeImage", "finalizer", NodeImageFinalizer)
 	}
 
 	if result, handled, err := r.handleAwaitingDeletion(ctx, nodeImage); handled {
@@ -88,12 +225,28 @@ func (r *NodeImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return result, err
 	}
 
+	if _, err := r.bucketOverride(nodeImage); err != nil {
+		log.Info("S3 bucket not allowed - skipping NodeImage reconciliation", "reason", err.Error())
+		if err := r.updateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError, metav1.Condition{
+			Type:               imagev1alpha1.NodeImageConditionDistributed,
+			Status:             metav1.ConditionFalse,
+			Reason:             imagev1alpha1.NodeImageReasonBucketNotAllowed,
+			Message:            err.Error(),
+			ObservedGeneration: nodeImage.Generation,
+		}); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update status for bucket not allowed: %w", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// Get the URL of the image
-	imageKey := image.GetImageKey(nodeImage)
-	url := r.S3Client.GetURL(imageKey)
+	url, err := r.imageURL(nodeImage)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
 
 	// Check if the url is valid
-	if err := r.S3Client.ValidURL(url); err != nil {
+	if err := r.ObjectStore.ValidURL(url); err != nil {
 		log.Info("Invalid URL", "url", url)
 		return ctrl.Result{}, fmt.Errorf("invalid URL: %s", url)
 	}
@@ -101,60 +254,250 @@ func (r *NodeImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	// Get the provider for this NodeImage
 	prov, ok := r.Providers[nodeImage.Spec.Provider]
 	if !ok {
-		log.Info("Provider not configured - skipping NodeImage reconciliation", "provider", nodeImage.Spec.Provider, "nodeImage", nodeImage.Name)
+		log.Info("Provider not configured - skipping NodeImage reconciliation")
 		// Mark as error to indicate configuration issue
 		// This gives users visibility that the provider needs to be configured
-		if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError); err != nil {
+		if err := r.updateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError, metav1.Condition{
+			Type:               imagev1alpha1.NodeImageConditionDistributed,
+			Status:             metav1.ConditionFalse,
+			Reason:             imagev1alpha1.NodeImageReasonUnsupportedProvider,
+			Message:            fmt.Sprintf("Provider %q is not configured", nodeImage.Spec.Provider),
+			ObservedGeneration: nodeImage.Generation,
+		}); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to update status for unconfigured provider: %w", err)
 		}
 		return ctrl.Result{}, nil
 	}
 
+	locations, err := imageLocations(nodeImage, prov)
+	if err != nil {
+		log.Info("Unknown locations - skipping NodeImage reconciliation", "reason", err.Error())
+		if err := r.updateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError, metav1.Condition{
+			Type:               imagev1alpha1.NodeImageConditionDistributed,
+			Status:             metav1.ConditionFalse,
+			Reason:             imagev1alpha1.NodeImageReasonUnknownLocation,
+			Message:            err.Error(),
+			ObservedGeneration: nodeImage.Generation,
+		}); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update status for unknown locations: %w", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if retryAfter, ok := r.connectivity.allow(nodeImage.Spec.Provider, r.currentTime(), r.providerProbeInterval()); !ok {
+		log.Info("Provider unreachable - skipping NodeImage reconciliation")
+		return r.providerUnavailable(ctx, nodeImage, retryAfter)
+	}
+
+	if r.verificationPending(nodeImage) {
+		return r.verify(ctx, nodeImage, locations, prov)
+	}
+
+	// check if the image is available where the locations import it from
+	switch result, checkedURL, err := r.checkAvailability(ctx, nodeImage, url, locations, prov); result {
+	case httpcheck.Available:
+	case httpcheck.NotFound:
+		log.Info("Image not available on S3 - marking as missing", "url", checkedURL, "response", err)
+		if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageMissing); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+		}
+		return r.periodicRequeue(), nil
+	default:
+		// an image that can't be checked right now is not known to be missing
+		log.Info("Failed to check image availability on S3 - retrying later", "url", checkedURL, "response", err)
+		return r.periodicRequeue(), nil
+	}
+
+	if err := r.setSourceURL(ctx, nodeImage, url); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return r.distribute(ctx, nodeImage, url, locations, prov)
+}
+
+// distribute creates the image in the locations of the provider. A provider
+// unreachable in all of them pauses the reconciles of its NodeImages instead
+// of marking each of them as Error.
+func (r *NodeImageReconciler) distribute(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, url string, locations []string, prov provider.Provider) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if err := r.resetInterruptedUpload(ctx, nodeImage, locations, prov); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the transition the distribution makes is notified once all locations
+	// are done, with the result of each of them
+	previous := nodeImage.Status.State
+	results := &locationResults{}
+	defer r.notifyDistribution(ctx, nodeImage, previous, results)
+	ctx = withDistribution(ctx)
+
 	// Process image for all locations in the provider
-	for loc := range prov.GetLocations() {
-		// check if the image is available
-		if err := ImageAvailable(url); err != nil {
-			log.Info("Image not available on S3 - marking as missing", "url", url, "response", err)
-			if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageMissing); err != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
-			}
-			return DefaultRequeue(), nil
+	var unreachable atomic.Int32
+	if err := r.forEachImageLocation(nodeImage, locations, func(worker *imagev1alpha1.NodeImage, loc string) error {
+		state, err := r.CreateProvider(ctx, worker, nodeImage, url, loc, prov)
+		results.record(loc, state, err)
+		if isConnectivityError(err) {
+			unreachable.Add(1)
 		}
-		if err := r.CreateProvider(ctx, nodeImage, url, loc, prov); err != nil {
-			if statusErr := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError); statusErr != nil {
+		return err
+	}); err != nil {
+		if errors.Is(err, errImageMissing) {
+			if statusErr := r.markMissing(ctx, nodeImage); statusErr != nil {
 				return ctrl.Result{}, fmt.Errorf("failed to create node image: %w\nfailed to update status: %w", err, statusErr)
 			}
-			return ctrl.Result{}, err
+			log.Info("Image not found in S3 bucket - marked as missing", "error", err.Error())
+			return r.periodicRequeue(), nil
 		}
+		if int(unreachable.Load()) == len(locations) {
+			if r.connectivity.lost(nodeImage.Spec.Provider, r.currentTime(), r.providerProbeInterval()) {
+				log.Error(err, "Provider unreachable in all locations - pausing reconciliation of its NodeImages")
+			}
+			return r.providerUnavailable(ctx, nodeImage, r.providerProbeInterval())
+		}
+		if reachableErr := r.providerReachable(ctx, nodeImage); reachableErr != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to create node image: %w\nfailed to update status: %w", err, reachableErr)
+		}
+		if statusErr := r.recordFailure(ctx, nodeImage); statusErr != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to create node image: %w\nfailed to update status: %w", err, statusErr)
+		}
+		if statusErr := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError); statusErr != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to create node image: %w\nfailed to update status: %w", err, statusErr)
+		}
+		// requeue with our own backoff instead of returning the error, so
+		// the retries follow the consecutive failures in the status
+		result := r.failureRequeue(nodeImage)
+		log.Error(err, "Failed to create node image", "failures", nodeImage.Status.ConsecutiveFailures, "retryAfter", result.RequeueAfter)
+		return result, nil
 	}
 
-	return DefaultRequeue(), nil
+	if err := r.providerReachable(ctx, nodeImage); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the locations finish in any order, the NodeImage is only Available
+	// once none of them is still scheduled
+	if err := r.UpdateStatus(ctx, nodeImage, results.state()); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.markReconciled(ctx, nodeImage); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if nodeImage.Status.State == imagev1alpha1.NodeImageScheduled {
+		return r.scheduledRequeue(), nil
+	}
+
+	if err := r.clearForceReupload(ctx, nodeImage); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if r.verificationPending(nodeImage) {
+		return ctrl.Result{RequeueAfter: r.VerificationDelay}, nil
+	}
+
+	return r.periodicRequeue(), nil
+}
+
+// verificationPending reports whether the image was uploaded and still has to
+// be verified.
+func (r *NodeImageReconciler) verificationPending(nodeImage *imagev1alpha1.NodeImage) bool {
+	if r.VerificationDelay <= 0 || r.DryRun {
+		return false
+	}
+	condition := meta.FindStatusCondition(nodeImage.Status.Conditions, imagev1alpha1.NodeImageConditionVerified)
+	return condition != nil && condition.Status == metav1.ConditionUnknown
+}
+
+// verify checks that a freshly uploaded image is present in every location.
+// Nothing is uploaded during verification, a missing image marks the
+// NodeImage as Error so the next reconcile uploads it again.
+func (r *NodeImageReconciler) verify(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, locations []string, prov provider.Provider) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	err := r.forEachLocation(locations, func(loc string) error {
+		name, err := r.providerImageName(nodeImage, loc, prov)
+		if err != nil {
+			return err
+		}
+		// always ask the provider, verification must not trust the cache
+		key := existsKey(nodeImage.Spec.Provider, loc, name)
+		existsCtx := provider.WithImageID(provider.WithLogValues(ctx, provider.LogKeyLocation, loc), locationImageID(nodeImage, loc))
+		exists, err := prov.Exists(existsCtx, name, loc)
+		if err != nil {
+			r.existsCache.forget(key)
+			return fmt.Errorf("failed to check if image exists: %w", err)
+		}
+		if !exists {
+			r.existsCache.forget(key)
+			return fmt.Errorf("uploaded image %s not present", name)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Info("Node image verification failed", "reason", err.Error())
+		if statusErr := r.updateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError, metav1.Condition{
+			Type:               imagev1alpha1.NodeImageConditionVerified,
+			Status:             metav1.ConditionFalse,
+			Reason:             imagev1alpha1.NodeImageReasonNotPresent,
+			Message:            err.Error(),
+			ObservedGeneration: nodeImage.Generation,
+		}); statusErr != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to verify node image: %w\nfailed to update status: %w", err, statusErr)
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to verify node image: %w", err)
+	}
+
+	log.Info("Node image verified")
+	if err := r.updateStatus(ctx, nodeImage, imagev1alpha1.NodeImageAvailable, metav1.Condition{
+		Type:               imagev1alpha1.NodeImageConditionVerified,
+		Status:             metav1.ConditionTrue,
+		Reason:             imagev1alpha1.NodeImageReasonPresent,
+		Message:            "Uploaded image present in all locations",
+		ObservedGeneration: nodeImage.Generation,
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.markReconciled(ctx, nodeImage); err != nil {
+		return ctrl.Result{}, err
+	}
+	return r.periodicRequeue(), nil
 }
 
 func (r *NodeImageReconciler) handleDeletion(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
-	log.Info("NodeImage is being deleted", "nodeImage", nodeImage.Name)
+	log.Info("NodeImage is being deleted")
 
 	prov, ok := r.Providers[nodeImage.Spec.Provider]
 	if !ok {
-		log.Info("Provider not configured - skipping deletion", "provider", nodeImage.Spec.Provider)
+		log.Info("Provider not configured - skipping deletion")
 		if controllerutil.ContainsFinalizer(nodeImage, NodeImageFinalizer) {
 			controllerutil.RemoveFinalizer(nodeImage, NodeImageFinalizer)
 			if err := r.Update(ctx, nodeImage); err != nil {
 				return ctrl.Result{}, err
 			}
-			log.Info("Finalizer removed from NodeImage", "finalizer", NodeImageFinalizer, "nodeImage", nodeImage.Name)
+			log.Info("Finalizer removed from NodeImage", "finalizer", NodeImageFinalizer)
 		}
 		return ctrl.Result{}, nil
 	}
 
-	for loc := range prov.GetLocations() {
-		if err := r.DeleteProvider(ctx, nodeImage, loc, prov); err != nil {
-			if statusErr := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError); statusErr != nil {
-				return ctrl.Result{}, fmt.Errorf("failed to delete node image: %w\nfailed to update status: %w", err, statusErr)
-			}
-			return ctrl.Result{}, err
+	// set the status
+	if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageDeleting); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.forEachImageLocation(nodeImage, deletionLocations(nodeImage, prov), func(worker *imagev1alpha1.NodeImage, loc string) error {
+		return r.DeleteProvider(ctx, worker, nodeImage, loc, prov)
+	}); err != nil && r.DisableFinalizer {
+		log.Error(err, "Failed to delete node image from provider - releasing the finalizer anyway")
+	} else if err != nil {
+		if statusErr := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageError); statusErr != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to delete node image: %w\nfailed to update status: %w", err, statusErr)
 		}
+		return ctrl.Result{}, err
+	} else if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageDeleted); err != nil {
+		return ctrl.Result{}, err
 	}
 
 	if controllerutil.ContainsFinalizer(nodeImage, NodeImageFinalizer) {
@@ -162,7 +505,7 @@ func (r *NodeImageReconciler) handleDeletion(ctx context.Context, nodeImage *ima
 		if err := r.Update(ctx, nodeImage); err != nil {
 			return ctrl.Result{}, err
 		}
-		log.Info("Finalizer removed from NodeImage", "finalizer", NodeImageFinalizer, "nodeImage", nodeImage.Name)
+		log.Info("Finalizer removed from NodeImage", "finalizer", NodeImageFinalizer)
 	}
 	return ctrl.Result{}, nil
 }
@@ -188,12 +531,12 @@ func (r *NodeImageReconciler) handleAwaitingDeletion(ctx context.Context, nodeIm
 
 	expirationTime := lastUsedTime.Add(r.ImageRetentionPeriod)
 	if time.Now().After(expirationTime) {
-		log.Info("Image retention period expired - deleting NodeImage", "nodeImage", nodeImage.Name)
-		return ctrl.Result{}, true, r.Delete(ctx, nodeImage)
+		log.Info("Image retention period expired - deleting NodeImage")
+		return ctrl.Result{}, true, r.deleteNodeImage(ctx, nodeImage)
 	}
 
 	requeueAfter := time.Until(expirationTime)
-	log.Info("Image awaiting deletion", "nodeImage", nodeImage.Name, "requeueAfter", requeueAfter)
+	log.Info("Image awaiting deletion", "requeueAfter", requeueAfter)
 	return ctrl.Result{RequeueAfter: requeueAfter}, true, nil
 }
 
@@ -218,113 +561,843 @@ func (r *NodeImageReconciler) handleNoReleases(ctx context.Context, nodeImage *i
 		if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageAwaitingDeletion); err != nil {
 			return ctrl.Result{}, true, err
 		}
-		log.Info("No releases reference this image - marking for deletion", "nodeImage", nodeImage.Name, "retentionPeriod", r.ImageRetentionPeriod)
+		log.Info("No releases reference this image - marking for deletion", "retentionPeriod", r.ImageRetentionPeriod)
 		return ctrl.Result{RequeueAfter: r.ImageRetentionPeriod}, true, nil
 	}
 
-	log.Info("No releases reference this image - deleting", "nodeImage", nodeImage.Name)
-	return ctrl.Result{}, true, r.Delete(ctx, nodeImage)
+	log.Info("No releases reference this image - deleting")
+	return ctrl.Result{}, true, r.deleteNodeImage(ctx, nodeImage)
 }
 
-func (r *NodeImageReconciler) CreateProvider(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, url string, loc string, prov provider.Provider) error {
+// imageLocations returns the locations of the provider the NodeImage is
+// distributed to, spec.locations or all of them if it is empty. Locations the
+// provider doesn't have are an error naming them.
+func imageLocations(nodeImage *imagev1alpha1.NodeImage, prov provider.Provider) ([]string, error) {
+	configured := prov.GetLocations()
+	if len(nodeImage.Spec.Locations) == 0 {
+		locations := make([]string, 0, len(configured))
+		for loc := range configured {
+			locations = append(locations, loc)
+		}
+		sort.Strings(locations)
+		return locations, nil
+	}
+
+	var unknown []string
+	for _, loc := range nodeImage.Spec.Locations {
+		if _, ok := configured[loc]; !ok {
+			unknown = append(unknown, loc)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("locations %s are not configured for provider %s", strings.Join(unknown, ", "), nodeImage.Spec.Provider)
+	}
+	return slices.Compact(slices.Sorted(slices.Values(nodeImage.Spec.Locations))), nil
+}
+
+// deletionLocations returns the locations the image is deleted from: those
+// the NodeImage is distributed to and those it was recorded in before
+// spec.locations changed. Unknown locations are skipped, nothing can have
+// been created there.
+func deletionLocations(nodeImage *imagev1alpha1.NodeImage, prov provider.Provider) []string {
+	configured := prov.GetLocations()
+	if len(nodeImage.Spec.Locations) == 0 {
+		locations, _ := imageLocations(nodeImage, prov)
+		return locations
+	}
+
+	locations := slices.Clone(nodeImage.Spec.Locations)
+	for _, location := range nodeImage.Status.Locations {
+		locations = append(locations, location.Name)
+	}
+	locations = slices.DeleteFunc(locations, func(loc string) bool {
+		_, ok := configured[loc]
+		return !ok
+	})
+	return slices.Compact(slices.Sorted(slices.Values(locations)))
+}
+
+// forEachLocation calls fn for every location, running at most
+// LocationConcurrency calls at a time. A failing location does not stop the
+// others; the returned error names every location that failed.
+func (r *NodeImageReconciler) forEachLocation(locations []string, fn func(loc string) error) error {
+	concurrency := r.LocationConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultLocationConcurrency
+	}
+
+	var mu sync.Mutex
+	failed := make(map[string]error)
+
+	g := errgroup.Group{}
+	g.SetLimit(concurrency)
+	for _, loc := range locations {
+		g.Go(func() error {
+			if err := fn(loc); err != nil {
+				mu.Lock()
+				failed[loc] = err
+				mu.Unlock()
+			}
+			// never fail the group, so the remaining locations still run
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return joinLocationErrors(failed)
+}
+
+// joinLocationErrors aggregates per-location errors into a single error that
+// lists the failed locations in a stable order.
+func joinLocationErrors(failed map[string]error) error {
+	if len(failed) == 0 {
+		return nil
+	}
+
+	locations := make([]string, 0, len(failed))
+	for loc := range failed {
+		locations = append(locations, loc)
+	}
+	sort.Strings(locations)
+
+	errs := make([]error, 0, len(locations))
+	for _, loc := range locations {
+		errs = append(errs, fmt.Errorf("location %s: %w", loc, failed[loc]))
+	}
+	return fmt.Errorf("failed in locations %s: %w", strings.Join(locations, ", "), errors.Join(errs...))
+}
+
+// CreateProvider creates the image in the location and returns the state it
+// left the location in. The image is read from nodeImage, while the status of
+// the location is written to shared, the NodeImage all locations of the
+// distribution write to; see forEachImageLocation. The state of the NodeImage
+// itself is left to the caller.
+func (r *NodeImageReconciler) CreateProvider(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, shared *imagev1alpha1.NodeImage, url string, loc string, prov provider.Provider) (state imagev1alpha1.NodeImageState, err error) {
+	ctx = provider.WithLogValues(ctx, provider.LogKeyLocation, loc)
 	log := log.FromContext(ctx)
 
-	// check if the image is already uploaded
-	if exists, err := prov.Exists(ctx, nodeImage.Spec.Name, loc); err != nil {
-		return fmt.Errorf("failed to check if image exists: %w", err)
+	name, err := r.providerImageName(nodeImage, loc, prov)
+	if err != nil {
+		return "", err
+	}
+
+	key := existsKey(nodeImage.Spec.Provider, loc, name)
+	defer func() {
+		if err != nil {
+			r.existsCache.forget(key)
+		}
+	}()
+
+	// the image is looked up by the ID recorded for it, and the ID the
+	// provider reports for the image found or created is recorded instead
+	imageID := locationImageID(nodeImage, loc)
+	ctx = provider.WithImageIDReport(provider.WithImageID(ctx, imageID), func(id string) {
+		imageID = id
+	})
+
+	// check if the image is already uploaded, unless it has to be uploaded
+	// again anyway
+	force := forceReupload(nodeImage, loc)
+	if force {
+		log.Info("Reupload of node image forced")
+	} else if exists, err := r.imageExists(ctx, key, name, loc, prov); err != nil {
+		return "", fmt.Errorf("failed to check if image exists: %w", err)
 	} else if exists {
 		// set the status
-		return r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageAvailable)
+		if err := r.recordLocation(ctx, shared, loc, locationImageName(prov, name, loc), imageID); err != nil {
+			return "", err
+		}
+		return imagev1alpha1.NodeImageAvailable, r.markDistributed(ctx, nodeImage, shared, loc, imagev1alpha1.NodeImageReasonAlreadyPresent)
 	}
 
-	log.Info("Node image not found, uploading", "nodeImage", nodeImage.Name, "location", loc)
+	// only upload inside of the distribution window
+	if !r.DistributionWindow.Contains(r.currentTime()) {
+		log.Info("Node image not found, outside of distribution window - upload scheduled", "window", r.DistributionWindow.String())
+		return imagev1alpha1.NodeImageScheduled, r.setLocationState(ctx, shared, loc, imagev1alpha1.NodeImageScheduled, "")
+	}
+
+	// the provider fetches the image itself and fails with a confusing error
+	// if it is not there
+	if err := r.verifyS3Object(ctx, nodeImage, shared, loc, prov); err != nil {
+		return "", err
+	}
+
+	// replace the image only once it is certain the upload goes ahead
+	if force {
+		if err := r.deleteForReupload(ctx, key, name, loc, prov); err != nil {
+			return "", err
+		}
+	}
+
+	// the ID of the image is the one reported by Create, if any
+	imageID = ""
+
+	log.Info("Node image not found, uploading")
 
 	// set the status
-	if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageUploading); err != nil {
-		return err
+	if err := r.setLocationState(ctx, shared, loc, imagev1alpha1.NodeImageUploading, ""); err != nil {
+		return "", err
 	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// the status is written even if the reconcile context is cancelled
+		if statusErr := r.setLocationState(context.WithoutCancel(ctx), shared, loc, imagev1alpha1.NodeImageError, err.Error()); statusErr != nil {
+			err = fmt.Errorf("%w\nfailed to update status: %w", err, statusErr)
+		}
+	}()
 
-	// import the image
-	err := prov.Create(ctx, url, nodeImage.Spec.Name, loc)
+	// an upload in flight when the operator shuts down gets the shutdown
+	// grace period to complete, otherwise it is recorded as aborted
+	reconcileCtx := ctx
+	ctx, stop := r.uploadContext(ctx)
+	defer stop()
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = r.uploadAborted(reconcileCtx, nodeImage, shared, loc, err)
+		}
+	}()
+
+	// import the image, reporting its progress in the status of the location
+	// and its provider task in the status, and telling the provider where it
+	// comes from
+	reportTask, clearTask := r.providerTask(ctx, shared, loc)
+	uploadCtx := provider.WithProgress(ctx, r.uploadProgress(ctx, shared, loc))
+	uploadCtx = provider.WithTask(uploadCtx, reportTask)
+	uploadCtx = provider.WithProvenance(uploadCtx, provider.Provenance{
+		NodeImage:       nodeImage.Name,
+		Image:           name,
+		Releases:        nodeImage.Status.Releases,
+		OperatorVersion: project.Version(),
+		Created:         r.currentTime(),
+	})
+	locationURL, err := r.locationURL(nodeImage, url, loc, prov)
 	if err != nil {
-		return fmt.Errorf("failed to import image: %w", err)
+		return "", err
+	}
+	createCtx, cancel := r.operationContext(uploadCtx)
+	defer cancel()
+	if err := prov.Create(createCtx, locationURL, name, loc); err != nil {
+		if timedOut(createCtx) {
+			return "", r.uploadTimedOut(ctx, nodeImage, shared, loc, err)
+		}
+		return "", fmt.Errorf("failed to import image: %w", err)
 	}
+	clearTask()
 
-	log.Info("Node image uploaded and processed", "nodeImage", nodeImage.Name, "location", loc)
+	if err := prov.Process(ctx, name, loc); err != nil {
+		return "", fmt.Errorf("failed to process image: %w", err)
+	}
+
+	if err := r.waitForReady(ctx, name, loc, prov); err != nil {
+		return "", err
+	}
+
+	log.Info("Node image uploaded and processed")
+	r.rememberExists(key)
 
 	// set the status
-	return r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageAvailable)
+	if err := r.recordLocation(ctx, shared, loc, locationImageName(prov, name, loc), imageID); err != nil {
+		return "", err
+	}
+	return imagev1alpha1.NodeImageAvailable, r.markDistributed(ctx, nodeImage, shared, loc, imagev1alpha1.NodeImageReasonUploaded)
 }
 
-func (r *NodeImageReconciler) DeleteProvider(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, loc string, prov provider.Provider) error {
+// imageExists reports whether the image exists in the location, asking the
+// provider only if it wasn't found there within the exists cache TTL
+func (r *NodeImageReconciler) imageExists(ctx context.Context, key string, name string, loc string, prov provider.Provider) (bool, error) {
+	if r.ExistsCacheTTL > 0 && r.existsCache.fresh(key, r.currentTime()) {
+		return true, nil
+	}
+
+	exists, err := prov.Exists(ctx, name, loc)
+	if err != nil || !exists {
+		return exists, err
+	}
+	r.rememberExists(key)
+	return true, nil
+}
+
+// rememberExists records the image as present for the exists cache TTL
+func (r *NodeImageReconciler) rememberExists(key string) {
+	if r.ExistsCacheTTL > 0 {
+		r.existsCache.remember(key, r.currentTime().Add(r.ExistsCacheTTL))
+	}
+}
+
+// defaultReadinessInterval is how often readiness is polled after an upload
+const defaultReadinessInterval = 10 * time.Second
+
+// waitForReady polls the provider until the uploaded image is ready or the
+// readiness timeout expires. Providers without a readiness check are polled
+// for the image to exist.
+func (r *NodeImageReconciler) waitForReady(ctx context.Context, name string, loc string, prov provider.Provider) error {
+	if r.ReadinessTimeout <= 0 || r.DryRun {
+		return nil
+	}
 	log := log.FromContext(ctx)
 
-	// set the status
-	if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageDeleting); err != nil {
-		return err
+	ready := prov.Exists
+	if checker, ok := prov.(provider.ReadinessChecker); ok {
+		ready = checker.Ready
 	}
 
-	// delete the image
-	if err := prov.Delete(ctx, nodeImage.Spec.Name, loc); err != nil {
-		return fmt.Errorf("failed to delete image: %w", err)
+	interval := r.readinessInterval
+	if interval <= 0 {
+		interval = defaultReadinessInterval
 	}
 
-	log.Info("Node image deleted", "nodeImage", nodeImage.Name, "location", loc)
+	err := wait.PollUntilContextTimeout(ctx, interval, r.ReadinessTimeout, true, func(ctx context.Context) (bool, error) {
+		ok, err := ready(ctx, name, loc)
+		if err != nil {
+			// keep polling, the provider may only be briefly unavailable
+			log.Info("Failed to check node image readiness", "name", name, "error", err.Error())
+			return false, nil
+		}
+		return ok, nil
+	})
+	if err != nil {
+		return fmt.Errorf("image %s not ready within %s: %w", name, r.ReadinessTimeout, err)
+	}
+	return nil
+}
 
-	// set the status
-	return r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageDeleted)
+// providerImageName returns the name of the image in the provider location,
+// checked against the provider's name length limit.
+func (r *NodeImageReconciler) providerImageName(nodeImage *imagev1alpha1.NodeImage, loc string, prov provider.Provider) (string, error) {
+	limiter, ok := prov.(provider.NameLimiter)
+	if !ok {
+		return nodeImage.Spec.Name, nil
+	}
+
+	name, err := image.FitName(nodeImage.Spec.Name, limiter.MaxNameLength(loc), r.TruncateLongNames)
+	if err != nil {
+		return "", fmt.Errorf("invalid image name for location %s: %w", loc, err)
+	}
+	return name, nil
+}
+
+// locationImageName returns the name the image has in the location, which
+// differs from name if the provider names images per location
+func locationImageName(prov provider.Provider, name string, loc string) string {
+	if namer, ok := prov.(provider.ImageNamer); ok {
+		return namer.ImageName(name, loc)
+	}
+	return name
+}
+
+// statusLocation returns the location recorded in the status, one with only
+// the name set if there is none
+func statusLocation(nodeImage *imagev1alpha1.NodeImage, loc string) imagev1alpha1.NodeImageLocation {
+	for _, location := range nodeImage.Status.Locations {
+		if location.Name == loc {
+			return location
+		}
+	}
+	return imagev1alpha1.NodeImageLocation{Name: loc}
+}
+
+// locationImageID returns the provider's ID of the image recorded for the
+// location in the status, empty if there is none
+func locationImageID(nodeImage *imagev1alpha1.NodeImage, loc string) string {
+	return statusLocation(nodeImage, loc).ProviderImageID
+}
+
+// recordLocation records the name and provider ID of the image in the
+// location in the status, so it is visible what was created where, and marks
+// the location Available. The status is only written if the location is new
+// or changed.
+func (r *NodeImageReconciler) recordLocation(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, loc string, imageName string, imageID string) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	return r.writeLocation(ctx, nodeImage, imagev1alpha1.NodeImageLocation{
+		Name:            loc,
+		ImageName:       imageName,
+		ProviderImageID: imageID,
+		State:           imagev1alpha1.NodeImageAvailable,
+	})
+}
+
+// setLocationState sets the state of the image in the location and the
+// message on it in the status, e.g. the progress of its upload. The status is
+// only written if either of them changed.
+func (r *NodeImageReconciler) setLocationState(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, loc string, state imagev1alpha1.NodeImageState, message string) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	location := statusLocation(nodeImage, loc)
+	location.State = state
+	location.Message = message
+	return r.writeLocation(ctx, nodeImage, location)
+}
+
+// writeLocation replaces the location in the status and writes it if the
+// location changed. It must be called while holding statusMu.
+func (r *NodeImageReconciler) writeLocation(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, location imagev1alpha1.NodeImageLocation) error {
+	if slices.Contains(nodeImage.Status.Locations, location) {
+		return nil
+	}
+
+	locations := slices.DeleteFunc(nodeImage.Status.Locations, func(existing imagev1alpha1.NodeImageLocation) bool {
+		return existing.Name == location.Name
+	})
+	locations = append(locations, location)
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+	nodeImage.Status.Locations = locations
+
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// forgetLocation removes the location from the status once the image was
+// deleted from it
+func (r *NodeImageReconciler) forgetLocation(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, loc string) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	locations := slices.DeleteFunc(slices.Clone(nodeImage.Status.Locations), func(location imagev1alpha1.NodeImageLocation) bool {
+		return location.Name == loc
+	})
+	if len(locations) == len(nodeImage.Status.Locations) {
+		return nil
+	}
+	nodeImage.Status.Locations = locations
+
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// markDistributed records in the Distributed condition of shared why the
+// image is in the location. An image found already present only counts as a
+// skipped upload if it was not distributed before, so the periodic existence
+// checks of an available image don't show up as skips.
+func (r *NodeImageReconciler) markDistributed(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, shared *imagev1alpha1.NodeImage, loc string, reason string) error {
+	r.statusMu.Lock()
+	distributed := meta.IsStatusConditionTrue(shared.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+	r.statusMu.Unlock()
+
+	message := fmt.Sprintf("Image uploaded to location %s", loc)
+	result := uploadResultUploaded
+	if reason == imagev1alpha1.NodeImageReasonAlreadyPresent {
+		if distributed {
+			return nil
+		}
+		message = fmt.Sprintf("Image already present in location %s, upload skipped", loc)
+		result = uploadResultSkipped
+	}
+	uploadsTotal.WithLabelValues(nodeImage.Spec.Provider, loc, result).Inc()
+
+	conditions := []metav1.Condition{{
+		Type:               imagev1alpha1.NodeImageConditionDistributed,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: nodeImage.Generation,
+	}}
+	if reason == imagev1alpha1.NodeImageReasonUploaded && r.VerificationDelay > 0 && !r.DryRun {
+		conditions = append(conditions, metav1.Condition{
+			Type:               imagev1alpha1.NodeImageConditionVerified,
+			Status:             metav1.ConditionUnknown,
+			Reason:             imagev1alpha1.NodeImageReasonVerificationPending,
+			Message:            fmt.Sprintf("Image uploaded to location %s, verifying in %s", loc, r.VerificationDelay),
+			ObservedGeneration: nodeImage.Generation,
+		})
+	}
+	return r.setConditions(ctx, shared, conditions...)
+}
+
+// DeleteProvider deletes the image from the location. Like CreateProvider it
+// reads the image from nodeImage and writes the status of the location to
+// shared, leaving the state of the NodeImage to the caller.
+func (r *NodeImageReconciler) DeleteProvider(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, shared *imagev1alpha1.NodeImage, loc string, prov provider.Provider) error {
+	ctx = provider.WithLogValues(ctx, provider.LogKeyLocation, loc)
+	log := log.FromContext(ctx)
+
+	// an image whose name exceeds the provider limit can never have been created
+	name, err := r.providerImageName(nodeImage, loc, prov)
+	if err != nil {
+		log.Info("Node image name not valid in location, nothing to delete", "reason", err.Error())
+		return nil
+	}
+
+	// delete the image, keeping the location in the status until it is
+	// gone so it shows what blocks the finalizer. The provider is told which
+	// NodeImage the image belongs to, to find copies of it under other names.
+	imageID := locationImageID(nodeImage, loc)
+	r.existsCache.forget(existsKey(nodeImage.Spec.Provider, loc, name))
+	deleteCtx := provider.WithProvenance(provider.WithImageID(ctx, imageID), provider.Provenance{
+		NodeImage: nodeImage.Name,
+		Image:     name,
+		Releases:  nodeImage.Status.Releases,
+	})
+	deleteCtx, cancel := r.operationContext(deleteCtx)
+	defer cancel()
+	if err := prov.Delete(deleteCtx, name, loc); err != nil {
+		if timedOut(deleteCtx) {
+			err = fmt.Errorf("timed out after %s: %w", r.OperationTimeout, err)
+		}
+		if recordErr := r.recordLocation(ctx, shared, loc, locationImageName(prov, name, loc), imageID); recordErr != nil {
+			return fmt.Errorf("failed to delete image: %w\n%w", err, recordErr)
+		}
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+
+	log.Info("Node image deleted")
+	return r.forgetLocation(ctx, shared, loc)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NodeImageReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.resync = make(chan event.GenericEvent)
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&imagev1alpha1.NodeImage{}).
+		WatchesRawSource(source.Channel(r.resync, &handler.EnqueueRequestForObject{})).
 		Named("image-nodeimage").
 		Complete(r)
 }
 
 func (r *NodeImageReconciler) UpdateStatus(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, state imagev1alpha1.NodeImageState) error {
+	return r.updateStatus(ctx, nodeImage, state)
+}
+
+// updateStatus sets the state and the given conditions, and writes the
+// status if any of them changed.
+func (r *NodeImageReconciler) updateStatus(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, state imagev1alpha1.NodeImageState, conditions ...metav1.Condition) error {
 	log := log.FromContext(ctx)
-	if nodeImage.Status.State != state {
-		nodeImage.Status.State = state
-		if err := r.Status().Update(ctx, nodeImage); err != nil {
-			return fmt.Errorf("failed to update status: %w", err)
+	r.statusMu.Lock()
+	previous := nodeImage.Status.State
+	nodeImage.Status.State = state
+	changed := previous != state
+	if changed {
+		// the message describes the previous state
+		nodeImage.Status.LastTransitionTime = metav1.NewTime(r.currentTime())
+		nodeImage.Status.Message = ""
+	}
+	for _, condition := range conditions {
+		if meta.SetStatusCondition(&nodeImage.Status.Conditions, condition) {
+			changed = true
 		}
-		log.Info("Node image status updated", "nodeImage", nodeImage.Name, "state", nodeImage.Status.State)
+	}
+	if !changed {
+		r.statusMu.Unlock()
+		return nil
+	}
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		r.statusMu.Unlock()
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	event := r.transitionEvent(nodeImage, previous, state)
+	r.statusMu.Unlock()
+
+	log.Info("Node image status updated", "state", state)
+	if previous != state {
+		r.notify(ctx, event)
+	}
+	return nil
+}
+
+// setConditions sets the given conditions and writes the status if any of
+// them changed. Unlike updateStatus it leaves the state alone, which the
+// locations of a distribution leave to the distribution.
+func (r *NodeImageReconciler) setConditions(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, conditions ...metav1.Condition) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	changed := false
+	for _, condition := range conditions {
+		if meta.SetStatusCondition(&nodeImage.Status.Conditions, condition) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// markReconciled stamps the time of a successful reconcile into the status
+// and resets the consecutive failures. Unlike updateStatus it writes the
+// status while the state stays the same, but only once per periodic requeue:
+// every write triggers another reconcile, which would stamp the time again.
+func (r *NodeImageReconciler) markReconciled(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	if nodeImage.Status.ConsecutiveFailures == 0 && r.currentTime().Sub(nodeImage.Status.LastReconcileTime.Time) < r.minPeriodicRequeue() {
+		return nil
+	}
+	nodeImage.Status.ConsecutiveFailures = 0
+	nodeImage.Status.LastReconcileTime = metav1.NewTime(r.currentTime())
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
 	}
 	return nil
 }
 
+// transitionEvent builds the notification event for a state transition.
+// It must be called while holding statusMu.
+func (r *NodeImageReconciler) transitionEvent(nodeImage *imagev1alpha1.NodeImage, previous, state imagev1alpha1.NodeImageState) notify.Event {
+	return notify.Event{
+		NodeImage:     nodeImage.Name,
+		Namespace:     nodeImage.Namespace,
+		Provider:      nodeImage.Spec.Provider,
+		Image:         nodeImage.Spec.Name,
+		State:         string(state),
+		PreviousState: string(previous),
+	}
+}
+
+// notify sends the event to the configured notifier if the new state is one
+// we notify about. Failures are logged and never block the reconcile.
+func (r *NodeImageReconciler) notify(ctx context.Context, event notify.Event) {
+	if r.Notifier == nil || inDistribution(ctx) {
+		return
+	}
+	switch imagev1alpha1.NodeImageState(event.State) {
+	case imagev1alpha1.NodeImageError:
+	case imagev1alpha1.NodeImageAvailable:
+		if !r.NotifyOnAvailable {
+			return
+		}
+	default:
+		return
+	}
+
+	if err := r.Notifier.Notify(ctx, event); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to send notification", "state", event.State)
+	}
+}
+
 func IsDeleted(nodeImage *imagev1alpha1.NodeImage) bool {
 	return !nodeImage.DeletionTimestamp.IsZero()
 }
 
-func ImageAvailable(url string) error {
-	resp, err := http.Head(url) // #nosec G107
+// imageURL returns the URL of the image: spec.url if set, the URL of the
+// image in the bucket of image.S3BucketAnnotation if set, and the URL of the
+// image in the object store otherwise
+func (r *NodeImageReconciler) imageURL(nodeImage *imagev1alpha1.NodeImage) (string, error) {
+	if nodeImage.Spec.URL != "" {
+		return nodeImage.Spec.URL, nil
+	}
+	imageKey, err := r.imageKey(nodeImage)
 	if err != nil {
-		return fmt.Errorf("error checking URL: %w", err)
+		return "", err
 	}
+	bucket, err := r.bucketOverride(nodeImage)
+	if err != nil {
+		return "", err
+	}
+	if bucket != "" {
+		return r.ObjectStore.(storage.BucketStore).GetBucketURL(bucket, "", imageKey), nil
+	}
+	return r.ObjectStore.GetURL(imageKey), nil
+}
 
-	// Ensure resp.Body is closed safely
-	defer func() {
-		if resp.Body != nil {
-			if err := resp.Body.Close(); err != nil {
-				fmt.Printf("Failed to close response body: %v", err)
+// bucketOverride returns the bucket of image.S3BucketAnnotation, or "" if the
+// NodeImage doesn't set it or sets spec.url. A bucket that is not one of
+// AllowedBuckets, or an object store without buckets, fails with an error, and
+// so does a spec.url in an S3 bucket other than the object store's that is not
+// one of AllowedBuckets.
+func (r *NodeImageReconciler) bucketOverride(nodeImage *imagev1alpha1.NodeImage) (string, error) {
+	if nodeImage.Spec.URL != "" {
+		buckets, ok := r.ObjectStore.(storage.BucketStore)
+		if !ok {
+			return "", nil
+		}
+		if bucket := buckets.URLBucket(nodeImage.Spec.URL); bucket != "" && !slices.Contains(r.AllowedBuckets, bucket) {
+			return "", fmt.Errorf("S3 bucket %q is not allowed", bucket)
+		}
+		return "", nil
+	}
+
+	bucket := nodeImage.Annotations[image.S3BucketAnnotation]
+	if bucket == "" {
+		return "", nil
+	}
+	if !slices.Contains(r.AllowedBuckets, bucket) {
+		return "", fmt.Errorf("S3 bucket %q is not allowed", bucket)
+	}
+	if _, ok := r.ObjectStore.(storage.BucketStore); !ok {
+		return "", fmt.Errorf("S3 bucket %q can't be used with the object store", bucket)
+	}
+	return bucket, nil
+}
+
+// locationURL returns the URL the image is imported into the location from:
+// the URL in the S3 bucket of the location if the provider configures one and
+// the object store supports buckets per location, and url otherwise. A
+// NodeImage with spec.url or image.S3BucketAnnotation is always imported from
+// url, which is in the bucket of the annotation.
+func (r *NodeImageReconciler) locationURL(nodeImage *imagev1alpha1.NodeImage, url string, loc string, prov provider.Provider) (string, error) {
+	bucket, region := r.locationBucket(nodeImage, loc, prov)
+	if bucket == "" {
+		return url, nil
+	}
+	imageKey, err := r.imageKey(nodeImage)
+	if err != nil {
+		return "", err
+	}
+	return r.ObjectStore.(storage.BucketStore).GetBucketURL(bucket, region, imageKey), nil
+}
+
+// locationBucket returns the S3 bucket and region the image is imported into
+// the location from: the bucket of image.S3BucketAnnotation, which Reconcile
+// has checked against AllowedBuckets, or the bucket of the location. It
+// returns "" if the image is imported from the object store's bucket or a URL.
+func (r *NodeImageReconciler) locationBucket(nodeImage *imagev1alpha1.NodeImage, loc string, prov provider.Provider) (string, string) {
+	if nodeImage.Spec.URL != "" {
+		return "", ""
+	}
+	if _, ok := r.ObjectStore.(storage.BucketStore); !ok {
+		return "", ""
+	}
+	if bucket := nodeImage.Annotations[image.S3BucketAnnotation]; bucket != "" {
+		return bucket, ""
+	}
+	sourcer, ok := prov.(provider.ImageSourcer)
+	if !ok {
+		return "", ""
+	}
+	return sourcer.ImageSource(loc)
+}
+
+// checkAvailability checks that the image is available at the URL every
+// location imports it from, which is not url for the locations with a bucket
+// of their own. It returns the first result other than Available with the
+// URL it was checked at.
+func (r *NodeImageReconciler) checkAvailability(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, url string, locations []string, prov provider.Provider) (httpcheck.Result, string, error) {
+	urls := []string{url}
+	if len(locations) > 0 {
+		urls = urls[:0]
+		for _, loc := range locations {
+			locationURL, err := r.locationURL(nodeImage, url, loc, prov)
+			if err != nil {
+				return httpcheck.TransientError, url, err
 			}
+			urls = append(urls, locationURL)
 		}
-	}()
+	}
+
+	for _, checkURL := range slices.Compact(urls) {
+		if result, err := r.imageChecker().Check(ctx, checkURL); result != httpcheck.Available {
+			return result, checkURL, err
+		}
+	}
+	return httpcheck.Available, url, nil
+}
 
-	// HTTP 200-299 means the file exists
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+// imageKey returns the S3 key of the node image, rendered from
+// ImageKeyTemplate or the default key template
+func (r *NodeImageReconciler) imageKey(nodeImage *imagev1alpha1.NodeImage) (string, error) {
+	if r.ImageKeyTemplate == nil {
+		return image.GetImageKey(nodeImage)
+	}
+	return image.ImageKey(r.ImageKeyTemplate, nodeImage)
+}
+
+// errImageMissing fails the upload of an image that is not in the S3 bucket
+var errImageMissing = errors.New("image not found in S3 bucket")
+
+// verifyS3Object marks the location of shared as Missing and fails with
+// errImageMissing if VerifyS3Object is set and the image is not in the bucket
+// the location imports it from. A NodeImage with spec.url is not imported from
+// a bucket and not verified.
+func (r *NodeImageReconciler) verifyS3Object(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, shared *imagev1alpha1.NodeImage, loc string, prov provider.Provider) error {
+	if !r.VerifyS3Object || nodeImage.Spec.URL != "" {
 		return nil
 	}
 
-	return fmt.Errorf("OVA file not found, status code: %d", resp.StatusCode)
+	imageKey, err := r.imageKey(nodeImage)
+	if err != nil {
+		return err
+	}
+	bucket, region := r.locationBucket(nodeImage, loc, prov)
+	exists, err := r.bucketObjectExists(ctx, bucket, region, imageKey)
+	if err != nil {
+		return fmt.Errorf("failed to verify image in S3: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	message := fmt.Sprintf("image %s not found in S3 bucket", imageKey)
+	if bucket != "" {
+		message = fmt.Sprintf("image %s not found in S3 bucket %s", imageKey, bucket)
+	}
+	if err := r.setLocationState(ctx, shared, loc, imagev1alpha1.NodeImageMissing, message); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", errImageMissing, imageKey)
+}
+
+// markMissing marks the NodeImage as Missing, with the message of the first
+// location the image is missing for
+func (r *NodeImageReconciler) markMissing(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) error {
+	if err := r.UpdateStatus(ctx, nodeImage, imagev1alpha1.NodeImageMissing); err != nil {
+		return err
+	}
+	for _, location := range nodeImage.Status.Locations {
+		if location.State == imagev1alpha1.NodeImageMissing {
+			return r.setMessage(ctx, nodeImage, location.Message)
+		}
+	}
+	return nil
+}
+
+// bucketObjectExists checks that the image is in the bucket, the bucket of
+// the object store if bucket is ""
+func (r *NodeImageReconciler) bucketObjectExists(ctx context.Context, bucket string, region string, imageKey string) (bool, error) {
+	if r.objectExists != nil {
+		return r.objectExists(ctx, bucket, region, imageKey)
+	}
+	if bucket == "" {
+		return r.ObjectStore.Exists(ctx, imageKey)
+	}
+	return r.ObjectStore.(storage.BucketStore).BucketExists(ctx, bucket, region, imageKey)
+}
+
+// DefaultImageCheckBackoff retries a transient failure to check the
+// availability of an image a few times within the reconcile
+var DefaultImageCheckBackoff = wait.Backoff{Duration: time.Second, Factor: 2, Steps: 3}
+
+// imageChecker returns the ImageChecker, or the default one if it is unset
+func (r *NodeImageReconciler) imageChecker() *httpcheck.Checker {
+	if r.ImageChecker != nil {
+		return r.ImageChecker
+	}
+	return &httpcheck.Checker{Backoff: DefaultImageCheckBackoff}
+}
+
+func (r *NodeImageReconciler) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// scheduledRequeue requeues a scheduled NodeImage for when the distribution
+// window opens next.
+func (r *NodeImageReconciler) scheduledRequeue() reconcile.Result {
+	requeueAfter := r.DistributionWindow.UntilOpen(r.currentTime())
+	if requeueAfter <= 0 {
+		return r.periodicRequeue()
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}
 }
 
+// DefaultRequeue requeues after DefaultRequeueInterval without jitter
 func DefaultRequeue() reconcile.Result {
 	return ctrl.Result{
 		Requeue:      true,
-		RequeueAfter: time.Minute * 5,
+		RequeueAfter: DefaultRequeueInterval,
 	}
 }
@@ -81,9 +81,9 @@ var _ = Describe("NodeImage Controller", func() {
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &NodeImageReconciler{
-				Client:    k8sClient,
-				S3Client:  s3Client,
-				Providers: make(map[string]provider.Provider), // Empty providers map - test provider doesn't need actual implementation
+				Client:      k8sClient,
+				ObjectStore: s3Client,
+				Providers:   make(map[string]provider.Provider), // Empty providers map - test provider doesn't need actual implementation
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -0,0 +1,109 @@
+package image
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/notify"
+)
+
+// distributionKey marks the context of a distribution, whose transitions are
+// notified once all locations are done instead of by every status update
+type distributionKey struct{}
+
+// withDistribution marks ctx as the context of a distribution
+func withDistribution(ctx context.Context) context.Context {
+	return context.WithValue(ctx, distributionKey{}, true)
+}
+
+// inDistribution reports whether ctx is the context of a distribution
+func inDistribution(ctx context.Context) bool {
+	distributing, _ := ctx.Value(distributionKey{}).(bool)
+	return distributing
+}
+
+// locationResults collects the outcome of the upload to every location of a
+// distribution, which run concurrently
+type locationResults struct {
+	mu        sync.Mutex
+	errors    map[string]error
+	scheduled bool
+}
+
+// record records the outcome of the upload to the location and the state it
+// left the NodeImage in
+func (l *locationResults) record(loc string, state imagev1alpha1.NodeImageState, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.errors == nil {
+		l.errors = make(map[string]error)
+	}
+	l.errors[loc] = err
+	if err == nil && state == imagev1alpha1.NodeImageScheduled {
+		l.scheduled = true
+	}
+}
+
+// state returns the state of a distribution in which no location failed:
+// Scheduled while the upload to any location waits for the distribution
+// window, Available otherwise
+func (l *locationResults) state() imagev1alpha1.NodeImageState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.scheduled {
+		return imagev1alpha1.NodeImageScheduled
+	}
+	return imagev1alpha1.NodeImageAvailable
+}
+
+// list returns the results sorted by location, naming the image recorded in
+// the status for the locations that succeeded
+func (l *locationResults) list(nodeImage *imagev1alpha1.NodeImage) []notify.LocationResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	results := make([]notify.LocationResult, 0, len(l.errors))
+	for loc, err := range l.errors {
+		if err != nil {
+			results = append(results, notify.LocationResult{Location: loc, State: string(imagev1alpha1.NodeImageError), Error: err.Error()})
+			continue
+		}
+		result := notify.LocationResult{Location: loc, State: string(imagev1alpha1.NodeImageAvailable)}
+		for _, location := range nodeImage.Status.Locations {
+			if location.Name == loc {
+				result.ImageName = location.ImageName
+			}
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Location < results[j].Location })
+	return results
+}
+
+// notifyDistribution notifies the transition of the NodeImage from previous
+// into the state the distribution left it in, with the results of all
+// locations. A distribution only becomes Available once every location
+// succeeded.
+func (r *NodeImageReconciler) notifyDistribution(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, previous imagev1alpha1.NodeImageState, results *locationResults) {
+	r.statusMu.Lock()
+	state := nodeImage.Status.State
+	event := r.transitionEvent(nodeImage, previous, state)
+	event.Locations = results.list(nodeImage)
+	r.statusMu.Unlock()
+
+	if state == previous {
+		return
+	}
+	if state == imagev1alpha1.NodeImageAvailable {
+		for _, result := range event.Locations {
+			if result.Error != "" {
+				return
+			}
+		}
+	}
+	r.notify(ctx, event)
+}
@@ -0,0 +1,183 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/notify"
+)
+
+// fakeNotifier records every event it is asked to send.
+type fakeNotifier struct {
+	mu     sync.Mutex
+	events []notify.Event
+	err    error
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, event notify.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+	return f.err
+}
+
+func TestUpdateStatusNotifies(t *testing.T) {
+	testCases := []struct {
+		name              string
+		initialState      imagev1alpha1.NodeImageState
+		newState          imagev1alpha1.NodeImageState
+		notifyOnAvailable bool
+		notifyErr         error
+		expectedEvents    int
+	}{
+		{
+			name:           "case 0: transition into Error notifies",
+			initialState:   imagev1alpha1.NodeImageUploading,
+			newState:       imagev1alpha1.NodeImageError,
+			expectedEvents: 1,
+		},
+		{
+			name:           "case 1: staying in Error does not notify again",
+			initialState:   imagev1alpha1.NodeImageError,
+			newState:       imagev1alpha1.NodeImageError,
+			expectedEvents: 0,
+		},
+		{
+			name:           "case 2: transition into Available is ignored by default",
+			initialState:   imagev1alpha1.NodeImageUploading,
+			newState:       imagev1alpha1.NodeImageAvailable,
+			expectedEvents: 0,
+		},
+		{
+			name:              "case 3: transition into Available notifies when enabled",
+			initialState:      imagev1alpha1.NodeImageUploading,
+			newState:          imagev1alpha1.NodeImageAvailable,
+			notifyOnAvailable: true,
+			expectedEvents:    1,
+		},
+		{
+			name:           "case 4: notifier failure does not fail the status update",
+			initialState:   imagev1alpha1.NodeImageUploading,
+			newState:       imagev1alpha1.NodeImageError,
+			notifyErr:      fmt.Errorf("webhook down"),
+			expectedEvents: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: tc.initialState},
+			}
+			notifier := &fakeNotifier{err: tc.notifyErr}
+			r := &NodeImageReconciler{
+				Client:            newFakeClient(t, nodeImage),
+				Notifier:          notifier,
+				NotifyOnAvailable: tc.notifyOnAvailable,
+			}
+
+			err := r.UpdateStatus(ctx, nodeImage, tc.newState)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.newState, nodeImage.Status.State)
+
+			require.Len(t, notifier.events, tc.expectedEvents)
+			if tc.expectedEvents > 0 {
+				assert.Equal(t, notify.Event{
+					NodeImage:     "capv-test-image",
+					Namespace:     "test-namespace",
+					Provider:      "capv",
+					Image:         "test-image",
+					State:         string(tc.newState),
+					PreviousState: string(tc.initialState),
+				}, notifier.events[0])
+			}
+		})
+	}
+}
+
+func TestDistributeNotifies(t *testing.T) {
+	testCases := []struct {
+		name           string
+		initialState   imagev1alpha1.NodeImageState
+		failing        []string
+		expectedEvents []notify.Event
+	}{
+		{
+			name:         "case 0: distribution to all locations notifies Available once",
+			initialState: imagev1alpha1.NodeImagePending,
+			expectedEvents: []notify.Event{{
+				State:         string(imagev1alpha1.NodeImageAvailable),
+				PreviousState: string(imagev1alpha1.NodeImagePending),
+				Locations: []notify.LocationResult{
+					{Location: "dc1", ImageName: "test-image", State: "Available"},
+					{Location: "dc2", ImageName: "test-image", State: "Available"},
+				},
+			}},
+		},
+		{
+			name:         "case 1: failed location notifies Error with the results of all locations",
+			initialState: imagev1alpha1.NodeImagePending,
+			failing:      []string{"dc2"},
+			expectedEvents: []notify.Event{{
+				State:         string(imagev1alpha1.NodeImageError),
+				PreviousState: string(imagev1alpha1.NodeImagePending),
+				Locations: []notify.LocationResult{
+					{Location: "dc1", ImageName: "test-image", State: "Available"},
+					{Location: "dc2", State: "Error", Error: "failed to import image: boom"},
+				},
+			}},
+		},
+		{
+			name:         "case 2: image already available does not notify again",
+			initialState: imagev1alpha1.NodeImageAvailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: tc.initialState},
+			}
+			prov := newFakeProvider("dc1", "dc2")
+			for _, loc := range tc.failing {
+				prov.createErr[loc] = fmt.Errorf("boom")
+			}
+			notifier := &fakeNotifier{}
+			r := &NodeImageReconciler{
+				Client:            newFakeClient(t, nodeImage),
+				Notifier:          notifier,
+				NotifyOnAvailable: true,
+			}
+
+			_, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+			require.NoError(t, err)
+
+			for i := range tc.expectedEvents {
+				tc.expectedEvents[i].NodeImage = "capv-test-image"
+				tc.expectedEvents[i].Namespace = "test-namespace"
+				tc.expectedEvents[i].Provider = "capv"
+				tc.expectedEvents[i].Image = "test-image"
+			}
+			if len(tc.expectedEvents) == 0 {
+				assert.Empty(t, notifier.events)
+			} else {
+				assert.Equal(t, tc.expectedEvents, notifier.events)
+			}
+		})
+	}
+}
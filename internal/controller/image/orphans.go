@@ -0,0 +1,123 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// OrphanCollector periodically deletes images left behind in provider
+// locations without a NodeImage, e.g. because a deletion partially failed
+// while the NodeImage was removed. Only providers implementing
+// provider.Lister are collected.
+type OrphanCollector struct {
+	Reconciler *NodeImageReconciler
+	Interval   time.Duration
+}
+
+// Start runs a collection every Interval until ctx is done. The first
+// collection only runs after one Interval, giving the release controller time
+// to create the NodeImages of existing releases after a fresh start.
+func (o *OrphanCollector) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("orphan-collector")
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := o.Reconciler.CollectOrphans(ctx); err != nil {
+				log.Error(err, "Failed to collect orphaned images")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leader deletes images
+func (o *OrphanCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// CollectOrphans deletes the images in all listable provider locations that
+// no NodeImage accounts for. To never delete images the operator does not
+// own, only images whose name follows the operator's naming convention are
+// considered, and nothing is deleted while no NodeImages exist at all.
+func (r *NodeImageReconciler) CollectOrphans(ctx context.Context) error {
+	var errs []error
+	for providerName, prov := range r.Providers {
+		lister, ok := prov.(provider.Lister)
+		if !ok {
+			continue
+		}
+		for loc := range prov.GetLocations() {
+			if err := r.collectOrphans(ctx, providerName, prov, lister, loc); err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", providerName, loc, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *NodeImageReconciler) collectOrphans(ctx context.Context, providerName string, prov provider.Provider, lister provider.Lister, loc string) error {
+	ctx = provider.WithLogValues(ctx, provider.LogKeyProvider, providerName, provider.LogKeyLocation, loc)
+	log := log.FromContext(ctx)
+
+	// List the images before the NodeImages, so an image uploaded in between
+	// always has its NodeImage in the list.
+	names, err := lister.List(ctx, loc)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %w", err)
+	}
+
+	nodeImages := &imagev1alpha1.NodeImageList{}
+	if err := r.List(ctx, nodeImages); err != nil {
+		return fmt.Errorf("failed to list node images: %w", err)
+	}
+	if len(nodeImages.Items) == 0 {
+		log.Info("No node images found, skipping orphan collection")
+		return nil
+	}
+
+	owned := map[string]bool{}
+	for i := range nodeImages.Items {
+		nodeImage := &nodeImages.Items[i]
+		if nodeImage.Spec.Provider != providerName {
+			continue
+		}
+		owned[nodeImage.Spec.Name] = true
+		if name, err := r.providerImageName(nodeImage, loc, prov); err == nil {
+			owned[name] = true
+		}
+	}
+
+	var errs []error
+	for _, name := range names {
+		if owned[name] {
+			continue
+		}
+		if _, ok := image.ParseImageName(name); !ok {
+			continue
+		}
+
+		log.Info("Deleting orphaned image", "name", name)
+		r.existsCache.forget(existsKey(providerName, loc, name))
+		if err := prov.Delete(ctx, name, loc); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete orphaned image %s: %w", name, err))
+			continue
+		}
+		orphansDeletedTotal.WithLabelValues(providerName, loc).Inc()
+	}
+	return errors.Join(errs...)
+}
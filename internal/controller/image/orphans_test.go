@@ -0,0 +1,113 @@
+package image
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// listingProvider is a fakeProvider that can list its images.
+type listingProvider struct {
+	*fakeProvider
+}
+
+func (l *listingProvider) List(ctx context.Context, loc string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var names []string
+	for key := range l.images {
+		if name, ok := strings.CutPrefix(key, loc+"/"); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func TestCollectOrphans(t *testing.T) {
+	const (
+		owned  = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
+		orphan = "flatcar-stable-3975.2.0-kube-1.29.8-tooling-1.18.1-gs"
+		ubuntu = "ubuntu-2404-kube-1.29.8-tooling-1.18.1-gs"
+		other  = "my-own-template"
+	)
+
+	nodeImage := func(name, providerName string) *imagev1alpha1.NodeImage {
+		return &imagev1alpha1.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{Name: providerName + "-" + name, Namespace: "test-namespace"},
+			Spec:       imagev1alpha1.NodeImageSpec{Name: name, Provider: providerName},
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		nodeImages      []client.Object
+		notListable     bool
+		expectedImages  []string
+		expectedDeleted []string
+	}{
+		{
+			name:            "case 0: orphaned images are deleted, owned and foreign ones kept",
+			nodeImages:      []client.Object{nodeImage(owned, "capv")},
+			expectedImages:  []string{"dc1/" + other, "dc1/" + owned, "dc2/" + other, "dc2/" + owned},
+			expectedDeleted: []string{"dc1", "dc1", "dc2", "dc2"},
+		},
+		{
+			name:           "case 1: nothing is deleted without any node images",
+			expectedImages: []string{"dc1/" + orphan, "dc1/" + other, "dc1/" + owned, "dc1/" + ubuntu, "dc2/" + orphan, "dc2/" + other, "dc2/" + owned, "dc2/" + ubuntu},
+		},
+		{
+			name:            "case 2: node images of other providers don't own images",
+			nodeImages:      []client.Object{nodeImage(owned, "capvcd")},
+			expectedImages:  []string{"dc1/" + other, "dc2/" + other},
+			expectedDeleted: []string{"dc1", "dc1", "dc1", "dc2", "dc2", "dc2"},
+		},
+		{
+			name:           "case 3: providers that can't list are skipped",
+			nodeImages:     []client.Object{nodeImage(owned, "capv")},
+			notListable:    true,
+			expectedImages: []string{"dc1/" + orphan, "dc1/" + other, "dc1/" + owned, "dc1/" + ubuntu, "dc2/" + orphan, "dc2/" + other, "dc2/" + owned, "dc2/" + ubuntu},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakeProvider("dc1", "dc2")
+			for _, loc := range []string{"dc1", "dc2"} {
+				for _, name := range []string{owned, orphan, ubuntu, other} {
+					fake.images[loc+"/"+name] = true
+				}
+			}
+
+			var prov provider.Provider = &listingProvider{fakeProvider: fake}
+			if tc.notListable {
+				prov = fake
+			}
+
+			r := &NodeImageReconciler{
+				Client:    newFakeClient(t, tc.nodeImages...),
+				Providers: map[string]provider.Provider{"capv": prov},
+			}
+
+			require.NoError(t, r.CollectOrphans(context.TODO()))
+
+			var images []string
+			for key := range fake.images {
+				images = append(images, key)
+			}
+			sort.Strings(images)
+			sort.Strings(tc.expectedImages)
+			sort.Strings(fake.deleted)
+			assert.Equal(t, tc.expectedImages, images)
+			assert.Equal(t, tc.expectedDeleted, fake.deleted)
+		})
+	}
+}
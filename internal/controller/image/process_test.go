@@ -0,0 +1,86 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// processFailingProvider fails every post-upload processing step
+type processFailingProvider struct {
+	*fakeProvider
+}
+
+func (p *processFailingProvider) Process(ctx context.Context, name string, loc string) error {
+	return errors.New("process failed")
+}
+
+func TestCreateProviderProcess(t *testing.T) {
+	testCases := []struct {
+		name              string
+		existing          bool
+		failProcess       bool
+		expectedProcessed []string
+		expectedError     bool
+		expectedState     imagev1alpha1.NodeImageState
+	}{
+		{
+			name:              "case 0: uploaded image is processed",
+			expectedProcessed: []string{"dc1"},
+			expectedState:     imagev1alpha1.NodeImageAvailable,
+		},
+		{
+			name:          "case 1: existing image is not processed again",
+			existing:      true,
+			expectedState: imagev1alpha1.NodeImageAvailable,
+		},
+		{
+			name:          "case 2: failed processing fails the upload",
+			failProcess:   true,
+			expectedError: true,
+			expectedState: imagev1alpha1.NodeImageError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases: []string{"v1.0.0"},
+					State:    imagev1alpha1.NodeImagePending,
+				},
+			}
+
+			fake := newFakeProvider("dc1")
+			if tc.existing {
+				fake.images["dc1/test-image"] = true
+			}
+
+			r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+			var err error
+			if tc.failProcess {
+				_, err = r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", &processFailingProvider{fake})
+			} else {
+				_, err = r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", fake)
+			}
+			if tc.expectedError {
+				require.ErrorContains(t, err, "failed to process image")
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedProcessed, fake.processed)
+			assert.Equal(t, tc.expectedState, statusLocation(nodeImage, "dc1").State)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// defaultProgressInterval is how often the progress of an upload is written
+// to the status at most
+const defaultProgressInterval = 15 * time.Second
+
+// uploadProgress returns the function the provider reports the progress of
+// an upload to the location with. It writes the progress into the message of
+// the location in the status, at most once per progress interval and once the
+// upload completed.
+func (r *NodeImageReconciler) uploadProgress(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, loc string) provider.ProgressFunc {
+	interval := r.progressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
+	var mu sync.Mutex
+	var last time.Time
+	return func(transferred int64, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		now := r.currentTime()
+		complete := total > 0 && transferred >= total
+		if !last.IsZero() && now.Sub(last) < interval && !complete {
+			return
+		}
+		last = now
+
+		message := fmt.Sprintf("uploading to %s, %s", loc, formatBytes(transferred))
+		if total > 0 {
+			message += "/" + formatBytes(total)
+		}
+		if err := r.setLocationState(ctx, nodeImage, loc, imagev1alpha1.NodeImageUploading, message); err != nil {
+			// progress is informational only and must not fail the upload
+			log.FromContext(ctx).Info("Failed to record upload progress", "error", err.Error())
+		}
+	}
+}
+
+// setMessage writes the status message if it changed
+func (r *NodeImageReconciler) setMessage(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, message string) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	if nodeImage.Status.Message == message {
+		return nil
+	}
+	nodeImage.Status.Message = message
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// setSourceURL records the URL the image is imported from in the status if
+// it changed
+func (r *NodeImageReconciler) setSourceURL(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, url string) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	if nodeImage.Status.SourceURL == url {
+		return nil
+	}
+	nodeImage.Status.SourceURL = url
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// providerTask returns the function the provider reports the task of an
+// upload to the location with, which writes the task reference into the
+// status, and a function clearing it again once the upload succeeded. A
+// failed upload keeps its task in the status to be looked into.
+func (r *NodeImageReconciler) providerTask(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, loc string) (provider.TaskFunc, func()) {
+	log := log.FromContext(ctx)
+
+	var mu sync.Mutex
+	var reported string
+	report := func(ref string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		reported = ref
+		if err := r.setProviderTaskRef(ctx, nodeImage, ref, ""); err != nil {
+			log.Info("Failed to record provider task", "task", ref, "error", err.Error())
+		}
+	}
+	clearTask := func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if reported == "" {
+			return
+		}
+		// the uploads to other locations may have reported their own task since
+		if err := r.setProviderTaskRef(ctx, nodeImage, "", reported); err != nil {
+			log.Info("Failed to clear provider task", "task", reported, "error", err.Error())
+		}
+	}
+	return report, clearTask
+}
+
+// setProviderTaskRef writes the provider task reference into the status. If
+// ifCurrent is set, the reference is only replaced while it is ifCurrent.
+func (r *NodeImageReconciler) setProviderTaskRef(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, ref string, ifCurrent string) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	current := nodeImage.Status.ProviderTaskRef
+	if current == ref || (ifCurrent != "" && current != ifCurrent) {
+		return nil
+	}
+	nodeImage.Status.ProviderTaskRef = ref
+	if err := r.Status().Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+// formatBytes formats a number of bytes in decimal units with at most one
+// decimal, e.g. 4.2GB
+func formatBytes(b int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(b)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	formatted := strconv.FormatFloat(value, 'f', 1, 64)
+	return strings.TrimSuffix(formatted, ".0") + units[unit]
+}
@@ -0,0 +1,211 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// progressProvider reports the given progress during Create and records the
+// status message after every report
+type progressProvider struct {
+	*fakeProvider
+	client   client.Client
+	reports  [][2]int64
+	messages []string
+}
+
+func (p *progressProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	for _, report := range p.reports {
+		provider.ReportProgress(ctx, report[0], report[1])
+
+		nodeImage := &imagev1alpha1.NodeImage{}
+		if err := p.client.Get(ctx, client.ObjectKey{Name: "capv-test-image", Namespace: "test-namespace"}, nodeImage); err != nil {
+			return err
+		}
+		p.messages = append(p.messages, statusLocation(nodeImage, loc).Message)
+	}
+	return p.fakeProvider.Create(ctx, imageURL, imageName, loc)
+}
+
+func TestCreateProviderProgress(t *testing.T) {
+	testCases := []struct {
+		name             string
+		reports          [][2]int64
+		expectedMessages []string
+	}{
+		{
+			name:             "case 0: progress is written to the message of the location",
+			reports:          [][2]int64{{4_200_000_000, 6_000_000_000}},
+			expectedMessages: []string{"uploading to dc1, 4.2GB/6GB"},
+		},
+		{
+			name:             "case 1: progress within the interval is not written",
+			reports:          [][2]int64{{1_000_000_000, 6_000_000_000}, {2_000_000_000, 6_000_000_000}},
+			expectedMessages: []string{"uploading to dc1, 1GB/6GB", "uploading to dc1, 1GB/6GB"},
+		},
+		{
+			name:             "case 2: completed upload is always written",
+			reports:          [][2]int64{{1_000_000_000, 6_000_000_000}, {6_000_000_000, 6_000_000_000}},
+			expectedMessages: []string{"uploading to dc1, 1GB/6GB", "uploading to dc1, 6GB/6GB"},
+		},
+		{
+			name:             "case 3: unknown size",
+			reports:          [][2]int64{{512_000_000, 0}},
+			expectedMessages: []string{"uploading to dc1, 512MB"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases: []string{"v1.0.0"},
+					State:    imagev1alpha1.NodeImagePending,
+				},
+			}
+
+			now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{
+				Client:           c,
+				progressInterval: time.Minute,
+				now:              func() time.Time { return now },
+			}
+			prov := &progressProvider{fakeProvider: newFakeProvider("dc1"), client: c, reports: tc.reports}
+
+			_, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedMessages, prov.messages)
+
+			// recording the image in the location clears the upload progress
+			location := statusLocation(nodeImage, "dc1")
+			assert.Equal(t, imagev1alpha1.NodeImageAvailable, location.State)
+			assert.Empty(t, location.Message)
+		})
+	}
+}
+
+// taskProvider reports the given task during Create and records the provider
+// task reference in the status after the report
+type taskProvider struct {
+	*fakeProvider
+	client   client.Client
+	task     string
+	recorded string
+}
+
+func (p *taskProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	provider.ReportTask(ctx, p.task)
+
+	nodeImage := &imagev1alpha1.NodeImage{}
+	if err := p.client.Get(ctx, client.ObjectKey{Name: "capv-test-image", Namespace: "test-namespace"}, nodeImage); err != nil {
+		return err
+	}
+	p.recorded = nodeImage.Status.ProviderTaskRef
+	return p.fakeProvider.Create(ctx, imageURL, imageName, loc)
+}
+
+func TestCreateProviderTask(t *testing.T) {
+	const task = "https://vcd.example.com/api/task/1234"
+
+	testCases := []struct {
+		name          string
+		createErr     error
+		expectedAfter string
+	}{
+		{
+			name:          "case 0: task is cleared once the upload succeeded",
+			expectedAfter: "",
+		},
+		{
+			name:          "case 1: task of a failed upload is kept",
+			createErr:     errors.New("upload failed"),
+			expectedAfter: task,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases: []string{"v1.0.0"},
+					State:    imagev1alpha1.NodeImagePending,
+				},
+			}
+
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{Client: c}
+			prov := &taskProvider{fakeProvider: newFakeProvider("dc1"), client: c, task: task}
+			if tc.createErr != nil {
+				prov.createErr["dc1"] = tc.createErr
+			}
+
+			_, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			if tc.createErr != nil {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, task, prov.recorded)
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+			assert.Equal(t, tc.expectedAfter, stored.Status.ProviderTaskRef)
+		})
+	}
+}
+
+func TestSetProviderTaskRef(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Status:     imagev1alpha1.NodeImageStatus{ProviderTaskRef: "task-b"},
+	}
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+	// the task of another location is not cleared
+	require.NoError(t, r.setProviderTaskRef(ctx, nodeImage, "", "task-a"))
+	assert.Equal(t, "task-b", nodeImage.Status.ProviderTaskRef)
+
+	require.NoError(t, r.setProviderTaskRef(ctx, nodeImage, "", "task-b"))
+	assert.Empty(t, nodeImage.Status.ProviderTaskRef)
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bytes    int64
+		expected string
+	}{
+		{name: "case 0: bytes", bytes: 512, expected: "512B"},
+		{name: "case 1: megabytes", bytes: 1_500_000, expected: "1.5MB"},
+		{name: "case 2: gigabytes with decimal", bytes: 4_230_000_000, expected: "4.2GB"},
+		{name: "case 3: whole gigabytes", bytes: 6_000_000_000, expected: "6GB"},
+		{name: "case 4: terabytes", bytes: 2_000_000_000_000_000, expected: "2000TB"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatBytes(tc.bytes))
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package image
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+	"github.com/giantswarm/image-distribution-operator/pkg/s3"
+)
+
+func TestReconcileUnsupportedProvider(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "capmox-test-image",
+			Namespace:  "test-namespace",
+			Finalizers: []string{NodeImageFinalizer},
+		},
+		Spec:   imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: provider.Proxmox},
+		Status: imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+
+	s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1"}, ctx)
+	require.NoError(t, err)
+
+	prov := newFakeProvider("dc1")
+	r := &NodeImageReconciler{
+		Client:      newFakeClient(t, nodeImage),
+		ObjectStore: s3Client,
+		Providers:   map[string]provider.Provider{provider.VSphere: prov},
+	}
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}})
+	require.NoError(t, err)
+	assert.Equal(t, ctrl.Result{}, result)
+	assert.Empty(t, prov.created)
+
+	updated := &imagev1alpha1.NodeImage{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}, updated))
+	assert.Equal(t, imagev1alpha1.NodeImageError, updated.Status.State)
+
+	condition := meta.FindStatusCondition(updated.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, imagev1alpha1.NodeImageReasonUnsupportedProvider, condition.Reason)
+	assert.Contains(t, condition.Message, `"capmox"`)
+}
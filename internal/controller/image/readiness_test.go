@@ -0,0 +1,108 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// slowProvider is a fakeProvider whose images only become ready after a
+// number of readiness checks.
+type slowProvider struct {
+	*fakeProvider
+	readyAfter int
+	checks     int
+}
+
+func (s *slowProvider) Ready(ctx context.Context, name string, loc string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks++
+	return s.images[loc+"/"+name] && s.checks > s.readyAfter, nil
+}
+
+func TestCreateProviderReadiness(t *testing.T) {
+	testCases := []struct {
+		name          string
+		readyAfter    int
+		noChecker     bool
+		dryRun        bool
+		timeout       time.Duration
+		expectError   bool
+		expectedState imagev1alpha1.NodeImageState
+	}{
+		{
+			name:          "case 0: image becomes ready in time",
+			readyAfter:    2,
+			timeout:       time.Second,
+			expectedState: imagev1alpha1.NodeImageAvailable,
+		},
+		{
+			name:          "case 1: image never becomes ready",
+			readyAfter:    1000,
+			timeout:       50 * time.Millisecond,
+			expectError:   true,
+			expectedState: imagev1alpha1.NodeImageError,
+		},
+		{
+			name:          "case 2: providers without readiness check wait for the image to exist",
+			noChecker:     true,
+			timeout:       time.Second,
+			expectedState: imagev1alpha1.NodeImageAvailable,
+		},
+		{
+			name:          "case 3: readiness wait disabled",
+			readyAfter:    1000,
+			timeout:       0,
+			expectedState: imagev1alpha1.NodeImageAvailable,
+		},
+		{
+			name:          "case 4: dry run uploads are not waited for",
+			readyAfter:    1000,
+			dryRun:        true,
+			timeout:       50 * time.Millisecond,
+			expectedState: imagev1alpha1.NodeImageAvailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			slow := &slowProvider{fakeProvider: newFakeProvider("dc1"), readyAfter: tc.readyAfter}
+			var prov provider.Provider = slow
+			if tc.noChecker {
+				prov = slow.fakeProvider
+			}
+
+			r := &NodeImageReconciler{
+				Client:            newFakeClient(t, nodeImage),
+				ReadinessTimeout:  tc.timeout,
+				DryRun:            tc.dryRun,
+				readinessInterval: 5 * time.Millisecond,
+			}
+
+			_, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			if tc.expectError {
+				assert.ErrorContains(t, err, "not ready within")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedState, statusLocation(nodeImage, "dc1").State)
+			if tc.timeout > 0 && !tc.noChecker && !tc.dryRun && !tc.expectError {
+				assert.Greater(t, slow.checks, tc.readyAfter)
+			}
+		})
+	}
+}
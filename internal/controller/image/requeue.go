@@ -0,0 +1,49 @@
+package image
+
+import (
+	"math/rand/v2"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultRequeueInterval is how often a NodeImage is reconciled again when
+	// RequeueInterval is unset.
+	DefaultRequeueInterval = 5 * time.Minute
+	// DefaultRequeueJitter is the fraction of the requeue interval the
+	// periodic reconciles are spread by in either direction by default.
+	DefaultRequeueJitter = 0.2
+)
+
+// jitter moves the interval by up to fraction of it in either direction,
+// random is in [0, 1) and picks the point within that band.
+func jitter(interval time.Duration, fraction float64, random float64) time.Duration {
+	if fraction <= 0 {
+		return interval
+	}
+	return interval + time.Duration((2*random-1)*fraction*float64(interval))
+}
+
+// periodicRequeue requeues a NodeImage for its next periodic reconcile. The
+// interval is jittered, so NodeImages reconciled at the same time, e.g.
+// after a restart, don't all check their images in the providers at once.
+func (r *NodeImageReconciler) periodicRequeue() ctrl.Result {
+	return ctrl.Result{
+		Requeue:      true,
+		RequeueAfter: jitter(r.requeueInterval(), r.RequeueJitter, rand.Float64()), // #nosec G404 -- no security impact
+	}
+}
+
+// minPeriodicRequeue is the earliest a NodeImage is reconciled again by
+// periodicRequeue
+func (r *NodeImageReconciler) minPeriodicRequeue() time.Duration {
+	return jitter(r.requeueInterval(), r.RequeueJitter, 0)
+}
+
+func (r *NodeImageReconciler) requeueInterval() time.Duration {
+	if r.RequeueInterval <= 0 {
+		return DefaultRequeueInterval
+	}
+	return r.RequeueInterval
+}
@@ -0,0 +1,65 @@
+package image
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fraction float64
+		random   float64
+		expected time.Duration
+	}{
+		{
+			name:     "case 0: lowest random shortens the interval by the fraction",
+			fraction: 0.2,
+			random:   0,
+			expected: 4 * time.Minute,
+		},
+		{
+			name:     "case 1: middle random keeps the interval",
+			fraction: 0.2,
+			random:   0.5,
+			expected: 5 * time.Minute,
+		},
+		{
+			name:     "case 2: upper random lengthens the interval",
+			fraction: 0.2,
+			random:   0.75,
+			expected: 5*time.Minute + 30*time.Second,
+		},
+		{
+			name:     "case 3: no jitter",
+			fraction: 0,
+			random:   0.9,
+			expected: 5 * time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, jitter(5*time.Minute, tc.fraction, tc.random))
+		})
+	}
+}
+
+func TestPeriodicRequeue(t *testing.T) {
+	r := &NodeImageReconciler{RequeueInterval: 10 * time.Minute, RequeueJitter: 0.2}
+
+	intervals := make(map[time.Duration]bool)
+	for range 1000 {
+		result := r.periodicRequeue()
+		assert.GreaterOrEqual(t, result.RequeueAfter, 8*time.Minute)
+		assert.Less(t, result.RequeueAfter, 12*time.Minute)
+		intervals[result.RequeueAfter] = true
+	}
+	// the reconciles are actually spread
+	assert.Greater(t, len(intervals), 1)
+
+	// without jitter the default interval is used as is
+	assert.Equal(t, DefaultRequeue(), (&NodeImageReconciler{}).periodicRequeue())
+}
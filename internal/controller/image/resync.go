@@ -0,0 +1,74 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// Resyncer periodically enqueues every NodeImage, so images deleted from a
+// provider out-of-band are noticed on a predictable cadence instead of
+// whenever the NodeImage is requeued next.
+type Resyncer struct {
+	Reconciler *NodeImageReconciler
+	Interval   time.Duration
+}
+
+// Start runs a resync every Interval until ctx is done
+func (s *Resyncer) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("resyncer")
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.Reconciler.Resync(ctx); err != nil {
+				log.Error(err, "Failed to resync node images")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leader, which runs the controller
+// the NodeImages are enqueued in, resyncs
+func (s *Resyncer) NeedLeaderElection() bool {
+	return true
+}
+
+// Resync enqueues every NodeImage in the controller. The exists cache is
+// cleared first, so the reconciles ask the providers whether the images are
+// still there.
+func (r *NodeImageReconciler) Resync(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	if r.resync == nil {
+		return fmt.Errorf("controller not set up")
+	}
+
+	nodeImages := &imagev1alpha1.NodeImageList{}
+	if err := r.List(ctx, nodeImages); err != nil {
+		return fmt.Errorf("failed to list node images: %w", err)
+	}
+
+	r.existsCache.clear()
+	for i := range nodeImages.Items {
+		select {
+		case r.resync <- event.GenericEvent{Object: &nodeImages.Items[i]}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	log.Info("Resynced node images", "count", len(nodeImages.Items))
+	return nil
+}
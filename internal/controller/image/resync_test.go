@@ -0,0 +1,60 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+func TestResync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var objs []*imagev1alpha1.NodeImage
+	for _, name := range []string{"capv-image-a", "capv-image-b", "capvcd-image-c"} {
+		objs = append(objs, &imagev1alpha1.NodeImage{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace"}})
+	}
+	c := newFakeClient(t, objs[0], objs[1], objs[2])
+
+	r := &NodeImageReconciler{Client: c}
+	require.Error(t, r.Resync(ctx), "resync requires the controller to be set up")
+
+	// the channel is watched like in SetupWithManager
+	r.resync = make(chan event.GenericEvent)
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	require.NoError(t, source.Channel(r.resync, &handler.EnqueueRequestForObject{}).Start(ctx, queue))
+
+	key := existsKey("capv", "dc1", "image-a")
+	r.existsCache.remember(key, time.Now().Add(time.Hour))
+
+	require.NoError(t, r.Resync(ctx))
+
+	var enqueued []types.NamespacedName
+	for range objs {
+		req, shutdown := queue.Get()
+		require.False(t, shutdown)
+		enqueued = append(enqueued, req.NamespacedName)
+		queue.Done(req)
+	}
+	assert.ElementsMatch(t, []types.NamespacedName{
+		{Name: "capv-image-a", Namespace: "test-namespace"},
+		{Name: "capv-image-b", Namespace: "test-namespace"},
+		{Name: "capvcd-image-c", Namespace: "test-namespace"},
+	}, enqueued)
+
+	// the reconciles ask the providers again
+	assert.False(t, r.existsCache.fresh(key, time.Now()))
+}
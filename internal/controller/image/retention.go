@@ -0,0 +1,83 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// DefaultRetentionInterval is how often the RetentionCollector checks the
+// number of unused NodeImages by default
+const DefaultRetentionInterval = 5 * time.Minute
+
+// RetentionCollector periodically deletes the NodeImages awaiting deletion
+// beyond the MaxUnusedImages most recently used ones of every provider,
+// before their retention period expired. Their finalizer removes the images
+// from the providers.
+type RetentionCollector struct {
+	Reconciler      *NodeImageReconciler
+	Interval        time.Duration
+	MaxUnusedImages int
+}
+
+// Start runs a collection every Interval until ctx is done
+func (c *RetentionCollector) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("retention-collector")
+	ctx = ctrl.LoggerInto(ctx, log)
+
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := c.Reconciler.CollectUnusedImages(ctx, c.MaxUnusedImages); err != nil {
+				log.Error(err, "Failed to collect unused node images")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection makes sure only the leader deletes NodeImages
+func (c *RetentionCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// CollectUnusedImages deletes the NodeImages awaiting deletion beyond the
+// keep most recently used ones of every provider. The deletion is
+// conditional on the resource version the NodeImage was listed at, so a
+// NodeImage a release started using again in between is kept.
+func (r *NodeImageReconciler) CollectUnusedImages(ctx context.Context, keep int) error {
+	nodeImages := &imagev1alpha1.NodeImageList{}
+	if err := r.List(ctx, nodeImages); err != nil {
+		return fmt.Errorf("failed to list node images: %w", err)
+	}
+
+	var errs []error
+	for _, nodeImage := range image.ExcessUnusedImages(nodeImages.Items, keep) {
+		ctx := provider.WithLogValues(ctx, provider.LogKeyNodeImage, nodeImage.Name, provider.LogKeyProvider, nodeImage.Spec.Provider)
+		log.FromContext(ctx).Info("Too many unused node images - deleting", "lastUsed", image.LastUsed(nodeImage), "maxUnusedImages", keep)
+		if err := r.deleteNodeImage(ctx, nodeImage, client.Preconditions{
+			UID:             &nodeImage.UID,
+			ResourceVersion: &nodeImage.ResourceVersion,
+		}); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("failed to delete node image %s: %w", nodeImage.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,49 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+)
+
+func TestCollectUnusedImages(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	nodeImage := func(name string, lastUsed time.Duration, releases ...string) *imagev1alpha1.NodeImage {
+		object := &imagev1alpha1.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace"},
+			Spec:       imagev1alpha1.NodeImageSpec{Name: name, Provider: "capv"},
+			Status:     imagev1alpha1.NodeImageStatus{Releases: releases, State: imagev1alpha1.NodeImageAvailable},
+		}
+		if len(releases) == 0 {
+			object.Status.State = imagev1alpha1.NodeImageAwaitingDeletion
+			object.Annotations = map[string]string{image.LastUsedAnnotation: now.Add(-lastUsed).Format(time.RFC3339)}
+		}
+		return object
+	}
+
+	k8sClient := newFakeClient(t,
+		nodeImage("used", 0, "v30.0.0"),
+		nodeImage("unused-new", time.Hour),
+		nodeImage("unused-old", 48*time.Hour),
+		nodeImage("unused-oldest", 72*time.Hour),
+	)
+	r := &NodeImageReconciler{Client: k8sClient}
+
+	require.NoError(t, r.CollectUnusedImages(context.TODO(), 1))
+
+	nodeImages := &imagev1alpha1.NodeImageList{}
+	require.NoError(t, k8sClient.List(context.TODO(), nodeImages, client.InNamespace("test-namespace")))
+	var names []string
+	for _, object := range nodeImages.Items {
+		names = append(names, object.Name)
+	}
+	assert.ElementsMatch(t, []string{"used", "unused-new"}, names)
+}
@@ -0,0 +1,68 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// forceReupload reports whether the force reupload annotation of the
+// NodeImage requests the image to be uploaded again to the location
+func forceReupload(nodeImage *imagev1alpha1.NodeImage, loc string) bool {
+	value, ok := nodeImage.Annotations[image.ForceReuploadAnnotation]
+	if !ok {
+		return false
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || value == "true" {
+		return true
+	}
+	for _, l := range strings.Split(value, ",") {
+		if strings.TrimSpace(l) == loc {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteForReupload deletes the image from the location if it is there, so
+// it can be uploaded again
+func (r *NodeImageReconciler) deleteForReupload(ctx context.Context, key string, name string, loc string, prov provider.Provider) error {
+	log := log.FromContext(ctx)
+
+	r.existsCache.forget(key)
+	exists, err := prov.Exists(ctx, name, loc)
+	if err != nil {
+		return fmt.Errorf("failed to check if image exists: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	log.Info("Reupload of node image forced, deleting it", "name", name)
+	if err := prov.Delete(ctx, name, loc); err != nil {
+		return fmt.Errorf("failed to delete image for reupload: %w", err)
+	}
+	return nil
+}
+
+// clearForceReupload removes the force reupload annotation once the image
+// was uploaded again, so the next reconcile doesn't repeat the upload
+func (r *NodeImageReconciler) clearForceReupload(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) error {
+	if _, ok := nodeImage.Annotations[image.ForceReuploadAnnotation]; !ok {
+		return nil
+	}
+
+	delete(nodeImage.Annotations, image.ForceReuploadAnnotation)
+	if err := r.Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to remove force reupload annotation: %w", err)
+	}
+	log.FromContext(ctx).Info("Forced reupload of node image done")
+	return nil
+}
@@ -0,0 +1,105 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/window"
+)
+
+func TestDistributeForceReupload(t *testing.T) {
+	testCases := []struct {
+		name               string
+		annotations        map[string]string
+		window             string
+		createErr          error
+		expectedDeleted    []string
+		expectedCreated    []string
+		expectedReason     string
+		expectedAnnotation bool
+	}{
+		{
+			name:           "case 0: existing image is not uploaded again without the annotation",
+			expectedReason: imagev1alpha1.NodeImageReasonAlreadyPresent,
+		},
+		{
+			name:            "case 1: existing image is replaced in all locations",
+			annotations:     map[string]string{image.ForceReuploadAnnotation: "true"},
+			expectedDeleted: []string{"dc1", "dc2"},
+			expectedCreated: []string{"dc1", "dc2"},
+			expectedReason:  imagev1alpha1.NodeImageReasonUploaded,
+		},
+		{
+			name:            "case 2: existing image is replaced in the listed locations",
+			annotations:     map[string]string{image.ForceReuploadAnnotation: "dc2, dc3"},
+			expectedDeleted: []string{"dc2"},
+			expectedCreated: []string{"dc2"},
+			expectedReason:  imagev1alpha1.NodeImageReasonUploaded,
+		},
+		{
+			name:               "case 3: image is kept outside of the distribution window",
+			annotations:        map[string]string{image.ForceReuploadAnnotation: "true"},
+			window:             "22:00-06:00",
+			expectedAnnotation: true,
+		},
+		{
+			name:               "case 4: annotation is kept if the upload fails",
+			annotations:        map[string]string{image.ForceReuploadAnnotation: "dc1"},
+			createErr:          errors.New("upload failed"),
+			expectedDeleted:    []string{"dc1"},
+			expectedAnnotation: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace", Annotations: tc.annotations},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			prov := newFakeProvider("dc1", "dc2")
+			prov.images["dc1/test-image"] = true
+			prov.images["dc2/test-image"] = true
+			prov.createErr["dc1"] = tc.createErr
+
+			w, err := window.Parse(tc.window)
+			require.NoError(t, err)
+			k8sClient := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{
+				Client:             k8sClient,
+				DistributionWindow: w,
+				now:                func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) },
+			}
+
+			_, err = r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expectedDeleted, prov.deleted)
+			assert.ElementsMatch(t, tc.expectedCreated, prov.created)
+			assert.True(t, prov.images["dc2/test-image"])
+
+			if tc.expectedReason != "" {
+				condition := meta.FindStatusCondition(nodeImage.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+				require.NotNil(t, condition)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+			}
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, k8sClient.Get(ctx, types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}, stored))
+			_, ok := stored.Annotations[image.ForceReuploadAnnotation]
+			assert.Equal(t, tc.expectedAnnotation, ok)
+		})
+	}
+}
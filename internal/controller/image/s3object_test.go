@@ -0,0 +1,442 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/httpcheck"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+	"github.com/giantswarm/image-distribution-operator/pkg/s3"
+)
+
+func TestDistributeVerifyS3Object(t *testing.T) {
+	testCases := []struct {
+		name            string
+		keyTemplate     string
+		url             string
+		source          [2]string
+		verify          bool
+		exists          bool
+		existsErr       error
+		existing        bool
+		expectedState   imagev1alpha1.NodeImageState
+		expectedMessage string
+		expectedCreated []string
+		expectedChecks  int
+		expectedBucket  [2]string
+		bucket          string
+	}{
+		{
+			name:            "case 0: image in the bucket is uploaded",
+			verify:          true,
+			exists:          true,
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1"},
+			expectedChecks:  1,
+		},
+		{
+			name:            "case 1: image missing in the bucket is marked as missing",
+			verify:          true,
+			exists:          false,
+			expectedState:   imagev1alpha1.NodeImageMissing,
+			expectedMessage: "image capv/test-image/test-image.ova not found in S3 bucket",
+			expectedChecks:  1,
+		},
+		{
+			name:           "case 2: failed check fails the upload",
+			verify:         true,
+			existsErr:      errors.New("access denied"),
+			expectedState:  imagev1alpha1.NodeImageError,
+			expectedChecks: 1,
+		},
+		{
+			name:            "case 3: image is not verified if disabled",
+			verify:          false,
+			exists:          false,
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1"},
+		},
+		{
+			name:          "case 4: image is not verified if already uploaded",
+			verify:        true,
+			exists:        false,
+			existing:      true,
+			expectedState: imagev1alpha1.NodeImageAvailable,
+		},
+		{
+			name:            "case 5: image is looked up at the key of the key template",
+			keyTemplate:     "images/{{.File}}",
+			verify:          true,
+			exists:          false,
+			expectedState:   imagev1alpha1.NodeImageMissing,
+			expectedMessage: "image images/test-image.ova not found in S3 bucket",
+			expectedChecks:  1,
+		},
+		{
+			name:            "case 6: image with a direct URL is not verified",
+			url:             "https://artifacts.example.com/test-image.ova",
+			verify:          true,
+			exists:          false,
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1"},
+		},
+		{
+			name:            "case 7: image is looked up in the bucket of the location",
+			source:          [2]string{"images-dc1", "ap-southeast-1"},
+			verify:          true,
+			exists:          true,
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1"},
+			expectedChecks:  1,
+			expectedBucket:  [2]string{"images-dc1", "ap-southeast-1"},
+		},
+		{
+			name:            "case 8: image missing in the bucket of the location is marked as missing",
+			source:          [2]string{"images-dc1", ""},
+			verify:          true,
+			exists:          false,
+			expectedState:   imagev1alpha1.NodeImageMissing,
+			expectedMessage: "image capv/test-image/test-image.ova not found in S3 bucket images-dc1",
+			expectedChecks:  1,
+			expectedBucket:  [2]string{"images-dc1", ""},
+		},
+		{
+			name:            "case 9: image is looked up in the bucket of the annotation",
+			bucket:          "images-staging",
+			source:          [2]string{"images-dc1", ""},
+			verify:          true,
+			exists:          false,
+			expectedState:   imagev1alpha1.NodeImageMissing,
+			expectedMessage: "image capv/test-image/test-image.ova not found in S3 bucket images-staging",
+			expectedChecks:  1,
+			expectedBucket:  [2]string{"images-staging", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1"}, ctx)
+			require.NoError(t, err)
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv", URL: tc.url},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			if tc.bucket != "" {
+				nodeImage.Annotations = map[string]string{image.S3BucketAnnotation: tc.bucket}
+			}
+			prov := &sourceProvider{
+				fakeProvider: newFakeProvider("dc1"),
+				sources:      map[string][2]string{"dc1": tc.source},
+				urls:         map[string]string{},
+			}
+			if tc.existing {
+				prov.images["dc1/test-image"] = true
+			}
+
+			keyTemplate, err := image.ParseKeyTemplate(tc.keyTemplate)
+			require.NoError(t, err)
+
+			checks := 0
+			var checkedBucket [2]string
+			r := &NodeImageReconciler{
+				Client:           newFakeClient(t, nodeImage),
+				ObjectStore:      s3Client,
+				ImageKeyTemplate: keyTemplate,
+				VerifyS3Object:   tc.verify,
+				objectExists: func(ctx context.Context, bucket string, region string, imageKey string) (bool, error) {
+					checks++
+					checkedBucket = [2]string{bucket, region}
+					return tc.exists, tc.existsErr
+				},
+			}
+
+			_, err = r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedState, nodeImage.Status.State)
+			assert.Equal(t, tc.expectedMessage, nodeImage.Status.Message)
+			assert.Equal(t, tc.expectedCreated, prov.created)
+			assert.Equal(t, tc.expectedChecks, checks)
+			assert.Equal(t, tc.expectedBucket, checkedBucket)
+		})
+	}
+}
+
+// sourceProvider imports images of some locations from their own bucket and
+// records the URL every location imported from
+type sourceProvider struct {
+	*fakeProvider
+	sources map[string][2]string
+	urls    map[string]string
+}
+
+func (p *sourceProvider) ImageSource(loc string) (string, string) {
+	return p.sources[loc][0], p.sources[loc][1]
+}
+
+func (p *sourceProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	p.mu.Lock()
+	p.urls[loc] = imageURL
+	p.mu.Unlock()
+	return p.fakeProvider.Create(ctx, imageURL, imageName, loc)
+}
+
+func TestCreateProviderLocationURL(t *testing.T) {
+	ctx := context.TODO()
+
+	s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1"}, ctx)
+	require.NoError(t, err)
+	url := s3Client.GetURL("capv/test-image/test-image.ova")
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := &sourceProvider{
+		fakeProvider: newFakeProvider("dc-asia", "dc-copy", "dc-europe"),
+		sources: map[string][2]string{
+			"dc-asia": {"images-asia", "ap-southeast-1"},
+			"dc-copy": {"images-copy", ""},
+		},
+		urls: map[string]string{},
+	}
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage), ObjectStore: s3Client}
+
+	_, err = r.distribute(ctx, nodeImage, url, prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"dc-asia":   "https://images-asia.s3.ap-southeast-1.amazonaws.com/capv/test-image/test-image.ova",
+		"dc-copy":   "https://images-copy.s3.eu-west-1.amazonaws.com/capv/test-image/test-image.ova",
+		"dc-europe": url,
+	}, prov.urls)
+}
+
+func TestCreateProviderDirectURL(t *testing.T) {
+	ctx := context.TODO()
+
+	s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1", AllowedHostPatterns: []string{"*.example.com"}}, ctx)
+	require.NoError(t, err)
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv", URL: "https://artifacts.example.com/test-image.ova"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := &sourceProvider{
+		fakeProvider: newFakeProvider("dc-asia", "dc-europe"),
+		sources: map[string][2]string{
+			"dc-asia": {"images-asia", "ap-southeast-1"},
+		},
+		urls: map[string]string{},
+	}
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage), ObjectStore: s3Client}
+
+	url, err := r.imageURL(nodeImage)
+	require.NoError(t, err)
+	require.NoError(t, s3Client.ValidURL(url))
+
+	// the direct URL is imported in every location, even those with their own bucket
+	_, err = r.distribute(ctx, nodeImage, url, prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"dc-asia":   "https://artifacts.example.com/test-image.ova",
+		"dc-europe": "https://artifacts.example.com/test-image.ova",
+	}, prov.urls)
+}
+
+func TestReconcileSourceURL(t *testing.T) {
+	ctx := context.TODO()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1", HTTP: true, AllowedHostPatterns: []string{serverURL.Hostname()}}, ctx)
+	require.NoError(t, err)
+
+	sourceURL := server.URL + "/capv/test-image.ova"
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace", Finalizers: []string{NodeImageFinalizer}},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv", URL: sourceURL},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := newFakeProvider("dc1")
+	c := newFakeClient(t, nodeImage)
+	r := &NodeImageReconciler{
+		Client:       c,
+		ObjectStore:  s3Client,
+		Providers:    map[string]provider.Provider{"capv": prov},
+		ImageChecker: &httpcheck.Checker{},
+	}
+
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}})
+	require.NoError(t, err)
+
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	assert.Equal(t, sourceURL, stored.Status.SourceURL)
+	assert.Equal(t, imagev1alpha1.NodeImageAvailable, stored.Status.State)
+	assert.Equal(t, []string{"dc1"}, prov.created)
+}
+
+// recordingTransport answers every request with 200 OK and records its URL
+type recordingTransport struct {
+	urls []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.urls = append(t.urls, req.URL.String())
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestReconcileBucketOverride(t *testing.T) {
+	testCases := []struct {
+		name              string
+		bucket            string
+		url               string
+		allowedBuckets    []string
+		expectedState     imagev1alpha1.NodeImageState
+		expectedReason    string
+		expectedMessage   string
+		expectedURL       string
+		expectedCreated   []string
+		expectedChecked   []string
+		expectedCondition bool
+	}{
+		{
+			name:            "case 0: image is imported from the bucket of the object store",
+			allowedBuckets:  []string{"images-staging"},
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedURL:     "https://images.s3.eu-west-1.amazonaws.com/capv/test-image/test-image.ova",
+			expectedCreated: []string{"dc1", "dc2"},
+			expectedChecked: []string{
+				"https://images.s3.eu-west-1.amazonaws.com/capv/test-image/test-image.ova",
+				"https://images-dc2.s3.eu-west-1.amazonaws.com/capv/test-image/test-image.ova",
+			},
+		},
+		{
+			name:            "case 1: image is imported from an allowed override bucket in every location",
+			bucket:          "images-staging",
+			allowedBuckets:  []string{"images-staging"},
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedURL:     "https://images-staging.s3.eu-west-1.amazonaws.com/capv/test-image/test-image.ova",
+			expectedCreated: []string{"dc1", "dc2"},
+			expectedChecked: []string{"https://images-staging.s3.eu-west-1.amazonaws.com/capv/test-image/test-image.ova"},
+		},
+		{
+			name:              "case 2: bucket not in the allowlist is rejected",
+			bucket:            "attacker-bucket",
+			allowedBuckets:    []string{"images-staging"},
+			expectedState:     imagev1alpha1.NodeImageError,
+			expectedReason:    imagev1alpha1.NodeImageReasonBucketNotAllowed,
+			expectedMessage:   `S3 bucket "attacker-bucket" is not allowed`,
+			expectedCondition: true,
+		},
+		{
+			name:              "case 3: bucket is rejected without an allowlist",
+			bucket:            "images-staging",
+			expectedState:     imagev1alpha1.NodeImageError,
+			expectedReason:    imagev1alpha1.NodeImageReasonBucketNotAllowed,
+			expectedMessage:   `S3 bucket "images-staging" is not allowed`,
+			expectedCondition: true,
+		},
+		{
+			name:              "case 4: URL in a bucket not in the allowlist is rejected",
+			url:               "https://attacker-bucket.s3.eu-west-1.amazonaws.com/capv/test-image.ova",
+			allowedBuckets:    []string{"images-staging"},
+			expectedState:     imagev1alpha1.NodeImageError,
+			expectedReason:    imagev1alpha1.NodeImageReasonBucketNotAllowed,
+			expectedMessage:   `S3 bucket "attacker-bucket" is not allowed`,
+			expectedCondition: true,
+		},
+		{
+			name:            "case 5: URL in an allowed bucket is imported in every location",
+			url:             "https://images-staging.s3.eu-west-1.amazonaws.com/capv/test-image.ova",
+			allowedBuckets:  []string{"images-staging"},
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedURL:     "https://images-staging.s3.eu-west-1.amazonaws.com/capv/test-image.ova",
+			expectedCreated: []string{"dc1", "dc2"},
+			expectedChecked: []string{"https://images-staging.s3.eu-west-1.amazonaws.com/capv/test-image.ova"},
+		},
+		{
+			name:            "case 6: URL in the bucket of the object store needs no allowlist",
+			url:             "https://images.s3.eu-west-1.amazonaws.com/capv/test-image.ova",
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedURL:     "https://images.s3.eu-west-1.amazonaws.com/capv/test-image.ova",
+			expectedCreated: []string{"dc1", "dc2"},
+			expectedChecked: []string{"https://images.s3.eu-west-1.amazonaws.com/capv/test-image.ova"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			s3Client, err := s3.New(s3.Config{BucketName: "images", Region: "eu-west-1"}, ctx)
+			require.NoError(t, err)
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace", Finalizers: []string{NodeImageFinalizer}},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv", URL: tc.url},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			if tc.bucket != "" {
+				nodeImage.Annotations = map[string]string{image.S3BucketAnnotation: tc.bucket}
+			}
+			// dc2 has a bucket of its own, which the override takes precedence over
+			prov := &sourceProvider{
+				fakeProvider: newFakeProvider("dc1", "dc2"),
+				sources:      map[string][2]string{"dc2": {"images-dc2", ""}},
+				urls:         map[string]string{},
+			}
+			transport := &recordingTransport{}
+			c := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{
+				Client:         c,
+				ObjectStore:    s3Client,
+				AllowedBuckets: tc.allowedBuckets,
+				Providers:      map[string]provider.Provider{"capv": prov},
+				ImageChecker:   &httpcheck.Checker{Client: &http.Client{Transport: transport}},
+			}
+
+			_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}})
+			require.NoError(t, err)
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+			assert.Equal(t, tc.expectedState, stored.Status.State)
+			assert.Equal(t, tc.expectedURL, stored.Status.SourceURL)
+			assert.Equal(t, tc.expectedChecked, transport.urls)
+			assert.ElementsMatch(t, tc.expectedCreated, prov.created)
+			if (tc.bucket != "" || tc.url != "") && tc.expectedURL != "" {
+				assert.Equal(t, map[string]string{"dc1": tc.expectedURL, "dc2": tc.expectedURL}, prov.urls)
+			}
+
+			condition := meta.FindStatusCondition(stored.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+			if tc.expectedCondition {
+				require.NotNil(t, condition)
+				assert.Equal(t, tc.expectedReason, condition.Reason)
+				assert.Equal(t, tc.expectedMessage, condition.Message)
+			}
+		})
+	}
+}
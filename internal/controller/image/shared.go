@@ -0,0 +1,19 @@
+package image
+
+import (
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// forEachImageLocation calls fn for every location like forEachLocation,
+// handing every location its own copy of nodeImage to read from. The
+// locations write their status to nodeImage itself while holding statusMu, so
+// no location reads the object while another one writes the response of the
+// API server into it.
+func (r *NodeImageReconciler) forEachImageLocation(nodeImage *imagev1alpha1.NodeImage, locations []string, fn func(worker *imagev1alpha1.NodeImage, loc string) error) error {
+	return r.forEachLocation(locations, func(loc string) error {
+		r.statusMu.Lock()
+		worker := nodeImage.DeepCopy()
+		r.statusMu.Unlock()
+		return fn(worker, loc)
+	})
+}
@@ -0,0 +1,65 @@
+package image
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+func TestDistributeWritesStateOnce(t *testing.T) {
+	ctx := context.TODO()
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	// the image is present in dc2 and uploaded to the others
+	prov := newFakeProvider("dc1", "dc2", "dc3")
+	prov.images["dc2/test-image"] = true
+
+	// every state written to the status, while the locations write theirs
+	var mu sync.Mutex
+	states := []imagev1alpha1.NodeImageState{imagev1alpha1.NodeImagePending}
+	scheme := runtime.NewScheme()
+	require.NoError(t, imagev1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&imagev1alpha1.NodeImage{}).
+		WithObjects(nodeImage).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if state := obj.(*imagev1alpha1.NodeImage).Status.State; state != states[len(states)-1] {
+					states = append(states, state)
+				}
+				return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	r := &NodeImageReconciler{Client: c}
+
+	_, err := r.distribute(ctx, nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"dc1", "dc3"}, prov.created)
+
+	// the state only changes once all locations are done
+	assert.Equal(t, []imagev1alpha1.NodeImageState{imagev1alpha1.NodeImagePending, imagev1alpha1.NodeImageAvailable}, states)
+
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	for _, loc := range prov.locations {
+		assert.Equal(t, imagev1alpha1.NodeImageAvailable, statusLocation(stored, loc).State, loc)
+	}
+}
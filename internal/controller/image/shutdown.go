@@ -0,0 +1,83 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+)
+
+// abortStatusTimeout bounds the status updates recording an aborted upload,
+// which run after the reconcile context was cancelled
+const abortStatusTimeout = 10 * time.Second
+
+// uploadContext returns the context an upload runs with. When ctx is
+// cancelled because the operator shuts down, the upload gets
+// ShutdownGracePeriod to complete before its context is cancelled too.
+func (r *NodeImageReconciler) uploadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.ShutdownGracePeriod <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	uploadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		log.FromContext(ctx).Info("Operator shutting down - waiting for the upload to complete", "gracePeriod", r.ShutdownGracePeriod)
+		timer := time.AfterFunc(r.ShutdownGracePeriod, cancel)
+		context.AfterFunc(uploadCtx, func() { timer.Stop() })
+	})
+	return uploadCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// uploadAborted records an upload the operator shutdown cut short in shared.
+// The location is marked for a forced reupload, so the next reconcile
+// replaces whatever the upload left behind instead of taking it as present.
+func (r *NodeImageReconciler) uploadAborted(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, shared *imagev1alpha1.NodeImage, loc string, err error) error {
+	log.FromContext(ctx).Info("Upload aborted by operator shutdown - it is retried from scratch on the next reconcile", "error", err.Error())
+
+	// the reconcile context is cancelled already
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortStatusTimeout)
+	defer cancel()
+
+	markErr := r.markForReupload(ctx, shared, loc)
+	statusErr := r.setConditions(ctx, shared, metav1.Condition{
+		Type:               imagev1alpha1.NodeImageConditionDistributed,
+		Status:             metav1.ConditionFalse,
+		Reason:             imagev1alpha1.NodeImageReasonUploadAborted,
+		Message:            fmt.Sprintf("Upload to location %s aborted by operator shutdown, retried on the next reconcile", loc),
+		ObservedGeneration: nodeImage.Generation,
+	})
+	return errors.Join(fmt.Errorf("upload aborted: %w", err), markErr, statusErr)
+}
+
+// markForReupload adds the location to the force reupload annotation
+func (r *NodeImageReconciler) markForReupload(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, loc string) error {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	if forceReupload(nodeImage, loc) {
+		return nil
+	}
+	value := loc
+	if existing := strings.TrimSpace(nodeImage.Annotations[image.ForceReuploadAnnotation]); existing != "" {
+		value = existing + "," + loc
+	}
+	if nodeImage.Annotations == nil {
+		nodeImage.Annotations = make(map[string]string)
+	}
+	nodeImage.Annotations[image.ForceReuploadAnnotation] = value
+
+	if err := r.Update(ctx, nodeImage); err != nil {
+		return fmt.Errorf("failed to mark location %s for reupload: %w", loc, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+)
+
+// blockingProvider blocks in Create until the upload is released or its
+// context is done
+type blockingProvider struct {
+	*fakeProvider
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	close(p.started)
+	select {
+	case <-p.release:
+		return p.fakeProvider.Create(ctx, imageURL, imageName, loc)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestCreateProviderShutdown(t *testing.T) {
+	testCases := []struct {
+		name               string
+		gracePeriod        time.Duration
+		release            bool
+		expectedError      bool
+		expectedState      imagev1alpha1.NodeImageState
+		expectedReason     string
+		expectedAnnotation string
+	}{
+		{
+			name:           "case 0: upload completes within the grace period",
+			gracePeriod:    time.Minute,
+			release:        true,
+			expectedState:  imagev1alpha1.NodeImageAvailable,
+			expectedReason: imagev1alpha1.NodeImageReasonUploaded,
+		},
+		{
+			name:               "case 1: upload is aborted after the grace period",
+			gracePeriod:        50 * time.Millisecond,
+			expectedError:      true,
+			expectedState:      imagev1alpha1.NodeImageError,
+			expectedReason:     imagev1alpha1.NodeImageReasonUploadAborted,
+			expectedAnnotation: "dc1",
+		},
+		{
+			name:               "case 2: upload is aborted right away without a grace period",
+			expectedError:      true,
+			expectedState:      imagev1alpha1.NodeImageError,
+			expectedReason:     imagev1alpha1.NodeImageReasonUploadAborted,
+			expectedAnnotation: "dc1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			prov := &blockingProvider{
+				fakeProvider: newFakeProvider("dc1"),
+				started:      make(chan struct{}),
+				release:      make(chan struct{}),
+			}
+			k8sClient := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{Client: k8sClient, ShutdownGracePeriod: tc.gracePeriod}
+
+			// the operator shuts down while the upload is running
+			ctx, cancel := context.WithCancel(context.TODO())
+			go func() {
+				<-prov.started
+				cancel()
+				if tc.release {
+					close(prov.release)
+				}
+			}()
+
+			_, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			if tc.expectedError {
+				assert.ErrorIs(t, err, context.Canceled)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, k8sClient.Get(context.TODO(), types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}, stored))
+			assert.Equal(t, tc.expectedState, statusLocation(stored, "dc1").State)
+			condition := meta.FindStatusCondition(stored.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+			require.NotNil(t, condition)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+			assert.Equal(t, tc.expectedAnnotation, stored.Annotations[image.ForceReuploadAnnotation])
+		})
+	}
+}
+
+func TestMarkForReupload(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:     "case 0: location is marked",
+			expected: "dc1",
+		},
+		{
+			name:        "case 1: location is added to the marked ones",
+			annotations: map[string]string{image.ForceReuploadAnnotation: "dc2"},
+			expected:    "dc2,dc1",
+		},
+		{
+			name:        "case 2: location marked already is kept",
+			annotations: map[string]string{image.ForceReuploadAnnotation: "dc1,dc2"},
+			expected:    "dc1,dc2",
+		},
+		{
+			name:        "case 3: all locations marked already are kept",
+			annotations: map[string]string{image.ForceReuploadAnnotation: "true"},
+			expected:    "true",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace", Annotations: tc.annotations},
+			}
+			r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage)}
+
+			require.NoError(t, r.markForReupload(context.TODO(), nodeImage, "dc1"))
+			assert.Equal(t, tc.expected, nodeImage.Annotations[image.ForceReuploadAnnotation])
+		})
+	}
+}
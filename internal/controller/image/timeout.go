@@ -0,0 +1,45 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// operationContext returns the context a provider operation, e.g. an import
+// or a deletion, runs with. Cancelling it aborts the provider task, so a hung
+// task doesn't block the work queue item forever.
+func (r *NodeImageReconciler) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.OperationTimeout)
+}
+
+// timedOut reports whether the operation running with ctx failed because it
+// exceeded the operation timeout, and not because its parent was cancelled
+func timedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), context.DeadlineExceeded)
+}
+
+// uploadTimedOut records an upload aborted after the operation timeout. Like
+// an upload aborted by a shutdown the location is marked for a forced
+// reupload, so the next attempt replaces whatever the upload left behind.
+func (r *NodeImageReconciler) uploadTimedOut(ctx context.Context, nodeImage *imagev1alpha1.NodeImage, shared *imagev1alpha1.NodeImage, loc string, err error) error {
+	log.FromContext(ctx).Info("Upload timed out - aborted", "timeout", r.OperationTimeout)
+
+	markErr := r.markForReupload(ctx, shared, loc)
+	statusErr := r.setConditions(ctx, shared, metav1.Condition{
+		Type:               imagev1alpha1.NodeImageConditionDistributed,
+		Status:             metav1.ConditionFalse,
+		Reason:             imagev1alpha1.NodeImageReasonTimeout,
+		Message:            fmt.Sprintf("Upload to location %s did not complete within %s", loc, r.OperationTimeout),
+		ObservedGeneration: nodeImage.Generation,
+	})
+	return errors.Join(fmt.Errorf("upload timed out after %s: %w", r.OperationTimeout, err), markErr, statusErr)
+}
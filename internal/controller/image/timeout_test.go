@@ -0,0 +1,137 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+)
+
+// hangingDeleteProvider blocks in Delete until its context is done, like a
+// provider task that never completes
+type hangingDeleteProvider struct {
+	*fakeProvider
+}
+
+func (p *hangingDeleteProvider) Delete(ctx context.Context, name string, loc string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCreateProviderTimeout(t *testing.T) {
+	testCases := []struct {
+		name               string
+		timeout            time.Duration
+		release            bool
+		expectedError      bool
+		expectedState      imagev1alpha1.NodeImageState
+		expectedReason     string
+		expectedAnnotation string
+	}{
+		{
+			name:           "case 0: upload completes within the timeout",
+			timeout:        time.Minute,
+			release:        true,
+			expectedState:  imagev1alpha1.NodeImageAvailable,
+			expectedReason: imagev1alpha1.NodeImageReasonUploaded,
+		},
+		{
+			name:           "case 1: upload is not bounded without a timeout",
+			release:        true,
+			expectedState:  imagev1alpha1.NodeImageAvailable,
+			expectedReason: imagev1alpha1.NodeImageReasonUploaded,
+		},
+		{
+			name:               "case 2: hanging upload is aborted after the timeout",
+			timeout:            50 * time.Millisecond,
+			expectedError:      true,
+			expectedState:      imagev1alpha1.NodeImageError,
+			expectedReason:     imagev1alpha1.NodeImageReasonTimeout,
+			expectedAnnotation: "dc1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			prov := &blockingProvider{
+				fakeProvider: newFakeProvider("dc1"),
+				started:      make(chan struct{}),
+				release:      make(chan struct{}),
+			}
+			if tc.release {
+				close(prov.release)
+			}
+			k8sClient := newFakeClient(t, nodeImage)
+			r := &NodeImageReconciler{Client: k8sClient, OperationTimeout: tc.timeout}
+
+			_, err := r.CreateProvider(context.TODO(), nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			if tc.expectedError {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			stored := &imagev1alpha1.NodeImage{}
+			require.NoError(t, k8sClient.Get(context.TODO(), types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}, stored))
+			assert.Equal(t, tc.expectedState, statusLocation(stored, "dc1").State)
+			condition := meta.FindStatusCondition(stored.Status.Conditions, imagev1alpha1.NodeImageConditionDistributed)
+			require.NotNil(t, condition)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+			assert.Equal(t, tc.expectedAnnotation, stored.Annotations[image.ForceReuploadAnnotation])
+		})
+	}
+}
+
+func TestDistributeTimeoutRequeues(t *testing.T) {
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+	prov := &blockingProvider{
+		fakeProvider: newFakeProvider("dc1"),
+		started:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	k8sClient := newFakeClient(t, nodeImage)
+	r := &NodeImageReconciler{Client: k8sClient, OperationTimeout: 50 * time.Millisecond}
+
+	// the work queue item is released and retried with the failure backoff
+	result, err := r.distribute(context.TODO(), nodeImage, "https://example.com/image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Positive(t, result.RequeueAfter)
+
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, k8sClient.Get(context.TODO(), types.NamespacedName{Name: nodeImage.Name, Namespace: nodeImage.Namespace}, stored))
+	assert.Equal(t, imagev1alpha1.NodeImageError, stored.Status.State)
+	assert.Equal(t, int32(1), stored.Status.ConsecutiveFailures)
+}
+
+func TestDeleteProviderTimeout(t *testing.T) {
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{State: imagev1alpha1.NodeImageAvailable},
+	}
+	prov := &hangingDeleteProvider{fakeProvider: newFakeProvider("dc1")}
+	r := &NodeImageReconciler{Client: newFakeClient(t, nodeImage), OperationTimeout: 50 * time.Millisecond}
+
+	err := r.DeleteProvider(context.TODO(), nodeImage, nodeImage, "dc1", prov)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "timed out after 50ms")
+	// the location stays in the status until the image is gone
+	assert.Len(t, nodeImage.Status.Locations, 1)
+}
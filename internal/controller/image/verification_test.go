@@ -0,0 +1,147 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+func TestCreateProviderVerificationPending(t *testing.T) {
+	testCases := []struct {
+		name            string
+		delay           time.Duration
+		existing        bool
+		dryRun          bool
+		expectedPending bool
+	}{
+		{
+			name:            "case 0: upload is verified when a delay is set",
+			delay:           30 * time.Second,
+			expectedPending: true,
+		},
+		{
+			name:  "case 1: verification disabled",
+			delay: 0,
+		},
+		{
+			name:     "case 2: already present images are not verified",
+			delay:    30 * time.Second,
+			existing: true,
+		},
+		{
+			name:   "case 3: dry run uploads are not verified",
+			delay:  30 * time.Second,
+			dryRun: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+			prov := newFakeProvider("dc1")
+			if tc.existing {
+				prov.images["dc1/test-image"] = true
+			}
+
+			r := &NodeImageReconciler{
+				Client:            newFakeClient(t, nodeImage),
+				VerificationDelay: tc.delay,
+				DryRun:            tc.dryRun,
+			}
+
+			state, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/image.ova", "dc1", prov)
+			require.NoError(t, err)
+			assert.Equal(t, imagev1alpha1.NodeImageAvailable, state)
+			assert.Equal(t, tc.expectedPending, r.verificationPending(nodeImage))
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	testCases := []struct {
+		name           string
+		present        []string
+		expectError    bool
+		expectedState  imagev1alpha1.NodeImageState
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "case 0: image present in all locations",
+			present:        []string{"dc1", "dc2"},
+			expectedState:  imagev1alpha1.NodeImageAvailable,
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: imagev1alpha1.NodeImageReasonPresent,
+		},
+		{
+			name:           "case 1: image missing in one location",
+			present:        []string{"dc1"},
+			expectError:    true,
+			expectedState:  imagev1alpha1.NodeImageError,
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: imagev1alpha1.NodeImageReasonNotPresent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status: imagev1alpha1.NodeImageStatus{
+					Releases: []string{"v1.0.0"},
+					State:    imagev1alpha1.NodeImageAvailable,
+					Conditions: []metav1.Condition{{
+						Type:               imagev1alpha1.NodeImageConditionVerified,
+						Status:             metav1.ConditionUnknown,
+						Reason:             imagev1alpha1.NodeImageReasonVerificationPending,
+						LastTransitionTime: metav1.Now(),
+					}},
+				},
+			}
+			prov := newFakeProvider("dc1", "dc2")
+			for _, loc := range tc.present {
+				prov.images[loc+"/test-image"] = true
+			}
+
+			r := &NodeImageReconciler{
+				Client:            newFakeClient(t, nodeImage),
+				VerificationDelay: 30 * time.Second,
+			}
+			require.True(t, r.verificationPending(nodeImage))
+
+			result, err := r.verify(ctx, nodeImage, prov.locations, prov)
+			if tc.expectError {
+				assert.ErrorContains(t, err, "location dc2")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, DefaultRequeue(), result)
+			}
+
+			// verification never uploads
+			assert.Empty(t, prov.created)
+			assert.Equal(t, tc.expectedState, nodeImage.Status.State)
+			assert.False(t, r.verificationPending(nodeImage))
+
+			condition := meta.FindStatusCondition(nodeImage.Status.Conditions, imagev1alpha1.NodeImageConditionVerified)
+			require.NotNil(t, condition)
+			assert.Equal(t, tc.expectedStatus, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+		})
+	}
+}
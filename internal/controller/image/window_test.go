@@ -0,0 +1,147 @@
+package image
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/window"
+)
+
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	require.NoError(t, imagev1alpha1.AddToScheme(scheme))
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&imagev1alpha1.NodeImage{}).
+		WithObjects(objs...).
+		Build()
+}
+
+func TestCreateProviderDistributionWindow(t *testing.T) {
+	testCases := []struct {
+		name            string
+		window          string
+		now             time.Time
+		existing        bool
+		expectedState   imagev1alpha1.NodeImageState
+		expectedCreated []string
+	}{
+		{
+			name:            "case 0: no window uploads at any time",
+			window:          "",
+			now:             time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1"},
+		},
+		{
+			name:            "case 1: inside the window the image is uploaded",
+			window:          "22:00-06:00",
+			now:             time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC),
+			expectedState:   imagev1alpha1.NodeImageAvailable,
+			expectedCreated: []string{"dc1"},
+		},
+		{
+			name:          "case 2: outside the window the upload is scheduled",
+			window:        "22:00-06:00",
+			now:           time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			expectedState: imagev1alpha1.NodeImageScheduled,
+		},
+		{
+			name:          "case 3: existing images are reported outside the window",
+			window:        "22:00-06:00",
+			now:           time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			existing:      true,
+			expectedState: imagev1alpha1.NodeImageAvailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			w, err := window.Parse(tc.window)
+			require.NoError(t, err)
+
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+				Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+			}
+
+			prov := newFakeProvider("dc1")
+			if tc.existing {
+				prov.images["dc1/test-image"] = true
+			}
+
+			r := &NodeImageReconciler{
+				Client:             newFakeClient(t, nodeImage),
+				DistributionWindow: w,
+				now:                func() time.Time { return tc.now },
+			}
+
+			state, err := r.CreateProvider(ctx, nodeImage, nodeImage, "https://example.com/test-image.ova", "dc1", prov)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedState, state)
+			assert.Equal(t, tc.expectedState, statusLocation(nodeImage, "dc1").State)
+			assert.Equal(t, tc.expectedCreated, prov.created)
+		})
+	}
+}
+
+func TestScheduledRequeue(t *testing.T) {
+	w, err := window.Parse("22:00-06:00")
+	require.NoError(t, err)
+
+	r := &NodeImageReconciler{
+		DistributionWindow: w,
+		now:                func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) },
+	}
+	assert.Equal(t, 10*time.Hour, r.scheduledRequeue().RequeueAfter)
+
+	// the window opened in the meantime, fall back to the default requeue
+	r.now = func() time.Time { return time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC) }
+	assert.Equal(t, DefaultRequeue(), r.scheduledRequeue())
+}
+
+func TestDistributeScheduledLocation(t *testing.T) {
+	ctx := context.TODO()
+
+	w, err := window.Parse("22:00-06:00")
+	require.NoError(t, err)
+
+	nodeImage := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "test-namespace"},
+		Spec:       imagev1alpha1.NodeImageSpec{Name: "test-image", Provider: "capv"},
+		Status:     imagev1alpha1.NodeImageStatus{Releases: []string{"v1.0.0"}, State: imagev1alpha1.NodeImagePending},
+	}
+
+	// the image is present in some locations and scheduled for the others,
+	// whichever location finishes last
+	prov := newFakeProvider("dc1", "dc2", "dc3", "dc4")
+	prov.images["dc1/test-image"] = true
+	prov.images["dc3/test-image"] = true
+
+	r := &NodeImageReconciler{
+		Client:             newFakeClient(t, nodeImage),
+		DistributionWindow: w,
+		now:                func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) },
+	}
+
+	result, err := r.distribute(ctx, nodeImage, "https://example.com/test-image.ova", prov.locations, prov)
+	require.NoError(t, err)
+	assert.Equal(t, r.scheduledRequeue(), result)
+	assert.Empty(t, prov.created)
+
+	stored := &imagev1alpha1.NodeImage{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(nodeImage), stored))
+	assert.Equal(t, imagev1alpha1.NodeImageScheduled, stored.Status.State)
+}
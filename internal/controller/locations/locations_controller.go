@@ -0,0 +1,107 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package locations
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// Key is the key of the locations file in the ConfigMaps
+const Key = "locations"
+
+// LocationsReconciler reloads the locations of providers from the ConfigMaps
+// they are loaded from, so locations can be added without a restart
+type LocationsReconciler struct {
+	client.Client
+	// Namespace is the namespace of the ConfigMaps
+	Namespace string
+	// ConfigMaps maps the names of the ConfigMaps to the providers loading
+	// their locations from them
+	ConfigMaps map[string]provider.LocationReloader
+}
+
+// Load returns the locations file in the ConfigMap, read with reader, e.g.
+// the API reader of a manager that is not started yet
+func Load(ctx context.Context, reader client.Reader, key client.ObjectKey) ([]byte, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := reader.Get(ctx, key, configMap); err != nil {
+		return nil, fmt.Errorf("failed to get locations ConfigMap %s: %w", key, err)
+	}
+	data, ok := configMap.Data[Key]
+	if !ok {
+		return nil, fmt.Errorf("locations ConfigMap %s has no key %s", key, Key)
+	}
+	return []byte(data), nil
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// Reconcile reloads the locations of the provider of a changed ConfigMap. A
+// deleted ConfigMap leaves the current locations in place. So do locations
+// failing to reload, which are retried with backoff, e.g. in case the
+// vCenter they are checked against was unreachable.
+func (r *LocationsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	reloader, ok := r.ConfigMaps[req.Name]
+	if !ok || req.Namespace != r.Namespace {
+		return ctrl.Result{}, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, req.NamespacedName, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("Locations ConfigMap not found, keeping the current locations")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	data, ok := configMap.Data[Key]
+	if !ok {
+		log.Info("Locations ConfigMap has no locations, keeping the current locations", "key", Key)
+		return ctrl.Result{}, nil
+	}
+	if err := reloader.ReloadLocations(ctx, []byte(data)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to reload locations, keeping the current locations: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager, watching only the
+// ConfigMaps of the providers
+func (r *LocationsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	watched := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := r.ConfigMaps[obj.GetName()]
+		return ok && obj.GetNamespace() == r.Namespace
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(watched)).
+		Named("locations").
+		Complete(r)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package locations
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// fakeReloader records the locations it was reloaded with
+type fakeReloader struct {
+	err      error
+	reloaded []string
+}
+
+func (f *fakeReloader) ReloadLocations(ctx context.Context, data []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.reloaded = append(f.reloaded, string(data))
+	return nil
+}
+
+func TestReconcile(t *testing.T) {
+	testCases := []struct {
+		name             string
+		configMap        *corev1.ConfigMap
+		request          types.NamespacedName
+		reloadErr        error
+		expectedReloaded []string
+		expectedError    bool
+	}{
+		{
+			name: "case 0: locations are reloaded",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "vsphere-locations", Namespace: "giantswarm"},
+				Data:       map[string]string{Key: "dc1: {}"},
+			},
+			request:          types.NamespacedName{Name: "vsphere-locations", Namespace: "giantswarm"},
+			expectedReloaded: []string{"dc1: {}"},
+		},
+		{
+			name:    "case 1: missing ConfigMap keeps the locations",
+			request: types.NamespacedName{Name: "vsphere-locations", Namespace: "giantswarm"},
+		},
+		{
+			name: "case 2: ConfigMap without locations keeps the locations",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "vsphere-locations", Namespace: "giantswarm"},
+				Data:       map[string]string{"other": "dc1: {}"},
+			},
+			request: types.NamespacedName{Name: "vsphere-locations", Namespace: "giantswarm"},
+		},
+		{
+			name: "case 3: ConfigMap in another namespace is ignored",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "vsphere-locations", Namespace: "default"},
+				Data:       map[string]string{Key: "dc1: {}"},
+			},
+			request: types.NamespacedName{Name: "vsphere-locations", Namespace: "default"},
+		},
+		{
+			name: "case 4: failed reload is retried",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "vsphere-locations", Namespace: "giantswarm"},
+				Data:       map[string]string{Key: "dc1: {}"},
+			},
+			request:       types.NamespacedName{Name: "vsphere-locations", Namespace: "giantswarm"},
+			reloadErr:     errors.New("datacenter is required for location dc1"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+			if tc.configMap != nil {
+				builder = builder.WithObjects(tc.configMap)
+			}
+
+			reloader := &fakeReloader{err: tc.reloadErr}
+			r := &LocationsReconciler{
+				Client:     builder.Build(),
+				Namespace:  "giantswarm",
+				ConfigMaps: map[string]provider.LocationReloader{"vsphere-locations": reloader},
+			}
+
+			_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: tc.request})
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedReloaded, reloader.reloaded)
+		})
+	}
+}
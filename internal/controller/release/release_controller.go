@@ -18,32 +18,64 @@ package release
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"strconv"
 	"time"
 
+	images "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
 	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
 
 	"github.com/giantswarm/releases/sdk/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
 	ReleaseControllerFinalizer = "image-distribution-operator.finalizers.giantswarm.io/release-controller"
+
+	// NodeImagesReadyAnnotation is set on a release to "true" once all of its
+	// node images are Available in their provider, and to "false" before
+	NodeImagesReadyAnnotation = "image-distribution-operator.giantswarm.io/node-images-ready"
 )
 
 // ReleaseReconciler reconciles a Release object
 type ReleaseReconciler struct {
 	client.Client
+	// Namespace is the namespace the node images of all releases are created
+	// in. Releases are cluster scoped, so there is no release namespace to
+	// restrict the watch to or to derive the node image namespace from.
 	Namespace            string
 	Providers            map[string]interface{}
 	ImageRetentionPeriod time.Duration
+	// ImageNameTemplate is the text/template used to name Flatcar images,
+	// image.DefaultNameTemplate is used if empty
+	ImageNameTemplate string
+	// ImageNameCollisionPolicy selects how ambiguous node image object names
+	// are handled, image.NameCollisionHash is used if empty
+	ImageNameCollisionPolicy string
+	// Recorder, when set, records an event on releases that are skipped
+	// because no node image can be built for them
+	Recorder events.EventRecorder
 }
 
+// ReasonInvalidRelease is the reason of the event recorded on a release no
+// node image can be built for
+const ReasonInvalidRelease = "InvalidRelease"
+
 // +kubebuilder:rbac:groups=release.giantswarm.io,resources=releases,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=release.giantswarm.io,resources=releases/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -55,49 +87,61 @@ type ReleaseReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.20.0/pkg/reconcile
 func (r *ReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
-
 	// Fetch the Release
 	release := &v1alpha1.Release{}
 	err := r.Get(ctx, req.NamespacedName, release)
 	if err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	ctx = provider.WithLogValues(ctx, provider.LogKeyRelease, release.Name)
 
-	flatcarChannel := "stable" // TODO: ensure that this is what it is supposed to be or if it comes from somewhere else
-
-	nodeImage, err := image.GetNodeImageFromRelease(release, flatcarChannel)
+	imageClient, err := image.New(image.Config{
+		Client:              r.Client,
+		Namespace:           r.Namespace,
+		Release:             release.Name,
+		NameTemplate:        r.ImageNameTemplate,
+		NameCollisionPolicy: r.ImageNameCollisionPolicy,
+	})
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// Check if the provider for this release is configured
-	if _, ok := r.Providers[nodeImage.Spec.Provider]; !ok {
-		log.Info("Provider not configured - skipping release", "provider", nodeImage.Spec.Provider, "release", release.Name)
-		return ctrl.Result{}, nil
+	nodeImages, err := imageClient.GetNodeImagesFromRelease(release)
+	if errors.Is(err, image.ErrInvalidRelease) {
+		return ctrl.Result{}, r.skipInvalidRelease(ctx, release, err)
+	} else if err != nil {
+		return ctrl.Result{}, err
 	}
+	// the node images of all architectures share the provider of the release
+	providerName := nodeImages[0].Spec.Provider
+	ctx = provider.WithLogValues(ctx, provider.LogKeyProvider, providerName)
+	log := log.FromContext(ctx)
 
-	imageClient, err := image.New(image.Config{
-		Client:    r.Client,
-		Namespace: r.Namespace,
-		Release:   release.Name,
-	})
-	if err != nil {
-		return ctrl.Result{}, err
+	// Check if the provider for this release is configured
+	if _, ok := r.Providers[providerName]; !ok {
+		log.Info("Provider not configured - skipping release")
+		return ctrl.Result{}, nil
 	}
 
 	// Handle deleted release
 	if IsDeleted(release) {
 		log.Info("Release is being deleted")
 
-		// Remove release from image status
-		if err := imageClient.RemoveReleaseFromNodeImageStatus(ctx, nodeImage.Name); err != nil {
+		if err := r.releaseDroppedNodeImages(ctx, imageClient, nodeImages); err != nil {
 			return ctrl.Result{}, err
 		}
+		for _, nodeImage := range nodeImages {
+			ctx := provider.WithLogValues(ctx, provider.LogKeyNodeImage, nodeImage.Name)
 
-		// Handle deletion
-		if err := imageClient.DeleteImage(ctx, nodeImage.Name, r.ImageRetentionPeriod); err != nil {
-			return ctrl.Result{}, err
+			// Remove release from image status
+			if err := imageClient.RemoveReleaseFromNodeImageStatus(ctx, nodeImage.Name); err != nil {
+				return ctrl.Result{}, err
+			}
+
+			// Handle deletion
+			if err := imageClient.DeleteImage(ctx, nodeImage.Name, r.ImageRetentionPeriod); err != nil {
+				return ctrl.Result{}, err
+			}
 		}
 
 		// remove finalizer
@@ -120,32 +164,180 @@ func (r *ReleaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		log.Info("Finalizer added to Release", "finalizer", ReleaseControllerFinalizer)
 	}
 
-	// Handle creation
-	if err := imageClient.CreateImage(ctx, nodeImage); err != nil {
+	for _, nodeImage := range nodeImages {
+		ctx := provider.WithLogValues(ctx, provider.LogKeyNodeImage, nodeImage.Name)
+
+		// Handle creation
+		if err := imageClient.CreateImage(ctx, nodeImage); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Add Releases to the image status
+		if err := imageClient.AddReleaseToNodeImageStatus(ctx, nodeImage.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.releaseDroppedNodeImages(ctx, imageClient, nodeImages); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// Add Releases to the image status
-	if err := imageClient.AddReleaseToNodeImageStatus(ctx, nodeImage.Name); err != nil {
+	pending, err := r.pendingNodeImages(ctx, nodeImages)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	if err := r.setNodeImagesReady(ctx, release, len(pending) == 0); err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(pending) > 0 {
+		log.Info("Waiting for node images to become available", "pending", pending)
+		return PendingRequeue(), nil
+	}
 
 	return DefaultRequeue(), nil
 }
 
+// releaseDroppedNodeImages removes the release from the node images that list
+// it but are no longer among nodeImages, e.g. the node image of an
+// architecture removed from the release, and deletes them once no release
+// uses them anymore
+func (r *ReleaseReconciler) releaseDroppedNodeImages(ctx context.Context, imageClient *image.Client, nodeImages []*images.NodeImage) error {
+	names, err := imageClient.NodeImagesOfRelease(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if slices.ContainsFunc(nodeImages, func(nodeImage *images.NodeImage) bool { return nodeImage.Name == name }) {
+			continue
+		}
+		ctx := provider.WithLogValues(ctx, provider.LogKeyNodeImage, name)
+		log.FromContext(ctx).Info("Node image no longer used by the release")
+
+		if err := imageClient.RemoveReleaseFromNodeImageStatus(ctx, name); err != nil {
+			return err
+		}
+		if err := imageClient.DeleteImage(ctx, name, r.ImageRetentionPeriod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pendingNodeImages returns the names of the node images that are not
+// Available yet
+func (r *ReleaseReconciler) pendingNodeImages(ctx context.Context, nodeImages []*images.NodeImage) ([]string, error) {
+	var pending []string
+	for _, nodeImage := range nodeImages {
+		stored := &images.NodeImage{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: r.Namespace, Name: nodeImage.Name}, stored)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		// a node image just created may not be in the cache yet
+		if err != nil || stored.Status.State != images.NodeImageAvailable {
+			pending = append(pending, nodeImage.Name)
+		}
+	}
+	return pending, nil
+}
+
+// setNodeImagesReady sets NodeImagesReadyAnnotation on the release, which is
+// only updated if its value changes
+func (r *ReleaseReconciler) setNodeImagesReady(ctx context.Context, release *v1alpha1.Release, ready bool) error {
+	value := strconv.FormatBool(ready)
+	if release.Annotations[NodeImagesReadyAnnotation] == value {
+		return nil
+	}
+	if release.Annotations == nil {
+		release.Annotations = make(map[string]string)
+	}
+	release.Annotations[NodeImagesReadyAnnotation] = value
+	if err := r.Update(ctx, release); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("Node images readiness of release updated", "ready", ready)
+	return nil
+}
+
+// skipInvalidRelease records why no node image can be built for the release
+// instead of requeuing it, as retrying won't help until the release changes.
+// A deleted release is released from the finalizer of an earlier reconcile.
+func (r *ReleaseReconciler) skipInvalidRelease(ctx context.Context, release *v1alpha1.Release, reason error) error {
+	log := log.FromContext(ctx)
+	log.Info("Invalid release - skipping", "reason", reason.Error())
+
+	if IsDeleted(release) {
+		if controllerutil.ContainsFinalizer(release, ReleaseControllerFinalizer) {
+			controllerutil.RemoveFinalizer(release, ReleaseControllerFinalizer)
+			if err := r.Update(ctx, release); err != nil {
+				return err
+			}
+			log.Info("Finalizer removed from Release", "finalizer", ReleaseControllerFinalizer)
+		}
+		return nil
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Eventf(release, nil, corev1.EventTypeWarning, ReasonInvalidRelease, "Skip", "No node image can be built for the release: %s", reason.Error())
+	}
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ReleaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Release{}).
+		Watches(&images.NodeImage{},
+			handler.EnqueueRequestsFromMapFunc(releasesOfNodeImage),
+			builder.WithPredicates(nodeImageStateChanged()),
+		).
 		Named("release").
 		Complete(r)
 }
 
+// releasesOfNodeImage enqueues the releases the node image is used by
+func releasesOfNodeImage(ctx context.Context, obj client.Object) []reconcile.Request {
+	nodeImage, ok := obj.(*images.NodeImage)
+	if !ok {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(nodeImage.Status.Releases))
+	for _, release := range nodeImage.Status.Releases {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Name: release}})
+	}
+	return requests
+}
+
+// nodeImageStateChanged passes the updates of node images that change their
+// state, which may change the readiness of their releases
+func nodeImageStateChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldImage, ok := e.ObjectOld.(*images.NodeImage)
+			if !ok {
+				return false
+			}
+			newImage, ok := e.ObjectNew.(*images.NodeImage)
+			if !ok {
+				return false
+			}
+			return oldImage.Status.State != newImage.Status.State
+		},
+	}
+}
+
 // IsDeleted returns true if the release is marked for deletion.
 func IsDeleted(release *v1alpha1.Release) bool {
 	return !release.DeletionTimestamp.IsZero()
 }
 
+// PendingRequeue is the requeue of a release whose node images are not all
+// Available yet. State changes of the node images requeue it right away.
+func PendingRequeue() reconcile.Result {
+	return ctrl.Result{RequeueAfter: time.Minute}
+}
+
 func DefaultRequeue() reconcile.Result {
 	return ctrl.Result{
 		Requeue:      true,
@@ -17,7 +17,28 @@ limitations under the License.
 package release
 
 import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/giantswarm/releases/sdk/api/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	images "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
 )
 
 var _ = Describe("Release Controller", func() {
@@ -30,3 +51,293 @@ var _ = Describe("Release Controller", func() {
 		})
 	})
 })
+
+func TestReconcileInvalidRelease(t *testing.T) {
+	testCases := []struct {
+		name          string
+		releaseName   string
+		deleted       bool
+		createErr     error
+		expectedError bool
+		expectedEvent bool
+	}{
+		{
+			name:          "case 0: release name without a version is skipped",
+			releaseName:   "vsphere-latest",
+			expectedEvent: true,
+		},
+		{
+			name:        "case 1: deleted invalid release has its finalizer removed",
+			releaseName: "vsphere-latest",
+			deleted:     true,
+		},
+		{
+			name:          "case 2: transient API error of a valid release is returned to requeue",
+			releaseName:   "vsphere-1.2.3",
+			createErr:     errors.New("connection refused"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			release := &v1alpha1.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       tc.releaseName,
+					Finalizers: []string{ReleaseControllerFinalizer},
+				},
+				Spec: v1alpha1.ReleaseSpec{
+					Components: []v1alpha1.ReleaseSpecComponent{
+						{Name: "flatcar", Version: "3975.2.0"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			}
+			if tc.deleted {
+				now := metav1.Now()
+				release.DeletionTimestamp = &now
+			}
+
+			s := runtime.NewScheme()
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			require.NoError(t, images.AddToScheme(s))
+			c := fake.NewClientBuilder().WithScheme(s).WithObjects(release).WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					if tc.createErr != nil {
+						return tc.createErr
+					}
+					return c.Create(ctx, obj, opts...)
+				},
+			}).Build()
+
+			recorder := events.NewFakeRecorder(1)
+			r := &ReleaseReconciler{
+				Client:    c,
+				Namespace: "giantswarm",
+				Providers: map[string]interface{}{"capv": nil},
+				Recorder:  recorder,
+			}
+
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: release.Name}})
+			if tc.expectedError {
+				assert.Error(t, err)
+				assert.Empty(t, recorder.Events)
+				return
+			}
+			// a permanently invalid release is not requeued
+			require.NoError(t, err)
+			assert.Equal(t, ctrl.Result{}, result)
+
+			if tc.expectedEvent {
+				require.Len(t, recorder.Events, 1)
+				assert.Contains(t, <-recorder.Events, "Warning InvalidRelease No node image can be built for the release: invalid release: provider name not found in release vsphere-latest")
+			} else {
+				assert.Empty(t, recorder.Events)
+			}
+
+			nodeImages := &images.NodeImageList{}
+			require.NoError(t, c.List(ctx, nodeImages))
+			assert.Empty(t, nodeImages.Items)
+
+			stored := &v1alpha1.Release{}
+			err = c.Get(ctx, client.ObjectKeyFromObject(release), stored)
+			if tc.deleted {
+				// without the finalizer the deleted release is gone
+				assert.True(t, apierrors.IsNotFound(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{ReleaseControllerFinalizer}, stored.Finalizers)
+		})
+	}
+}
+
+func TestReconcileArchitectures(t *testing.T) {
+	ctx := context.TODO()
+
+	release := &v1alpha1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "vsphere-1.2.3",
+			Annotations: map[string]string{image.ArchitecturesAnnotation: "amd64,arm64"},
+		},
+		Spec: v1alpha1.ReleaseSpec{
+			Components: []v1alpha1.ReleaseSpecComponent{
+				{Name: "flatcar", Version: "3975.2.0"},
+				{Name: "kubernetes", Version: "v1.30.4"},
+				{Name: "os-tooling", Version: "v1.18.1"},
+			},
+		},
+	}
+
+	s := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	require.NoError(t, images.AddToScheme(s))
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(release).WithStatusSubresource(&images.NodeImage{}).Build()
+
+	r := &ReleaseReconciler{
+		Client:    c,
+		Namespace: "giantswarm",
+		Providers: map[string]interface{}{"capv": nil},
+	}
+
+	// a node image is created for each architecture
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: release.Name}})
+	require.NoError(t, err)
+
+	nodeImages := &images.NodeImageList{}
+	require.NoError(t, c.List(ctx, nodeImages))
+	var names []string
+	for _, nodeImage := range nodeImages.Items {
+		names = append(names, nodeImage.Spec.Name)
+		assert.Equal(t, []string{release.Name}, nodeImage.Status.Releases)
+	}
+	assert.ElementsMatch(t, []string{
+		"flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+		"flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-arm64",
+	}, names)
+
+	// the node images of both architectures are deleted with the release
+	stored := &v1alpha1.Release{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(release), stored))
+	require.NoError(t, c.Delete(ctx, stored))
+	_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: release.Name}})
+	require.NoError(t, err)
+
+	nodeImages = &images.NodeImageList{}
+	require.NoError(t, c.List(ctx, nodeImages))
+	assert.Empty(t, nodeImages.Items)
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, client.ObjectKeyFromObject(release), stored)))
+}
+
+func TestReconcileRemovedArchitecture(t *testing.T) {
+	ctx := context.TODO()
+
+	release := &v1alpha1.Release{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "vsphere-1.2.3",
+			Annotations: map[string]string{image.ArchitecturesAnnotation: "amd64,arm64"},
+		},
+		Spec: v1alpha1.ReleaseSpec{
+			Components: []v1alpha1.ReleaseSpecComponent{
+				{Name: "flatcar", Version: "3975.2.0"},
+				{Name: "kubernetes", Version: "v1.30.4"},
+				{Name: "os-tooling", Version: "v1.18.1"},
+			},
+		},
+	}
+
+	s := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	require.NoError(t, images.AddToScheme(s))
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(release).WithStatusSubresource(&images.NodeImage{}).Build()
+
+	r := &ReleaseReconciler{
+		Client:    c,
+		Namespace: "giantswarm",
+		Providers: map[string]interface{}{"capv": nil},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: release.Name}}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	// arm64 is removed from the release
+	stored := &v1alpha1.Release{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(release), stored))
+	stored.Annotations[image.ArchitecturesAnnotation] = "amd64"
+	require.NoError(t, c.Update(ctx, stored))
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	// the node image of arm64 no longer holds the release and is deleted
+	nodeImages := &images.NodeImageList{}
+	require.NoError(t, c.List(ctx, nodeImages))
+	require.Len(t, nodeImages.Items, 1)
+	assert.Equal(t, "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs", nodeImages.Items[0].Spec.Name)
+	assert.Equal(t, []string{release.Name}, nodeImages.Items[0].Status.Releases)
+}
+
+func TestReconcileWaitsForNodeImages(t *testing.T) {
+	ctx := context.TODO()
+
+	release := &v1alpha1.Release{
+		ObjectMeta: metav1.ObjectMeta{Name: "vsphere-1.2.3"},
+		Spec: v1alpha1.ReleaseSpec{
+			Components: []v1alpha1.ReleaseSpecComponent{
+				{Name: "flatcar", Version: "3975.2.0"},
+				{Name: "kubernetes", Version: "v1.30.4"},
+				{Name: "os-tooling", Version: "v1.18.1"},
+			},
+		},
+	}
+
+	s := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	require.NoError(t, images.AddToScheme(s))
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(release).WithStatusSubresource(&images.NodeImage{}).Build()
+
+	r := &ReleaseReconciler{
+		Client:    c,
+		Namespace: "giantswarm",
+		Providers: map[string]interface{}{"capv": nil},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: release.Name}}
+
+	// the release is requeued until its node image is Available
+	result, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, PendingRequeue(), result)
+
+	stored := &v1alpha1.Release{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(release), stored))
+	assert.Equal(t, "false", stored.Annotations[NodeImagesReadyAnnotation])
+
+	nodeImage := &images.NodeImage{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "giantswarm", Name: "capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"}, nodeImage))
+	nodeImage.Status.State = images.NodeImageUploading
+	require.NoError(t, c.Status().Update(ctx, nodeImage))
+
+	result, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, PendingRequeue(), result)
+
+	// an Available node image makes the release ready
+	nodeImage.Status.State = images.NodeImageAvailable
+	require.NoError(t, c.Status().Update(ctx, nodeImage))
+
+	result, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRequeue(), result)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(release), stored))
+	assert.Equal(t, "true", stored.Annotations[NodeImagesReadyAnnotation])
+
+	// a node image failing again makes it wait again
+	nodeImage.Status.State = images.NodeImageError
+	require.NoError(t, c.Status().Update(ctx, nodeImage))
+
+	result, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, PendingRequeue(), result)
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(release), stored))
+	assert.Equal(t, "false", stored.Annotations[NodeImagesReadyAnnotation])
+}
+
+func TestReleasesOfNodeImage(t *testing.T) {
+	nodeImage := &images.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "capv-test-image", Namespace: "giantswarm"},
+		Status:     images.NodeImageStatus{Releases: []string{"vsphere-1.2.3", "vsphere-1.2.4"}},
+	}
+	assert.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "vsphere-1.2.3"}},
+		{NamespacedName: types.NamespacedName{Name: "vsphere-1.2.4"}},
+	}, releasesOfNodeImage(context.TODO(), nodeImage))
+
+	updated := nodeImage.DeepCopy()
+	assert.False(t, nodeImageStateChanged().Update(event.UpdateEvent{ObjectOld: nodeImage, ObjectNew: updated}))
+	updated.Status.State = images.NodeImageAvailable
+	assert.True(t, nodeImageStateChanged().Update(event.UpdateEvent{ObjectOld: nodeImage, ObjectNew: updated}))
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// log is for logging in this package.
+var nodeimagelog = logf.Log.WithName("nodeimage-resource")
+
+// Providers are the provider names a NodeImage may be distributed with. The
+// test provider is only used by the controller tests.
+var Providers = []string{provider.VSphere, provider.CloudDirector, provider.Proxmox, "test"}
+
+// maxNameLength is the longest image name accepted, the providers apply their
+// own, shorter limits when uploading
+const maxNameLength = 253
+
+// namePattern matches image names built by the default and custom name
+// templates, e.g. flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._-]*[a-zA-Z0-9])?$`)
+
+// SetupNodeImageWebhookWithManager registers the webhook for NodeImage in the manager.
+func SetupNodeImageWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &imagev1alpha1.NodeImage{}).
+		WithDefaulter(&NodeImageCustomDefaulter{}).
+		WithValidator(&NodeImageCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-image-giantswarm-io-v1alpha1-nodeimage,mutating=true,failurePolicy=fail,sideEffects=None,groups=image.giantswarm.io,resources=nodeimages,verbs=create;update,versions=v1alpha1,name=mnodeimage-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// NodeImageCustomDefaulter sets the provider of NodeImages created without
+// one from the prefix of their object name, which names the provider for
+// NodeImages created by the operator, e.g. capv-flatcar-stable-...
+type NodeImageCustomDefaulter struct{}
+
+var _ admission.Defaulter[*imagev1alpha1.NodeImage] = &NodeImageCustomDefaulter{}
+
+// Default sets an empty provider to the one the object name starts with.
+// NodeImages whose name starts with no provider are left to the validator.
+func (d *NodeImageCustomDefaulter) Default(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) error {
+	if nodeImage.Spec.Provider != "" {
+		return nil
+	}
+	if prov, ok := nameProvider(nodeImage.Name); ok {
+		nodeimagelog.Info("Defaulting NodeImage provider from its name", "name", nodeImage.GetName(), "provider", prov)
+		nodeImage.Spec.Provider = prov
+	}
+	return nil
+}
+
+// nameProvider returns the provider the object name of a NodeImage starts
+// with, followed by a dash
+func nameProvider(name string) (string, bool) {
+	for _, prov := range Providers {
+		if strings.HasPrefix(name, prov+"-") {
+			return prov, true
+		}
+	}
+	return "", false
+}
+
+// +kubebuilder:webhook:path=/validate-image-giantswarm-io-v1alpha1-nodeimage,mutating=false,failurePolicy=fail,sideEffects=None,groups=image.giantswarm.io,resources=nodeimages,verbs=create;update;delete,versions=v1alpha1,name=vnodeimage-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// NodeImageCustomValidator rejects NodeImages with an unknown provider, a
+// provider other than the one their name starts with or an invalid image
+// name, which the reconciler would otherwise silently skip, and the deletion
+// of NodeImages still referenced by releases.
+type NodeImageCustomValidator struct{}
+
+var _ admission.Validator[*imagev1alpha1.NodeImage] = &NodeImageCustomValidator{}
+
+// ValidateCreate validates the spec of a new NodeImage
+func (v *NodeImageCustomValidator) ValidateCreate(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) (admission.Warnings, error) {
+	nodeimagelog.Info("Validation for NodeImage upon creation", "name", nodeImage.GetName())
+
+	return nil, validateNodeImage(nodeImage)
+}
+
+// ValidateUpdate validates the spec of an updated NodeImage. Updates leaving
+// the spec unchanged are always allowed, so NodeImages created before the
+// webhook was installed can still have their finalizers removed.
+func (v *NodeImageCustomValidator) ValidateUpdate(ctx context.Context, oldNodeImage, nodeImage *imagev1alpha1.NodeImage) (admission.Warnings, error) {
+	nodeimagelog.Info("Validation for NodeImage upon update", "name", nodeImage.GetName())
+
+	if apiequality.Semantic.DeepEqual(oldNodeImage.Spec, nodeImage.Spec) {
+		return nil, nil
+	}
+	return nil, validateNodeImage(nodeImage)
+}
+
+// ValidateDelete rejects the deletion of a NodeImage that releases still
+// reference, as its finalizer would remove the image from the providers while
+// clusters use it. The allow deletion annotation overrides this.
+func (v *NodeImageCustomValidator) ValidateDelete(ctx context.Context, nodeImage *imagev1alpha1.NodeImage) (admission.Warnings, error) {
+	nodeimagelog.Info("Validation for NodeImage upon deletion", "name", nodeImage.GetName())
+
+	if len(nodeImage.Status.Releases) == 0 {
+		return nil, nil
+	}
+	if nodeImage.Annotations[image.AllowDeletionAnnotation] == "true" {
+		return admission.Warnings{fmt.Sprintf("deleting NodeImage %s still referenced by releases %s", nodeImage.Name, strings.Join(nodeImage.Status.Releases, ", "))}, nil
+	}
+	return nil, apierrors.NewForbidden(imagev1alpha1.GroupVersion.WithResource("nodeimages").GroupResource(), nodeImage.Name,
+		fmt.Errorf("still referenced by releases %s, annotate it with %s=true to delete it anyway", strings.Join(nodeImage.Status.Releases, ", "), image.AllowDeletionAnnotation))
+}
+
+func validateNodeImage(nodeImage *imagev1alpha1.NodeImage) error {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	providerPath := specPath.Child("provider")
+	if !slices.Contains(Providers, nodeImage.Spec.Provider) {
+		errs = append(errs, field.NotSupported(providerPath, nodeImage.Spec.Provider, Providers))
+	} else if prov, ok := nameProvider(nodeImage.Name); ok && prov != nodeImage.Spec.Provider {
+		// the object name would suggest the image of another provider
+		errs = append(errs, field.Invalid(providerPath, nodeImage.Spec.Provider,
+			fmt.Sprintf("does not match provider %s the name %s starts with", prov, nodeImage.Name)))
+	}
+
+	namePath := specPath.Child("name")
+	switch {
+	case nodeImage.Spec.Name == "":
+		errs = append(errs, field.Required(namePath, "image name must not be empty"))
+	case len(nodeImage.Spec.Name) > maxNameLength:
+		errs = append(errs, field.TooLong(namePath, nodeImage.Spec.Name, maxNameLength))
+	case !namePattern.MatchString(nodeImage.Spec.Name):
+		errs = append(errs, field.Invalid(namePath, nodeImage.Spec.Name,
+			"must consist of alphanumeric characters, '-', '_' or '.', and start and end with an alphanumeric character"))
+	}
+
+	// whether the host is allowed is up to the operator configuration
+	if nodeImage.Spec.URL != "" {
+		if u, err := url.Parse(nodeImage.Spec.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, field.Invalid(specPath.Child("url"), nodeImage.Spec.URL, "must be an absolute HTTP(S) URL"))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(imagev1alpha1.GroupVersion.WithKind("NodeImage").GroupKind(), nodeImage.Name, errs)
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	imagev1alpha1 "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+)
+
+func TestDefault(t *testing.T) {
+	testCases := []struct {
+		name             string
+		objectName       string
+		provider         string
+		expectedProvider string
+	}{
+		{
+			name:             "case 0: provider from the name prefix",
+			objectName:       "capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			expectedProvider: "capv",
+		},
+		{
+			name:             "case 1: provider prefix of another provider",
+			objectName:       "capvcd-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			expectedProvider: "capvcd",
+		},
+		{
+			name:             "case 2: explicit provider is kept",
+			objectName:       "capv-test-image",
+			provider:         "capmox",
+			expectedProvider: "capmox",
+		},
+		{
+			name:       "case 3: name without a provider prefix",
+			objectName: "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+		},
+		{
+			name:       "case 4: provider name without a dash",
+			objectName: "capvimage",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.objectName},
+				Spec:       imagev1alpha1.NodeImageSpec{Provider: tc.provider, Name: "test-image"},
+			}
+
+			assert.NoError(t, (&NodeImageCustomDefaulter{}).Default(context.TODO(), nodeImage))
+			assert.Equal(t, tc.expectedProvider, nodeImage.Spec.Provider)
+		})
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		objectName    string
+		spec          imagev1alpha1.NodeImageSpec
+		expectedError string
+	}{
+		{
+			name: "case 0: valid flatcar image",
+			spec: imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"},
+		},
+		{
+			name: "case 1: valid test image",
+			spec: imagev1alpha1.NodeImageSpec{Provider: "test", Name: "test-image"},
+		},
+		{
+			name:          "case 2: unknown provider",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capvx", Name: "test-image"},
+			expectedError: `spec.provider: Unsupported value: "capvx"`,
+		},
+		{
+			name:          "case 3: empty provider",
+			spec:          imagev1alpha1.NodeImageSpec{Name: "test-image"},
+			expectedError: "spec.provider: Unsupported value",
+		},
+		{
+			name:          "case 4: empty name",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capvcd"},
+			expectedError: "spec.name: Required value",
+		},
+		{
+			name:          "case 5: name with invalid characters",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capmox", Name: "flatcar stable"},
+			expectedError: "spec.name: Invalid value",
+		},
+		{
+			name:          "case 6: name ending with a dash",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "flatcar-"},
+			expectedError: "spec.name: Invalid value",
+		},
+		{
+			name:          "case 7: too long name",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capv", Name: strings.Repeat("a", 254)},
+			expectedError: "spec.name: Too long",
+		},
+		{
+			name: "case 8: image with a direct URL",
+			spec: imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "test-image", URL: "https://artifacts.example.com/capv/test-image.ova"},
+		},
+		{
+			name:          "case 9: relative URL",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "test-image", URL: "capv/test-image.ova"},
+			expectedError: "spec.url: Invalid value",
+		},
+		{
+			name:          "case 10: URL with an unsupported scheme",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "test-image", URL: "ftp://artifacts.example.com/capv/test-image.ova"},
+			expectedError: "spec.url: Invalid value",
+		},
+		{
+			name:       "case 11: provider matching the name prefix",
+			objectName: "capvcd-test-image",
+			spec:       imagev1alpha1.NodeImageSpec{Provider: "capvcd", Name: "test-image"},
+		},
+		{
+			name:          "case 12: provider not matching the name prefix",
+			objectName:    "capv-test-image",
+			spec:          imagev1alpha1.NodeImageSpec{Provider: "capvcd", Name: "test-image"},
+			expectedError: "spec.provider: Invalid value",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objectName := tc.objectName
+			if objectName == "" {
+				objectName = "node-image"
+			}
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: objectName},
+				Spec:       tc.spec,
+			}
+
+			_, err := (&NodeImageCustomValidator{}).ValidateCreate(context.TODO(), nodeImage)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, apierrors.IsInvalid(err))
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	invalid := &imagev1alpha1.NodeImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-image"},
+		Spec:       imagev1alpha1.NodeImageSpec{Provider: "capvx", Name: "test-image"},
+	}
+
+	// removing the finalizer of an invalid node image is allowed
+	updated := invalid.DeepCopy()
+	updated.Finalizers = nil
+	_, err := (&NodeImageCustomValidator{}).ValidateUpdate(context.TODO(), invalid, updated)
+	assert.NoError(t, err)
+
+	// fixing the provider is allowed
+	updated = invalid.DeepCopy()
+	updated.Spec.Provider = "capv"
+	_, err = (&NodeImageCustomValidator{}).ValidateUpdate(context.TODO(), invalid, updated)
+	assert.NoError(t, err)
+
+	// changing the spec to another invalid one is rejected
+	updated = invalid.DeepCopy()
+	updated.Spec.Name = ""
+	_, err = (&NodeImageCustomValidator{}).ValidateUpdate(context.TODO(), invalid, updated)
+	assert.Error(t, err)
+}
+
+func TestValidateDelete(t *testing.T) {
+	testCases := []struct {
+		name            string
+		releases        []string
+		annotations     map[string]string
+		expectedError   bool
+		expectedWarning bool
+	}{
+		{
+			name: "case 0: unreferenced node image",
+		},
+		{
+			name:          "case 1: node image referenced by a release",
+			releases:      []string{"v30.0.0"},
+			expectedError: true,
+		},
+		{
+			name:            "case 2: referenced node image with the allow deletion annotation",
+			releases:        []string{"v30.0.0"},
+			annotations:     map[string]string{image.AllowDeletionAnnotation: "true"},
+			expectedWarning: true,
+		},
+		{
+			name:          "case 3: referenced node image with a disabled allow deletion annotation",
+			releases:      []string{"v30.0.0"},
+			annotations:   map[string]string{image.AllowDeletionAnnotation: "false"},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeImage := &imagev1alpha1.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-image", Annotations: tc.annotations},
+				Spec:       imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "test-image"},
+				Status:     imagev1alpha1.NodeImageStatus{Releases: tc.releases},
+			}
+
+			warnings, err := (&NodeImageCustomValidator{}).ValidateDelete(context.TODO(), nodeImage)
+			if tc.expectedError {
+				assert.True(t, apierrors.IsForbidden(err))
+				assert.ErrorContains(t, err, "v30.0.0")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedWarning, len(warnings) > 0)
+		})
+	}
+}
+
+var _ = Describe("NodeImage Webhook", func() {
+	newNodeImage := func(name string, spec imagev1alpha1.NodeImageSpec) *imagev1alpha1.NodeImage {
+		return &imagev1alpha1.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       spec,
+		}
+	}
+
+	Context("When creating a NodeImage", func() {
+		It("Should admit a valid NodeImage", func() {
+			nodeImage := newNodeImage("capv-valid", imagev1alpha1.NodeImageSpec{
+				Provider: "capv",
+				Name:     "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			})
+			Expect(k8sClient.Create(ctx, nodeImage)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, nodeImage)).To(Succeed())
+		})
+
+		It("Should deny an unknown provider", func() {
+			nodeImage := newNodeImage("capvx-invalid", imagev1alpha1.NodeImageSpec{
+				Provider: "capvx",
+				Name:     "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			})
+			err := k8sClient.Create(ctx, nodeImage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.provider"))
+		})
+
+		It("Should default the provider from the name prefix", func() {
+			nodeImage := newNodeImage("capvcd-defaulted", imagev1alpha1.NodeImageSpec{Name: "test-image"})
+			Expect(k8sClient.Create(ctx, nodeImage)).To(Succeed())
+			Expect(nodeImage.Spec.Provider).To(Equal("capvcd"))
+			Expect(k8sClient.Delete(ctx, nodeImage)).To(Succeed())
+		})
+
+		It("Should deny a provider not matching the name prefix", func() {
+			nodeImage := newNodeImage("capv-mismatch", imagev1alpha1.NodeImageSpec{Provider: "capvcd", Name: "test-image"})
+			err := k8sClient.Create(ctx, nodeImage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("does not match provider capv"))
+		})
+
+		It("Should deny an empty provider without a provider prefix", func() {
+			nodeImage := newNodeImage("no-provider", imagev1alpha1.NodeImageSpec{Name: "test-image"})
+			err := k8sClient.Create(ctx, nodeImage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.provider"))
+		})
+
+		It("Should deny an empty image name", func() {
+			nodeImage := newNodeImage("capv-empty", imagev1alpha1.NodeImageSpec{Provider: "capv"})
+			err := k8sClient.Create(ctx, nodeImage)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spec.name"))
+		})
+	})
+
+	Context("When updating a NodeImage", func() {
+		It("Should deny changing the provider to an unknown one", func() {
+			nodeImage := newNodeImage("capv-update", imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "test-image"})
+			Expect(k8sClient.Create(ctx, nodeImage)).To(Succeed())
+
+			nodeImage.Spec.Provider = "capvx"
+			Expect(k8sClient.Update(ctx, nodeImage)).NotTo(Succeed())
+
+			nodeImage.Spec.Provider = "capv"
+			Expect(k8sClient.Delete(ctx, nodeImage)).To(Succeed())
+		})
+	})
+
+	Context("When deleting a NodeImage", func() {
+		It("Should deny the deletion while releases reference it", func() {
+			nodeImage := newNodeImage("capv-referenced", imagev1alpha1.NodeImageSpec{Provider: "capv", Name: "test-image"})
+			Expect(k8sClient.Create(ctx, nodeImage)).To(Succeed())
+			nodeImage.Status.Releases = []string{"v30.0.0"}
+			Expect(k8sClient.Status().Update(ctx, nodeImage)).To(Succeed())
+
+			err := k8sClient.Delete(ctx, nodeImage)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(image.AllowDeletionAnnotation))
+		})
+
+		It("Should admit the deletion with the allow deletion annotation", func() {
+			nodeImage := &imagev1alpha1.NodeImage{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Name: "capv-referenced", Namespace: "default"}, nodeImage)).To(Succeed())
+			nodeImage.Annotations = map[string]string{image.AllowDeletionAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, nodeImage)).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, nodeImage)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// imageExtensions are the extensions of the image files downloaded by the
+// operator. Other files in a download directory are never removed.
+var imageExtensions = []string{".ova", ".qcow2"}
+
+// RemoveStaleImages removes the image files directly in dir that were last
+// modified more than maxAge ago, e.g. downloads left behind by a process that
+// was killed mid-import. It returns the number of removed files. A missing
+// directory is not an error.
+func RemoveStaleImages(ctx context.Context, dir string, maxAge time.Duration) (int, error) {
+	log := log.FromContext(ctx)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	var errs []error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isImageFile(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("failed to stat %s: %w", entry.Name(), err))
+			}
+			continue
+		}
+		if info.ModTime().After(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", path, err))
+			continue
+		}
+		log.Info("Removed stale image file", "path", path, "size", info.Size(), "modified", info.ModTime())
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+func isImageFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, imageExt := range imageExtensions {
+		if ext == imageExt {
+			return true
+		}
+	}
+	return false
+}
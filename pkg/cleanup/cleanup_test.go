@@ -0,0 +1,53 @@
+package cleanup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveStaleImages(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+
+	files := []struct {
+		name        string
+		modified    time.Time
+		expectExist bool
+	}{
+		{name: "vcd-image-123.ova", modified: old},
+		{name: "vcd-patched-456.ova", modified: old},
+		{name: "flatcar.qcow2", modified: old},
+		{name: "vcd-image-789.ova", modified: time.Now(), expectExist: true},
+		{name: "notes.txt", modified: old, expectExist: true},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+		require.NoError(t, os.Chtimes(path, f.modified, f.modified))
+	}
+	// directories are never removed, whatever their name
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.ova"), 0700))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "nested.ova"), old, old))
+
+	removed, err := RemoveStaleImages(context.TODO(), dir, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 3, removed)
+
+	for _, f := range files {
+		_, err := os.Stat(filepath.Join(dir, f.name))
+		assert.Equal(t, f.expectExist, err == nil, f.name)
+	}
+	assert.DirExists(t, filepath.Join(dir, "nested.ova"))
+}
+
+func TestRemoveStaleImagesMissingDirectory(t *testing.T) {
+	removed, err := RemoveStaleImages(context.TODO(), filepath.Join(t.TempDir(), "missing"), time.Hour)
+	assert.NoError(t, err)
+	assert.Zero(t, removed)
+}
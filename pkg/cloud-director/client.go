@@ -4,14 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/vmware/go-vcloud-director/v3/govcd"
+	types "github.com/vmware/go-vcloud-director/v3/types/v56"
+	"golang.org/x/time/rate"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
 )
 
 // defaultSessionRefreshThreshold is kept comfortably under Cloud Director's
@@ -20,16 +32,78 @@ import (
 // left unset.
 const defaultSessionRefreshThreshold = 20 * time.Hour
 
+const (
+	// defaultDownloadTimeout bounds the whole download of an image from S3
+	defaultDownloadTimeout = 30 * time.Minute
+	// defaultDownloadStallTimeout aborts a download that receives no data
+	defaultDownloadStallTimeout = 2 * time.Minute
+)
+
+const (
+	// defaultUploadPieceSize is the size of the chunks images are uploaded in
+	defaultUploadPieceSize = 10 << 20
+	// minUploadPieceSize is the smallest piece size accepted. govcd silently
+	// falls back to its own default for pieces of 1KB or less.
+	minUploadPieceSize = 1 << 20
+	// maxUploadPieceSize is the largest piece size accepted, a whole piece is
+	// held in memory while it is uploaded
+	maxUploadPieceSize = 1 << 30
+)
+
 // Client wraps the govcd client
 type Client struct {
-	cloudDirector           *govcd.VCDClient
-	url                     string
+	cloudDirector *govcd.VCDClient
+	url           string
+	// locationMu guards location, which is replaced when it is reloaded
+	locationMu              sync.RWMutex
 	location                *Location
 	downloadDir             string
+	downloadFallbackDir     string
 	credentials             *Credentials
 	backoff                 wait.Backoff
 	authenticatedAt         time.Time
 	sessionRefreshThreshold time.Duration
+	downloadTimeout         time.Duration
+	downloadStallTimeout    time.Duration
+	uploadPieceSize         int64
+	maxImageSize            int64
+	dryRun                  bool
+	// overwritePolicy handles uploads whose name is taken by a vApp template
+	// with different content
+	overwritePolicy string
+	// httpClient downloads the images
+	httpClient *http.Client
+	// limiter bounds the bandwidth of downloads and uploads, unlimited if nil
+	limiter *rate.Limiter
+
+	// login performs a single authentication attempt against Cloud Director
+	login func() error
+	// upload uploads a local OVA to the catalog and waits for it to finish
+	upload func(ctx context.Context, config ImporterConfig, localPath string) error
+	// mergeMetadata adds metadata to the uploaded vApp template
+	mergeMetadata func(config ImporterConfig, metadata map[string]types.MetadataValue) error
+	// templateChecksum returns the source checksum of an existing vApp
+	// template named like the upload, and whether there is one
+	templateChecksum func(config ImporterConfig) (string, bool, error)
+	// deleteItem deletes the catalog item named like the upload
+	deleteItem func(config ImporterConfig) error
+	// templateID returns the ID of the uploaded vApp template
+	templateID func(config ImporterConfig) (string, error)
+	// freeSpace returns the free bytes on the filesystem of a directory
+	freeSpace func(dir string) (uint64, error)
+	// listVAppTemplates returns the names of the vApp templates in the catalogs
+	listVAppTemplates func(ctx context.Context) ([]string, error)
+	// listTemplateMetadata returns the vApp templates in the catalogs with
+	// their metadata
+	listTemplateMetadata func(ctx context.Context) ([]vAppTemplateInfo, error)
+	// deleteVAppTemplate deletes a vApp template listed by listTemplateMetadata
+	deleteVAppTemplate func(ctx context.Context, vAppTemplate vAppTemplateInfo) error
+	// catalogAccess and setCatalogAccess read and replace the access control
+	// settings of the catalog
+	catalogAccess    func(ctx context.Context, catalog string) (*types.ControlAccessParams, error)
+	setCatalogAccess func(ctx context.Context, catalog string, access *types.ControlAccessParams) error
+	// orgHREF returns the HREF of an organization by name
+	orgHREF func(name string) (string, error)
 }
 
 type Credentials struct {
@@ -47,15 +121,68 @@ type Location struct {
 	VDC             string `yaml:"vdc"`
 	Catalog         string `yaml:"catalog"`
 	HardwareVersion int    `yaml:"hardwareVersion"`
+	// Catalogs maps Flatcar channels to the catalog their images are placed
+	// in, e.g. beta: k8s-beta. Images of other channels, and images whose
+	// name has no channel, are placed in Catalog.
+	Catalogs map[string]string `yaml:"catalogs"`
+	// Description is a Go text/template for the catalog item and vApp template
+	// description, {{.Name}} being the image name. Defaults to "Node image {{.Name}}".
+	Description string `yaml:"description"`
+	// ComputerName is the computer name baked into the guest customization
+	// of the template. The name from the OVF descriptor is kept if empty.
+	ComputerName string `yaml:"computerName"`
+	// Metadata is added to every vApp template uploaded to the catalog, on
+	// top of the component versions parsed from the image name
+	Metadata map[string]string `yaml:"metadata"`
+	// ShareWithOrgs are the organizations the catalog is shared with
+	// read-only after an upload, so their clusters can use the templates
+	ShareWithOrgs []string `yaml:"shareWithOrgs"`
+	// ImageSuffix is appended to the names of the vApp templates uploaded to
+	// the catalogs, e.g. to tell apart images built for different firmware
+	ImageSuffix string `yaml:"imageSuffix"`
+	// DeleteDuplicates makes Delete also delete the vApp templates uploaded
+	// for the same NodeImage from the same OVA under another name, e.g. one
+	// used before, matched by their source checksum and NodeImage metadata
+	DeleteDuplicates bool `yaml:"deleteDuplicates"`
+
+	description *template.Template
 }
 
 // Config holds the configuration for the cloudDirector client
 type Config struct {
-	Backoff                 wait.Backoff
-	CredentialsFile         string
-	LocationsFile           string
-	DownloadDir             string
+	Backoff         wait.Backoff
+	CredentialsFile string
+	LocationsFile   string
+	// Locations is the content of a locations file, e.g. of a ConfigMap, used
+	// instead of LocationsFile if set
+	Locations   []byte
+	DownloadDir string
+	// DownloadFallbackDir is used if DownloadDir is not writable
+	DownloadFallbackDir     string
 	SessionRefreshThreshold time.Duration
+	// DownloadTimeout bounds the whole download of an image, defaults to 30m
+	DownloadTimeout time.Duration
+	// DownloadStallTimeout aborts a download receiving no data for this long, defaults to 2m
+	DownloadStallTimeout time.Duration
+	// UploadPieceSize is the size in bytes of the chunks images are uploaded
+	// in, defaults to 10MB. Every chunk is a request of its own, so larger
+	// chunks upload faster over high-latency links to distant endpoints, at
+	// the cost of memory and of more data to resend when a chunk fails.
+	UploadPieceSize int64
+	// MaxImageSizeBytes fails downloads of images larger than it before they
+	// start, unlimited if 0
+	MaxImageSizeBytes int64
+	// DryRun makes Create and Delete only log the intended action
+	DryRun bool
+	// Proxy is the proxy images are downloaded through. Images are pushed
+	// from the operator, so Cloud Director itself never fetches the URL.
+	Proxy download.Proxy
+	// OverwritePolicy handles an upload whose name is taken by a vApp
+	// template with different content: error (the default), skip or replace
+	OverwritePolicy string
+	// Limiter bounds the bandwidth of downloads and uploads, shared with
+	// other transfers. They are unlimited if nil.
+	Limiter *rate.Limiter
 }
 
 // New initializes a new cloudDirector client
@@ -74,7 +201,12 @@ func New(c Config, ctx context.Context) (*Client, error) {
 		return nil, fmt.Errorf("unable to parse URL: %w", err)
 	}
 
-	location, err := loadLocation(c.LocationsFile)
+	var location *Location
+	if c.Locations != nil {
+		location, err = parseLocation(c.Locations)
+	} else {
+		location, err = loadLocation(c.LocationsFile)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load locations file:\n%w", err)
 	}
@@ -85,15 +217,59 @@ func New(c Config, ctx context.Context) (*Client, error) {
 		sessionRefreshThreshold = defaultSessionRefreshThreshold
 	}
 
+	downloadTimeout := c.DownloadTimeout
+	if downloadTimeout <= 0 {
+		downloadTimeout = defaultDownloadTimeout
+	}
+	downloadStallTimeout := c.DownloadStallTimeout
+	if downloadStallTimeout <= 0 {
+		downloadStallTimeout = defaultDownloadStallTimeout
+	}
+	uploadPieceSize, err := checkUploadPieceSize(c.UploadPieceSize)
+	if err != nil {
+		return nil, err
+	}
+	overwritePolicy, err := checkOverwritePolicy(c.OverwritePolicy)
+	if err != nil {
+		return nil, err
+	}
+
 	client := &Client{
 		cloudDirector:           govcd.NewVCDClient(*u, creds.Insecure),
 		url:                     creds.URL,
 		location:                location,
 		downloadDir:             c.DownloadDir,
+		downloadFallbackDir:     c.DownloadFallbackDir,
 		credentials:             creds,
 		backoff:                 c.Backoff,
 		sessionRefreshThreshold: sessionRefreshThreshold,
+		downloadTimeout:         downloadTimeout,
+		downloadStallTimeout:    downloadStallTimeout,
+		uploadPieceSize:         uploadPieceSize,
+		maxImageSize:            c.MaxImageSizeBytes,
+		dryRun:                  c.DryRun,
+		overwritePolicy:         overwritePolicy,
+		httpClient:              &http.Client{Transport: c.Proxy.Transport()},
+		limiter:                 c.Limiter,
 	}
+	// uploads are sent by the Cloud Director SDK, throttle its requests
+	vcdHTTP := &client.cloudDirector.Client.Http
+	vcdHTTP.Transport = download.LimitTransport(vcdHTTP.Transport, c.Limiter)
+	client.login = func() error {
+		return client.cloudDirector.Authenticate(creds.Username, creds.Password, creds.Org)
+	}
+	client.upload = client.uploadOVA
+	client.mergeMetadata = mergeVAppTemplateMetadata
+	client.templateChecksum = vAppTemplateChecksum
+	client.deleteItem = deleteCatalogItem
+	client.templateID = vAppTemplateID
+	client.freeSpace = freeDiskSpace
+	client.listVAppTemplates = client.queryVAppTemplates
+	client.listTemplateMetadata = client.queryVAppTemplateMetadata
+	client.deleteVAppTemplate = client.removeVAppTemplate
+	client.catalogAccess = client.getCatalogAccess
+	client.setCatalogAccess = client.updateCatalogAccess
+	client.orgHREF = client.getOrgHREF
 
 	if err := client.authenticate(ctx); err != nil {
 		return nil, fmt.Errorf("failed to create Cloud Director client: %w", err)
@@ -102,6 +278,18 @@ func New(c Config, ctx context.Context) (*Client, error) {
 	return client, nil
 }
 
+// checkUploadPieceSize returns the upload piece size to use for the configured
+// one, failing if it is out of the accepted bounds
+func checkUploadPieceSize(size int64) (int64, error) {
+	if size == 0 {
+		return defaultUploadPieceSize, nil
+	}
+	if size < minUploadPieceSize || size > maxUploadPieceSize {
+		return 0, fmt.Errorf("invalid upload piece size %d: must be between %d and %d bytes", size, int64(minUploadPieceSize), int64(maxUploadPieceSize))
+	}
+	return size, nil
+}
+
 // authenticate logs in to Cloud Director, retrying with backoff, and records
 // the time of the successful login so ensureSession can tell when a refresh
 // is due.
@@ -111,7 +299,7 @@ func (c *Client) authenticate(ctx context.Context) error {
 	var lastErr error
 	err := wait.ExponentialBackoff(c.backoff,
 		func() (done bool, err error) {
-			lastErr = c.cloudDirector.Authenticate(c.credentials.Username, c.credentials.Password, c.credentials.Org)
+			lastErr = c.login()
 
 			// Return if client was successfully created, otherwise retry
 			if lastErr == nil {
@@ -145,68 +333,274 @@ func (c *Client) ensureSession(ctx context.Context) error {
 	return nil
 }
 
+// withSessionRetry runs fn and, if it failed because the Cloud Director
+// session expired while it was running, re-authenticates and runs it once
+// more. ensureSession only refreshes sessions ahead of time, which does not
+// help when a long upload outlives the session.
+func (c *Client) withSessionRetry(ctx context.Context, fn func() error) error {
+	err := fn()
+	if err == nil || !isSessionExpired(err) {
+		return err
+	}
+
+	log := log.FromContext(ctx)
+	log.Info("Cloud Director session expired mid-operation, re-authenticating", "error", err.Error())
+	if authErr := c.authenticate(ctx); authErr != nil {
+		return fmt.Errorf("failed to refresh Cloud Director session: %w\n%w", authErr, err)
+	}
+	return fn()
+}
+
+// isSessionExpired reports whether err was caused by an expired or otherwise
+// invalid session. go-vcloud-director flattens most errors into strings, so
+// besides the typed API error this also looks at the error message.
+func isSessionExpired(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *types.Error
+	if errors.As(err, &apiErr) && apiErr.MajorErrorCode == 401 {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{"api error: 401", "unauthorized", "session has expired", "session expired"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxCatalogItemNameLength is the longest catalog item name Cloud Director accepts
+const maxCatalogItemNameLength = 128
+
+// MaxNameLength returns the maximum length of an image name in the catalog,
+// leaving room for the location's image suffix
+func (c *Client) MaxNameLength(loc string) int {
+	limit := maxCatalogItemNameLength
+	if location := c.currentLocation(); location != nil && location.ImageSuffix != "" {
+		limit -= len(location.ImageSuffix) + 1
+	}
+	return limit
+}
+
+// ImageName returns the name of the vApp template for the image, with the
+// location's image suffix appended
+func (c *Client) ImageName(name string, loc string) string {
+	if location := c.currentLocation(); location != nil && location.ImageSuffix != "" {
+		return fmt.Sprintf("%s-%s", name, location.ImageSuffix)
+	}
+	return name
+}
+
 // GetLocations returns all configured cloudDirector locations
 func (c *Client) GetLocations() map[string]interface{} {
+	location := c.currentLocation()
 	locations := make(map[string]interface{})
-	locations[c.location.Name] = c.location
+	locations[location.Name] = location
 	return locations
 }
 
-// Exists checks if an image already exists in cloudDirector
+// currentLocation returns the configuration of the location. It is replaced
+// instead of changed when it is reloaded, so it can be read without holding
+// the lock.
+func (c *Client) currentLocation() *Location {
+	c.locationMu.RLock()
+	defer c.locationMu.RUnlock()
+	return c.location
+}
+
+// ReloadLocations replaces the location with the one in data, the content of
+// a locations file, e.g. of a changed ConfigMap. An invalid location is
+// rejected and the current one is kept.
+func (c *Client) ReloadLocations(ctx context.Context, data []byte) error {
+	location, err := parseLocation(data)
+	if err != nil {
+		return fmt.Errorf("failed to load locations:\n%w", err)
+	}
+	location.Org = c.credentials.Org
+
+	c.locationMu.Lock()
+	c.location = location
+	c.locationMu.Unlock()
+
+	log.FromContext(ctx).Info("Reloaded Cloud Director location", "location", location.Name)
+	return nil
+}
+
+// Exists checks if an image already exists in cloudDirector and reports the
+// ID of the vApp template found
 func (c *Client) Exists(ctx context.Context, name string, loc string) (bool, error) {
 	log := log.FromContext(ctx)
 
-	catalog, err := c.getCatalog(ctx)
+	catalog, err := c.getCatalog(ctx, name)
 	if err != nil {
 		return false, err
 	}
 
 	// Check if the vApp template exists in the catalog
-	_, err = catalog.GetVAppTemplateByName(name)
+	templateName := c.ImageName(name, loc)
+	vAppTemplate, err := findVAppTemplate(ctx, catalog, templateName)
 	if err != nil {
 		if govcd.ContainsNotFound(err) {
-			log.Info("vApp template not found in catalog", "name", name, "catalog", c.location.Catalog)
+			log.Info("vApp template not found in catalog", "name", templateName, "catalog", catalog.Catalog.Name)
 			return false, nil
 		}
-		return false, fmt.Errorf("failed to check for vApp template %s: %w", name, err)
+		return false, fmt.Errorf("failed to check for vApp template %s: %w", templateName, err)
 	}
 
-	log.Info("vApp template exists in catalog", "name", name, "catalog", c.location.Catalog)
+	log.Info("vApp template exists in catalog", "name", templateName, "catalog", catalog.Catalog.Name)
+	provider.ReportImageID(ctx, vAppTemplate.VAppTemplate.ID)
 	return true, nil
 }
 
+// findVAppTemplate returns the vApp template of the image ID in the context
+// if it still has the given name, and looks the template up in the catalog by
+// name otherwise, e.g. because there is no ID or its template was deleted
+func findVAppTemplate(ctx context.Context, catalog *govcd.Catalog, templateName string) (*govcd.VAppTemplate, error) {
+	if id := provider.ImageIDFrom(ctx); id != "" {
+		vAppTemplate, err := catalog.GetVAppTemplateById(id)
+		if err == nil && vAppTemplate.VAppTemplate.Name == templateName {
+			return vAppTemplate, nil
+		}
+	}
+	return catalog.GetVAppTemplateByName(templateName)
+}
+
+// List returns the names of the node image vApp templates in the catalogs
+func (c *Client) List(ctx context.Context, loc string) ([]string, error) {
+	templates, err := c.listVAppTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the location's image suffix is stripped so the names match the ones
+	// passed to Exists and Delete, anything not named like a node image is
+	// left alone
+	suffix := ""
+	if location := c.currentLocation(); location.ImageSuffix != "" {
+		suffix = "-" + location.ImageSuffix
+	}
+	var names []string
+	for _, vAppTemplate := range templates {
+		name, ok := strings.CutSuffix(vAppTemplate, suffix)
+		if !ok || name == "" {
+			continue
+		}
+		if _, ok := image.ParseImageName(name); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// queryVAppTemplates returns the names of all vApp templates in the catalogs
+// of the location
+func (c *Client) queryVAppTemplates(ctx context.Context) ([]string, error) {
+	var names []string
+	for _, catalogName := range c.currentLocation().catalogNames() {
+		catalog, err := c.getCatalogByName(ctx, catalogName)
+		if err != nil {
+			return nil, err
+		}
+
+		templates, err := catalog.QueryVappTemplateList()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vApp templates in catalog %s: %w", catalogName, err)
+		}
+		for _, vAppTemplate := range templates {
+			names = append(names, vAppTemplate.Name)
+		}
+	}
+	return names, nil
+}
+
+// vAppTemplateResolved is the status of a vApp template that finished processing
+const vAppTemplateResolved = 8
+
+// Ready reports whether the vApp template exists and finished processing
+func (c *Client) Ready(ctx context.Context, name string, loc string) (bool, error) {
+	catalog, err := c.getCatalog(ctx, name)
+	if err != nil {
+		return false, err
+	}
+
+	templateName := c.ImageName(name, loc)
+	vAppTemplate, err := catalog.GetVAppTemplateByName(templateName)
+	if err != nil {
+		if govcd.ContainsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check for vApp template %s: %w", templateName, err)
+	}
+	return vAppTemplate.VAppTemplate.Status == vAppTemplateResolved, nil
+}
+
 // Delete deletes an image from cloudDirector
 func (c *Client) Delete(ctx context.Context, name string, loc string) error {
 	log := log.FromContext(ctx)
+	templateName := c.ImageName(name, loc)
+
+	if c.dryRun {
+		catalogName, _ := c.currentLocation().catalogName(name)
+		log.Info("Dry run: would delete vApp template", "name", templateName, "catalog", catalogName)
+		return nil
+	}
 
-	catalog, err := c.getCatalog(ctx)
+	catalog, err := c.getCatalog(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to get catalog: %w", err)
 	}
 
+	// the NodeImage the image is deleted for finds its duplicates under other
+	// names, even once the template under the current name is gone
+	var nodeImage string
+	if provenance, ok := provider.ProvenanceFrom(ctx); ok {
+		nodeImage = provenance.NodeImage
+	}
+	deleteDuplicates := c.currentLocation().DeleteDuplicates
+
 	// Get the vApp template
-	vAppTemplate, err := catalog.GetVAppTemplateByName(name)
+	vAppTemplate, err := findVAppTemplate(ctx, catalog, templateName)
 	if err != nil {
 		if govcd.ContainsNotFound(err) {
-			log.Info("vApp template not found, nothing to delete", "name", name, "catalog", c.location.Catalog)
+			log.Info("vApp template not found, nothing to delete", "name", templateName, "catalog", catalog.Catalog.Name)
+			if duplicates := duplicateSelector(nodeImage, nil); deleteDuplicates && duplicates != nil {
+				return c.DeleteByMetadata(ctx, duplicates)
+			}
 			return nil
 		}
-		return fmt.Errorf("failed to get vApp template %s: %w", name, err)
+		return fmt.Errorf("failed to get vApp template %s: %w", templateName, err)
 	}
 
-	log.Info("Deleting vApp template", "name", name, "catalog", c.location.Catalog)
+	var duplicates map[string]string
+	if deleteDuplicates {
+		metadata, err := vAppTemplateMetadata(vAppTemplate)
+		if err != nil {
+			return fmt.Errorf("failed to get metadata of vApp template %s: %w", templateName, err)
+		}
+		duplicates = duplicateSelector(nodeImage, metadata)
+	}
+
+	log.Info("Deleting vApp template", "name", templateName, "catalog", catalog.Catalog.Name)
 
 	// Delete the vApp template
 	err = vAppTemplate.Delete()
 	if err != nil {
 		if govcd.ContainsNotFound(err) {
-			log.Info("vApp template already deleted or not found", "name", name, "catalog", c.location.Catalog)
+			log.Info("vApp template already deleted or not found", "name", templateName, "catalog", catalog.Catalog.Name)
 			return nil
 		}
-		return fmt.Errorf("failed to delete vApp template %s: %w", name, err)
+		return fmt.Errorf("failed to delete vApp template %s: %w", templateName, err)
 	}
 
-	log.Info("Successfully deleted vApp template", "name", name, "catalog", c.location.Catalog)
+	log.Info("Successfully deleted vApp template", "name", templateName, "catalog", catalog.Catalog.Name)
+
+	if duplicates != nil {
+		return c.DeleteByMetadata(ctx, duplicates)
+	}
 	return nil
 }
 
@@ -214,21 +608,28 @@ func (c *Client) Delete(ctx context.Context, name string, loc string) error {
 func (c *Client) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
 	log := log.FromContext(ctx)
 
+	if err := checkArtifact(imageURL); err != nil {
+		return err
+	}
+
+	if c.dryRun {
+		catalogName, _ := c.currentLocation().catalogName(imageName)
+		log.Info("Dry run: would import image", "name", c.ImageName(imageName, loc), "url", imageURL, "catalog", catalogName)
+		return nil
+	}
+
 	// Get the catalog where we'll upload
-	catalog, err := c.getCatalog(ctx)
+	catalog, err := c.getCatalog(ctx, imageName)
 	if err != nil {
 		return fmt.Errorf("failed to get catalog: %w", err)
 	}
 
-	// Create import configuration
-	importConfig := ImporterConfig{
-		Name:            imageName,
-		Path:            imageURL,
-		Catalog:         catalog,
-		HardwareVersion: c.location.HardwareVersion,
+	importConfig, err := c.importerConfig(catalog, imageURL, imageName)
+	if err != nil {
+		return err
 	}
 
-	log.Info("Starting image import", "name", imageName, "url", imageURL)
+	log.Info("Starting image import", "name", importConfig.Name, "url", imageURL, "catalog", catalog.Catalog.Name)
 
 	// Import the image (waits for completion internally)
 	err = c.importImage(ctx, importConfig)
@@ -236,10 +637,53 @@ func (c *Client) Create(ctx context.Context, imageURL string, imageName string,
 		return fmt.Errorf("failed to import image: %w", err)
 	}
 
-	log.Info("Image import completed", "name", imageName)
+	log.Info("Image import completed", "name", importConfig.Name)
 	return nil
 }
 
+// importerConfig returns the configuration importing the image into the
+// catalog as a vApp template named with the location's image suffix. The
+// description and metadata are derived from the image name itself.
+func (c *Client) importerConfig(catalog *govcd.Catalog, imageURL string, imageName string) (ImporterConfig, error) {
+	location := c.currentLocation()
+	description, err := location.describe(imageName)
+	if err != nil {
+		return ImporterConfig{}, err
+	}
+
+	return ImporterConfig{
+		Name:            c.ImageName(imageName, location.Name),
+		Path:            imageURL,
+		Catalog:         catalog,
+		HardwareVersion: location.HardwareVersion,
+		Description:     description,
+		ComputerName:    location.ComputerName,
+		Metadata:        location.metadata(imageName),
+		UploadPieceSize: c.uploadPieceSize,
+	}, nil
+}
+
+// Process shares the catalog of the image with the organizations of the
+// location, if any. An uploaded vApp template is usable from its catalog as
+// soon as it is resolved.
+func (c *Client) Process(ctx context.Context, name string, loc string) error {
+	location := c.currentLocation()
+	if len(location.ShareWithOrgs) == 0 {
+		return nil
+	}
+	catalogName, err := location.catalogName(name)
+	if err != nil {
+		return err
+	}
+	if c.dryRun {
+		log.FromContext(ctx).Info("Dry run: would share catalog", "catalog", catalogName, "orgs", location.ShareWithOrgs)
+		return nil
+	}
+	return c.withSessionRetry(ctx, func() error {
+		return c.shareCatalog(ctx, catalogName)
+	})
+}
+
 // getOrg returns the organization object. go-vcloud-director reports an
 // expired session as ErrorEntityNotFound here - indistinguishable from the
 // org actually being missing - so on that specific error it forces a
@@ -249,33 +693,112 @@ func (c *Client) getOrg(ctx context.Context) (*govcd.Org, error) {
 		return nil, err
 	}
 
-	org, err := c.cloudDirector.GetOrgByName(c.location.Org)
+	org, err := c.cloudDirector.GetOrgByName(c.currentLocation().Org)
 	if errors.Is(err, govcd.ErrorEntityNotFound) {
 		if reauthErr := c.authenticate(ctx); reauthErr == nil {
-			org, err = c.cloudDirector.GetOrgByName(c.location.Org)
+			org, err = c.cloudDirector.GetOrgByName(c.currentLocation().Org)
 		}
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get organization %s: %w", c.location.Org, err)
+		return nil, fmt.Errorf("failed to get organization %s: %w", c.currentLocation().Org, err)
 	}
 	return org, nil
 }
 
-// getCatalog returns the catalog object
-func (c *Client) getCatalog(ctx context.Context) (*govcd.Catalog, error) {
+// getCatalog returns the catalog object the image is placed in
+func (c *Client) getCatalog(ctx context.Context, imageName string) (*govcd.Catalog, error) {
+	catalogName, err := c.currentLocation().catalogName(imageName)
+	if err != nil {
+		return nil, err
+	}
+	return c.getCatalogByName(ctx, catalogName)
+}
+
+// getCatalogByName returns the catalog object with the given name
+func (c *Client) getCatalogByName(ctx context.Context, catalogName string) (*govcd.Catalog, error) {
 	org, err := c.getOrg(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	catalog, err := org.GetCatalogByName(c.location.Catalog, false)
+	catalog, err := org.GetCatalogByName(catalogName, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get catalog %s for organization %s: %w",
-			c.location.Catalog, c.location.Org, err)
+			catalogName, c.currentLocation().Org, err)
 	}
 	return catalog, nil
 }
 
+// catalogName returns the name of the catalog the image is placed in: the
+// catalog of its Flatcar channel if the location maps it, and Catalog
+// otherwise
+func (l *Location) catalogName(imageName string) (string, error) {
+	if components, ok := image.ParseImageName(imageName); ok && components.Channel != "" {
+		if catalog, ok := l.Catalogs[components.Channel]; ok {
+			return catalog, nil
+		}
+	}
+	if l.Catalog == "" {
+		return "", fmt.Errorf("no catalog configured for image %s in location %s", imageName, l.Name)
+	}
+	return l.Catalog, nil
+}
+
+// catalogNames returns the names of all catalogs of the location, sorted
+func (l *Location) catalogNames() []string {
+	var names []string
+	if l.Catalog != "" {
+		names = append(names, l.Catalog)
+	}
+	for _, catalog := range l.Catalogs {
+		if !slices.Contains(names, catalog) {
+			names = append(names, catalog)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// defaultDescription is used when a location does not configure a description
+const defaultDescription = "Node image {{.Name}}"
+
+// computerNameRe matches a single DNS label, which is what guest
+// customization accepts as a Linux computer name
+var computerNameRe = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// describe renders the description of the given image
+func (l *Location) describe(name string) (string, error) {
+	tmpl := l.description
+	if tmpl == nil {
+		tmpl = template.Must(template.New("description").Parse(defaultDescription))
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, struct{ Name string }{Name: name}); err != nil {
+		return "", fmt.Errorf("failed to render description for %s: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// metadata returns the metadata of the given image, the component versions
+// parsed from its name overlaid with the configured location metadata
+func (l *Location) metadata(name string) map[string]string {
+	metadata := map[string]string{}
+	if components, ok := image.ParseImageName(name); ok {
+		metadata["os"] = components.OS
+		metadata["os-version"] = components.OSVersion
+		metadata["kubernetes-version"] = components.KubernetesVersion
+		metadata["os-tooling-version"] = components.ToolingVersion
+		if components.Channel != "" {
+			metadata["release-channel"] = components.Channel
+		}
+	}
+	for k, v := range l.Metadata {
+		metadata[k] = v
+	}
+	return metadata
+}
+
 func loadCredentials(path string) (*Credentials, error) {
 	file, err := os.ReadFile(path) // nolint:gosec
 	if err != nil {
@@ -296,10 +819,14 @@ func loadLocation(path string) (*Location, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read locations file:\n%w", err)
 	}
+	return parseLocation(file)
+}
 
+// parseLocation unmarshals and checks the content of a locations file
+func parseLocation(data []byte) (*Location, error) {
 	var location Location
 
-	if err := yaml.Unmarshal(file, &location); err != nil {
+	if err := yaml.Unmarshal(data, &location); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal locations file:\n%w", err)
 	}
 	if location.Name == "" {
@@ -308,9 +835,27 @@ func loadLocation(path string) (*Location, error) {
 	if location.VDC == "" {
 		return nil, fmt.Errorf("location VDC is required")
 	}
-	if location.Catalog == "" {
-		return nil, fmt.Errorf("location Catalog is required")
+	if location.Catalog == "" && len(location.Catalogs) == 0 {
+		return nil, fmt.Errorf("location Catalog or Catalogs is required")
+	}
+	for channel, catalog := range location.Catalogs {
+		if channel == "" || catalog == "" {
+			return nil, fmt.Errorf("location Catalogs must map channels to catalogs, got %q: %q", channel, catalog)
+		}
+	}
+	if location.ComputerName != "" && !computerNameRe.MatchString(location.ComputerName) {
+		return nil, fmt.Errorf("location computerName %q is not a valid hostname", location.ComputerName)
+	}
+
+	description := location.Description
+	if description == "" {
+		description = defaultDescription
+	}
+	parsed, err := template.New("description").Option("missingkey=error").Parse(description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse location description: %w", err)
 	}
+	location.description = parsed
 
 	return &location, nil
 }
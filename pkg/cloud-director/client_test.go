@@ -0,0 +1,401 @@
+package clouddirector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	types "github.com/vmware/go-vcloud-director/v3/types/v56"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// newTestClient returns a client whose logins are counted instead of being
+// sent to Cloud Director.
+func newTestClient(loginErr error) (*Client, *int) {
+	logins := 0
+	c := &Client{
+		backoff:                 wait.Backoff{Duration: time.Millisecond, Steps: 1},
+		sessionRefreshThreshold: defaultSessionRefreshThreshold,
+		authenticatedAt:         time.Now(),
+		httpClient:              http.DefaultClient,
+		overwritePolicy:         overwritePolicyError,
+		login: func() error {
+			logins++
+			return loginErr
+		},
+		templateChecksum: func(config ImporterConfig) (string, bool, error) {
+			return "", false, nil
+		},
+		templateID: func(config ImporterConfig) (string, error) {
+			return "", nil
+		},
+	}
+	return c, &logins
+}
+
+func TestWithSessionRetry(t *testing.T) {
+	expired := fmt.Errorf("upload failed: %w", &types.Error{MajorErrorCode: 401, Message: "session expired"})
+
+	testCases := []struct {
+		name           string
+		errs           []error
+		loginErr       error
+		expectedCalls  int
+		expectedLogins int
+		expectError    bool
+	}{
+		{
+			name:           "case 0: successful operation is not retried",
+			errs:           []error{nil},
+			expectedCalls:  1,
+			expectedLogins: 0,
+		},
+		{
+			name:           "case 1: expired session reconnects once and retries",
+			errs:           []error{expired, nil},
+			expectedCalls:  2,
+			expectedLogins: 1,
+		},
+		{
+			name:           "case 2: other errors are not retried",
+			errs:           []error{fmt.Errorf("disk full")},
+			expectedCalls:  1,
+			expectedLogins: 0,
+			expectError:    true,
+		},
+		{
+			name:           "case 3: only a single reconnect is attempted",
+			errs:           []error{expired, expired},
+			expectedCalls:  2,
+			expectedLogins: 1,
+			expectError:    true,
+		},
+		{
+			name:           "case 4: failed reconnect is not retried",
+			errs:           []error{expired},
+			loginErr:       fmt.Errorf("invalid credentials"),
+			expectedCalls:  1,
+			expectedLogins: 1,
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, logins := newTestClient(tc.loginErr)
+
+			calls := 0
+			err := c.withSessionRetry(context.Background(), func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedCalls, calls)
+			assert.Equal(t, tc.expectedLogins, *logins)
+		})
+	}
+}
+
+func TestIsSessionExpired(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "case 0: nil error",
+			err:      nil,
+			expected: false,
+		},
+		{
+			name:     "case 1: typed 401 api error",
+			err:      fmt.Errorf("wrapped: %w", &types.Error{MajorErrorCode: 401}),
+			expected: true,
+		},
+		{
+			name:     "case 2: flattened 401 api error",
+			err:      fmt.Errorf("file upload failed. Err: %s", types.Error{MajorErrorCode: 401, Message: "Unauthorized"}),
+			expected: true,
+		},
+		{
+			name:     "case 3: http status without body",
+			err:      fmt.Errorf("401 Unauthorized"),
+			expected: true,
+		},
+		{
+			name:     "case 4: other api error",
+			err:      &types.Error{MajorErrorCode: 500, Message: "internal error"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isSessionExpired(tc.err))
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	// without a govcd client any call to Cloud Director would panic
+	c := &Client{
+		location: &Location{Name: "loc", Catalog: "catalog"},
+		dryRun:   true,
+	}
+
+	assert.NoError(t, c.Create(context.Background(), "https://example.com/image.ova", "image", "loc"))
+	assert.NoError(t, c.Delete(context.Background(), "image", "loc"))
+}
+
+func TestList(t *testing.T) {
+	const name = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
+
+	c := &Client{
+		location: &Location{Name: "loc", Catalog: "catalog"},
+		listVAppTemplates: func(ctx context.Context) ([]string, error) {
+			return []string{name, "my-own-template"}, nil
+		},
+	}
+
+	// templates not named like node images are not listed
+	names, err := c.List(context.Background(), "loc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{name}, names)
+
+	c.listVAppTemplates = func(ctx context.Context) ([]string, error) {
+		return nil, fmt.Errorf("catalog unavailable")
+	}
+	_, err = c.List(context.Background(), "loc")
+	assert.ErrorContains(t, err, "catalog unavailable")
+}
+
+func TestImageSuffix(t *testing.T) {
+	const name = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
+
+	c := &Client{
+		location: &Location{Name: "loc", Catalog: "catalog", ImageSuffix: "efi"},
+		listVAppTemplates: func(ctx context.Context) ([]string, error) {
+			return []string{name + "-efi", name, name + "-bios"}, nil
+		},
+	}
+	assert.Equal(t, name+"-efi", c.ImageName(name, "loc"))
+	assert.Equal(t, maxCatalogItemNameLength-4, c.MaxNameLength("loc"))
+
+	// the template is named with the suffix, its metadata is parsed from the
+	// image name
+	config, err := c.importerConfig(nil, "https://example.com/image.ova", name)
+	assert.NoError(t, err)
+	assert.Equal(t, name+"-efi", config.Name)
+	assert.Equal(t, "stable", config.Metadata["release-channel"])
+	assert.Equal(t, "Node image "+name, config.Description)
+
+	// names are listed without the suffix, templates of other suffixes are
+	// left alone
+	names, err := c.List(context.Background(), "loc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{name}, names)
+
+	c.location.ImageSuffix = ""
+	assert.Equal(t, name, c.ImageName(name, "loc"))
+	assert.Equal(t, maxCatalogItemNameLength, c.MaxNameLength("loc"))
+}
+
+func TestReloadLocations(t *testing.T) {
+	c := &Client{
+		credentials: &Credentials{Org: "org"},
+		location:    &Location{Name: "loc", Org: "org", VDC: "vdc", Catalog: "catalog"},
+	}
+
+	// an invalid location is rejected and the current one kept
+	err := c.ReloadLocations(context.Background(), []byte("name: loc\ncatalog: catalog\n"))
+	assert.ErrorContains(t, err, "location VDC is required")
+	assert.Equal(t, "catalog", c.currentLocation().Catalog)
+
+	require.NoError(t, c.ReloadLocations(context.Background(), []byte("name: loc\nvdc: vdc\ncatalog: other\nimageSuffix: efi\n")))
+	assert.Equal(t, "other", c.currentLocation().Catalog)
+	assert.Equal(t, "org", c.currentLocation().Org)
+	assert.Equal(t, "image-efi", c.ImageName("image", "loc"))
+}
+
+func TestCheckUploadPieceSize(t *testing.T) {
+	testCases := []struct {
+		name        string
+		size        int64
+		expected    int64
+		expectError bool
+	}{
+		{name: "case 0: unset defaults to 10MB", size: 0, expected: 10 << 20},
+		{name: "case 1: configured size", size: 64 << 20, expected: 64 << 20},
+		{name: "case 2: smallest size", size: 1 << 20, expected: 1 << 20},
+		{name: "case 3: largest size", size: 1 << 30, expected: 1 << 30},
+		{name: "case 4: too small", size: 1024, expectError: true},
+		{name: "case 5: too large", size: 2 << 30, expectError: true},
+		{name: "case 6: negative", size: -1, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := checkUploadPieceSize(tc.size)
+			if tc.expectError {
+				assert.ErrorContains(t, err, "invalid upload piece size")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, size)
+		})
+	}
+}
+
+func TestImporterConfigUploadPieceSize(t *testing.T) {
+	c := &Client{
+		location:        &Location{Name: "loc", Catalog: "catalog"},
+		uploadPieceSize: 64 << 20,
+	}
+
+	config, err := c.importerConfig(nil, "https://example.com/image.ova", "image")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(64<<20), config.UploadPieceSize)
+}
+
+func TestLoadLocation(t *testing.T) {
+	testCases := []struct {
+		name                string
+		locations           string
+		expectError         bool
+		expectedDescription string
+		expectedComputer    string
+	}{
+		{
+			name:                "case 0: default description",
+			locations:           "name: loc\nvdc: vdc\ncatalog: catalog\n",
+			expectedDescription: "Node image flatcar-stable-gs",
+		},
+		{
+			name: "case 1: configured description and computer name",
+			locations: "name: loc\nvdc: vdc\ncatalog: catalog\n" +
+				"description: \"Giant Swarm {{.Name}} (managed)\"\ncomputerName: gs-node\n",
+			expectedDescription: "Giant Swarm flatcar-stable-gs (managed)",
+			expectedComputer:    "gs-node",
+		},
+		{
+			name:                "case 2: static description",
+			locations:           "name: loc\nvdc: vdc\ncatalog: catalog\ndescription: inventory-tag\n",
+			expectedDescription: "inventory-tag",
+		},
+		{
+			name:        "case 3: invalid description template",
+			locations:   "name: loc\nvdc: vdc\ncatalog: catalog\ndescription: \"{{.Name\"\n",
+			expectError: true,
+		},
+		{
+			name:        "case 4: unknown description field",
+			locations:   "name: loc\nvdc: vdc\ncatalog: catalog\ndescription: \"{{.Version}}\"\n",
+			expectError: true,
+		},
+		{
+			name:        "case 5: invalid computer name",
+			locations:   "name: loc\nvdc: vdc\ncatalog: catalog\ncomputerName: gs_node\n",
+			expectError: true,
+		},
+		{
+			name:                "case 6: catalogs per channel without a default catalog",
+			locations:           "name: loc\nvdc: vdc\ncatalogs:\n  stable: k8s-stable\n",
+			expectedDescription: "Node image flatcar-stable-gs",
+		},
+		{
+			name:        "case 7: no catalog",
+			locations:   "name: loc\nvdc: vdc\n",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "locations")
+			assert.NoError(t, os.WriteFile(path, []byte(tc.locations), 0600))
+
+			location, err := loadLocation(path)
+			if err == nil {
+				var description string
+				description, err = location.describe("flatcar-stable-gs")
+				if err == nil {
+					assert.Equal(t, tc.expectedDescription, description)
+					assert.Equal(t, tc.expectedComputer, location.ComputerName)
+				}
+			}
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCatalogName(t *testing.T) {
+	testCases := []struct {
+		name            string
+		location        *Location
+		image           string
+		expectedCatalog string
+		expectError     bool
+	}{
+		{
+			name:            "case 0: single catalog",
+			location:        &Location{Catalog: "catalog"},
+			image:           "flatcar-beta-4230.1.0-kube-1.31.1-tooling-1.20.0-gs",
+			expectedCatalog: "catalog",
+		},
+		{
+			name:            "case 1: catalog of the channel",
+			location:        &Location{Catalog: "catalog", Catalogs: map[string]string{"stable": "k8s-stable", "beta": "k8s-beta"}},
+			image:           "flatcar-beta-4230.1.0-kube-1.31.1-tooling-1.20.0-gs",
+			expectedCatalog: "k8s-beta",
+		},
+		{
+			name:            "case 2: channel without a catalog falls back to the catalog",
+			location:        &Location{Catalog: "catalog", Catalogs: map[string]string{"stable": "k8s-stable"}},
+			image:           "flatcar-alpha-4230.1.0-kube-1.31.1-tooling-1.20.0-gs",
+			expectedCatalog: "catalog",
+		},
+		{
+			name:            "case 3: image without a channel falls back to the catalog",
+			location:        &Location{Catalog: "catalog", Catalogs: map[string]string{"stable": "k8s-stable"}},
+			image:           "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+			expectedCatalog: "catalog",
+		},
+		{
+			name:        "case 4: channel without a catalog and no fallback",
+			location:    &Location{Name: "loc", Catalogs: map[string]string{"stable": "k8s-stable"}},
+			image:       "flatcar-beta-4230.1.0-kube-1.31.1-tooling-1.20.0-gs",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			catalog, err := tc.location.catalogName(tc.image)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCatalog, catalog)
+		})
+	}
+
+	location := &Location{Catalog: "catalog", Catalogs: map[string]string{"stable": "k8s-stable", "beta": "k8s-beta", "alpha": "catalog"}}
+	assert.Equal(t, []string{"catalog", "k8s-beta", "k8s-stable"}, location.catalogNames())
+}
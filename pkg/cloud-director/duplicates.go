@@ -0,0 +1,150 @@
+package clouddirector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// vAppTemplateInfo is a vApp template in a catalog of the location with its
+// metadata
+type vAppTemplateInfo struct {
+	Catalog  string
+	Name     string
+	HREF     string
+	Metadata map[string]string
+}
+
+// DeleteByMetadata deletes the vApp templates in the catalogs of the location
+// whose metadata has all key-value pairs of the selector, whatever their
+// name, e.g. all templates uploaded from the same OVA under a name used
+// before. An empty selector is rejected, it would match every template.
+func (c *Client) DeleteByMetadata(ctx context.Context, selector map[string]string) error {
+	log := log.FromContext(ctx)
+
+	if len(selector) == 0 {
+		return fmt.Errorf("metadata selector is empty")
+	}
+
+	templates, err := c.listTemplateMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	var failed []error
+	for _, vAppTemplate := range templates {
+		if !matchesMetadata(vAppTemplate.Metadata, selector) {
+			continue
+		}
+		if c.dryRun {
+			log.Info("Dry run: would delete vApp template matching metadata", "name", vAppTemplate.Name, "catalog", vAppTemplate.Catalog, "selector", selector)
+			continue
+		}
+		log.Info("Deleting vApp template matching metadata", "name", vAppTemplate.Name, "catalog", vAppTemplate.Catalog, "selector", selector)
+		if err := c.deleteVAppTemplate(ctx, vAppTemplate); err != nil {
+			failed = append(failed, fmt.Errorf("failed to delete vApp template %s: %w", vAppTemplate.Name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d of the vApp templates matching %v: %w", len(failed), selector, failed[0])
+	}
+	return nil
+}
+
+// duplicateSelector returns the metadata selector of the copies of an image
+// under other names: the NodeImage they were uploaded for, the one in the
+// metadata of the template if nodeImage is empty, and the source checksum of
+// the template if it is known. The checksum alone also matches the templates
+// of other NodeImages uploaded from the same OVA, e.g. of another tooling
+// version, so without a NodeImage there is no selector.
+func duplicateSelector(nodeImage string, metadata map[string]string) map[string]string {
+	if nodeImage == "" {
+		nodeImage = metadata[nodeImageKey]
+	}
+	if nodeImage == "" {
+		return nil
+	}
+	selector := map[string]string{nodeImageKey: nodeImage}
+	if checksum := metadata[sourceChecksumKey]; checksum != "" {
+		selector[sourceChecksumKey] = checksum
+	}
+	return selector
+}
+
+// matchesMetadata reports whether metadata has all key-value pairs of the
+// selector
+func matchesMetadata(metadata map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// queryVAppTemplateMetadata returns the vApp templates in the catalogs of the
+// location with their metadata
+func (c *Client) queryVAppTemplateMetadata(ctx context.Context) ([]vAppTemplateInfo, error) {
+	var templates []vAppTemplateInfo
+	for _, catalogName := range c.currentLocation().catalogNames() {
+		catalog, err := c.getCatalogByName(ctx, catalogName)
+		if err != nil {
+			return nil, err
+		}
+
+		results, err := catalog.QueryVappTemplateList()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list vApp templates in catalog %s: %w", catalogName, err)
+		}
+		for _, result := range results {
+			vAppTemplate, err := c.cloudDirector.GetVAppTemplateByHref(result.HREF)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get vApp template %s: %w", result.Name, err)
+			}
+			metadata, err := vAppTemplateMetadata(vAppTemplate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get metadata of vApp template %s: %w", result.Name, err)
+			}
+			templates = append(templates, vAppTemplateInfo{
+				Catalog:  catalogName,
+				Name:     result.Name,
+				HREF:     result.HREF,
+				Metadata: metadata,
+			})
+		}
+	}
+	return templates, nil
+}
+
+// vAppTemplateMetadata returns the string metadata of the vApp template
+func vAppTemplateMetadata(vAppTemplate *govcd.VAppTemplate) (map[string]string, error) {
+	metadata, err := vAppTemplate.GetMetadata()
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(metadata.MetadataEntry))
+	for _, entry := range metadata.MetadataEntry {
+		if entry != nil && entry.TypedValue != nil {
+			values[entry.Key] = entry.TypedValue.Value
+		}
+	}
+	return values, nil
+}
+
+// removeVAppTemplate deletes the vApp template, which is gone already if it
+// can't be found
+func (c *Client) removeVAppTemplate(ctx context.Context, info vAppTemplateInfo) error {
+	vAppTemplate, err := c.cloudDirector.GetVAppTemplateByHref(info.HREF)
+	if err != nil {
+		if govcd.ContainsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if err := vAppTemplate.Delete(); err != nil && !govcd.ContainsNotFound(err) {
+		return err
+	}
+	return nil
+}
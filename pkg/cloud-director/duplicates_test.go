@@ -0,0 +1,144 @@
+package clouddirector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteByMetadata(t *testing.T) {
+	templates := []vAppTemplateInfo{
+		{Catalog: "k8s", Name: "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs", Metadata: map[string]string{sourceChecksumKey: "abc", "os-version": "3975.2.0"}},
+		{Catalog: "k8s", Name: "flatcar-stable-3975.2.0-kube-v1.30.4-gs", Metadata: map[string]string{sourceChecksumKey: "abc", "os-version": "3975.2.0"}},
+		{Catalog: "k8s-beta", Name: "flatcar-3975.2.0-kube-1.30.4", Metadata: map[string]string{sourceChecksumKey: "abc"}},
+		{Catalog: "k8s", Name: "flatcar-stable-4081.2.0-kube-1.31.1-tooling-1.19.0-gs", Metadata: map[string]string{sourceChecksumKey: "def", "os-version": "4081.2.0"}},
+		{Catalog: "k8s", Name: "my-own-template"},
+		{Catalog: "k8s", Name: "flatcar-stable-4152.2.0-kube-1.31.4-tooling-1.20.0-gs", Metadata: map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.0"}},
+		{Catalog: "k8s", Name: "flatcar-stable-4152.2.0-kube-v1.31.4-gs", Metadata: map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.0"}},
+		{Catalog: "k8s", Name: "flatcar-stable-4152.2.0-kube-1.31.4-tooling-1.20.1-gs", Metadata: map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.1"}},
+	}
+
+	testCases := []struct {
+		name            string
+		selector        map[string]string
+		dryRun          bool
+		deleteErr       map[string]error
+		expectedDeleted []string
+		expectedError   string
+	}{
+		{
+			name:     "case 0: all templates matching the checksum are deleted",
+			selector: map[string]string{sourceChecksumKey: "abc"},
+			expectedDeleted: []string{
+				"flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+				"flatcar-stable-3975.2.0-kube-v1.30.4-gs",
+				"flatcar-3975.2.0-kube-1.30.4",
+			},
+		},
+		{
+			name:     "case 1: templates must match every key of the selector",
+			selector: map[string]string{sourceChecksumKey: "abc", "os-version": "3975.2.0"},
+			expectedDeleted: []string{
+				"flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+				"flatcar-stable-3975.2.0-kube-v1.30.4-gs",
+			},
+		},
+		{
+			name:     "case 2: nothing matches",
+			selector: map[string]string{sourceChecksumKey: "123"},
+		},
+		{
+			name:          "case 3: empty selector is rejected",
+			expectedError: "metadata selector is empty",
+		},
+		{
+			name:     "case 4: dry run deletes nothing",
+			selector: map[string]string{sourceChecksumKey: "abc"},
+			dryRun:   true,
+		},
+		{
+			name:      "case 5: failed deletion does not stop the others",
+			selector:  map[string]string{sourceChecksumKey: "abc"},
+			deleteErr: map[string]error{"flatcar-stable-3975.2.0-kube-v1.30.4-gs": fmt.Errorf("busy")},
+			expectedDeleted: []string{
+				"flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+				"flatcar-3975.2.0-kube-1.30.4",
+			},
+			expectedError: "failed to delete vApp template flatcar-stable-3975.2.0-kube-v1.30.4-gs: busy",
+		},
+		{
+			name:     "case 6: templates of another NodeImage from the same OVA are kept",
+			selector: map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.0"},
+			expectedDeleted: []string{
+				"flatcar-stable-4152.2.0-kube-1.31.4-tooling-1.20.0-gs",
+				"flatcar-stable-4152.2.0-kube-v1.31.4-gs",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var deleted []string
+			c := &Client{
+				location: &Location{Name: "loc", Catalog: "k8s"},
+				dryRun:   tc.dryRun,
+				listTemplateMetadata: func(ctx context.Context) ([]vAppTemplateInfo, error) {
+					return templates, nil
+				},
+				deleteVAppTemplate: func(ctx context.Context, vAppTemplate vAppTemplateInfo) error {
+					if err := tc.deleteErr[vAppTemplate.Name]; err != nil {
+						return err
+					}
+					deleted = append(deleted, vAppTemplate.Name)
+					return nil
+				},
+			}
+
+			err := c.DeleteByMetadata(context.Background(), tc.selector)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedDeleted, deleted)
+		})
+	}
+}
+
+func TestDuplicateSelector(t *testing.T) {
+	testCases := []struct {
+		name      string
+		nodeImage string
+		metadata  map[string]string
+		expected  map[string]string
+	}{
+		{
+			name:      "case 0: NodeImage and checksum of the template",
+			nodeImage: "capvcd-tooling-1.20.0",
+			metadata:  map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.0"},
+			expected:  map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.0"},
+		},
+		{
+			name:      "case 1: template under the current name is gone",
+			nodeImage: "capvcd-tooling-1.20.0",
+			expected:  map[string]string{nodeImageKey: "capvcd-tooling-1.20.0"},
+		},
+		{
+			name:     "case 2: NodeImage of the template if the NodeImage is not known",
+			metadata: map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.0"},
+			expected: map[string]string{sourceChecksumKey: "ghi", nodeImageKey: "capvcd-tooling-1.20.0"},
+		},
+		{
+			name:     "case 3: checksum alone selects nothing",
+			metadata: map[string]string{sourceChecksumKey: "ghi"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, duplicateSelector(tc.nodeImage, tc.metadata))
+		})
+	}
+}
@@ -3,15 +3,30 @@ package clouddirector
 import (
 	"archive/tar"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/vmware/go-vcloud-director/v3/govcd"
+	types "github.com/vmware/go-vcloud-director/v3/types/v56"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
 )
 
 // ImporterConfig holds the configuration for the OVF importer
@@ -20,6 +35,30 @@ type ImporterConfig struct {
 	Path            string
 	Catalog         *govcd.Catalog
 	HardwareVersion int // e.g. 19 → "vmx-19"; 0 means no patching
+	Description     string
+	ComputerName    string // guest customization computer name; empty means no patching
+	Metadata        map[string]string
+	UploadPieceSize int64 // size of the chunks the image is uploaded in
+}
+
+// checkArtifact fails for images that push mode can't import. Images are
+// downloaded as a single file and uploaded with their disks from it, so an
+// exploded OVF whose disks are sibling files can't be imported; Cloud
+// Director has no pull mode here to import it by link instead.
+func checkArtifact(imageURL string) error {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return fmt.Errorf("invalid image URL: %w", err)
+	}
+
+	switch ext := strings.ToLower(path.Ext(u.Path)); ext {
+	case ".ova":
+		return nil
+	case ".ovf":
+		return fmt.Errorf("image %s is an exploded OVF: only OVA archives are supported, as images are pushed from a single downloaded file", u.Path)
+	default:
+		return fmt.Errorf("image %s has unsupported type %q: only OVA archives are supported", u.Path, ext)
+	}
 }
 
 // importImage handles the actual import using push mode and waits for completion
@@ -31,8 +70,14 @@ func (c *Client) importImage(ctx context.Context, config ImporterConfig) error {
 func (c *Client) pushImport(ctx context.Context, config ImporterConfig) error {
 	log := log.FromContext(ctx)
 
+	// Patching the OVF descriptor writes a second copy of the OVA
+	copies := int64(1)
+	if ovfPatch(config) != nil {
+		copies = 2
+	}
+
 	// Download the OVA file to local filesystem
-	localPath, err := c.downloadImage(ctx, config.Path)
+	localPath, err := c.downloadImage(ctx, config.Path, copies)
 	if err != nil {
 		return fmt.Errorf("failed to download image: %w", err)
 	}
@@ -42,11 +87,32 @@ func (c *Client) pushImport(ctx context.Context, config ImporterConfig) error {
 		}
 	}() // Cleanup after upload
 
-	// Patch the OVF descriptor in the OVA if a hardware version is configured
-	if config.HardwareVersion != 0 {
-		patchedPath, err := patchOVAHardwareVersion(localPath, c.downloadDir, fmt.Sprintf("vmx-%d", config.HardwareVersion))
+	// The checksum of the image as downloaded is stored with the template,
+	// so a template of the same name can be told apart from this image
+	checksum, err := fileChecksum(localPath)
+	if err != nil {
+		return err
+	}
+	config.Metadata = maps.Clone(config.Metadata)
+	if config.Metadata == nil {
+		config.Metadata = map[string]string{}
+	}
+	config.Metadata[sourceChecksumKey] = checksum
+	// NodeImages of the same OVA share the checksum, the NodeImage tells
+	// their templates apart when deleting duplicates
+	if provenance, ok := provider.ProvenanceFrom(ctx); ok && provenance.NodeImage != "" {
+		config.Metadata[nodeImageKey] = provenance.NodeImage
+	}
+
+	if skip, err := c.checkExisting(ctx, config, checksum); err != nil || skip {
+		return err
+	}
+
+	// Patch the OVF descriptor in the OVA if a hardware version or computer name is configured
+	if patch := ovfPatch(config); patch != nil {
+		patchedPath, err := patchOVA(localPath, filepath.Dir(localPath), patch)
 		if err != nil {
-			return fmt.Errorf("failed to patch OVA hardware version: %w", err)
+			return fmt.Errorf("failed to patch OVA: %w", err)
 		}
 		if patchedPath != localPath {
 			defer func() {
@@ -60,43 +126,418 @@ func (c *Client) pushImport(ctx context.Context, config ImporterConfig) error {
 
 	log.Info("Starting upload to cloud director", "localPath", localPath)
 
+	// Long uploads can outlive the session, so retry once with a fresh one.
+	// The failed attempt may have left a partial catalog item behind, which
+	// has to be removed before the name can be used again.
+	attempt := 0
+	err = c.withSessionRetry(ctx, func() error {
+		attempt++
+		if attempt > 1 {
+			c.removePartialUpload(ctx, config)
+		}
+		return c.upload(ctx, config, localPath)
+	})
+	if err != nil {
+		// an upload aborted because the operator shuts down leaves a partial
+		// catalog item behind
+		if ctx.Err() != nil {
+			c.removePartialUpload(ctx, config)
+		}
+		return err
+	}
+
+	// A template without its metadata would be picked up as available on
+	// the next reconcile, so remove it again to have the upload retried
+	if err := c.applyMetadata(ctx, config); err != nil {
+		c.removePartialUpload(ctx, config)
+		return err
+	}
+
+	log.Info("Push upload completed successfully", "name", config.Name)
+
+	// the ID only spares lookups by name, a template whose ID could not be
+	// read is still usable
+	var id string
+	err = c.withSessionRetry(ctx, func() error {
+		var err error
+		id, err = c.templateID(config)
+		return err
+	})
+	if err != nil {
+		log.Info("Failed to get the ID of the uploaded vApp template", "name", config.Name, "error", err.Error())
+		return nil
+	}
+	provider.ReportImageID(ctx, id)
+
+	return nil
+}
+
+// sourceChecksumKey is the metadata key of the SHA256 checksum of the OVA a
+// vApp template was uploaded from
+const sourceChecksumKey = "source-sha256"
+
+// nodeImageKey is the metadata key of the NodeImage a vApp template was
+// uploaded for
+const nodeImageKey = "node-image"
+
+// Policies for an upload whose name is taken by a vApp template with
+// different content
+const (
+	// overwritePolicyError fails the upload
+	overwritePolicyError = "error"
+	// overwritePolicySkip keeps the existing template
+	overwritePolicySkip = "skip"
+	// overwritePolicyReplace deletes the existing template and uploads the image
+	overwritePolicyReplace = "replace"
+)
+
+// checkOverwritePolicy returns the overwrite policy to use for the
+// configured one, defaulting to overwritePolicyError
+func checkOverwritePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return overwritePolicyError, nil
+	case overwritePolicyError, overwritePolicySkip, overwritePolicyReplace:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid overwrite policy %q: must be %q, %q or %q",
+		policy, overwritePolicyError, overwritePolicySkip, overwritePolicyReplace)
+}
+
+// checkExisting handles a vApp template that already uses the name of the
+// upload and reports whether the upload is skipped. A template uploaded from
+// an image with the same checksum is kept. For one with different or unknown
+// content the overwrite policy decides.
+func (c *Client) checkExisting(ctx context.Context, config ImporterConfig, checksum string) (bool, error) {
+	log := log.FromContext(ctx)
+
+	var existing string
+	var found bool
+	err := c.withSessionRetry(ctx, func() error {
+		var err error
+		existing, found, err = c.templateChecksum(config)
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check for existing vApp template %s: %w", config.Name, err)
+	}
+	if !found {
+		return false, nil
+	}
+	if existing == checksum {
+		log.Info("vApp template with the same content exists, skipping upload", "name", config.Name)
+		return true, nil
+	}
+
+	switch c.overwritePolicy {
+	case overwritePolicySkip:
+		log.Info("vApp template with different content exists, keeping it", "name", config.Name,
+			"checksum", existing, "imageChecksum", checksum)
+		return true, nil
+	case overwritePolicyReplace:
+		log.Info("vApp template with different content exists, replacing it", "name", config.Name,
+			"checksum", existing, "imageChecksum", checksum)
+		err := c.withSessionRetry(ctx, func() error {
+			return c.deleteItem(config)
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to delete vApp template %s to replace it: %w", config.Name, err)
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("vApp template %s already exists with different content (checksum %q, image checksum %q)",
+			config.Name, existing, checksum)
+	}
+}
+
+// vAppTemplateChecksum returns the source checksum stored in the metadata of
+// the vApp template of the import and whether the template exists. The
+// checksum is empty for templates uploaded without one.
+func vAppTemplateChecksum(config ImporterConfig) (string, bool, error) {
+	vAppTemplate, err := config.Catalog.GetVAppTemplateByName(config.Name)
+	if err != nil {
+		if govcd.ContainsNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	metadata, err := vAppTemplate.GetMetadata()
+	if err != nil {
+		return "", true, fmt.Errorf("failed to get metadata: %w", err)
+	}
+	for _, entry := range metadata.MetadataEntry {
+		if entry != nil && entry.Key == sourceChecksumKey && entry.TypedValue != nil {
+			return entry.TypedValue.Value, true, nil
+		}
+	}
+	return "", true, nil
+}
+
+// vAppTemplateID returns the ID of the vApp template of the import
+func vAppTemplateID(config ImporterConfig) (string, error) {
+	vAppTemplate, err := config.Catalog.GetVAppTemplateByName(config.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get vApp template %s: %w", config.Name, err)
+	}
+	return vAppTemplate.VAppTemplate.ID, nil
+}
+
+// deleteCatalogItem deletes the catalog item of the import
+func deleteCatalogItem(config ImporterConfig) error {
+	item, err := config.Catalog.GetCatalogItemByName(config.Name, true)
+	if err != nil {
+		if govcd.ContainsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	return item.Delete()
+}
+
+// fileChecksum returns the hex encoded SHA256 checksum of the file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path) // #nosec G304
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to compute checksum of %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadOVA uploads a local OVA to the catalog and waits for the upload to finish
+func (c *Client) uploadOVA(ctx context.Context, config ImporterConfig, localPath string) error {
+	log := log.FromContext(ctx)
+
 	// Upload to cloud director
 	uploadTask, err := config.Catalog.UploadOvf(
-		localPath,   // ovaFileName - local file path
-		config.Name, // itemName
-		fmt.Sprintf("Node image %s", config.Name), // description
-		1024*1024*10, // uploadPieceSize - 10MB chunks
+		localPath,              // ovaFileName - local file path
+		config.Name,            // itemName
+		config.Description,     // description
+		config.UploadPieceSize, // uploadPieceSize
 	)
 	if err != nil {
 		return fmt.Errorf("failed to start push upload: %w", err)
 	}
 
-	log.Info("Push upload started, waiting for completion", "name", config.Name)
+	// The task is needed to look into a stuck upload on the Cloud Director side
+	taskHREF, taskID := taskRef(uploadTask.Task)
+	log.Info("Push upload started, waiting for completion", "name", config.Name, "taskHREF", taskHREF, "taskID", taskID)
+	provider.ReportTask(ctx, taskHREF)
+
+	if info, err := os.Stat(localPath); err == nil {
+		stop := reportUploadProgress(ctx, uploadTask.GetUploadProgress, info.Size(), uploadProgressInterval)
+		defer stop()
+	}
 
 	// Wait for upload task completion - UploadTask must be waited on directly
 	// to ensure proper upload error handling
-	err = uploadTask.WaitTaskCompletion()
+	err = waitForUpload(ctx, &uploadTask)
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("upload aborted (task %s): %w", taskID, err)
+	}
 	if err != nil {
 		// Check if there was an upload error
 		if uploadErr := uploadTask.GetUploadError(); uploadErr != nil {
-			return fmt.Errorf("upload failed: %w", uploadErr)
+			return fmt.Errorf("upload failed (task %s): %w", taskID, uploadErr)
 		}
-		return fmt.Errorf("task completion failed: %w", err)
+		return fmt.Errorf("task %s completion failed: %w", taskID, err)
 	}
 
-	log.Info("Push upload completed successfully", "name", config.Name)
+	log.Info("Push upload task completed", "name", config.Name, "taskHREF", taskHREF, "taskID", taskID)
+	return nil
+}
+
+// waitForUpload waits for the upload task to complete. If ctx is done first,
+// e.g. because the operator shuts down, the task is cancelled, so Cloud
+// Director doesn't wait for an upload that is never completed.
+func waitForUpload(ctx context.Context, uploadTask *govcd.UploadTask) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- uploadTask.WaitTaskCompletion()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if err := uploadTask.CancelTask(); err != nil {
+			log.FromContext(ctx).Info("Failed to cancel upload task", "error", err.Error())
+		}
+		return ctx.Err()
+	}
+}
+
+// taskRef returns the HREF and ID of a Cloud Director task, or empty strings
+// for a task that is unknown
+func taskRef(task *govcd.Task) (string, string) {
+	if task == nil || task.Task == nil {
+		return "", ""
+	}
+	return task.Task.HREF, task.Task.ID
+}
+
+// uploadProgressInterval is how often the progress of an upload is reported
+const uploadProgressInterval = 5 * time.Second
+
+// reportUploadProgress polls the upload percentage every interval and passes
+// it to the progress function of the context as the share of size uploaded,
+// until the returned function is called
+func reportUploadProgress(ctx context.Context, percentage func() string, size int64, interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				percent, err := strconv.ParseFloat(percentage(), 64)
+				if err != nil {
+					continue
+				}
+				provider.ReportProgress(ctx, int64(percent*float64(size)/100), size)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// applyMetadata adds the configured metadata to the uploaded vApp template
+func (c *Client) applyMetadata(ctx context.Context, config ImporterConfig) error {
+	if len(config.Metadata) == 0 {
+		return nil
+	}
+	log := log.FromContext(ctx)
+
+	metadata := make(map[string]types.MetadataValue, len(config.Metadata))
+	for k, v := range config.Metadata {
+		metadata[k] = types.MetadataValue{
+			TypedValue: &types.MetadataTypedValue{
+				XsiType: types.MetadataStringValue,
+				Value:   v,
+			},
+		}
+	}
+
+	err := c.withSessionRetry(ctx, func() error {
+		return c.mergeMetadata(config, metadata)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply metadata to vApp template %s: %w", config.Name, err)
+	}
 
+	log.Info("Applied metadata to vApp template", "name", config.Name, "keys", len(metadata))
 	return nil
 }
 
+// mergeVAppTemplateMetadata merges metadata into the vApp template of the catalog item
+func mergeVAppTemplateMetadata(config ImporterConfig, metadata map[string]types.MetadataValue) error {
+	vAppTemplate, err := config.Catalog.GetVAppTemplateByName(config.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get vApp template %s: %w", config.Name, err)
+	}
+	return vAppTemplate.MergeMetadataWithMetadataValues(metadata)
+}
+
+// removePartialUpload deletes the catalog item left behind by a failed upload.
+// It is best effort, a leftover item surfaces as an error on the next upload.
+func (c *Client) removePartialUpload(ctx context.Context, config ImporterConfig) {
+	log := log.FromContext(ctx)
+
+	item, err := config.Catalog.GetCatalogItemByName(config.Name, true)
+	if err != nil {
+		if !govcd.ContainsNotFound(err) {
+			log.Info("Failed to look up partial upload", "name", config.Name, "error", err.Error())
+		}
+		return
+	}
+	if err := item.Delete(); err != nil {
+		log.Info("Failed to remove partial upload", "name", config.Name, "error", err.Error())
+		return
+	}
+	log.Info("Removed partial upload", "name", config.Name)
+}
+
 // hwVersionRe matches the VirtualSystemType element in an OVF descriptor
 var hwVersionRe = regexp.MustCompile(`(?i)<vssd:VirtualSystemType>[^<]*</vssd:VirtualSystemType>`)
 
-// patchOVAHardwareVersion rewrites the OVF descriptor inside the OVA tarball,
-// replacing the VirtualSystemType with the given hardware version. Returns the
-// path to the patched OVA (a new temp file) or the original path unchanged if
-// no OVF was found.
-func patchOVAHardwareVersion(ovaPath, dir, hardwareVersion string) (string, error) {
+// ovfComputerNameRe matches the ComputerName element of an existing guest
+// customization section in an OVF descriptor
+var ovfComputerNameRe = regexp.MustCompile(`(?i)<vcloud:ComputerName>[^<]*</vcloud:ComputerName>`)
+
+// virtualSystemEndRe matches the closing VirtualSystem element in an OVF descriptor
+var virtualSystemEndRe = regexp.MustCompile(`(?i)</(\w+:)?VirtualSystem>`)
+
+// guestCustomizationSection declares its own namespaces, the descriptors of
+// the node images do not declare the vcloud one
+const guestCustomizationSection = `<vcloud:GuestCustomizationSection xmlns:vcloud="http://www.vmware.com/vcloud/v1.5" ` +
+	`xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" ovf:required="false">` +
+	`<ovf:Info>Specifies Guest OS Customization Settings</ovf:Info>` +
+	`<vcloud:ComputerName>%s</vcloud:ComputerName>` +
+	`</vcloud:GuestCustomizationSection>`
+
+// ovfPatch returns the changes to apply to the OVF descriptor for the given
+// import, or nil if the descriptor can be uploaded as is
+func ovfPatch(config ImporterConfig) func([]byte) []byte {
+	if config.HardwareVersion == 0 && config.ComputerName == "" {
+		return nil
+	}
+
+	return func(data []byte) []byte {
+		if config.HardwareVersion != 0 {
+			data = patchHardwareVersion(data, fmt.Sprintf("vmx-%d", config.HardwareVersion))
+		}
+		if config.ComputerName != "" {
+			data = patchComputerName(data, config.ComputerName)
+		}
+		return data
+	}
+}
+
+// patchHardwareVersion replaces the VirtualSystemType with the given hardware version
+func patchHardwareVersion(data []byte, hardwareVersion string) []byte {
+	replacement := []byte("<vssd:VirtualSystemType>" + hardwareVersion + "</vssd:VirtualSystemType>")
+	return hwVersionRe.ReplaceAll(data, replacement)
+}
+
+// patchComputerName sets the computer name of the guest customization
+// section, adding the section to the first virtual system if there is none
+func patchComputerName(data []byte, computerName string) []byte {
+	if ovfComputerNameRe.Match(data) {
+		replacement := []byte("<vcloud:ComputerName>" + computerName + "</vcloud:ComputerName>")
+		return ovfComputerNameRe.ReplaceAll(data, replacement)
+	}
+
+	loc := virtualSystemEndRe.FindIndex(data)
+	if loc == nil {
+		return data
+	}
+	section := fmt.Sprintf(guestCustomizationSection, computerName)
+
+	patched := make([]byte, 0, len(data)+len(section))
+	patched = append(patched, data[:loc[0]]...)
+	patched = append(patched, section...)
+	return append(patched, data[loc[0]:]...)
+}
+
+// patchOVA rewrites the OVF descriptor inside the OVA tarball using patch.
+// Returns the path to the patched OVA (a new temp file) or the original path
+// unchanged if no OVF was found.
+func patchOVA(ovaPath, dir string, patch func([]byte) []byte) (string, error) {
 	in, err := os.Open(ovaPath) // #nosec G304
 	if err != nil {
 		return "", fmt.Errorf("open OVA: %w", err)
@@ -109,7 +550,7 @@ func patchOVAHardwareVersion(ovaPath, dir, hardwareVersion string) (string, erro
 	}
 	outPath := out.Name()
 
-	patched, err := rewriteOVA(in, out, hardwareVersion)
+	patched, err := rewriteOVA(in, out, patch)
 	_ = out.Close()
 	if err != nil {
 		_ = os.Remove(outPath)
@@ -124,12 +565,11 @@ func patchOVAHardwareVersion(ovaPath, dir, hardwareVersion string) (string, erro
 
 // rewriteOVA copies the tar from r to w, patching any .ovf entry it finds.
 // Returns true if an OVF entry was found and patched.
-func rewriteOVA(r io.Reader, w io.Writer, hardwareVersion string) (bool, error) {
+func rewriteOVA(r io.Reader, w io.Writer, patch func([]byte) []byte) (bool, error) {
 	tr := tar.NewReader(r)
 	tw := tar.NewWriter(w)
 	defer func() { _ = tw.Close() }()
 
-	replacement := []byte("<vssd:VirtualSystemType>" + hardwareVersion + "</vssd:VirtualSystemType>")
 	patched := false
 	for {
 		hdr, err := tr.Next()
@@ -145,7 +585,7 @@ func rewriteOVA(r io.Reader, w io.Writer, hardwareVersion string) (bool, error)
 			if err != nil {
 				return false, fmt.Errorf("read OVF entry: %w", err)
 			}
-			patchedData := hwVersionRe.ReplaceAll(data, replacement)
+			patchedData := patch(data)
 			hdr.Size = int64(len(patchedData))
 			if err := tw.WriteHeader(hdr); err != nil {
 				return false, fmt.Errorf("write OVF header: %w", err)
@@ -166,44 +606,222 @@ func rewriteOVA(r io.Reader, w io.Writer, hardwareVersion string) (bool, error)
 	return patched, nil
 }
 
-// downloadImage downloads OVA from S3 to local temp file
-func (c *Client) downloadImage(ctx context.Context, imageURL string) (string, error) {
+// downloadProgressInterval is how often the progress of a download is logged
+const downloadProgressInterval = 30 * time.Second
+
+// errDownloadStalled cancels a download that did not receive any data for the
+// stall timeout
+var errDownloadStalled = errors.New("download stalled")
+
+// downloadSpaceMargin is the disk space kept free on top of the downloaded images
+const downloadSpaceMargin = 256 << 20
+
+// downloadImage downloads OVA from S3 to local temp file. The download is
+// aborted if it takes longer than the download timeout or receives no data
+// for the stall timeout, so a hanging connection can't block the reconcile.
+// An aborted download is kept and continued with a range request by the next
+// attempt, starting over if the server doesn't support ranges.
+// It fails early if the image exceeds the maximum image size or the download
+// directory can't hold the given number of copies of it.
+func (c *Client) downloadImage(ctx context.Context, imageURL string, copies int64) (string, error) {
 	log := log.FromContext(ctx)
 
-	// Ensure download directory exists
-	if err := os.MkdirAll(c.downloadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create download directory %s: %w", c.downloadDir, err)
+	// Ensure download directory exists and is writable
+	dir, err := download.Dir(ctx, c.downloadDir, c.downloadFallbackDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to prepare download directory: %w", err)
 	}
 
-	// Create temp file in the configured download directory
-	tmpFile, err := os.CreateTemp(c.downloadDir, "vcd-image-*.ova")
+	partial, err := download.OpenPartial(dir, "vcd-image-"+download.PartialName(imageURL))
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if c.downloadTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, c.downloadTimeout)
+		defer cancelTimeout()
 	}
-	defer func() { _ = tmpFile.Close() }()
 
 	// Download from URL
-	log.Info("Downloading image", "url", imageURL, "dest", tmpFile.Name())
+	log.Info("Downloading image", "url", imageURL, "dest", partial.Name(), "offset", partial.Offset)
 
-	resp, err := http.Get(imageURL) // #nosec G107 - URL is from trusted source (Release CR)
+	resp, total, err := c.requestImage(ctx, imageURL, partial)
 	if err != nil {
-		_ = os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("failed to download: %w", err)
+		_ = partial.Close()
+		return "", err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode != http.StatusOK {
-		_ = os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	if c.maxImageSize > 0 && total > c.maxImageSize {
+		_ = partial.Discard()
+		return "", fmt.Errorf("image %s is %d bytes, exceeding the maximum image size of %d bytes", imageURL, total, c.maxImageSize)
+	}
+	if err := c.checkDiskSpace(dir, total, copies, partial.Offset); err != nil {
+		_ = partial.Discard()
+		return "", err
+	}
+
+	body := &progressReader{reader: resp.Body}
+	if c.downloadStallTimeout > 0 {
+		stall := time.AfterFunc(c.downloadStallTimeout, func() { cancel(errDownloadStalled) })
+		defer stall.Stop()
+		body.onRead = func() { stall.Reset(c.downloadStallTimeout) }
 	}
 
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(downloadProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				log.Info("Downloading image", "url", imageURL, "bytes", partial.Offset+body.read.Load(), "total", total)
+			}
+		}
+	}()
+
 	// Copy to file
-	written, err := io.Copy(tmpFile, resp.Body)
+	written, err := io.Copy(partial, download.LimitReader(ctx, body, c.limiter))
+	if err != nil {
+		_ = partial.Close()
+		return "", c.downloadError(ctx, err)
+	}
+
+	// Move the download to a file of its own, so the image can be downloaded
+	// again for another location while this one is uploaded
+	dest, err := os.CreateTemp(dir, "vcd-image-*.ova")
+	if err != nil {
+		_ = partial.Close()
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	_ = dest.Close()
+	if err := partial.Complete(total, dest.Name()); err != nil {
+		_ = os.Remove(dest.Name())
+		return "", fmt.Errorf("failed to complete download: %w", err)
+	}
+
+	log.Info("Downloaded image", "bytes", written, "resumedAt", partial.Offset, "path", dest.Name())
+	return dest.Name(), nil
+}
+
+// requestImage requests the image from the end of the partial download. If
+// the server ignores the range or the partial download is beyond the end of
+// the image, the partial download is restarted. It returns the response and
+// the total size of the image, -1 if unknown.
+func (c *Client) requestImage(ctx context.Context, imageURL string, partial *download.Partial) (*http.Response, int64, error) {
+	log := log.FromContext(ctx)
+
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create download request: %w", err)
+		}
+		if partial.Offset > 0 {
+			req.Header.Set("Range", download.RangeHeader(partial.Offset))
+		}
+
+		resp, err := c.httpClient.Do(req) // #nosec G107 - URL is from trusted source (Release CR)
+		if err != nil {
+			return nil, 0, c.downloadError(ctx, err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && partial.Offset > 0:
+			start, total, err := download.ParseContentRange(resp.Header.Get("Content-Range"))
+			if err != nil || start != partial.Offset {
+				_ = resp.Body.Close()
+				if err == nil {
+					err = fmt.Errorf("requested range from %d, got range from %d", partial.Offset, start)
+				}
+				return nil, 0, fmt.Errorf("failed to resume download: %w", err)
+			}
+			return resp, total, nil
+		case resp.StatusCode == http.StatusOK:
+			if partial.Offset > 0 {
+				log.Info("Server doesn't support ranges - downloading the whole image", "url", imageURL)
+				if err := partial.Restart(); err != nil {
+					_ = resp.Body.Close()
+					return nil, 0, err
+				}
+			}
+			return resp, resp.ContentLength, nil
+		case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && partial.Offset > 0:
+			_ = resp.Body.Close()
+			log.Info("Partial download is beyond the end of the image - downloading the whole image", "url", imageURL, "offset", partial.Offset)
+			if err := partial.Restart(); err != nil {
+				return nil, 0, err
+			}
+		default:
+			_ = resp.Body.Close()
+			return nil, 0, fmt.Errorf("download failed with status: %d", resp.StatusCode)
+		}
+	}
+}
+
+// checkDiskSpace fails if the download directory has less free space than
+// needed for the given number of copies of an image of size bytes, of which
+// downloaded bytes are already on disk. Images of unknown size are not checked.
+func (c *Client) checkDiskSpace(dir string, size int64, copies int64, downloaded int64) error {
+	if c.freeSpace == nil || size <= 0 {
+		return nil
+	}
+
+	available, err := c.freeSpace(dir)
 	if err != nil {
-		_ = os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("failed to write file: %w", err)
+		return fmt.Errorf("failed to check free disk space in %s: %w", dir, err)
 	}
 
-	log.Info("Downloaded image", "bytes", written, "path", tmpFile.Name())
-	return tmpFile.Name(), nil
+	needed := uint64(size*copies-downloaded) + downloadSpaceMargin
+	if available < needed {
+		return fmt.Errorf("not enough disk space in %s to download image of %d bytes: %d bytes needed, %d bytes available",
+			dir, size, needed, available)
+	}
+	return nil
+}
+
+// freeDiskSpace returns the bytes available to unprivileged users on the
+// filesystem of dir
+func freeDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil // #nosec G115
+}
+
+// downloadError turns a failed download into an error telling timeouts and
+// stalls apart from other failures
+func (c *Client) downloadError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(context.Cause(ctx), errDownloadStalled):
+		return fmt.Errorf("failed to download: no data received for %s: %w", c.downloadStallTimeout, errDownloadStalled)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("failed to download: timed out after %s: %w", c.downloadTimeout, context.DeadlineExceeded)
+	default:
+		return fmt.Errorf("failed to download: %w", err)
+	}
+}
+
+// progressReader counts the bytes read and reports every read with data
+type progressReader struct {
+	reader io.Reader
+	read   atomic.Int64
+	onRead func()
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.read.Add(int64(n))
+		if p.onRead != nil {
+			p.onRead()
+		}
+	}
+	return n, err
 }
@@ -0,0 +1,741 @@
+package clouddirector
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	types "github.com/vmware/go-vcloud-director/v3/types/v56"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+const testOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <VirtualSystem ovf:id="flatcar">
+    <VirtualHardwareSection>
+      <System>
+        <vssd:VirtualSystemType>vmx-13</vssd:VirtualSystemType>
+      </System>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestOVFPatch(t *testing.T) {
+	testCases := []struct {
+		name        string
+		config      ImporterConfig
+		ovf         string
+		expectNil   bool
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:      "case 0: nothing configured",
+			config:    ImporterConfig{},
+			expectNil: true,
+		},
+		{
+			name:        "case 1: hardware version",
+			config:      ImporterConfig{HardwareVersion: 19},
+			ovf:         testOVF,
+			contains:    []string{"<vssd:VirtualSystemType>vmx-19</vssd:VirtualSystemType>"},
+			notContains: []string{"vmx-13", "GuestCustomizationSection"},
+		},
+		{
+			name:   "case 2: computer name adds a guest customization section",
+			config: ImporterConfig{ComputerName: "node"},
+			ovf:    testOVF,
+			contains: []string{
+				"<vssd:VirtualSystemType>vmx-13</vssd:VirtualSystemType>",
+				"<vcloud:ComputerName>node</vcloud:ComputerName></vcloud:GuestCustomizationSection></VirtualSystem>",
+			},
+		},
+		{
+			name:   "case 3: computer name replaces an existing one",
+			config: ImporterConfig{ComputerName: "node"},
+			ovf: `<VirtualSystem><vcloud:GuestCustomizationSection>` +
+				`<vcloud:ComputerName>flatcar</vcloud:ComputerName>` +
+				`</vcloud:GuestCustomizationSection></VirtualSystem>`,
+			contains:    []string{"<vcloud:ComputerName>node</vcloud:ComputerName>"},
+			notContains: []string{"flatcar", "xmlns:vcloud"},
+		},
+		{
+			name:   "case 4: hardware version and computer name",
+			config: ImporterConfig{HardwareVersion: 19, ComputerName: "node"},
+			ovf:    testOVF,
+			contains: []string{
+				"<vssd:VirtualSystemType>vmx-19</vssd:VirtualSystemType>",
+				"<vcloud:ComputerName>node</vcloud:ComputerName>",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patch := ovfPatch(tc.config)
+			if tc.expectNil {
+				assert.Nil(t, patch)
+				return
+			}
+			require.NotNil(t, patch)
+
+			patched := string(patch([]byte(tc.ovf)))
+			for _, s := range tc.contains {
+				assert.Contains(t, patched, s)
+			}
+			for _, s := range tc.notContains {
+				assert.NotContains(t, patched, s)
+			}
+		})
+	}
+}
+
+func TestCheckArtifact(t *testing.T) {
+	testCases := []struct {
+		name          string
+		imageURL      string
+		expectedError string
+	}{
+		{
+			name:     "case 0: OVA",
+			imageURL: "https://bucket.s3.eu-west-1.amazonaws.com/flatcar-stable-3975.2.0-kube-v1.30.4.ova",
+		},
+		{
+			name:     "case 1: OVA with query",
+			imageURL: "https://bucket.s3.eu-west-1.amazonaws.com/flatcar-stable-3975.2.0-kube-v1.30.4.OVA?X-Amz-Signature=abc",
+		},
+		{
+			name:          "case 2: exploded OVF",
+			imageURL:      "https://bucket.s3.eu-west-1.amazonaws.com/flatcar/image.ovf",
+			expectedError: "is an exploded OVF",
+		},
+		{
+			name:          "case 3: other type",
+			imageURL:      "https://bucket.s3.eu-west-1.amazonaws.com/flatcar.qcow2",
+			expectedError: `unsupported type ".qcow2"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkArtifact(tc.imageURL)
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRewriteOVA(t *testing.T) {
+	var in bytes.Buffer
+	tw := tar.NewWriter(&in)
+	for name, content := range map[string]string{"flatcar.ovf": testOVF, "flatcar.vmdk": "disk"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	var out bytes.Buffer
+	patched, err := rewriteOVA(&in, &out, ovfPatch(ImporterConfig{ComputerName: "node"}))
+	require.NoError(t, err)
+	assert.True(t, patched)
+
+	entries := map[string]string{}
+	tr := tar.NewReader(&out)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries[hdr.Name] = string(data)
+	}
+
+	assert.Contains(t, entries["flatcar.ovf"], "<vcloud:ComputerName>node</vcloud:ComputerName>")
+	assert.Equal(t, "disk", entries["flatcar.vmdk"])
+}
+
+// ovaChecksum is the SHA256 checksum of the OVA served by the tests, "ova"
+const ovaChecksum = "3e148e71852ccff006c97dcf0180b49766f272bd65677af47baca7d76dedeffc"
+
+// templateURN is the ID of the vApp template uploaded by the tests
+const templateURN = "urn:vcloud:vapptemplate:5b3c1d9e-4f2a-4c1b-9a7e-2d6f8e0c3b41"
+
+func TestPushImportMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ova"))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name             string
+		metadata         map[string]string
+		nodeImage        string
+		uploadErr        error
+		expectError      bool
+		expectedMetadata map[string]string
+		expectedID       string
+	}{
+		{
+			name: "case 0: metadata applied after a successful upload",
+			metadata: map[string]string{
+				"kubernetes-version": "1.30.4",
+				"owner-team":         "rocket",
+			},
+			expectedMetadata: map[string]string{
+				"kubernetes-version": "1.30.4",
+				"owner-team":         "rocket",
+				sourceChecksumKey:    ovaChecksum,
+			},
+			expectedID: templateURN,
+		},
+		{
+			name:             "case 1: no metadata configured",
+			metadata:         nil,
+			expectedMetadata: map[string]string{sourceChecksumKey: ovaChecksum},
+			expectedID:       templateURN,
+		},
+		{
+			name:        "case 2: no metadata after a failed upload",
+			metadata:    map[string]string{"owner-team": "rocket"},
+			uploadErr:   fmt.Errorf("upload failed"),
+			expectError: true,
+		},
+		{
+			name:      "case 3: NodeImage of the upload is recorded",
+			nodeImage: "vsphere-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			expectedMetadata: map[string]string{
+				sourceChecksumKey: ovaChecksum,
+				nodeImageKey:      "vsphere-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			},
+			expectedID: templateURN,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newTestClient(nil)
+			c.downloadDir = t.TempDir()
+
+			var uploaded string
+			var pieceSize int64
+			c.upload = func(ctx context.Context, config ImporterConfig, localPath string) error {
+				uploaded = config.Name
+				pieceSize = config.UploadPieceSize
+				return tc.uploadErr
+			}
+			var applied map[string]string
+			c.mergeMetadata = func(config ImporterConfig, metadata map[string]types.MetadataValue) error {
+				applied = map[string]string{}
+				for k, v := range metadata {
+					assert.Equal(t, types.MetadataStringValue, v.TypedValue.XsiType)
+					applied[k] = v.TypedValue.Value
+				}
+				return nil
+			}
+			c.templateID = func(config ImporterConfig) (string, error) {
+				return templateURN, nil
+			}
+			var reportedID string
+			ctx := provider.WithImageIDReport(context.TODO(), func(id string) { reportedID = id })
+			if tc.nodeImage != "" {
+				ctx = provider.WithProvenance(ctx, provider.Provenance{NodeImage: tc.nodeImage})
+			}
+
+			err := c.pushImport(ctx, ImporterConfig{
+				Name:            "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+				Path:            server.URL + "/image.ova",
+				Metadata:        tc.metadata,
+				UploadPieceSize: 64 << 20,
+			})
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs", uploaded)
+				assert.Equal(t, int64(64<<20), pieceSize)
+			}
+			assert.Equal(t, tc.expectedMetadata, applied)
+			assert.Equal(t, tc.expectedID, reportedID)
+		})
+	}
+}
+
+func TestPushImportOverwritePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ova"))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name            string
+		policy          string
+		existing        string
+		exists          bool
+		expectedUploads int
+		expectedDeletes int
+		expectedError   string
+	}{
+		{
+			name:            "case 0: no existing template",
+			policy:          overwritePolicyError,
+			expectedUploads: 1,
+		},
+		{
+			name:     "case 1: existing template with the same content is kept",
+			policy:   overwritePolicyReplace,
+			existing: ovaChecksum,
+			exists:   true,
+		},
+		{
+			name:          "case 2: error on different content",
+			policy:        overwritePolicyError,
+			existing:      "other",
+			exists:        true,
+			expectedError: "already exists with different content",
+		},
+		{
+			name:     "case 3: skip keeps different content",
+			policy:   overwritePolicySkip,
+			existing: "other",
+			exists:   true,
+		},
+		{
+			name:            "case 4: replace deletes and uploads again",
+			policy:          overwritePolicyReplace,
+			existing:        "other",
+			exists:          true,
+			expectedDeletes: 1,
+			expectedUploads: 1,
+		},
+		{
+			name:            "case 5: template without a checksum is replaced",
+			policy:          overwritePolicyReplace,
+			exists:          true,
+			expectedDeletes: 1,
+			expectedUploads: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := newTestClient(nil)
+			c.downloadDir = t.TempDir()
+			c.overwritePolicy = tc.policy
+
+			c.templateChecksum = func(config ImporterConfig) (string, bool, error) {
+				return tc.existing, tc.exists, nil
+			}
+			deletes := 0
+			c.deleteItem = func(config ImporterConfig) error {
+				deletes++
+				return nil
+			}
+			uploads := 0
+			c.upload = func(ctx context.Context, config ImporterConfig, localPath string) error {
+				uploads++
+				return nil
+			}
+			c.mergeMetadata = func(config ImporterConfig, metadata map[string]types.MetadataValue) error {
+				return nil
+			}
+
+			err := c.pushImport(context.TODO(), ImporterConfig{
+				Name: "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+				Path: server.URL + "/image.ova",
+			})
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedUploads, uploads)
+			assert.Equal(t, tc.expectedDeletes, deletes)
+		})
+	}
+}
+
+func TestCheckOverwritePolicy(t *testing.T) {
+	policy, err := checkOverwritePolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, overwritePolicyError, policy)
+
+	policy, err = checkOverwritePolicy(overwritePolicyReplace)
+	assert.NoError(t, err)
+	assert.Equal(t, overwritePolicyReplace, policy)
+
+	_, err = checkOverwritePolicy("overwrite")
+	assert.ErrorContains(t, err, "invalid overwrite policy")
+}
+
+func TestLocationMetadata(t *testing.T) {
+	location := &Location{Metadata: map[string]string{
+		"owner-team":         "rocket",
+		"kubernetes-version": "overridden",
+	}}
+
+	assert.Equal(t, map[string]string{
+		"os":                 "flatcar",
+		"os-version":         "3975.2.0",
+		"kubernetes-version": "overridden",
+		"os-tooling-version": "1.18.1",
+		"release-channel":    "stable",
+		"owner-team":         "rocket",
+	}, location.metadata("flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"))
+
+	assert.Equal(t, map[string]string{
+		"os":                 "ubuntu",
+		"os-version":         "2404",
+		"kubernetes-version": "1.30.4",
+		"os-tooling-version": "1.18.1",
+	}, (&Location{}).metadata("ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs"))
+
+	// names that can't be parsed only get the configured metadata
+	assert.Equal(t, map[string]string{"owner-team": "rocket"},
+		(&Location{Metadata: map[string]string{"owner-team": "rocket"}}).metadata("custom-image"))
+}
+
+func TestDownloadImage(t *testing.T) {
+	testCases := []struct {
+		name            string
+		handler         http.HandlerFunc
+		timeout         time.Duration
+		stallTimeout    time.Duration
+		expectedErr     error
+		expectedContent string
+	}{
+		{
+			name: "case 0: successful download",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("ova"))
+			},
+			timeout:         time.Minute,
+			stallTimeout:    time.Minute,
+			expectedContent: "ova",
+		},
+		{
+			name: "case 1: stalled download",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "1000")
+				_, _ = w.Write([]byte("partial"))
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			},
+			timeout:      time.Minute,
+			stallTimeout: 100 * time.Millisecond,
+			expectedErr:  errDownloadStalled,
+		},
+		{
+			name: "case 2: download exceeding the timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				for {
+					select {
+					case <-r.Context().Done():
+						return
+					case <-time.After(10 * time.Millisecond):
+						_, _ = w.Write([]byte("x"))
+						w.(http.Flusher).Flush()
+					}
+				}
+			},
+			timeout:      200 * time.Millisecond,
+			stallTimeout: time.Minute,
+			expectedErr:  context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.handler)
+			defer server.Close()
+
+			c, _ := newTestClient(nil)
+			c.downloadDir = t.TempDir()
+			c.downloadTimeout = tc.timeout
+			c.downloadStallTimeout = tc.stallTimeout
+
+			path, err := c.downloadImage(context.TODO(), server.URL+"/image.ova", 1)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+
+				// the partial download is kept to be resumed
+				entries, readErr := os.ReadDir(c.downloadDir)
+				require.NoError(t, readErr)
+				require.Len(t, entries, 1)
+				assert.Equal(t, "vcd-image-"+download.PartialName(server.URL+"/image.ova"), entries[0].Name())
+				return
+			}
+			require.NoError(t, err)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedContent, string(content))
+		})
+	}
+}
+
+func TestDownloadImageResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	testCases := []struct {
+		name           string
+		partial        []byte
+		ignoreRanges   bool
+		expectedRanges []string
+	}{
+		{
+			name:           "case 0: partial download is continued",
+			partial:        content[:400],
+			expectedRanges: []string{"bytes=400-"},
+		},
+		{
+			name:           "case 1: download without partial download requests everything",
+			expectedRanges: []string{""},
+		},
+		{
+			name:           "case 2: partial download is restarted if the server ignores ranges",
+			partial:        content[:400],
+			ignoreRanges:   true,
+			expectedRanges: []string{"bytes=400-"},
+		},
+		{
+			name:           "case 3: partial download beyond the end of the image is restarted",
+			partial:        append(append([]byte{}, content...), "garbage"...),
+			expectedRanges: []string{"bytes=1007-", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if tc.ignoreRanges {
+					_, _ = w.Write(content)
+					return
+				}
+				http.ServeContent(w, r, "image.ova", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			c, _ := newTestClient(nil)
+			c.downloadDir = t.TempDir()
+			imageURL := server.URL + "/image.ova"
+			if tc.partial != nil {
+				require.NoError(t, os.WriteFile(filepath.Join(c.downloadDir, "vcd-image-"+download.PartialName(imageURL)), tc.partial, 0600))
+			}
+
+			path, err := c.downloadImage(context.TODO(), imageURL, 1)
+			require.NoError(t, err)
+
+			downloaded, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, content, downloaded)
+			assert.Equal(t, tc.expectedRanges, ranges)
+
+			// only the completed download is left
+			entries, err := os.ReadDir(c.downloadDir)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, filepath.Base(path), entries[0].Name())
+		})
+	}
+}
+
+func TestDownloadImageMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ova"))
+	}))
+	defer server.Close()
+
+	c, _ := newTestClient(nil)
+	c.downloadDir = t.TempDir()
+
+	c.maxImageSize = 3
+	_, err := c.downloadImage(context.TODO(), server.URL+"/image.ova", 1)
+	require.NoError(t, err)
+
+	c.downloadDir = t.TempDir()
+	c.maxImageSize = 2
+	_, err = c.downloadImage(context.TODO(), server.URL+"/image.ova", 1)
+	assert.ErrorContains(t, err, "is 3 bytes, exceeding the maximum image size of 2 bytes")
+
+	// nothing is left behind
+	entries, err := os.ReadDir(c.downloadDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloadImageProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a forward proxy gets the absolute URL of the image
+		proxied = append(proxied, r.URL.String())
+		_, _ = w.Write([]byte("ova"))
+	}))
+	defer proxy.Close()
+
+	c, _ := newTestClient(nil)
+	c.downloadDir = t.TempDir()
+	c.httpClient = &http.Client{Transport: download.Proxy{URL: proxy.URL}.Transport()}
+
+	// the host only resolves through the proxy
+	path, err := c.downloadImage(context.TODO(), "http://images.example.invalid/image.ova", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://images.example.invalid/image.ova"}, proxied)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "ova", string(content))
+}
+
+func TestDownloadImageDiskSpace(t *testing.T) {
+	const size = 1 << 20
+
+	testCases := []struct {
+		name        string
+		available   uint64
+		freeErr     error
+		copies      int64
+		expectError string
+	}{
+		{
+			name:      "case 0: enough space",
+			available: size + downloadSpaceMargin,
+			copies:    1,
+		},
+		{
+			name:        "case 1: not enough space for the margin",
+			available:   size + downloadSpaceMargin - 1,
+			copies:      1,
+			expectError: "not enough disk space",
+		},
+		{
+			name:        "case 2: not enough space for the patched copy",
+			available:   size + downloadSpaceMargin,
+			copies:      2,
+			expectError: "not enough disk space",
+		},
+		{
+			name:        "case 3: free space can't be determined",
+			freeErr:     fmt.Errorf("statfs failed"),
+			copies:      1,
+			expectError: "failed to check free disk space",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", fmt.Sprint(size))
+				_, _ = w.Write(make([]byte, size))
+			}))
+			defer server.Close()
+
+			c, _ := newTestClient(nil)
+			c.downloadDir = t.TempDir()
+			c.freeSpace = func(dir string) (uint64, error) {
+				assert.Equal(t, c.downloadDir, dir)
+				return tc.available, tc.freeErr
+			}
+
+			path, err := c.downloadImage(context.TODO(), server.URL+"/image.ova", tc.copies)
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+
+				// nothing is left behind
+				entries, readErr := os.ReadDir(c.downloadDir)
+				require.NoError(t, readErr)
+				assert.Empty(t, entries)
+				return
+			}
+			require.NoError(t, err)
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, int64(size), info.Size())
+		})
+	}
+}
+
+func TestFreeDiskSpace(t *testing.T) {
+	available, err := freeDiskSpace(t.TempDir())
+	require.NoError(t, err)
+	assert.Greater(t, available, uint64(0))
+
+	_, err = freeDiskSpace(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestReportUploadProgress(t *testing.T) {
+	testCases := []struct {
+		name       string
+		percentage string
+		expected   [2]int64
+		reported   bool
+	}{
+		{
+			name:       "case 0: percentage is reported as bytes of the size",
+			percentage: "70.00",
+			expected:   [2]int64{4_200_000_000, 6_000_000_000},
+			reported:   true,
+		},
+		{
+			name:       "case 1: invalid percentage is not reported",
+			percentage: "unknown",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var reported [][2]int64
+			ctx := provider.WithProgress(context.TODO(), func(transferred int64, total int64) {
+				mu.Lock()
+				defer mu.Unlock()
+				reported = append(reported, [2]int64{transferred, total})
+			})
+
+			polls := make(chan struct{}, 10)
+			stop := reportUploadProgress(ctx, func() string {
+				select {
+				case polls <- struct{}{}:
+				default:
+				}
+				return tc.percentage
+			}, 6_000_000_000, time.Millisecond)
+
+			// wait for two polls, so the first one was reported
+			<-polls
+			<-polls
+			stop()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !tc.reported {
+				assert.Empty(t, reported)
+				return
+			}
+			require.NotEmpty(t, reported)
+			assert.Equal(t, tc.expected, reported[0])
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package clouddirector
+
+import (
+	"context"
+	"fmt"
+
+	types "github.com/vmware/go-vcloud-director/v3/types/v56"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// shareCatalog gives the organizations of the location read-only access to
+// the catalog, so their clusters can use the uploaded vApp templates. The
+// access the catalog grants already is kept, and nothing is written if all
+// organizations have access.
+func (c *Client) shareCatalog(ctx context.Context, catalog string) error {
+	log := log.FromContext(ctx)
+
+	access, err := c.catalogAccess(ctx, catalog)
+	if err != nil {
+		return fmt.Errorf("failed to get access control of catalog %s: %w", catalog, err)
+	}
+	if access.AccessSettings == nil {
+		access.AccessSettings = &types.AccessSettingList{}
+	}
+
+	shared := make(map[string]bool, len(access.AccessSettings.AccessSetting))
+	for _, setting := range access.AccessSettings.AccessSetting {
+		if setting.Subject != nil {
+			shared[setting.Subject.HREF] = true
+		}
+	}
+
+	var added []string
+	for _, org := range c.currentLocation().ShareWithOrgs {
+		href, err := c.orgHREF(org)
+		if err != nil {
+			return fmt.Errorf("failed to get organization %s to share catalog %s with: %w", org, catalog, err)
+		}
+		if shared[href] {
+			continue
+		}
+		access.AccessSettings.AccessSetting = append(access.AccessSettings.AccessSetting, &types.AccessSetting{
+			Subject:     &types.LocalSubject{HREF: href, Type: types.MimeOrg},
+			AccessLevel: types.ControlAccessReadOnly,
+		})
+		shared[href] = true
+		added = append(added, org)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	if err := c.setCatalogAccess(ctx, catalog, access); err != nil {
+		return fmt.Errorf("failed to share catalog %s: %w", catalog, err)
+	}
+	log.Info("Shared catalog with organizations", "catalog", catalog, "orgs", added)
+	return nil
+}
+
+// getCatalogAccess returns the access control settings of the catalog
+func (c *Client) getCatalogAccess(ctx context.Context, catalogName string) (*types.ControlAccessParams, error) {
+	catalog, err := c.getCatalogByName(ctx, catalogName)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.GetAccessControl(true)
+}
+
+// updateCatalogAccess replaces the access control settings of the catalog
+func (c *Client) updateCatalogAccess(ctx context.Context, catalogName string, access *types.ControlAccessParams) error {
+	catalog, err := c.getCatalogByName(ctx, catalogName)
+	if err != nil {
+		return err
+	}
+	return catalog.SetAccessControl(access, true)
+}
+
+// getOrgHREF returns the HREF of the organization with the given name
+func (c *Client) getOrgHREF(name string) (string, error) {
+	org, err := c.cloudDirector.GetOrgByName(name)
+	if err != nil {
+		return "", err
+	}
+	return org.Org.HREF, nil
+}
@@ -0,0 +1,90 @@
+package clouddirector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	types "github.com/vmware/go-vcloud-director/v3/types/v56"
+)
+
+// orgSetting grants the organization read-only access
+func orgSetting(org string) *types.AccessSetting {
+	return &types.AccessSetting{
+		Subject:     &types.LocalSubject{HREF: "https://vcd.example.com/api/org/" + org, Type: types.MimeOrg},
+		AccessLevel: types.ControlAccessReadOnly,
+	}
+}
+
+func TestProcessSharesCatalog(t *testing.T) {
+	testCases := []struct {
+		name             string
+		shareWithOrgs    []string
+		current          []*types.AccessSetting
+		orgErr           error
+		expectedSettings []*types.AccessSetting
+		expectError      bool
+	}{
+		{
+			name: "case 0: catalog is not shared without orgs",
+		},
+		{
+			name:             "case 1: catalog is shared after an upload",
+			shareWithOrgs:    []string{"org-a", "org-b"},
+			expectedSettings: []*types.AccessSetting{orgSetting("org-a"), orgSetting("org-b")},
+		},
+		{
+			name:          "case 2: sharing is skipped if already shared",
+			shareWithOrgs: []string{"org-a"},
+			current:       []*types.AccessSetting{orgSetting("org-a")},
+		},
+		{
+			name:             "case 3: existing access is kept",
+			shareWithOrgs:    []string{"org-a", "org-b"},
+			current:          []*types.AccessSetting{orgSetting("org-a"), orgSetting("org-c")},
+			expectedSettings: []*types.AccessSetting{orgSetting("org-a"), orgSetting("org-c"), orgSetting("org-b")},
+		},
+		{
+			name:          "case 4: unknown org fails",
+			shareWithOrgs: []string{"org-a"},
+			orgErr:        fmt.Errorf("org not found"),
+			expectError:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var written []*types.AccessSetting
+			c := &Client{
+				location: &Location{Name: "loc", Catalog: "catalog", ShareWithOrgs: tc.shareWithOrgs},
+				catalogAccess: func(ctx context.Context, catalog string) (*types.ControlAccessParams, error) {
+					assert.Equal(t, "catalog", catalog)
+					access := &types.ControlAccessParams{}
+					if tc.current != nil {
+						access.AccessSettings = &types.AccessSettingList{AccessSetting: tc.current}
+					}
+					return access, nil
+				},
+				setCatalogAccess: func(ctx context.Context, catalog string, access *types.ControlAccessParams) error {
+					assert.Equal(t, "catalog", catalog)
+					written = access.AccessSettings.AccessSetting
+					return nil
+				},
+				orgHREF: func(name string) (string, error) {
+					return "https://vcd.example.com/api/org/" + name, tc.orgErr
+				},
+			}
+
+			err := c.Process(context.TODO(), "image", "loc")
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, written)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSettings, written)
+		})
+	}
+}
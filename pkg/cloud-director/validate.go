@@ -0,0 +1,89 @@
+package clouddirector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/vmware/go-vcloud-director/v3/govcd"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// ValidateConfig checks the credentials and locations files of the
+// configuration, logs in to Cloud Director once and looks up the VDC and
+// catalogs of the location, without changing anything. Checks depending on
+// a failed one are skipped.
+func ValidateConfig(ctx context.Context, c Config) []provider.ConfigCheck {
+	var checks []provider.ConfigCheck
+	check := func(name string, err error) bool {
+		checks = append(checks, provider.ConfigCheck{Name: name, Err: err})
+		return err == nil
+	}
+
+	var u *url.URL
+	creds, err := loadCredentials(c.CredentialsFile)
+	if err == nil {
+		err = checkCredentials(creds)
+	}
+	if err == nil {
+		u, err = url.ParseRequestURI(creds.URL)
+	}
+	credsValid := check(fmt.Sprintf("credentials file %s", c.CredentialsFile), err)
+
+	location, err := loadLocation(c.LocationsFile)
+	locationValid := check(fmt.Sprintf("locations file %s", c.LocationsFile), err)
+
+	if !credsValid {
+		return checks
+	}
+	client := &Client{
+		cloudDirector:           govcd.NewVCDClient(*u, creds.Insecure),
+		url:                     creds.URL,
+		credentials:             creds,
+		backoff:                 wait.Backoff{Steps: 1},
+		sessionRefreshThreshold: defaultSessionRefreshThreshold,
+	}
+	client.login = func() error {
+		return client.cloudDirector.Authenticate(creds.Username, creds.Password, creds.Org)
+	}
+	if !check(fmt.Sprintf("login to Cloud Director %s as %s@%s", creds.URL, creds.Username, creds.Org), client.authenticate(ctx)) {
+		return checks
+	}
+	defer func() { _ = client.cloudDirector.Disconnect() }()
+
+	if !locationValid {
+		return checks
+	}
+	location.Org = creds.Org
+	client.location = location
+
+	org, err := client.getOrg(ctx)
+	if !check(fmt.Sprintf("organization %s", creds.Org), err) {
+		return checks
+	}
+	_, err = org.GetVDCByName(location.VDC, false)
+	check(fmt.Sprintf("VDC %s", location.VDC), err)
+	for _, catalog := range location.catalogNames() {
+		_, err := client.getCatalogByName(ctx, catalog)
+		check(fmt.Sprintf("catalog %s", catalog), err)
+	}
+	return checks
+}
+
+// checkCredentials fails for credentials missing the fields needed to log in
+func checkCredentials(creds *Credentials) error {
+	switch {
+	case creds.URL == "":
+		return errors.New("url is required")
+	case creds.Username == "":
+		return errors.New("username is required")
+	case creds.Password == "":
+		return errors.New("password is required")
+	case creds.Org == "":
+		return errors.New("org is required")
+	}
+	return nil
+}
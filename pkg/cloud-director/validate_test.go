@@ -0,0 +1,76 @@
+package clouddirector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	// an endpoint that is not Cloud Director
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	validLocation := "name: loc\nvdc: vdc\ncatalog: catalog\n"
+
+	testCases := []struct {
+		name           string
+		credentials    string
+		location       string
+		expectedChecks []string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "case 0: failed login skips the location",
+			credentials:    "url: " + server.URL + "/api\nusername: user\npassword: pass\norg: org\n",
+			location:       validLocation,
+			expectedChecks: []string{"credentials file", "locations file", "login to Cloud Director"},
+			expectedErrors: map[string]string{"login to Cloud Director": "404"},
+		},
+		{
+			name:           "case 1: incomplete credentials skip the login",
+			credentials:    "url: " + server.URL + "/api\nusername: user\npassword: pass\n",
+			location:       validLocation,
+			expectedChecks: []string{"credentials file", "locations file"},
+			expectedErrors: map[string]string{"credentials file": "org is required"},
+		},
+		{
+			name:           "case 2: invalid URL and location",
+			credentials:    "url: vcd.example.com\nusername: user\npassword: pass\norg: org\n",
+			location:       "name: loc\ncatalog: catalog\n",
+			expectedChecks: []string{"credentials file", "locations file"},
+			expectedErrors: map[string]string{
+				"credentials file": "invalid URI",
+				"locations file":   "location VDC is required",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			credentialsFile := filepath.Join(dir, "credentials")
+			require.NoError(t, os.WriteFile(credentialsFile, []byte(tc.credentials), 0600))
+			locationsFile := filepath.Join(dir, "locations")
+			require.NoError(t, os.WriteFile(locationsFile, []byte(tc.location), 0600))
+
+			checks := ValidateConfig(context.TODO(), Config{CredentialsFile: credentialsFile, LocationsFile: locationsFile})
+
+			require.Len(t, checks, len(tc.expectedChecks))
+			for i, prefix := range tc.expectedChecks {
+				assert.Contains(t, checks[i].Name, prefix)
+				if expectedErr, ok := tc.expectedErrors[prefix]; ok {
+					assert.ErrorContains(t, checks[i].Err, expectedErr, checks[i].Name)
+				} else {
+					assert.NoError(t, checks[i].Err, checks[i].Name)
+				}
+			}
+		})
+	}
+}
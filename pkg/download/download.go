@@ -0,0 +1,55 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// probePattern names the file written to check that a directory is writable
+const probePattern = ".write-probe-*"
+
+// CheckWritable creates dir if missing and writes and removes a probe file in
+// it, so a read-only volume or missing permissions are reported up front
+// instead of deep inside an import.
+func CheckWritable(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("download directory is not set")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("download directory %s can't be created: %w", dir, err)
+	}
+
+	probe, err := os.CreateTemp(dir, probePattern)
+	if err != nil {
+		return fmt.Errorf("download directory %s is not writable: %w", dir, err)
+	}
+	closeErr := probe.Close()
+	if err := os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("failed to remove probe file from download directory %s: %w", dir, err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("download directory %s is not writable: %w", dir, closeErr)
+	}
+	return nil
+}
+
+// Dir returns dir if it is writable, and otherwise fallback if one is set and
+// writable. The error names both directories if neither can be used.
+func Dir(ctx context.Context, dir string, fallback string) (string, error) {
+	err := CheckWritable(dir)
+	if err == nil {
+		return dir, nil
+	}
+	if fallback == "" || fallback == dir {
+		return "", err
+	}
+
+	if fallbackErr := CheckWritable(fallback); fallbackErr != nil {
+		return "", fmt.Errorf("%w\nfallback %w", err, fallbackErr)
+	}
+	log.FromContext(ctx).Info("Download directory not writable - using fallback directory", "directory", dir, "fallback", fallback, "reason", err.Error())
+	return fallback, nil
+}
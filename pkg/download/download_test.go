@@ -0,0 +1,96 @@
+package download
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unwritableDir returns a directory that can't be created, even as root,
+// because its parent is a regular file
+func unwritableDir(t *testing.T) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0600))
+	return filepath.Join(file, "images")
+}
+
+func TestCheckWritable(t *testing.T) {
+	testCases := []struct {
+		name          string
+		dir           func(t *testing.T) string
+		expectedError string
+	}{
+		{
+			name: "case 0: existing directory",
+			dir:  func(t *testing.T) string { return t.TempDir() },
+		},
+		{
+			name: "case 1: missing directory is created",
+			dir:  func(t *testing.T) string { return filepath.Join(t.TempDir(), "nested", "images") },
+		},
+		{
+			name:          "case 2: directory can't be created",
+			dir:           unwritableDir,
+			expectedError: "can't be created",
+		},
+		{
+			name:          "case 3: no directory",
+			dir:           func(t *testing.T) string { return "" },
+			expectedError: "not set",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := tc.dir(t)
+
+			err := CheckWritable(dir)
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			// the probe file is removed again
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestCheckWritableReadOnly(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0500))
+	t.Cleanup(func() { _ = os.Chmod(dir, 0700) })
+
+	assert.ErrorContains(t, CheckWritable(dir), "is not writable")
+}
+
+func TestDir(t *testing.T) {
+	writable := t.TempDir()
+	fallback := t.TempDir()
+
+	dir, err := Dir(context.TODO(), writable, fallback)
+	require.NoError(t, err)
+	assert.Equal(t, writable, dir)
+
+	dir, err = Dir(context.TODO(), unwritableDir(t), fallback)
+	require.NoError(t, err)
+	assert.Equal(t, fallback, dir)
+
+	_, err = Dir(context.TODO(), unwritableDir(t), "")
+	assert.Error(t, err)
+
+	_, err = Dir(context.TODO(), unwritableDir(t), unwritableDir(t))
+	assert.ErrorContains(t, err, "fallback")
+}
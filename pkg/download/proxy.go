@@ -0,0 +1,66 @@
+package download
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Proxy configures the HTTP proxy the operator downloads images through. The
+// zero value uses the proxy of the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+// environment variables, like the default transport.
+type Proxy struct {
+	// URL of the proxy for both http and https URLs, e.g.
+	// http://proxy.example.com:3128. Overrides HTTPS_PROXY and HTTP_PROXY.
+	URL string
+	// NoProxy lists the hosts reached without the proxy, in addition to those
+	// of NO_PROXY: host names, domain suffixes like .example.com, IP
+	// addresses and CIDRs, each optionally with a port
+	NoProxy []string
+}
+
+// Validate fails if the proxy URL is not an absolute http, https or socks5 URL
+func (p Proxy) Validate() error {
+	if p.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if !slices.Contains([]string{"http", "https", "socks5"}, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %s: expected an http, https or socks5 URL with a host", u.Redacted())
+	}
+	return nil
+}
+
+// ProxyFunc returns the function choosing the proxy of a request, for
+// http.Transport.Proxy. Loopback addresses are never proxied.
+func (p Proxy) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	if p.URL != "" {
+		cfg.HTTPProxy = p.URL
+		cfg.HTTPSProxy = p.URL
+	}
+	if len(p.NoProxy) > 0 {
+		noProxy := slices.DeleteFunc([]string{cfg.NoProxy, strings.Join(p.NoProxy, ",")}, func(s string) bool { return s == "" })
+		cfg.NoProxy = strings.Join(noProxy, ",")
+	}
+
+	proxyFunc := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// Transport returns a copy of the default transport sending requests through
+// the proxy
+func (p Proxy) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = p.ProxyFunc()
+	return transport
+}
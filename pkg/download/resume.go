@@ -0,0 +1,176 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// partialPrefix names the files of downloads that did not complete yet. They
+// keep the extension of the image, so stale partial downloads are removed
+// with the other stale images.
+const partialPrefix = "partial-"
+
+// inUse holds the paths of the partial downloads currently written to
+var inUse sync.Map
+
+// PartialName returns the file name of the partial download of the image at
+// key, e.g. its S3 key or URL. The name is the same for every attempt, so a
+// later attempt finds the partial download of an earlier one.
+func PartialName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return partialPrefix + hex.EncodeToString(sum[:8]) + filepath.Ext(key)
+}
+
+// Partial is a download written to a file that is kept if the download
+// fails, so the next attempt can continue where it stopped. The content is
+// assumed not to change between attempts, which holds for images as their
+// names are versioned; the size is checked on completion.
+type Partial struct {
+	*os.File
+	// Offset is the number of bytes written by earlier attempts, the
+	// download continues from it
+	Offset int64
+	// resumable is false for a download into a unique temporary file,
+	// which is removed on failure
+	resumable bool
+	path      string
+}
+
+// OpenPartial opens the partial download name in dir, creating it if it
+// doesn't exist yet. If another download in this process is writing to it,
+// e.g. the same image for another location, a new temporary file is used
+// instead which is not resumed.
+func OpenPartial(dir string, name string) (*Partial, error) {
+	path := filepath.Join(dir, name)
+	if _, loaded := inUse.LoadOrStore(path, struct{}{}); loaded {
+		file, err := os.CreateTemp(dir, "*-"+name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		return &Partial{File: file, path: file.Name()}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600) //nolint:gosec
+	if err != nil {
+		inUse.Delete(path)
+		return nil, fmt.Errorf("failed to open partial download %s: %w", path, err)
+	}
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		_ = file.Close()
+		inUse.Delete(path)
+		return nil, fmt.Errorf("failed to seek to the end of partial download %s: %w", path, err)
+	}
+	return &Partial{File: file, Offset: offset, resumable: true, path: path}, nil
+}
+
+// Restart discards the content written by earlier attempts, e.g. because the
+// source ignored the requested range
+func (p *Partial) Restart() error {
+	if err := p.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate partial download %s: %w", p.path, err)
+	}
+	if _, err := p.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to the start of partial download %s: %w", p.path, err)
+	}
+	p.Offset = 0
+	return nil
+}
+
+// Complete checks the download holds total bytes, unless total is negative
+// because the size is unknown, and moves it to dest. A download of the wrong
+// size is removed, so the next attempt starts over.
+func (p *Partial) Complete(total int64, dest string) error {
+	defer p.release()
+
+	info, err := p.Stat()
+	if err != nil {
+		_ = p.File.Close()
+		return fmt.Errorf("failed to stat partial download %s: %w", p.path, err)
+	}
+	if total >= 0 && info.Size() != total {
+		_ = p.File.Close()
+		_ = os.Remove(p.path)
+		return fmt.Errorf("downloaded %d bytes, expected %d", info.Size(), total)
+	}
+	if err := p.File.Close(); err != nil {
+		return fmt.Errorf("failed to close partial download %s: %w", p.path, err)
+	}
+	if err := os.Rename(p.path, dest); err != nil {
+		return fmt.Errorf("failed to move partial download %s to %s: %w", p.path, dest, err)
+	}
+	return nil
+}
+
+// Close closes the download after a failure, keeping it for the next
+// attempt if it is resumable
+func (p *Partial) Close() error {
+	defer p.release()
+
+	err := p.File.Close()
+	if !p.resumable {
+		if removeErr := os.Remove(p.path); removeErr != nil && err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
+
+// Discard closes and removes the download, e.g. if there is not enough disk
+// space to complete it
+func (p *Partial) Discard() error {
+	defer p.release()
+
+	_ = p.File.Close()
+	return os.Remove(p.path)
+}
+
+func (p *Partial) release() {
+	if p.resumable {
+		inUse.Delete(p.path)
+	}
+}
+
+// RangeHeader returns the value of the Range header requesting the content
+// from offset to its end
+func RangeHeader(offset int64) string {
+	return fmt.Sprintf("bytes=%d-", offset)
+}
+
+// ParseContentRange returns the first byte and the total size of the content
+// from a Content-Range header like "bytes 100-199/200". The total size is -1
+// if the source doesn't know it.
+func ParseContentRange(header string) (int64, int64, error) {
+	rangeSpec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, fmt.Errorf("unsupported content range %q", header)
+	}
+	byteRange, size, found := strings.Cut(rangeSpec, "/")
+	if !found {
+		return 0, 0, fmt.Errorf("content range %q has no size", header)
+	}
+	first, _, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("content range %q has no byte range", header)
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start of content range %q: %w", header, err)
+	}
+	if size == "*" {
+		return start, -1, nil
+	}
+	total, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid size of content range %q: %w", header, err)
+	}
+	return start, total, nil
+}
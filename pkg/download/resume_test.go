@@ -0,0 +1,110 @@
+package download
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenPartial(t *testing.T) {
+	dir := t.TempDir()
+	name := PartialName("capv/image/image.ova")
+	assert.Equal(t, ".ova", filepath.Ext(name))
+
+	// a new download starts at the beginning
+	partial, err := OpenPartial(dir, name)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), partial.Offset)
+	_, err = partial.WriteString("part")
+	require.NoError(t, err)
+
+	// a concurrent download of the same image gets a file of its own, which
+	// is removed on failure
+	concurrent, err := OpenPartial(dir, name)
+	require.NoError(t, err)
+	assert.NotEqual(t, partial.Name(), concurrent.Name())
+	require.NoError(t, concurrent.Close())
+	assert.NoFileExists(t, concurrent.Name())
+
+	// a failed download is kept and continued
+	require.NoError(t, partial.Close())
+	partial, err = OpenPartial(dir, name)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), partial.Offset)
+	_, err = partial.WriteString("ial")
+	require.NoError(t, err)
+
+	// a download of the wrong size is removed
+	require.ErrorContains(t, partial.Complete(10, filepath.Join(dir, "image.ova")), "downloaded 7 bytes, expected 10")
+	assert.NoFileExists(t, filepath.Join(dir, name))
+
+	// a restarted download discards earlier attempts
+	partial, err = OpenPartial(dir, name)
+	require.NoError(t, err)
+	_, err = partial.WriteString("garbage")
+	require.NoError(t, err)
+	require.NoError(t, partial.Restart())
+	assert.Equal(t, int64(0), partial.Offset)
+	_, err = partial.WriteString("image")
+	require.NoError(t, err)
+	require.NoError(t, partial.Complete(5, filepath.Join(dir, "image.ova")))
+
+	content, err := os.ReadFile(filepath.Join(dir, "image.ova"))
+	require.NoError(t, err)
+	assert.Equal(t, "image", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, name))
+}
+
+func TestParseContentRange(t *testing.T) {
+	testCases := []struct {
+		name          string
+		header        string
+		expectedStart int64
+		expectedTotal int64
+		expectedError bool
+	}{
+		{
+			name:          "case 0: range with size",
+			header:        "bytes 100-199/200",
+			expectedStart: 100,
+			expectedTotal: 200,
+		},
+		{
+			name:          "case 1: range with unknown size",
+			header:        "bytes 100-199/*",
+			expectedStart: 100,
+			expectedTotal: -1,
+		},
+		{
+			name:          "case 2: unsupported unit",
+			header:        "items 100-199/200",
+			expectedError: true,
+		},
+		{
+			name:          "case 3: missing size",
+			header:        "bytes 100-199",
+			expectedError: true,
+		},
+		{
+			name:          "case 4: invalid start",
+			header:        "bytes x-199/200",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, total, err := ParseContentRange(tc.header)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStart, start)
+			assert.Equal(t, tc.expectedTotal, total)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package download
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// NewLimiter returns a limiter allowing bytesPerSecond bytes per second, nil
+// for an unlimited rate if bytesPerSecond is not positive. A single limiter
+// shared by all transfers bounds the bandwidth they use together.
+func NewLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+}
+
+// limitedReader waits for the limiter before passing on what it read
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+// LimitReader returns a reader that reads from r no faster than the limiter
+// allows, r itself if the limiter is nil. Waiting for the limiter is aborted
+// with the context.
+func LimitReader(ctx context.Context, r io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, reader: r, limiter: limiter}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// the limiter can't grant more than its burst at once
+	if burst := l.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := l.reader.Read(p)
+	if n > 0 {
+		if waitErr := l.limiter.WaitN(l.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// limitedBody is a request body read no faster than the limiter allows
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// limitedTransport sends request bodies no faster than the limiter allows
+type limitedTransport struct {
+	transport http.RoundTripper
+	limiter   *rate.Limiter
+}
+
+// LimitTransport returns a transport that sends the bodies of requests, e.g.
+// uploads, no faster than the limiter allows, transport itself if the
+// limiter is nil
+func LimitTransport(transport http.RoundTripper, limiter *rate.Limiter) http.RoundTripper {
+	if limiter == nil {
+		return transport
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &limitedTransport{transport: transport, limiter: limiter}
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return t.transport.RoundTrip(req)
+	}
+	limited := req.Clone(req.Context())
+	limited.Body = limitedBody{Reader: LimitReader(req.Context(), req.Body, t.limiter), Closer: req.Body}
+	return t.transport.RoundTrip(limited)
+}
@@ -0,0 +1,83 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitReader(t *testing.T) {
+	testCases := []struct {
+		name           string
+		bytesPerSecond int64
+		minDuration    time.Duration
+	}{
+		{
+			name: "case 0: no limit",
+		},
+		{
+			// the burst of one second is copied right away, the rest at the limit
+			name:           "case 1: copy is throttled to the limit",
+			bytesPerSecond: 20000,
+			minDuration:    500 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := bytes.Repeat([]byte("0123456789"), 3000)
+
+			var copied bytes.Buffer
+			start := time.Now()
+			n, err := io.Copy(&copied, LimitReader(context.TODO(), bytes.NewReader(payload), NewLimiter(tc.bytesPerSecond)))
+			elapsed := time.Since(start)
+
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(payload)), n)
+			assert.Equal(t, payload, copied.Bytes())
+			assert.GreaterOrEqual(t, elapsed, tc.minDuration)
+		})
+	}
+}
+
+func TestLimitReaderCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	// the burst is used up by the first read, the second one has to wait
+	reader := LimitReader(ctx, strings.NewReader(strings.Repeat("x", 200)), NewLimiter(100))
+	_, err := io.Copy(io.Discard, reader)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLimitTransport(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: LimitTransport(nil, NewLimiter(20000))}
+	payload := bytes.Repeat([]byte("0123456789"), 3000)
+
+	start := time.Now()
+	resp, err := client.Post(server.URL, "application/octet-stream", bytes.NewReader(payload))
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, len(payload), received)
+	assert.GreaterOrEqual(t, elapsed, 500*time.Millisecond)
+
+	// without a limit the transport is used as is
+	assert.Equal(t, http.DefaultTransport, LimitTransport(http.DefaultTransport, NewLimiter(0)))
+}
@@ -0,0 +1,268 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+	objectstore "github.com/giantswarm/image-distribution-operator/pkg/storage"
+)
+
+// Client reads node images from a Google Cloud Storage bucket
+type Client struct {
+	bucket     bucket
+	bucketName string
+	timeout    time.Duration
+	directory  string
+	// fallbackDirectory is used if directory is not writable
+	fallbackDirectory string
+	// allowedHostPatterns are the hosts images may be imported from besides GCS
+	allowedHostPatterns []string
+	// limiter bounds the bandwidth of pulls, unlimited if nil
+	limiter *rate.Limiter
+}
+
+type Config struct {
+	BucketName string
+	// CredentialsFile is the service account key the bucket is accessed
+	// with. Application Default Credentials are used if empty, e.g. of a
+	// workload identity.
+	CredentialsFile string
+	Timeout         time.Duration
+	// Directory is where pulled images are stored, defaults to Directory
+	Directory string
+	// FallbackDirectory is used if Directory is not writable, e.g. a read-only volume
+	FallbackDirectory string
+	// AllowedHostPatterns are shell patterns of the hosts, e.g.
+	// "*.cdn.example.com", images are imported from besides GCS. Only GCS
+	// URLs are valid if empty.
+	AllowedHostPatterns []string
+	// Proxy is the proxy GCS is reached through
+	Proxy download.Proxy
+	// Limiter bounds the bandwidth of pulls, shared with other transfers.
+	// Pulls are unlimited if nil.
+	Limiter *rate.Limiter
+}
+
+const (
+	Directory = "/tmp/images"
+)
+
+// host serves the objects of all buckets, path style
+const host = "storage.googleapis.com"
+
+// bucket is the part of the GCS API the client uses, so tests can replace it
+// with a fake
+type bucket interface {
+	// attrs returns the attributes of the object, storage.ErrObjectNotExist
+	// if there is none
+	attrs(ctx context.Context, key string) (*storage.ObjectAttrs, error)
+	// newRangeReader reads the object from offset to its end and returns the
+	// size of the whole object
+	newRangeReader(ctx context.Context, key string, offset int64) (io.ReadCloser, int64, error)
+	// signedURL returns a URL of the object signed with the credentials
+	signedURL(key string, opts *storage.SignedURLOptions) (string, error)
+}
+
+// bucketHandle implements bucket with the GCS client library
+type bucketHandle struct {
+	*storage.BucketHandle
+}
+
+func (b bucketHandle) attrs(ctx context.Context, key string) (*storage.ObjectAttrs, error) {
+	return b.Object(key).Attrs(ctx)
+}
+
+func (b bucketHandle) newRangeReader(ctx context.Context, key string, offset int64) (io.ReadCloser, int64, error) {
+	r, err := b.Object(key).NewRangeReader(ctx, offset, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, r.Attrs.Size, nil
+}
+
+func (b bucketHandle) signedURL(key string, opts *storage.SignedURLOptions) (string, error) {
+	return b.SignedURL(key, opts)
+}
+
+// New initializes a new GCS client
+func New(c Config, ctx context.Context) (*Client, error) {
+	if c.BucketName == "" {
+		return nil, errors.New("GCS bucket name is required")
+	}
+	if err := objectstore.CheckHostPatterns(c.AllowedHostPatterns); err != nil {
+		return nil, err
+	}
+
+	opts := []option.ClientOption{option.WithScopes(storage.ScopeReadOnly)}
+	if c.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(c.CredentialsFile))
+	}
+	// the authenticating transport wraps the one of the proxy
+	transport, err := htransport.NewTransport(ctx, c.Proxy.Transport(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GCS credentials: %w", err)
+	}
+	opts = append(opts, option.WithHTTPClient(&http.Client{Transport: transport}))
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	directory := c.Directory
+	if directory == "" {
+		directory = Directory
+	}
+
+	return &Client{
+		bucket:              bucketHandle{client.Bucket(c.BucketName)},
+		bucketName:          c.BucketName,
+		timeout:             c.Timeout,
+		directory:           directory,
+		fallbackDirectory:   c.FallbackDirectory,
+		allowedHostPatterns: c.AllowedHostPatterns,
+		limiter:             c.Limiter,
+	}, nil
+}
+
+var _ objectstore.ObjectStore = &Client{}
+
+// GetURL returns the URL of an image in the bucket
+func (c *Client) GetURL(imageKey string) string {
+	return fmt.Sprintf("https://%s/%s/%s", host, c.bucketName, objectstore.EscapeKey(imageKey))
+}
+
+// gcsURLPattern matches the URLs of objects in GCS buckets
+var gcsURLPattern = regexp.MustCompile(`^https://` + regexp.QuoteMeta(host) + `/[a-z0-9][a-z0-9._-]*/.+`)
+
+// ValidURL returns an error if the URL is neither in a GCS bucket nor on an
+// allowed host
+func (c *Client) ValidURL(url string) error {
+	if url == "" {
+		return fmt.Errorf("URL is empty")
+	}
+
+	if gcsURLPattern.MatchString(url) || objectstore.AllowedURL(url, "https", c.allowedHostPatterns) {
+		return nil
+	}
+	if len(c.allowedHostPatterns) == 0 {
+		return fmt.Errorf("URL is not a GCS bucket")
+	}
+	return fmt.Errorf("URL is neither a GCS bucket nor on a host matching %s", strings.Join(c.allowedHostPatterns, ", "))
+}
+
+// Exists checks with an authenticated request whether the image is in the
+// bucket, which the public URL can't tell for private buckets
+func (c *Client) Exists(ctx context.Context, imageKey string) (bool, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	_, err := c.bucket.attrs(ctx, imageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check image %s in GCS bucket %s: %w", imageKey, c.bucketName, err)
+	}
+	return true, nil
+}
+
+// Presign returns a URL of the image signed with the client's credentials,
+// which can be downloaded from without credentials until it expires
+func (c *Client) Presign(ctx context.Context, imageKey string, expires time.Duration) (string, error) {
+	signed, err := c.bucket.signedURL(imageKey, &storage.SignedURLOptions{
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(expires),
+		Scheme:  storage.SigningSchemeV4,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to presign image %s in GCS bucket %s: %w", imageKey, c.bucketName, err)
+	}
+	return signed, nil
+}
+
+// Pull fetches an image from GCS and stores it locally. A pull that failed
+// midway is continued with a ranged request by the next one.
+func (c *Client) Pull(ctx context.Context, imageKey string) (string, error) {
+	log := log.FromContext(ctx)
+
+	log.Info("Starting to pull image from GCS", "imageKey", imageKey, "bucketName", c.bucketName)
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	directory, err := download.Dir(ctx, c.directory, c.fallbackDirectory)
+	if err != nil {
+		return "", fmt.Errorf("failed to ensure local directory %s.\n%w", c.directory, err)
+	}
+	localFilePath := filepath.Join(directory, filepath.Base(imageKey))
+
+	partial, err := download.OpenPartial(directory, download.PartialName(imageKey))
+	if err != nil {
+		return "", err
+	}
+
+	body, total, err := c.readObject(ctx, imageKey, partial)
+	if err != nil {
+		_ = partial.Close()
+		return "", fmt.Errorf("failed to pull image %s from GCS bucket %s.\n%w", imageKey, c.bucketName, err)
+	}
+	defer func() {
+		if err := body.Close(); err != nil {
+			log.Error(err, "failed to close GCS reader")
+		}
+	}()
+
+	// keep the partial file on failure so the next pull continues it
+	if _, err := io.Copy(partial, download.LimitReader(ctx, body, c.limiter)); err != nil {
+		if closeErr := partial.Close(); closeErr != nil {
+			log.Error(closeErr, "failed to close partial local file", "localFilePath", partial.Name())
+		}
+		return "", fmt.Errorf("failed to write GCS object to file %s.\n%w", partial.Name(), err)
+	}
+	if err := partial.Complete(total, localFilePath); err != nil {
+		return "", fmt.Errorf("failed to write GCS object to file %s.\n%w", localFilePath, err)
+	}
+
+	log.Info("Completed download of image from GCS", "imageKey", imageKey, "localFilePath", localFilePath, "resumedAt", partial.Offset)
+	return localFilePath, nil
+}
+
+// readObject reads the image from the end of the partial download,
+// restarting it if the partial download is beyond the end of the image. It
+// returns the reader and the total size of the image.
+func (c *Client) readObject(ctx context.Context, imageKey string, partial *download.Partial) (io.ReadCloser, int64, error) {
+	body, total, err := c.bucket.newRangeReader(ctx, imageKey, partial.Offset)
+	var apiErr *googleapi.Error
+	if err != nil && partial.Offset > 0 && errors.As(err, &apiErr) && apiErr.Code == http.StatusRequestedRangeNotSatisfiable {
+		if err := partial.Restart(); err != nil {
+			return nil, 0, err
+		}
+		body, total, err = c.bucket.newRangeReader(ctx, imageKey, 0)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, total, nil
+}
@@ -0,0 +1,244 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+)
+
+// fakeBucket is an in-memory bucket recording the offsets objects are read from
+type fakeBucket struct {
+	objects map[string][]byte
+	err     error
+	offsets []int64
+	signed  *storage.SignedURLOptions
+}
+
+func (f *fakeBucket) attrs(ctx context.Context, key string) (*storage.ObjectAttrs, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	content, ok := f.objects[key]
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	return &storage.ObjectAttrs{Name: key, Size: int64(len(content))}, nil
+}
+
+func (f *fakeBucket) newRangeReader(ctx context.Context, key string, offset int64) (io.ReadCloser, int64, error) {
+	f.offsets = append(f.offsets, offset)
+	content, ok := f.objects[key]
+	if !ok {
+		return nil, 0, storage.ErrObjectNotExist
+	}
+	if offset > 0 && offset >= int64(len(content)) {
+		return nil, 0, &googleapi.Error{Code: http.StatusRequestedRangeNotSatisfiable}
+	}
+	return io.NopCloser(bytes.NewReader(content[offset:])), int64(len(content)), nil
+}
+
+func (f *fakeBucket) signedURL(key string, opts *storage.SignedURLOptions) (string, error) {
+	f.signed = opts
+	return "https://storage.googleapis.com/images/" + key + "?X-Goog-Signature=signature", nil
+}
+
+func TestExists(t *testing.T) {
+	testCases := []struct {
+		name          string
+		key           string
+		err           error
+		expected      bool
+		expectedError bool
+	}{
+		{
+			name:     "case 0: object exists",
+			key:      "capv/image/image.ova",
+			expected: true,
+		},
+		{
+			name: "case 1: object is missing",
+			key:  "capv/missing/missing.ova",
+		},
+		{
+			name:          "case 2: access denied is an error",
+			key:           "capv/image/image.ova",
+			err:           &googleapi.Error{Code: http.StatusForbidden},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{
+				bucket:     &fakeBucket{objects: map[string][]byte{"capv/image/image.ova": []byte("ova")}, err: tc.err},
+				bucketName: "images",
+				timeout:    time.Minute,
+			}
+
+			exists, err := c.Exists(context.TODO(), tc.key)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, exists)
+		})
+	}
+}
+
+func TestPresign(t *testing.T) {
+	bucket := &fakeBucket{}
+	c := &Client{bucket: bucket, bucketName: "images"}
+
+	signed, err := c.Presign(context.TODO(), "capv/image/image.ova", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "https://storage.googleapis.com/images/capv/image/image.ova?X-Goog-Signature=signature", signed)
+	assert.Equal(t, http.MethodGet, bucket.signed.Method)
+	assert.Equal(t, storage.SigningSchemeV4, bucket.signed.Scheme)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), bucket.signed.Expires, time.Minute)
+
+	c.bucket = &errorSigner{fakeBucket: bucket}
+	_, err = c.Presign(context.TODO(), "capv/image/image.ova", time.Hour)
+	assert.ErrorContains(t, err, "failed to presign image capv/image/image.ova in GCS bucket images")
+}
+
+// errorSigner is a fakeBucket without credentials to sign URLs with
+type errorSigner struct {
+	*fakeBucket
+}
+
+func (e *errorSigner) signedURL(key string, opts *storage.SignedURLOptions) (string, error) {
+	return "", errors.New("no private key")
+}
+
+func TestValidURL(t *testing.T) {
+	testCases := []struct {
+		name                string
+		allowedHostPatterns []string
+		url                 string
+		expectedError       string
+	}{
+		{
+			name: "case 0: GCS URL",
+			url:  "https://storage.googleapis.com/images/capv/image.ova",
+		},
+		{
+			name:          "case 1: S3 URL",
+			url:           "https://images.s3.eu-west-1.amazonaws.com/capv/image.ova",
+			expectedError: "URL is not a GCS bucket",
+		},
+		{
+			name:                "case 2: URL on an allowed host",
+			allowedHostPatterns: []string{"*.cdn.example.com"},
+			url:                 "https://eu.cdn.example.com/capv/image.ova",
+		},
+		{
+			name:                "case 3: URL on another host",
+			allowedHostPatterns: []string{"*.cdn.example.com"},
+			url:                 "https://cdn.example.org/capv/image.ova",
+			expectedError:       "URL is neither a GCS bucket nor on a host matching *.cdn.example.com",
+		},
+		{
+			name:          "case 4: empty URL",
+			expectedError: "URL is empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{bucketName: "images", allowedHostPatterns: tc.allowedHostPatterns}
+
+			err := c.ValidURL(tc.url)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+
+	// the URLs of the store are valid
+	c := &Client{bucketName: "images"}
+	assert.Equal(t, "https://storage.googleapis.com/images/capv/image.ova", c.GetURL("capv/image.ova"))
+	assert.NoError(t, c.ValidURL(c.GetURL("capv/image.ova")))
+	// special characters are escaped within their path segment
+	assert.Equal(t, "https://storage.googleapis.com/images/capv/image%20%231%3F/image%25.ova", c.GetURL("capv/image #1?/image%.ova"))
+}
+
+func TestPullResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	testCases := []struct {
+		name            string
+		partial         []byte
+		expectedOffsets []int64
+	}{
+		{
+			name:            "case 0: image is pulled",
+			expectedOffsets: []int64{0},
+		},
+		{
+			name:            "case 1: partial pull is continued",
+			partial:         content[:400],
+			expectedOffsets: []int64{400},
+		},
+		{
+			name:            "case 2: partial pull beyond the end of the image is restarted",
+			partial:         append(append([]byte{}, content...), "garbage"...),
+			expectedOffsets: []int64{1007, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bucket := &fakeBucket{objects: map[string][]byte{"capv/image/image.ova": content}}
+			c := &Client{
+				bucket:     bucket,
+				bucketName: "images",
+				timeout:    time.Minute,
+				directory:  t.TempDir(),
+			}
+			if tc.partial != nil {
+				require.NoError(t, os.WriteFile(filepath.Join(c.directory, download.PartialName("capv/image/image.ova")), tc.partial, 0600))
+			}
+
+			path, err := c.Pull(context.TODO(), "capv/image/image.ova")
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(c.directory, "image.ova"), path)
+			assert.Equal(t, tc.expectedOffsets, bucket.offsets)
+
+			pulled, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, content, pulled)
+
+			// the partial pull was moved to the image
+			entries, err := os.ReadDir(c.directory)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
+
+func TestPullMissing(t *testing.T) {
+	c := &Client{
+		bucket:     &fakeBucket{objects: map[string][]byte{}},
+		bucketName: "images",
+		directory:  t.TempDir(),
+	}
+
+	_, err := c.Pull(context.TODO(), "capv/image/image.ova")
+	assert.ErrorIs(t, err, storage.ErrObjectNotExist)
+}
@@ -0,0 +1,98 @@
+// Package httpcheck probes whether a file can be downloaded from a URL.
+package httpcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Result is the outcome of an availability check
+type Result int
+
+const (
+	// Available means the URL answered with a 2xx status
+	Available Result = iota
+	// NotFound means the URL answered with a status retrying won't change,
+	// e.g. 404 or 403 for a missing S3 object
+	NotFound
+	// TransientError means the availability is unknown, as the URL could not
+	// be reached or answered with a 5xx or 429 status until the retries ran out
+	TransientError
+)
+
+func (r Result) String() string {
+	switch r {
+	case Available:
+		return "Available"
+	case NotFound:
+		return "NotFound"
+	case TransientError:
+		return "TransientError"
+	default:
+		return fmt.Sprintf("Result(%d)", int(r))
+	}
+}
+
+// defaultTimeout bounds a single request of a Checker without a client
+const defaultTimeout = 30 * time.Second
+
+// Checker checks the availability of URLs with HEAD requests
+type Checker struct {
+	// Client sends the requests, defaults to a client with a 30s timeout
+	Client *http.Client
+	// Backoff retries transient errors, the zero value checks only once
+	Backoff wait.Backoff
+}
+
+// Check reports whether the file at url is available. The error tells why
+// for any other result.
+func (c *Checker) Check(ctx context.Context, url string) (Result, error) {
+	client := c.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultTimeout}
+	}
+
+	backoff := c.Backoff
+	if backoff.Steps < 1 {
+		backoff.Steps = 1
+	}
+
+	result := TransientError
+	var lastErr error
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		result, lastErr = check(ctx, client, url)
+		return result != TransientError, nil
+	})
+	if result == TransientError && lastErr == nil {
+		// the context ended before the first request
+		lastErr = err
+	}
+	return result, lastErr
+}
+
+// check sends a single HEAD request to url
+func check(ctx context.Context, client *http.Client, url string) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return NotFound, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	resp, err := client.Do(req) // #nosec G107 - URL is from trusted source (Release CR)
+	if err != nil {
+		return TransientError, fmt.Errorf("error checking URL: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return Available, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return TransientError, fmt.Errorf("URL unavailable, status code: %d", resp.StatusCode)
+	default:
+		return NotFound, fmt.Errorf("file not found, status code: %d", resp.StatusCode)
+	}
+}
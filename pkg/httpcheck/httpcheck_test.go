@@ -0,0 +1,104 @@
+package httpcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name             string
+		statuses         []int
+		expected         Result
+		expectedRequests int
+	}{
+		{
+			name:             "case 0: available",
+			statuses:         []int{http.StatusOK},
+			expected:         Available,
+			expectedRequests: 1,
+		},
+		{
+			name:             "case 1: not found is not retried",
+			statuses:         []int{http.StatusNotFound},
+			expected:         NotFound,
+			expectedRequests: 1,
+		},
+		{
+			name:             "case 2: forbidden S3 object is not found",
+			statuses:         []int{http.StatusForbidden},
+			expected:         NotFound,
+			expectedRequests: 1,
+		},
+		{
+			name:             "case 3: server error is retried until available",
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusOK},
+			expected:         Available,
+			expectedRequests: 2,
+		},
+		{
+			name:             "case 4: throttling until the retries ran out",
+			statuses:         []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			expected:         TransientError,
+			expectedRequests: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodHead, r.Method)
+				w.WriteHeader(tc.statuses[min(requests, len(tc.statuses)-1)])
+				requests++
+			}))
+			defer server.Close()
+
+			c := &Checker{
+				Client:  server.Client(),
+				Backoff: wait.Backoff{Duration: time.Millisecond, Steps: 3},
+			}
+			result, err := c.Check(context.TODO(), server.URL+"/image.ova")
+			assert.Equal(t, tc.expected, result)
+			if tc.expected == Available {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.Equal(t, tc.expectedRequests, requests)
+		})
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	// the zero backoff checks only once
+	c := &Checker{}
+	result, err := c.Check(context.TODO(), url)
+	assert.Equal(t, TransientError, result)
+	assert.ErrorContains(t, err, "error checking URL")
+}
+
+func TestCheckCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &Checker{Client: server.Client()}
+	result, err := c.Check(ctx, server.URL)
+	assert.Equal(t, TransientError, result)
+	assert.ErrorIs(t, err, context.Canceled)
+}
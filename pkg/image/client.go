@@ -3,9 +3,13 @@ package image
 import (
 	"context"
 	"fmt"
+	"slices"
+	"text/template"
 	"time"
 
+	releases "github.com/giantswarm/releases/sdk/api/v1alpha1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
@@ -14,6 +18,16 @@ import (
 
 const (
 	LastUsedAnnotation = "image-distribution-operator.giantswarm.io/last-used"
+	// ForceReuploadAnnotation makes the operator delete and upload the image
+	// again even if it exists. Its value is "true" for all locations or a
+	// comma separated list of locations. It is removed after the upload.
+	ForceReuploadAnnotation = "image-distribution-operator.giantswarm.io/force-reupload"
+	// AllowDeletionAnnotation set to "true" lets the webhook admit the
+	// deletion of a NodeImage that releases still reference
+	AllowDeletionAnnotation = "image-distribution-operator.giantswarm.io/allow-deletion"
+	// S3BucketAnnotation imports the image of a single NodeImage from another
+	// S3 bucket, which must be one of the allowed bucket overrides
+	S3BucketAnnotation = "image-distribution-operator.giantswarm.io/s3-bucket"
 )
 
 // Config is a struct that holds the configuration for the Client
@@ -21,13 +35,22 @@ type Config struct {
 	Client    client.Client
 	Namespace string
 	Release   string
+	// NameTemplate is the text/template used to name Flatcar images,
+	// DefaultNameTemplate is used if empty
+	NameTemplate string
+	// NameCollisionPolicy selects how ambiguous or invalid node image object
+	// names are handled, NameCollisionHash is used if empty
+	NameCollisionPolicy string
 }
 
 // Client holds the client and the namespace for node image objects
 type Client struct {
 	client.Client
-	Namespace string
-	Release   string
+	Namespace    string
+	Release      string
+	nameTemplate *template.Template
+	// collisionPolicy selects how ambiguous node image object names are handled
+	collisionPolicy NameCollisionPolicy
 }
 
 // New creates a new Client object
@@ -42,85 +65,140 @@ func New(c Config) (*Client, error) {
 		return nil, fmt.Errorf("release is required")
 	}
 
+	nameTemplate, err := ParseNameTemplate(c.NameTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	collisionPolicy, err := ParseNameCollisionPolicy(c.NameCollisionPolicy)
+	if err != nil {
+		return nil, err
+	}
+
 	// create a new ImageList object
 	client := &Client{
-		Client:    c.Client,
-		Namespace: c.Namespace,
-		Release:   c.Release,
+		Client:          c.Client,
+		Namespace:       c.Namespace,
+		Release:         c.Release,
+		nameTemplate:    nameTemplate,
+		collisionPolicy: collisionPolicy,
 	}
 
 	return client, nil
 }
 
+// GetNodeImagesFromRelease returns the node images for the release, one per
+// architecture, named by the configured name template
+func (i *Client) GetNodeImagesFromRelease(release *releases.Release) ([]*images.NodeImage, error) {
+	return getNodeImagesFromRelease(release, i.nameTemplate, i.collisionPolicy)
+}
+
+// NodeImagesOfRelease returns the names of the node images that list the
+// release in their status
+func (i *Client) NodeImagesOfRelease(ctx context.Context) ([]string, error) {
+	list := &images.NodeImageList{}
+	if err := i.List(ctx, list, client.InNamespace(i.Namespace)); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, object := range list.Items {
+		if slices.Contains(object.Status.Releases, i.Release) {
+			names = append(names, object.Name)
+		}
+	}
+	return names, nil
+}
+
+// RemoveReleaseFromNodeImageStatus removes the release from the releases of
+// the node image. Releases deleted at the same time update the same status,
+// so the update is retried on conflicts with a freshly read object.
 func (i *Client) RemoveReleaseFromNodeImageStatus(ctx context.Context, image string) error {
 	log := log.FromContext(ctx)
 
-	// Get Image Object
-	object := &images.NodeImage{}
-	if err := i.Get(ctx, client.ObjectKey{
-		Namespace: i.Namespace,
-		Name:      image,
-	}, object); err != nil {
-		if apierrors.IsNotFound(err) {
-			return nil
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get Image Object
+		object := &images.NodeImage{}
+		if err := i.Get(ctx, client.ObjectKey{
+			Namespace: i.Namespace,
+			Name:      image,
+		}, object); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
 		}
-		return err
-	}
 
-	// Check node image status and remove the release from the list
-	for index, release := range object.Status.Releases {
-		if release == i.Release {
-			object.Status.Releases = append(object.Status.Releases[:index], object.Status.Releases[index+1:]...)
-			break
+		// Check node image status and remove the release from the list
+		found := false
+		for index, release := range object.Status.Releases {
+			if release == i.Release {
+				object.Status.Releases = append(object.Status.Releases[:index], object.Status.Releases[index+1:]...)
+				object.Status.ReleaseCount = int32(len(object.Status.Releases)) // #nosec G115
+				found = true
+				break
+			}
 		}
-	}
-	// Update the object
-	log.Info("Removing release from the status of node image", "nodeImage", object.Name, "release", i.Release)
-	return i.Status().Update(ctx, object)
+		if !found {
+			return nil
+		}
+
+		// Update the object
+		log.Info("Removing release from the status of node image")
+		return client.IgnoreNotFound(i.Status().Update(ctx, object))
+	})
 }
 
+// DeleteImage deletes the node image, or marks it for deletion if a retention
+// period is set, once no release uses it anymore. The deletion is made
+// conditional on the resource version the empty releases list was read at,
+// so a release added concurrently is never left with a deleted node image.
 func (i *Client) DeleteImage(ctx context.Context, image string, retentionPeriod time.Duration) error {
 	log := log.FromContext(ctx)
 
-	// Get Image Object
-	object := &images.NodeImage{}
-	if err := i.Get(ctx, client.ObjectKey{
-		Namespace: i.Namespace,
-		Name:      image,
-	}, object); err != nil {
-		if apierrors.IsNotFound(err) {
-			return nil
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get Image Object
+		object := &images.NodeImage{}
+		if err := i.Get(ctx, client.ObjectKey{
+			Namespace: i.Namespace,
+			Name:      image,
+		}, object); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
 		}
-		return err
-	}
 
-	// If there are still releases in the list, nothing to do
-	if len(object.Status.Releases) > 0 {
-		return nil
-	}
+		// If there are still releases in the list, nothing to do
+		if len(object.Status.Releases) > 0 {
+			return nil
+		}
 
-	// Check if we should retain the image
-	if retentionPeriod > 0 {
-		// update state to AwaitingDeletion if not already
-		if object.Status.State != images.NodeImageAwaitingDeletion {
-			object.Status.State = images.NodeImageAwaitingDeletion
-			// Set last used annotation
-			if object.Annotations == nil {
-				object.Annotations = make(map[string]string)
-			}
-			object.Annotations[LastUsedAnnotation] = time.Now().Format(time.RFC3339)
-			log.Info("Marking node image for deletion", "nodeImage", object.Name, "retentionPeriod", retentionPeriod)
-			if err := i.Update(ctx, object); err != nil {
-				return err
+		// Check if we should retain the image
+		if retentionPeriod > 0 {
+			// update state to AwaitingDeletion if not already
+			if object.Status.State != images.NodeImageAwaitingDeletion {
+				object.Status.State = images.NodeImageAwaitingDeletion
+				// Set last used annotation
+				if object.Annotations == nil {
+					object.Annotations = make(map[string]string)
+				}
+				object.Annotations[LastUsedAnnotation] = time.Now().Format(time.RFC3339)
+				log.Info("Marking node image for deletion", "retentionPeriod", retentionPeriod)
+				if err := i.Update(ctx, object); err != nil {
+					return client.IgnoreNotFound(err)
+				}
+				return client.IgnoreNotFound(i.Status().Update(ctx, object))
 			}
-			return i.Status().Update(ctx, object)
+			return nil
 		}
-		return nil
-	}
 
-	// If there are no releases left, delete the object
-	log.Info("Deleting node image", "nodeImage", object.Name)
-	return i.Delete(ctx, object)
+		// If there are no releases left, delete the object
+		log.Info("Deleting node image")
+		return client.IgnoreNotFound(i.Delete(ctx, object, client.Preconditions{
+			UID:             &object.UID,
+			ResourceVersion: &object.ResourceVersion,
+		}))
+	})
 }
 
 func (i *Client) CreateImage(ctx context.Context, image *images.NodeImage) error {
@@ -132,11 +210,19 @@ func (i *Client) CreateImage(ctx context.Context, image *images.NodeImage) error
 	} else if err != nil {
 		return err
 	}
-	log.Info("Created node image", "nodeImage", image.Name)
+	log.Info("Created node image")
 	return nil
 }
 
+// AddReleaseToNodeImageStatus adds the release to the releases of the node
+// image, retrying on conflicts with releases updating it at the same time.
 func (i *Client) AddReleaseToNodeImageStatus(ctx context.Context, image string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return i.addReleaseToNodeImageStatus(ctx, image)
+	})
+}
+
+func (i *Client) addReleaseToNodeImageStatus(ctx context.Context, image string) error {
 	log := log.FromContext(ctx)
 
 	// Get Image Object
@@ -149,30 +235,38 @@ func (i *Client) AddReleaseToNodeImageStatus(ctx context.Context, image string)
 	}
 
 	// Check node image status
-	for _, release := range object.Status.Releases {
-		if release == i.Release {
-			// release is already listed
+	if slices.Contains(object.Status.Releases, i.Release) {
+		// release is already listed, only NodeImages created before the
+		// release count was added still lack it
+		if int(object.Status.ReleaseCount) == len(object.Status.Releases) {
 			return nil
 		}
+		object.Status.ReleaseCount = int32(len(object.Status.Releases)) // #nosec G115
+		return i.Status().Update(ctx, object)
 	}
-	// Add release to the list
-	object.Status.Releases = append(object.Status.Releases, i.Release)
 
-	// If the State is empty or AwaitingDeletion, set it to Pending and remove last used annotation
+	// If the State is empty or AwaitingDeletion, remove the last used
+	// annotation first. The update returns the stored status, which the
+	// release is added to below, so releases added in the meantime are kept.
 	if object.Status.State == "" || object.Status.State == images.NodeImageAwaitingDeletion {
-		object.Status.State = images.NodeImagePending
-		// Remove last used annotation if it exists
-		if object.Annotations != nil {
-			if _, exists := object.Annotations[LastUsedAnnotation]; exists {
-				delete(object.Annotations, LastUsedAnnotation)
-				// Update metadata first to clear annotation
-				if err := i.Update(ctx, object); err != nil {
-					return err
-				}
+		if _, exists := object.Annotations[LastUsedAnnotation]; exists {
+			delete(object.Annotations, LastUsedAnnotation)
+			if err := i.Update(ctx, object); err != nil {
+				return err
+			}
+			if slices.Contains(object.Status.Releases, i.Release) {
+				return nil
 			}
 		}
 	}
 
-	log.Info("Adding release to the status of node image", "nodeImage", object.Name, "release", i.Release)
+	// Add release to the list and set the State to Pending if it is empty or AwaitingDeletion
+	object.Status.Releases = append(object.Status.Releases, i.Release)
+	object.Status.ReleaseCount = int32(len(object.Status.Releases)) // #nosec G115
+	if object.Status.State == "" || object.Status.State == images.NodeImageAwaitingDeletion {
+		object.Status.State = images.NodeImagePending
+	}
+
+	log.Info("Adding release to the status of node image")
 	return i.Status().Update(ctx, object)
 }
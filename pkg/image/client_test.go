@@ -3,14 +3,19 @@ package image
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	releases "github.com/giantswarm/releases/sdk/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	images "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
 )
@@ -95,6 +100,8 @@ func TestRemoveImage(t *testing.T) {
 
 			if tc.expectDeleted {
 				assert.ElementsMatch(t, tc.expectedReleases, fetchedImage.Status.Releases)
+			} else {
+				assert.Equal(t, int32(len(tc.expectedReleases)), fetchedImage.Status.ReleaseCount)
 			}
 		})
 	}
@@ -203,6 +210,8 @@ func TestCreateOrUpdateImage(t *testing.T) {
 			}
 
 			assert.ElementsMatch(t, tc.expectedReleases, fetchedImage.Status.Releases)
+			// NodeImages listing the release without a count get it too
+			assert.Equal(t, int32(len(tc.expectedReleases)), fetchedImage.Status.ReleaseCount)
 		})
 	}
 }
@@ -340,3 +349,255 @@ func TestDeleteImageWithRetentionPeriod(t *testing.T) {
 		})
 	}
 }
+
+func TestClientNameTemplate(t *testing.T) {
+	release := &releases.Release{
+		ObjectMeta: metav1.ObjectMeta{Name: "vsphere-1.2.3"},
+		Spec: releases.ReleaseSpec{
+			Components: []releases.ReleaseSpecComponent{
+				{Name: "flatcar", Version: "3975.2.0"},
+				{Name: "kubernetes", Version: "v1.30.4"},
+				{Name: "os-tooling", Version: "v1.18.1"},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name               string
+		nameTemplate       string
+		expectedObjectName string
+		expectError        bool
+	}{
+		{
+			name:               "case 0: default template",
+			expectedObjectName: "capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+		},
+		{
+			name:               "case 1: custom template",
+			nameTemplate:       "custom-{{.Channel}}-{{.FlatcarVersion}}-{{.KubernetesVersion}}",
+			expectedObjectName: "capv-custom-stable-3975.2.0-1.30.4",
+		},
+		{
+			name:         "case 2: invalid template is rejected",
+			nameTemplate: "custom-{{.Unknown}}",
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(Config{
+				Client:       fake.NewClientBuilder().Build(),
+				Namespace:    "test-namespace",
+				Release:      release.Name,
+				NameTemplate: tc.nameTemplate,
+			})
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			nodeImages, err := c.GetNodeImagesFromRelease(release)
+			assert.NoError(t, err)
+			require.Len(t, nodeImages, 1)
+			assert.Equal(t, tc.expectedObjectName, nodeImages[0].Name)
+		})
+	}
+}
+
+func TestConcurrentReleaseDeletion(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, images.AddToScheme(scheme))
+
+	releaseNames := []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0"}
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&images.NodeImage{}).
+		WithObjects(&images.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-image", Namespace: "test-namespace"},
+			Status:     images.NodeImageStatus{Releases: releaseNames},
+		}).
+		Build()
+
+	// all releases are deleted at the same time
+	var wg sync.WaitGroup
+	errs := make([]error, len(releaseNames))
+	for n, release := range releaseNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := New(Config{Client: fakeClient, Namespace: "test-namespace", Release: release})
+			if err != nil {
+				errs[n] = err
+				return
+			}
+			if err := c.RemoveReleaseFromNodeImageStatus(ctx, "test-image"); err != nil {
+				errs[n] = err
+				return
+			}
+			errs[n] = c.DeleteImage(ctx, "test-image", 0)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	err := fakeClient.Get(ctx, client.ObjectKey{Name: "test-image", Namespace: "test-namespace"}, &images.NodeImage{})
+	assert.True(t, apierrors.IsNotFound(err), "expected node image to be deleted, got %v", err)
+}
+
+func TestDeleteImageReleaseAddedConcurrently(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, images.AddToScheme(scheme))
+
+	// a new release is added to the node image right before the deletion
+	// reaches the API server, after the empty releases list was read
+	var once sync.Once
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&images.NodeImage{}).
+		WithObjects(&images.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-image", Namespace: "test-namespace"},
+			Status:     images.NodeImageStatus{},
+		}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+				once.Do(func() {
+					adder, err := New(Config{Client: c, Namespace: "test-namespace", Release: "v2.0.0"})
+					assert.NoError(t, err)
+					assert.NoError(t, adder.AddReleaseToNodeImageStatus(ctx, "test-image"))
+				})
+				return c.Delete(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	c, err := New(Config{Client: fakeClient, Namespace: "test-namespace", Release: "v1.0.0"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteImage(ctx, "test-image", 0))
+
+	fetched := &images.NodeImage{}
+	assert.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "test-image", Namespace: "test-namespace"}, fetched))
+	assert.Equal(t, []string{"v2.0.0"}, fetched.Status.Releases)
+}
+
+func TestAddReleaseConflictingUpdate(t *testing.T) {
+	ctx := context.TODO()
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, images.AddToScheme(scheme))
+
+	// another release is added to the node image after it was read, so the
+	// first status update conflicts and has to be applied again
+	conflicts := 0
+	var once sync.Once
+	fakeClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&images.NodeImage{}).
+		WithObjects(&images.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-image", Namespace: "test-namespace"},
+			Status:     images.NodeImageStatus{Releases: []string{"v1.0.0"}, State: images.NodeImageAvailable},
+		}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				once.Do(func() {
+					adder, err := New(Config{Client: c, Namespace: "test-namespace", Release: "v2.0.0"})
+					assert.NoError(t, err)
+					assert.NoError(t, adder.AddReleaseToNodeImageStatus(ctx, "test-image"))
+				})
+				err := c.SubResource(subResourceName).Update(ctx, obj, opts...)
+				if apierrors.IsConflict(err) {
+					conflicts++
+				}
+				return err
+			},
+		}).
+		Build()
+
+	c, err := New(Config{Client: fakeClient, Namespace: "test-namespace", Release: "v3.0.0"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.AddReleaseToNodeImageStatus(ctx, "test-image"))
+
+	fetched := &images.NodeImage{}
+	assert.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "test-image", Namespace: "test-namespace"}, fetched))
+	assert.Equal(t, 1, conflicts)
+	assert.ElementsMatch(t, []string{"v1.0.0", "v2.0.0", "v3.0.0"}, fetched.Status.Releases)
+}
+
+func TestConcurrentReleaseAddition(t *testing.T) {
+	testCases := []struct {
+		name     string
+		existing *images.NodeImage
+	}{
+		{
+			name: "case 0: node image created by the releases",
+		},
+		{
+			name: "case 1: node image awaiting deletion",
+			existing: &images.NodeImage{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-image",
+					Namespace:   "test-namespace",
+					Annotations: map[string]string{LastUsedAnnotation: time.Now().Format(time.RFC3339)},
+				},
+				Status: images.NodeImageStatus{State: images.NodeImageAwaitingDeletion},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			scheme := runtime.NewScheme()
+			assert.NoError(t, images.AddToScheme(scheme))
+
+			builder := fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&images.NodeImage{})
+			if tc.existing != nil {
+				builder = builder.WithObjects(tc.existing)
+			}
+			fakeClient := builder.Build()
+
+			// releases sharing the node image are reconciled at the same time
+			releaseNames := []string{"v1.0.0", "v1.0.1", "v1.1.0", "v1.2.0", "v1.2.1", "v1.3.0", "v1.4.0", "v1.5.0"}
+			var wg sync.WaitGroup
+			errs := make([]error, len(releaseNames))
+			for n, release := range releaseNames {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c, err := New(Config{Client: fakeClient, Namespace: "test-namespace", Release: release})
+					if err != nil {
+						errs[n] = err
+						return
+					}
+					image := &images.NodeImage{ObjectMeta: metav1.ObjectMeta{Name: "test-image"}}
+					if err := c.CreateImage(ctx, image); err != nil {
+						errs[n] = err
+						return
+					}
+					errs[n] = c.AddReleaseToNodeImageStatus(ctx, "test-image")
+				}()
+			}
+			wg.Wait()
+
+			for _, err := range errs {
+				assert.NoError(t, err)
+			}
+
+			// no release is lost
+			fetched := &images.NodeImage{}
+			assert.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "test-image", Namespace: "test-namespace"}, fetched))
+			assert.ElementsMatch(t, releaseNames, fetched.Status.Releases)
+			assert.Equal(t, images.NodeImagePending, fetched.Status.State)
+			assert.NotContains(t, fetched.Annotations, LastUsedAnnotation)
+		})
+	}
+}
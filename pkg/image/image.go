@@ -1,45 +1,200 @@
 package image
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
 
 	images "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
 
 	releases "github.com/giantswarm/releases/sdk/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const (
 	providerVSphere       = "vsphere"
 	providerCloudDirector = "cloud-director"
 	providerProxmox       = "proxmox"
-	providerCapV          = "capv"
-	providerCapVCD        = "capvcd"
-	providerCapMox        = "capmox"
+	providerCapV          = provider.VSphere
+	providerCapVCD        = provider.CloudDirector
+	providerCapMox        = provider.Proxmox
+
+	OSFlatcar = "flatcar"
+	OSUbuntu  = "ubuntu"
 )
 
-func GetNodeImageFromRelease(release *releases.Release, flatcarChannel string) (*images.NodeImage, error) {
-	imageName, err := getImageName(release, flatcarChannel)
+// FlatcarChannelAnnotation on a Release selects the Flatcar channel its node image is built from
+const FlatcarChannelAnnotation = "release.giantswarm.io/flatcar-channel"
+
+// defaultFlatcarChannel is used when a Release does not specify a channel
+const defaultFlatcarChannel = "stable"
+
+var flatcarChannels = []string{"stable", "beta", "alpha", "lts"}
+
+// ArchitecturesAnnotation on a Release lists the comma separated CPU
+// architectures node images are built for, e.g. "amd64,arm64"
+const ArchitecturesAnnotation = "release.giantswarm.io/architectures"
+
+// DefaultArchitecture is the architecture of releases without
+// ArchitecturesAnnotation. Its images are named without an architecture, the
+// images of other architectures end in -<architecture>.
+const DefaultArchitecture = "amd64"
+
+// architectures lists the supported architectures in the order their node
+// images are returned
+var architectures = []string{DefaultArchitecture, "arm64"}
+
+// DefaultNameTemplate is the name template of Flatcar images,
+// taken from github.com/giantswarm/capi-image-builder
+const DefaultNameTemplate = "flatcar-{{.Channel}}-{{.FlatcarVersion}}-kube-{{.KubernetesVersion}}-tooling-{{.ToolingVersion}}-gs"
+
+var defaultNameTemplate = template.Must(template.New("image-name").Option("missingkey=error").Parse(DefaultNameTemplate))
+
+// NameTemplateData holds the fields available to an image name template.
+// Versions are passed without a leading "v".
+type NameTemplateData struct {
+	Channel           string
+	FlatcarVersion    string
+	KubernetesVersion string
+	ToolingVersion    string
+}
+
+// ParseNameTemplate parses and validates a Flatcar image name template,
+// falling back to DefaultNameTemplate if text is empty
+func ParseNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return defaultNameTemplate, nil
+	}
+
+	tmpl, err := template.New("image-name").Option("missingkey=error").Parse(text)
 	if err != nil {
-		return &images.NodeImage{}, err
+		return nil, fmt.Errorf("failed to parse image name template: %w", err)
+	}
+
+	// render once so references to unknown fields fail now and not on the first release
+	if _, err := buildImageName(tmpl, "stable", "0.0.0", "0.0.0", "0.0.0"); err != nil {
+		return nil, fmt.Errorf("invalid image name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// supportedOS lists the OS release components node images are built from,
+// in the order they are looked up
+var supportedOS = []string{OSFlatcar, OSUbuntu}
+
+// ErrInvalidRelease is returned for a release no node image can be built
+// for, e.g. because its name has no provider or it lacks a component. The
+// release has to change for it to succeed, so it is not worth retrying.
+var ErrInvalidRelease = errors.New("invalid release")
+
+// GetNodeImagesFromRelease returns the node images for the release, one per
+// architecture, using the default name template
+func GetNodeImagesFromRelease(release *releases.Release) ([]*images.NodeImage, error) {
+	return getNodeImagesFromRelease(release, defaultNameTemplate, NameCollisionHash)
+}
+
+func getNodeImagesFromRelease(release *releases.Release, nameTemplate *template.Template, collisionPolicy NameCollisionPolicy) ([]*images.NodeImage, error) {
+	imageName, err := getImageName(release, nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRelease, err)
 	}
 
 	providerName, err := GetImageProvider(release.Name)
 	if err != nil {
-		return &images.NodeImage{}, err
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRelease, err)
 	}
 
 	provider := getProviderFromProviderName(providerName)
 
-	return GetNodeImage(imageName, provider, release.Name), nil
+	releaseArchitectures, err := GetArchitectures(release)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRelease, err)
+	}
+
+	var nodeImages []*images.NodeImage
+	for _, arch := range releaseArchitectures {
+		archImageName := withArchitecture(imageName, arch)
+		objectName, err := NodeImageName(provider, archImageName, collisionPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRelease, err)
+		}
+		nodeImages = append(nodeImages, newNodeImage(objectName, archImageName, provider))
+	}
+	return nodeImages, nil
 }
 
+// GetArchitectures returns the architectures set on the release in the order
+// of the supported architectures, falling back to DefaultArchitecture
+func GetArchitectures(release *releases.Release) ([]string, error) {
+	value := release.Annotations[ArchitecturesAnnotation]
+	if strings.TrimSpace(value) == "" {
+		return []string{DefaultArchitecture}, nil
+	}
+
+	requested := map[string]bool{}
+	for arch := range strings.SplitSeq(value, ",") {
+		arch = strings.TrimSpace(arch)
+		if arch == "" {
+			continue
+		}
+		if !slices.Contains(architectures, arch) {
+			return nil, fmt.Errorf("unknown architecture %q in release %s, expected one of %s", arch, release.Name, strings.Join(architectures, ", "))
+		}
+		requested[arch] = true
+	}
+
+	var result []string
+	for _, arch := range architectures {
+		if requested[arch] {
+			result = append(result, arch)
+		}
+	}
+	return result, nil
+}
+
+// withArchitecture appends the architecture to the image name, unless it is
+// the default architecture
+func withArchitecture(name, arch string) string {
+	if arch == DefaultArchitecture {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", name, arch)
+}
+
+// splitArchitecture returns the image name without the architecture it ends
+// in and that architecture, DefaultArchitecture if it ends in none
+func splitArchitecture(name string) (string, string) {
+	for _, arch := range architectures {
+		if arch == DefaultArchitecture {
+			continue
+		}
+		if base, ok := strings.CutSuffix(name, "-"+arch); ok {
+			return base, arch
+		}
+	}
+	return name, DefaultArchitecture
+}
+
+// GetNodeImage returns the node image for the image of the provider,
+// disambiguating its object name with NameCollisionHash if needed
 func GetNodeImage(imageName, providerName, releaseName string) *images.NodeImage {
+	return newNodeImage(disambiguateName(providerName, imageName), imageName, providerName)
+}
+
+func newNodeImage(objectName, imageName, providerName string) *images.NodeImage {
 	return &images.NodeImage{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: strings.Join([]string{providerName, imageName}, "-"),
+			Name: objectName,
 		},
 
 		Spec: images.NodeImageSpec{
@@ -49,15 +204,95 @@ func GetNodeImage(imageName, providerName, releaseName string) *images.NodeImage
 	}
 }
 
-func getImageName(release *releases.Release, flatcarChannel string) (string, error) {
+// NameCollisionPolicy selects how node images are named whose plain
+// <provider>-<image> object name is ambiguous or not a valid object name
+type NameCollisionPolicy string
+
+const (
+	// NameCollisionHash appends a hash of the provider and image name to the
+	// object name, so it is unique and stable for the same inputs
+	NameCollisionHash NameCollisionPolicy = "hash"
+	// NameCollisionReject fails to create the node image
+	NameCollisionReject NameCollisionPolicy = "reject"
+)
+
+// ParseNameCollisionPolicy validates policy, falling back to NameCollisionHash if empty
+func ParseNameCollisionPolicy(policy string) (NameCollisionPolicy, error) {
+	switch NameCollisionPolicy(policy) {
+	case "":
+		return NameCollisionHash, nil
+	case NameCollisionHash, NameCollisionReject:
+		return NameCollisionPolicy(policy), nil
+	}
+	return "", fmt.Errorf("unknown image name collision policy %q, expected %s or %s", policy, NameCollisionHash, NameCollisionReject)
+}
+
+// NodeImageName returns the object name of the node image for the image of
+// the provider. The name is <provider>-<image> unless that is ambiguous: as
+// the provider and image name are joined with a dash, a provider containing a
+// dash could produce the same name as another provider, e.g. "foo-bar" with
+// image "baz" and "foo" with image "bar-baz". Such names, and names that are
+// not valid object names, are handled according to policy.
+func NodeImageName(providerName, imageName string, policy NameCollisionPolicy) (string, error) {
+	name, ok := plainNodeImageName(providerName, imageName)
+	if ok {
+		return name, nil
+	}
+
+	switch policy {
+	case NameCollisionHash, "":
+		return disambiguateName(providerName, imageName), nil
+	case NameCollisionReject:
+		if strings.Contains(providerName, "-") {
+			return "", fmt.Errorf("node image name %s is ambiguous, provider name %s contains a dash", name, providerName)
+		}
+		return "", fmt.Errorf("node image name %s is invalid: %s", name, strings.Join(validation.IsDNS1123Subdomain(name), ", "))
+	}
+	return "", fmt.Errorf("unknown image name collision policy %q", policy)
+}
+
+// plainNodeImageName returns <provider>-<image> and whether it is an
+// unambiguous, valid object name
+func plainNodeImageName(providerName, imageName string) (string, bool) {
+	name := strings.Join([]string{providerName, imageName}, "-")
+	return name, !strings.Contains(providerName, "-") && len(validation.IsDNS1123Subdomain(name)) == 0
+}
+
+var invalidObjectNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// disambiguateName returns <provider>-<image> if it is unambiguous and valid,
+// and otherwise a valid object name ending in a hash of provider and image
+func disambiguateName(providerName, imageName string) string {
+	name, ok := plainNodeImageName(providerName, imageName)
+	if ok {
+		return name
+	}
+
+	// the separator cannot be part of a provider name, so the hash input is unambiguous
+	sum := sha256.Sum256([]byte(providerName + "/" + imageName))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+
+	prefix := invalidObjectNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	prefix = strings.Trim(prefix, "-")
+	if maxPrefix := validation.DNS1123SubdomainMaxLength - nameHashLength - 1; len(prefix) > maxPrefix {
+		prefix = strings.TrimRight(prefix[:maxPrefix], "-")
+	}
+	if prefix == "" {
+		return hash
+	}
+	return fmt.Sprintf("%s-%s", prefix, hash)
+}
+
+func getImageName(release *releases.Release, nameTemplate *template.Template) (string, error) {
 
-	var flatcarVersion, kubernetesVersion, toolingVersion string
+	var osName, osVersion, kubernetesVersion, toolingVersion string
 	{
-		flatcar, err := getReleaseComponent(release, "flatcar")
+		os, err := GetImageOS(release)
 		if err != nil {
 			return "", err
 		}
-		flatcarVersion = flatcar.Version
+		osName = os.Name
+		osVersion = os.Version
 
 		kubernetes, err := getReleaseComponent(release, "kubernetes")
 		if err != nil {
@@ -72,8 +307,8 @@ func getImageName(release *releases.Release, flatcarChannel string) (string, err
 		toolingVersion = tooling.Version
 	}
 
-	if flatcarVersion == "" {
-		return "", fmt.Errorf("flatcar version is empty")
+	if osVersion == "" {
+		return "", fmt.Errorf("%s version is empty", osName)
 	}
 	if kubernetesVersion == "" {
 		return "", fmt.Errorf("kubernetes version is empty")
@@ -81,11 +316,41 @@ func getImageName(release *releases.Release, flatcarChannel string) (string, err
 	if toolingVersion == "" {
 		return "", fmt.Errorf("tooling version is empty")
 	}
-	if flatcarChannel == "" {
-		return "", fmt.Errorf("flatcar channel is empty")
+
+	switch osName {
+	case OSUbuntu:
+		return buildUbuntuImageName(osVersion, kubernetesVersion, toolingVersion), nil
+	default:
+		flatcarChannel, err := GetFlatcarChannel(release)
+		if err != nil {
+			return "", err
+		}
+		return buildImageName(nameTemplate, flatcarChannel, osVersion, kubernetesVersion, toolingVersion)
+	}
+}
+
+// GetFlatcarChannel returns the Flatcar channel set on the release, falling back to stable
+func GetFlatcarChannel(release *releases.Release) (string, error) {
+	channel, ok := release.Annotations[FlatcarChannelAnnotation]
+	if !ok || channel == "" {
+		return defaultFlatcarChannel, nil
+	}
+	for _, c := range flatcarChannels {
+		if channel == c {
+			return channel, nil
+		}
 	}
+	return "", fmt.Errorf("unknown flatcar channel %q in release %s, expected one of %s", channel, release.Name, strings.Join(flatcarChannels, ", "))
+}
 
-	return buildImageName(flatcarChannel, flatcarVersion, kubernetesVersion, toolingVersion), nil
+// GetImageOS returns the OS component (flatcar or ubuntu) the release's node image is built from
+func GetImageOS(release *releases.Release) (releases.ReleaseSpecComponent, error) {
+	for _, os := range supportedOS {
+		if c, err := getReleaseComponent(release, os); err == nil {
+			return c, nil
+		}
+	}
+	return releases.ReleaseSpecComponent{}, fmt.Errorf("no OS component (%s) found in release %s", strings.Join(supportedOS, " or "), release.Name)
 }
 
 // GetImageProvider extracts the provider name from a release name (e.g., "vsphere-1.2.3" -> "vsphere")
@@ -99,17 +364,105 @@ func GetImageProvider(release string) (string, error) {
 	return "", fmt.Errorf("provider name not found in release %s", release)
 }
 
-// taken from github.com/giantswarm/capi-image-builder
-func buildImageName(flatcarChannel, flatcarVersion, kubernetesVersion, toolingVersion string) string {
+// buildImageName renders the name of a Flatcar image from the name template
+func buildImageName(nameTemplate *template.Template, flatcarChannel, flatcarVersion, kubernetesVersion, toolingVersion string) (string, error) {
+	var name bytes.Buffer
+	if err := nameTemplate.Execute(&name, NameTemplateData{
+		Channel:           flatcarChannel,
+		FlatcarVersion:    flatcarVersion,
+		KubernetesVersion: strings.TrimPrefix(kubernetesVersion, "v"),
+		ToolingVersion:    strings.TrimPrefix(toolingVersion, "v"),
+	}); err != nil {
+		return "", fmt.Errorf("failed to render image name: %w", err)
+	}
+	if name.Len() == 0 {
+		return "", fmt.Errorf("image name template rendered an empty name")
+	}
+	return name.String(), nil
+}
+
+// buildUbuntuImageName builds the name of an Ubuntu based image, which has no channel
+func buildUbuntuImageName(ubuntuVersion, kubernetesVersion, toolingVersion string) string {
 	return fmt.Sprintf(
-		"flatcar-%s-%s-kube-%s-tooling-%s-gs",
-		flatcarChannel,
-		flatcarVersion,
+		"ubuntu-%s-kube-%s-tooling-%s-gs",
+		ubuntuVersion,
 		strings.TrimPrefix(kubernetesVersion, "v"),
 		strings.TrimPrefix(toolingVersion, "v"),
 	)
 }
 
+// NameComponents are the component versions encoded in an image name
+type NameComponents struct {
+	OS                string
+	OSVersion         string
+	Channel           string // only set for Flatcar images
+	KubernetesVersion string
+	ToolingVersion    string
+	Architecture      string // DefaultArchitecture unless the name ends in another one
+}
+
+var (
+	flatcarNameRe = regexp.MustCompile(`^flatcar-([a-z]+)-([0-9.]+)-kube-([0-9.]+)-tooling-([0-9.]+)-gs$`)
+	ubuntuNameRe  = regexp.MustCompile(`^ubuntu-([0-9.]+)-kube-([0-9.]+)-tooling-([0-9.]+)-gs$`)
+)
+
+// ParseImageName extracts the component versions from an image name in the
+// default format, optionally ending in an architecture. It returns false for
+// names it does not recognise, e.g. names built from a custom name template
+// or truncated names.
+func ParseImageName(name string) (NameComponents, bool) {
+	name, arch := splitArchitecture(name)
+	if matches := flatcarNameRe.FindStringSubmatch(name); len(matches) == 5 {
+		return NameComponents{
+			OS:                OSFlatcar,
+			Channel:           matches[1],
+			OSVersion:         matches[2],
+			KubernetesVersion: matches[3],
+			ToolingVersion:    matches[4],
+			Architecture:      arch,
+		}, true
+	}
+	if matches := ubuntuNameRe.FindStringSubmatch(name); len(matches) == 4 {
+		return NameComponents{
+			OS:                OSUbuntu,
+			OSVersion:         matches[1],
+			KubernetesVersion: matches[2],
+			ToolingVersion:    matches[3],
+			Architecture:      arch,
+		}, true
+	}
+	return NameComponents{}, false
+}
+
+// nameHashLength is the number of hex characters of the name hash kept by TruncateName
+const nameHashLength = 8
+
+// FitName returns name if it fits into maxLength. Longer names are shortened
+// with TruncateName if truncate is set and rejected otherwise. A maxLength of
+// 0 means there is no limit.
+func FitName(name string, maxLength int, truncate bool) (string, error) {
+	if maxLength <= 0 || len(name) <= maxLength {
+		return name, nil
+	}
+	if !truncate {
+		return "", fmt.Errorf("image name %s is %d characters long, exceeding the limit of %d", name, len(name), maxLength)
+	}
+	if maxLength <= nameHashLength+1 {
+		return "", fmt.Errorf("cannot truncate image name %s to %d characters", name, maxLength)
+	}
+	return TruncateName(name, maxLength), nil
+}
+
+// TruncateName shortens name to maxLength, replacing the end with a hash of
+// the full name so different long names stay unique. The result is
+// deterministic, so the same name always maps to the same truncated name.
+func TruncateName(name string, maxLength int) string {
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	prefix := strings.TrimRight(name[:maxLength-nameHashLength-1], "-.")
+	return fmt.Sprintf("%s-%s", prefix, hash)
+}
+
 func getReleaseComponent(release *releases.Release, component string) (releases.ReleaseSpecComponent, error) {
 	components := release.Spec.Components
 
@@ -122,37 +475,142 @@ func getReleaseComponent(release *releases.Release, component string) (releases.
 	return releases.ReleaseSpecComponent{}, fmt.Errorf("component %s not found in release %s", component, release.Name)
 }
 
-func GetImageKey(nodeImage *images.NodeImage) string {
-	if nodeImage.Spec.Provider == providerCapMox {
-		return getQcow2ImageKey(nodeImage)
+// DefaultKeyTemplate is the template of the S3 key of node images, stored
+// below a directory named after their provider
+const DefaultKeyTemplate = "{{.Provider}}/{{.Name}}/{{.File}}"
+
+var defaultKeyTemplate = template.Must(template.New("image-key").Option("missingkey=error").Parse(DefaultKeyTemplate))
+
+// KeyTemplateData holds the fields available to an image key template
+type KeyTemplateData struct {
+	// Provider is the provider of the node image, e.g. capv
+	Provider string
+	// Name is the name of the node image
+	Name string
+	// File is the file name of the image, e.g.
+	// flatcar-stable-3975.2.0-kube-v1.30.4.ova
+	File string
+}
+
+// ParseKeyTemplate parses and validates an image key template, falling back
+// to DefaultKeyTemplate if text is empty
+func ParseKeyTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return defaultKeyTemplate, nil
+	}
+
+	tmpl, err := template.New("image-key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image key template: %w", err)
+	}
+
+	// render once so references to unknown fields fail now and not on the first node image
+	if _, err := ImageKey(tmpl, &images.NodeImage{Spec: images.NodeImageSpec{Name: "image", Provider: providerCapV}}); err != nil {
+		return nil, fmt.Errorf("invalid image key template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// GetImageKey returns the S3 key of the node image, using the default key template
+func GetImageKey(nodeImage *images.NodeImage) (string, error) {
+	return ImageKey(defaultKeyTemplate, nodeImage)
+}
+
+// ImageKey renders the S3 key of the node image from the key template
+func ImageKey(keyTemplate *template.Template, nodeImage *images.NodeImage) (string, error) {
+	var key bytes.Buffer
+	if err := keyTemplate.Execute(&key, KeyTemplateData{
+		Provider: nodeImage.Spec.Provider,
+		Name:     nodeImage.Spec.Name,
+		File:     getImageFileName(nodeImage),
+	}); err != nil {
+		return "", fmt.Errorf("failed to render image key: %w", err)
+	}
+	if key.Len() == 0 {
+		return "", fmt.Errorf("image key template rendered an empty key")
 	}
-	return getOVAImageKey(nodeImage)
+	if err := ValidateKey(key.String()); err != nil {
+		return "", err
+	}
+	return key.String(), nil
 }
 
-func getOVAImageKey(nodeImage *images.NodeImage) string {
-	// the image name is like "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
-	// the ova file name is like "flatcar-stable-3975.2.0-kube-v1.30.4.ova"
-	ovaFileName := strings.Split(nodeImage.Spec.Name, "-tooling")[0]
-	regexp := regexp.MustCompile(`(kube-)(\d+\.\d+\.\d+)`)
-	ovaFileName = regexp.ReplaceAllString(ovaFileName, `${1}v${2}`)
+// maxKeyLength is the maximum length of an S3 key in bytes
+const maxKeyLength = 1024
+
+// ErrInvalidImageKey is returned for an image key that is not a valid S3 key
+// or would escape the directory it is rendered into
+var ErrInvalidImageKey = errors.New("invalid image key")
 
-	// Both capv and capvcd images are stored in the capv/ directory in S3
-	s3Provider := nodeImage.Spec.Provider
-	if s3Provider == providerCapVCD {
-		s3Provider = providerCapV
+// ValidateKey checks that key is a valid S3 key made of relative path
+// segments. Names from custom templates end up in the key, so absolute keys,
+// empty, "." and ".." segments, backslashes and control characters are
+// rejected rather than passed on to S3 and the providers.
+func ValidateKey(key string) error {
+	if len(key) > maxKeyLength {
+		return fmt.Errorf("%w: key is longer than %d bytes", ErrInvalidImageKey, maxKeyLength)
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidImageKey, key)
+	}
+	if i := strings.IndexFunc(key, func(r rune) bool { return r == '\\' || unicode.IsControl(r) }); i >= 0 {
+		r, _ := utf8.DecodeRuneInString(key[i:])
+		return fmt.Errorf("%w: %q contains invalid character %q", ErrInvalidImageKey, key, r)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		switch segment {
+		case "":
+			return fmt.Errorf("%w: %q contains an empty path segment", ErrInvalidImageKey, key)
+		case ".", "..":
+			return fmt.Errorf("%w: %q contains a %q path segment", ErrInvalidImageKey, key, segment)
+		}
 	}
+	return nil
+}
 
-	return fmt.Sprintf("%s/%s/%s.ova", s3Provider, nodeImage.Spec.Name, ovaFileName)
+// getImageFileName returns the file name of the image in S3, a qcow2 file
+// for Proxmox and an OVA otherwise
+func getImageFileName(nodeImage *images.NodeImage) string {
+	fileName := imageFileBaseName(nodeImage.Spec.Name)
+	if nodeImage.Spec.Provider == providerCapMox {
+		return fileName + ".qcow2"
+	}
+	return fileName + ".ova"
 }
 
-func getQcow2ImageKey(nodeImage *images.NodeImage) string {
-	// the image name is like "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
-	// the qcow2 file name is like "flatcar-stable-3975.2.0-kube-v1.30.4.qcow2"
-	qcow2FileName := strings.Split(nodeImage.Spec.Name, "-tooling")[0]
-	regexp := regexp.MustCompile(`(kube-)(\d+\.\d+\.\d+)`)
-	qcow2FileName = regexp.ReplaceAllString(qcow2FileName, `${1}v${2}`)
+// kubeVersionRe matches the Kubernetes version in image names not in the
+// default format
+var kubeVersionRe = regexp.MustCompile(`(kube-)(\d+\.\d+\.\d+)`)
+
+// imageFileBaseName returns the file name of the image without its
+// extension. For names in the default format it is built from their
+// components, e.g. flatcar-stable-3975.2.0-kube-v1.30.4 for
+// flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs. Other names, e.g.
+// built from a custom name template, are cut at their tooling version. The
+// files of architectures other than the default end in the architecture,
+// e.g. flatcar-stable-3975.2.0-kube-v1.30.4-arm64.
+func imageFileBaseName(name string) string {
+	if components, ok := ParseImageName(name); ok {
+		return components.fileBaseName()
+	}
+	base, arch := splitArchitecture(name)
+	fileName, _, _ := strings.Cut(base, "-tooling")
+	return withArchitecture(kubeVersionRe.ReplaceAllString(fileName, `${1}v${2}`), arch)
+}
 
-	return fmt.Sprintf("capmox/%s/%s.qcow2", nodeImage.Spec.Name, qcow2FileName)
+// fileBaseName returns the file name the image with these components is
+// stored under, without its extension
+func (c NameComponents) fileBaseName() string {
+	var name string
+	if c.OS == OSFlatcar {
+		name = fmt.Sprintf("flatcar-%s-%s-kube-v%s", c.Channel, c.OSVersion, c.KubernetesVersion)
+	} else {
+		name = fmt.Sprintf("%s-%s-kube-v%s", c.OS, c.OSVersion, c.KubernetesVersion)
+	}
+	if c.Architecture == "" {
+		return name
+	}
+	return withArchitecture(name, c.Architecture)
 }
 
 func getProviderFromProviderName(providerName string) string {
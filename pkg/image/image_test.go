@@ -1,10 +1,13 @@
 package image
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	releases "github.com/giantswarm/releases/sdk/api/v1alpha1"
 
@@ -115,11 +118,10 @@ func TestGetProviderFromProviderName(t *testing.T) {
 	}
 }
 
-func TestGetNodeImageFromRelease(t *testing.T) {
+func TestGetNodeImagesFromRelease(t *testing.T) {
 	testCases := []struct {
 		name               string
 		release            *releases.Release
-		flatcarChannel     string
 		expectedImageName  string
 		expectedProvider   string
 		expectedObjectName string
@@ -139,7 +141,6 @@ func TestGetNodeImageFromRelease(t *testing.T) {
 					},
 				},
 			},
-			flatcarChannel:     "stable",
 			expectedImageName:  "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
 			expectedProvider:   providerCapV,
 			expectedObjectName: "capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
@@ -159,7 +160,6 @@ func TestGetNodeImageFromRelease(t *testing.T) {
 					},
 				},
 			},
-			flatcarChannel:     "stable",
 			expectedImageName:  "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
 			expectedProvider:   providerCapVCD,
 			expectedObjectName: "capvcd-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
@@ -179,7 +179,6 @@ func TestGetNodeImageFromRelease(t *testing.T) {
 					},
 				},
 			},
-			flatcarChannel:     "stable",
 			expectedImageName:  "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
 			expectedProvider:   providerCapMox,
 			expectedObjectName: "capmox-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
@@ -198,8 +197,7 @@ func TestGetNodeImageFromRelease(t *testing.T) {
 					},
 				},
 			},
-			flatcarChannel: "stable",
-			expectError:    true,
+			expectError: true,
 		},
 		{
 			name: "case 4: missing kubernetes component returns error",
@@ -214,19 +212,152 @@ func TestGetNodeImageFromRelease(t *testing.T) {
 					},
 				},
 			},
-			flatcarChannel: "stable",
-			expectError:    true,
+			expectError: true,
+		},
+		{
+			name: "case 5: ubuntu release creates node image without channel",
+			release: &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "vsphere-1.2.3",
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "ubuntu", Version: "2404"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			},
+			expectedImageName:  "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+			expectedProvider:   providerCapV,
+			expectedObjectName: "capv-ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+			expectError:        false,
+		},
+		{
+			name: "case 6: ubuntu release ignores the flatcar channel",
+			release: &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "proxmox-1.2.3",
+					Annotations: map[string]string{FlatcarChannelAnnotation: "beta"},
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "ubuntu", Version: "2404"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			},
+			expectedImageName:  "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+			expectedProvider:   providerCapMox,
+			expectedObjectName: "capmox-ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+			expectError:        false,
+		},
+		{
+			name: "case 7: beta channel is read from the release annotation",
+			release: &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "vsphere-1.2.3",
+					Annotations: map[string]string{FlatcarChannelAnnotation: "beta"},
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "flatcar", Version: "4459.1.0"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			},
+			expectedImageName:  "flatcar-beta-4459.1.0-kube-1.30.4-tooling-1.18.1-gs",
+			expectedProvider:   providerCapV,
+			expectedObjectName: "capv-flatcar-beta-4459.1.0-kube-1.30.4-tooling-1.18.1-gs",
+			expectError:        false,
+		},
+		{
+			name: "case 8: alpha channel is read from the release annotation",
+			release: &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cloud-director-0.10.5",
+					Annotations: map[string]string{FlatcarChannelAnnotation: "alpha"},
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "flatcar", Version: "4487.0.0"},
+						{Name: "kubernetes", Version: "v1.31.1"},
+						{Name: "os-tooling", Version: "v1.19.0"},
+					},
+				},
+			},
+			expectedImageName:  "flatcar-alpha-4487.0.0-kube-1.31.1-tooling-1.19.0-gs",
+			expectedProvider:   providerCapVCD,
+			expectedObjectName: "capvcd-flatcar-alpha-4487.0.0-kube-1.31.1-tooling-1.19.0-gs",
+			expectError:        false,
+		},
+		{
+			name: "case 9: empty channel annotation falls back to stable",
+			release: &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "proxmox-1.2.3",
+					Annotations: map[string]string{FlatcarChannelAnnotation: ""},
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "flatcar", Version: "3975.2.0"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			},
+			expectedImageName:  "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			expectedProvider:   providerCapMox,
+			expectedObjectName: "capmox-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			expectError:        false,
+		},
+		{
+			name: "case 10: unknown channel returns error",
+			release: &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "vsphere-1.2.3",
+					Annotations: map[string]string{FlatcarChannelAnnotation: "nightly"},
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "flatcar", Version: "3975.2.0"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "case 11: release name without a version returns error",
+			release: &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "vsphere-latest",
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "flatcar", Version: "3975.2.0"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			},
+			expectError: true,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			nodeImage, err := GetNodeImageFromRelease(tc.release, tc.flatcarChannel)
+			nodeImages, err := GetNodeImagesFromRelease(tc.release)
 
 			if tc.expectError {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrInvalidRelease)
 			} else {
-				assert.NoError(t, err)
+				require.NoError(t, err)
+				require.Len(t, nodeImages, 1)
+				nodeImage := nodeImages[0]
 				assert.Equal(t, tc.expectedImageName, nodeImage.Spec.Name)
 				assert.Equal(t, tc.expectedProvider, nodeImage.Spec.Provider)
 				assert.Equal(t, tc.expectedObjectName, nodeImage.Name)
@@ -235,6 +366,85 @@ func TestGetNodeImageFromRelease(t *testing.T) {
 	}
 }
 
+func TestGetNodeImagesFromReleaseArchitectures(t *testing.T) {
+	const name = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
+
+	testCases := []struct {
+		name                string
+		architectures       string
+		expectedImageNames  []string
+		expectedObjectNames []string
+		expectedKeys        []string
+		expectError         bool
+	}{
+		{
+			name:                "case 0: release without architectures has an amd64 image",
+			expectedImageNames:  []string{name},
+			expectedObjectNames: []string{"capv-" + name},
+			expectedKeys:        []string{"capv/" + name + "/flatcar-stable-3975.2.0-kube-v1.30.4.ova"},
+		},
+		{
+			name:                "case 1: release with two architectures has two images",
+			architectures:       "arm64, amd64",
+			expectedImageNames:  []string{name, name + "-arm64"},
+			expectedObjectNames: []string{"capv-" + name, "capv-" + name + "-arm64"},
+			expectedKeys: []string{
+				"capv/" + name + "/flatcar-stable-3975.2.0-kube-v1.30.4.ova",
+				"capv/" + name + "-arm64/flatcar-stable-3975.2.0-kube-v1.30.4-arm64.ova",
+			},
+		},
+		{
+			name:                "case 2: arm64 only release",
+			architectures:       "arm64,arm64",
+			expectedImageNames:  []string{name + "-arm64"},
+			expectedObjectNames: []string{"capv-" + name + "-arm64"},
+			expectedKeys:        []string{"capv/" + name + "-arm64/flatcar-stable-3975.2.0-kube-v1.30.4-arm64.ova"},
+		},
+		{
+			name:          "case 3: unknown architecture is rejected",
+			architectures: "amd64,riscv64",
+			expectError:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "vsphere-1.2.3",
+					Annotations: map[string]string{ArchitecturesAnnotation: tc.architectures},
+				},
+				Spec: releases.ReleaseSpec{
+					Components: []releases.ReleaseSpecComponent{
+						{Name: "flatcar", Version: "3975.2.0"},
+						{Name: "kubernetes", Version: "v1.30.4"},
+						{Name: "os-tooling", Version: "v1.18.1"},
+					},
+				},
+			}
+
+			nodeImages, err := GetNodeImagesFromRelease(release)
+			if tc.expectError {
+				assert.ErrorIs(t, err, ErrInvalidRelease)
+				return
+			}
+			require.NoError(t, err)
+
+			var imageNames, objectNames, keys []string
+			for _, nodeImage := range nodeImages {
+				imageNames = append(imageNames, nodeImage.Spec.Name)
+				objectNames = append(objectNames, nodeImage.Name)
+				key, err := GetImageKey(nodeImage)
+				require.NoError(t, err)
+				keys = append(keys, key)
+			}
+			assert.Equal(t, tc.expectedImageNames, imageNames)
+			assert.Equal(t, tc.expectedObjectNames, objectNames)
+			assert.Equal(t, tc.expectedKeys, keys)
+		})
+	}
+}
+
 func TestGetImageKey(t *testing.T) {
 	testCases := []struct {
 		name             string
@@ -260,7 +470,7 @@ func TestGetImageKey(t *testing.T) {
 					Provider: providerCapVCD,
 				},
 			},
-			expectedImageKey: "capv/flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs/" +
+			expectedImageKey: "capvcd/flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs/" +
 				"flatcar-stable-3975.2.0-kube-v1.30.4.ova",
 		},
 		{
@@ -285,19 +495,261 @@ func TestGetImageKey(t *testing.T) {
 			expectedImageKey: "capv/flatcar-stable-3975.2.0-kube-1.29.0-tooling-1.18.1-gs/" +
 				"flatcar-stable-3975.2.0-kube-v1.29.0.ova",
 		},
+		{
+			name: "case 4: ubuntu node image generates correct S3 key",
+			nodeImage: &images.NodeImage{
+				Spec: images.NodeImageSpec{
+					Name:     "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+					Provider: providerCapV,
+				},
+			},
+			expectedImageKey: "capv/ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs/" +
+				"ubuntu-2404-kube-v1.30.4.ova",
+		},
+		{
+			name: "case 5: cloud-director ubuntu node image is stored below its provider",
+			nodeImage: &images.NodeImage{
+				Spec: images.NodeImageSpec{
+					Name:     "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+					Provider: providerCapVCD,
+				},
+			},
+			expectedImageKey: "capvcd/ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs/" +
+				"ubuntu-2404-kube-v1.30.4.ova",
+		},
+		{
+			name: "case 6: name from a custom template is cut at its tooling version",
+			nodeImage: &images.NodeImage{
+				Spec: images.NodeImageSpec{
+					Name:     "flatcar-3975.2.0-kube-1.30.4-tooling-1.18.1",
+					Provider: providerCapV,
+				},
+			},
+			expectedImageKey: "capv/flatcar-3975.2.0-kube-1.30.4-tooling-1.18.1/" +
+				"flatcar-3975.2.0-kube-v1.30.4.ova",
+		},
+		{
+			name: "case 7: arm64 name from a custom template keeps its architecture",
+			nodeImage: &images.NodeImage{
+				Spec: images.NodeImageSpec{
+					Name:     "flatcar-3975.2.0-kube-1.30.4-tooling-1.18.1-arm64",
+					Provider: providerCapV,
+				},
+			},
+			expectedImageKey: "capv/flatcar-3975.2.0-kube-1.30.4-tooling-1.18.1-arm64/" +
+				"flatcar-3975.2.0-kube-v1.30.4-arm64.ova",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			imageKey := GetImageKey(tc.nodeImage)
+			imageKey, err := GetImageKey(tc.nodeImage)
+			require.NoError(t, err)
 			assert.Equal(t, tc.expectedImageKey, imageKey)
 		})
 	}
 }
 
+func TestImageKeyTemplate(t *testing.T) {
+	// the key layout of previous releases, storing capvcd images below capv/
+	legacyTemplate := `{{if eq .Provider "capvcd"}}capv{{else}}{{.Provider}}{{end}}/{{.Name}}/{{.File}}`
+
+	testCases := []struct {
+		name             string
+		keyTemplate      string
+		provider         string
+		expectedImageKey string
+		expectedError    bool
+	}{
+		{
+			name:             "case 0: default template uses the provider as prefix",
+			provider:         providerCapVCD,
+			expectedImageKey: "capvcd/flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs/flatcar-stable-3975.2.0-kube-v1.30.4.ova",
+		},
+		{
+			name:             "case 1: legacy template stores capvcd images below capv",
+			keyTemplate:      legacyTemplate,
+			provider:         providerCapVCD,
+			expectedImageKey: "capv/flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs/flatcar-stable-3975.2.0-kube-v1.30.4.ova",
+		},
+		{
+			name:             "case 2: legacy template keeps the capmox prefix",
+			keyTemplate:      legacyTemplate,
+			provider:         providerCapMox,
+			expectedImageKey: "capmox/flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs/flatcar-stable-3975.2.0-kube-v1.30.4.qcow2",
+		},
+		{
+			name:             "case 3: flat custom layout",
+			keyTemplate:      "images/{{.File}}",
+			provider:         providerCapV,
+			expectedImageKey: "images/flatcar-stable-3975.2.0-kube-v1.30.4.ova",
+		},
+		{
+			name:          "case 4: unknown field is rejected",
+			keyTemplate:   "{{.Bucket}}/{{.File}}",
+			expectedError: true,
+		},
+		{
+			name:          "case 5: empty key is rejected",
+			keyTemplate:   `{{if false}}{{.File}}{{end}}`,
+			expectedError: true,
+		},
+		{
+			name:          "case 6: template escaping the bucket root is rejected",
+			keyTemplate:   "../{{.Provider}}/{{.File}}",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyTemplate, err := ParseKeyTemplate(tc.keyTemplate)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			imageKey, err := ImageKey(keyTemplate, &images.NodeImage{
+				Spec: images.NodeImageSpec{
+					Name:     "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+					Provider: tc.provider,
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedImageKey, imageKey)
+		})
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	testCases := []struct {
+		name          string
+		key           string
+		expectedError bool
+	}{
+		{
+			name: "case 0: default key is valid",
+			key:  "capv/flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs/flatcar-stable-3975.2.0-kube-v1.30.4.ova",
+		},
+		{
+			name: "case 1: special characters are left for the URL to escape",
+			key:  "capv/image #1?/image 100%.ova",
+		},
+		{
+			name: "case 2: dots inside a segment are valid",
+			key:  "capv/..image../image.ova",
+		},
+		{
+			name:          "case 3: parent directory segment is rejected",
+			key:           "capv/../../etc/passwd",
+			expectedError: true,
+		},
+		{
+			name:          "case 4: trailing parent directory segment is rejected",
+			key:           "capv/image/..",
+			expectedError: true,
+		},
+		{
+			name:          "case 5: current directory segment is rejected",
+			key:           "capv/./image.ova",
+			expectedError: true,
+		},
+		{
+			name:          "case 6: absolute key is rejected",
+			key:           "/capv/image.ova",
+			expectedError: true,
+		},
+		{
+			name:          "case 7: empty segment is rejected",
+			key:           "capv//image.ova",
+			expectedError: true,
+		},
+		{
+			name:          "case 8: backslash traversal is rejected",
+			key:           `capv/..\..\image.ova`,
+			expectedError: true,
+		},
+		{
+			name:          "case 9: control character is rejected",
+			key:           "capv/image\n.ova",
+			expectedError: true,
+		},
+		{
+			name:          "case 10: invalid UTF-8 is rejected",
+			key:           "capv/image\xff.ova",
+			expectedError: true,
+		},
+		{
+			name:          "case 11: key longer than 1024 bytes is rejected",
+			key:           "capv/" + strings.Repeat("a", 1024) + ".ova",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateKey(tc.key)
+			if tc.expectedError {
+				assert.ErrorIs(t, err, ErrInvalidImageKey)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGetImageKeyAdversarialName(t *testing.T) {
+	testCases := []struct {
+		name          string
+		imageName     string
+		expectedError bool
+	}{
+		{
+			name:      "case 0: name with spaces is kept in the key",
+			imageName: "custom image",
+		},
+		{
+			name:          "case 1: parent directory name is rejected",
+			imageName:     "..",
+			expectedError: true,
+		},
+		{
+			name:          "case 2: name traversing out of the provider directory is rejected",
+			imageName:     "../../other-bucket/image",
+			expectedError: true,
+		},
+		{
+			name:          "case 3: absolute name is rejected",
+			imageName:     "/etc/passwd",
+			expectedError: true,
+		},
+		{
+			name:          "case 4: name with a newline is rejected",
+			imageName:     "image\nkey",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			imageKey, err := GetImageKey(&images.NodeImage{
+				Spec: images.NodeImageSpec{Name: tc.imageName, Provider: providerCapV},
+			})
+			if tc.expectedError {
+				assert.ErrorIs(t, err, ErrInvalidImageKey)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(imageKey, "capv/"+tc.imageName+"/"), imageKey)
+		})
+	}
+}
+
 func TestBuildImageName(t *testing.T) {
 	testCases := []struct {
 		name              string
+		nameTemplate      string
 		flatcarChannel    string
 		flatcarVersion    string
 		kubernetesVersion string
@@ -328,12 +780,396 @@ func TestBuildImageName(t *testing.T) {
 			toolingVersion:    "v1.18.1",
 			expectedName:      "flatcar-beta-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
 		},
+		{
+			name:              "case 3: custom template without channel and tooling",
+			nameTemplate:      "k8s-{{.KubernetesVersion}}-flatcar-{{.FlatcarVersion}}",
+			flatcarChannel:    "stable",
+			flatcarVersion:    "3975.2.0",
+			kubernetesVersion: "v1.30.4",
+			toolingVersion:    "v1.18.1",
+			expectedName:      "k8s-1.30.4-flatcar-3975.2.0",
+		},
+		{
+			name:              "case 4: custom template using template functions",
+			nameTemplate:      `{{printf "%s_%s" .Channel .FlatcarVersion}}-kube-v{{.KubernetesVersion}}`,
+			flatcarChannel:    "beta",
+			flatcarVersion:    "4459.2.4",
+			kubernetesVersion: "1.34.5",
+			toolingVersion:    "1.27.0",
+			expectedName:      "beta_4459.2.4-kube-v1.34.5",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			imageName := buildImageName(tc.flatcarChannel, tc.flatcarVersion, tc.kubernetesVersion, tc.toolingVersion)
+			nameTemplate, err := ParseNameTemplate(tc.nameTemplate)
+			assert.NoError(t, err)
+
+			imageName, err := buildImageName(nameTemplate, tc.flatcarChannel, tc.flatcarVersion, tc.kubernetesVersion, tc.toolingVersion)
+			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedName, imageName)
 		})
 	}
 }
+
+func TestParseNameTemplate(t *testing.T) {
+	testCases := []struct {
+		name         string
+		nameTemplate string
+		expectError  bool
+	}{
+		{
+			name:         "case 0: empty template falls back to the default",
+			nameTemplate: "",
+		},
+		{
+			name:         "case 1: default template is valid",
+			nameTemplate: DefaultNameTemplate,
+		},
+		{
+			name:         "case 2: unknown field is rejected",
+			nameTemplate: "flatcar-{{.Version}}",
+			expectError:  true,
+		},
+		{
+			name:         "case 3: syntax error is rejected",
+			nameTemplate: "flatcar-{{.FlatcarVersion",
+			expectError:  true,
+		},
+		{
+			name:         "case 4: template rendering an empty name is rejected",
+			nameTemplate: "{{if false}}{{.Channel}}{{end}}",
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseNameTemplate(tc.nameTemplate)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetImageOS(t *testing.T) {
+	testCases := []struct {
+		name            string
+		components      []releases.ReleaseSpecComponent
+		expectedOS      string
+		expectedVersion string
+		expectError     bool
+	}{
+		{
+			name: "case 0: flatcar release",
+			components: []releases.ReleaseSpecComponent{
+				{Name: "flatcar", Version: "3975.2.0"},
+				{Name: "kubernetes", Version: "v1.30.4"},
+			},
+			expectedOS:      OSFlatcar,
+			expectedVersion: "3975.2.0",
+		},
+		{
+			name: "case 1: ubuntu release",
+			components: []releases.ReleaseSpecComponent{
+				{Name: "kubernetes", Version: "v1.30.4"},
+				{Name: "ubuntu", Version: "2404"},
+			},
+			expectedOS:      OSUbuntu,
+			expectedVersion: "2404",
+		},
+		{
+			name: "case 2: release without OS component returns error",
+			components: []releases.ReleaseSpecComponent{
+				{Name: "kubernetes", Version: "v1.30.4"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := &releases.Release{
+				ObjectMeta: metav1.ObjectMeta{Name: "vsphere-1.2.3"},
+				Spec:       releases.ReleaseSpec{Components: tc.components},
+			}
+
+			os, err := GetImageOS(release)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedOS, os.Name)
+			assert.Equal(t, tc.expectedVersion, os.Version)
+		})
+	}
+}
+
+func TestBuildUbuntuImageName(t *testing.T) {
+	assert.Equal(t,
+		"ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+		buildUbuntuImageName("2404", "v1.30.4", "v1.18.1"),
+	)
+}
+
+func TestFitName(t *testing.T) {
+	longName := "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-with-a-very-long-custom-naming-scheme"
+
+	testCases := []struct {
+		name         string
+		imageName    string
+		maxLength    int
+		truncate     bool
+		expectedName string
+		expectError  bool
+	}{
+		{
+			name:         "case 0: name within the limit is unchanged",
+			imageName:    "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			maxLength:    80,
+			expectedName: "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+		},
+		{
+			name:         "case 1: no limit",
+			imageName:    longName,
+			maxLength:    0,
+			expectedName: longName,
+		},
+		{
+			name:        "case 2: name exceeding the limit is rejected",
+			imageName:   longName,
+			maxLength:   80,
+			expectError: true,
+		},
+		{
+			name:         "case 3: name exceeding the limit is truncated with a hash",
+			imageName:    longName,
+			maxLength:    80,
+			truncate:     true,
+			expectedName: TruncateName(longName, 80),
+		},
+		{
+			name:        "case 4: limit too small to keep the hash",
+			imageName:   longName,
+			maxLength:   9,
+			truncate:    true,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, err := FitName(tc.imageName, tc.maxLength, tc.truncate)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedName, name)
+			if tc.maxLength > 0 {
+				assert.LessOrEqual(t, len(name), tc.maxLength)
+			}
+		})
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	a := "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-suffix-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	b := "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-suffix-bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+
+	truncated := TruncateName(a, 64)
+	assert.LessOrEqual(t, len(truncated), 64)
+	assert.True(t, strings.HasPrefix(truncated, "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-"))
+	assert.Regexp(t, `-[0-9a-f]{8}$`, truncated)
+
+	// deterministic
+	assert.Equal(t, truncated, TruncateName(a, 64))
+	// names sharing the truncated prefix stay unique
+	assert.NotEqual(t, truncated, TruncateName(b, 64))
+}
+
+func TestParseImageName(t *testing.T) {
+	testCases := []struct {
+		name       string
+		imageName  string
+		expected   NameComponents
+		expectedOK bool
+	}{
+		{
+			name:      "case 0: flatcar image",
+			imageName: "flatcar-beta-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			expected: NameComponents{
+				OS:                OSFlatcar,
+				OSVersion:         "3975.2.0",
+				Channel:           "beta",
+				KubernetesVersion: "1.30.4",
+				ToolingVersion:    "1.18.1",
+				Architecture:      DefaultArchitecture,
+			},
+			expectedOK: true,
+		},
+		{
+			name:      "case 1: ubuntu image",
+			imageName: "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+			expected: NameComponents{
+				OS:                OSUbuntu,
+				OSVersion:         "2404",
+				KubernetesVersion: "1.30.4",
+				ToolingVersion:    "1.18.1",
+				Architecture:      DefaultArchitecture,
+			},
+			expectedOK: true,
+		},
+		{
+			name:      "case 2: custom name",
+			imageName: "gs-flatcar-3975.2.0-k8s-1.30.4",
+		},
+		{
+			name:      "case 3: arm64 image",
+			imageName: "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs-arm64",
+			expected: NameComponents{
+				OS:                OSFlatcar,
+				OSVersion:         "3975.2.0",
+				Channel:           "stable",
+				KubernetesVersion: "1.30.4",
+				ToolingVersion:    "1.18.1",
+				Architecture:      "arm64",
+			},
+			expectedOK: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			components, ok := ParseImageName(tc.imageName)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expected, components)
+		})
+	}
+}
+
+func TestNodeImageName(t *testing.T) {
+	testCases := []struct {
+		name          string
+		providerName  string
+		imageName     string
+		policy        NameCollisionPolicy
+		expectedName  string
+		expectedHash  bool
+		expectedError bool
+	}{
+		{
+			name:         "case 0: plain name",
+			providerName: "capv",
+			imageName:    "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			policy:       NameCollisionHash,
+			expectedName: "capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+		},
+		{
+			name:         "case 1: image name starting with the provider stays plain",
+			providerName: "capv",
+			imageName:    "capv-flatcar-stable-3975.2.0",
+			policy:       NameCollisionReject,
+			expectedName: "capv-capv-flatcar-stable-3975.2.0",
+		},
+		{
+			name:         "case 2: provider with a dash is hashed",
+			providerName: "foo-bar",
+			imageName:    "baz",
+			policy:       NameCollisionHash,
+			expectedHash: true,
+		},
+		{
+			name:          "case 3: provider with a dash is rejected",
+			providerName:  "foo-bar",
+			imageName:     "baz",
+			policy:        NameCollisionReject,
+			expectedError: true,
+		},
+		{
+			name:         "case 4: invalid characters are hashed",
+			providerName: "capv",
+			imageName:    "Flatcar_Stable",
+			policy:       NameCollisionHash,
+			expectedHash: true,
+		},
+		{
+			name:          "case 5: invalid characters are rejected",
+			providerName:  "capv",
+			imageName:     "Flatcar_Stable",
+			policy:        NameCollisionReject,
+			expectedError: true,
+		},
+		{
+			name:         "case 6: too long name is hashed",
+			providerName: "capv",
+			imageName:    strings.Repeat("a", 300),
+			policy:       NameCollisionHash,
+			expectedHash: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, err := NodeImageName(tc.providerName, tc.imageName, tc.policy)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, validation.IsDNS1123Subdomain(name))
+			if tc.expectedHash {
+				assert.Regexp(t, `-[0-9a-f]{8}$`, name)
+				return
+			}
+			assert.Equal(t, tc.expectedName, name)
+		})
+	}
+}
+
+func TestNodeImageNameCollisions(t *testing.T) {
+	inputs := [][2]string{
+		{"foo", "bar-baz"},
+		{"foo-bar", "baz"},
+		{"foo-bar-baz", ""},
+		{"capv", "cd-flatcar"},
+		{"capvcd", "flatcar"},
+		{"capv", "flatcar"},
+		{"capv", "capv-flatcar"},
+		{"capv-capv", "flatcar"},
+		{"capv", "Flatcar"},
+		{"capv", "flatcar_"},
+	}
+
+	seen := map[string][2]string{}
+	for _, input := range inputs {
+		name, err := NodeImageName(input[0], input[1], NameCollisionHash)
+		assert.NoError(t, err)
+		assert.Empty(t, validation.IsDNS1123Subdomain(name), name)
+		if other, ok := seen[name]; ok {
+			t.Errorf("%v and %v both map to %s", other, input, name)
+		}
+		seen[name] = input
+
+		// deterministic
+		again, _ := NodeImageName(input[0], input[1], NameCollisionHash)
+		assert.Equal(t, name, again)
+	}
+}
+
+func TestParseNameCollisionPolicy(t *testing.T) {
+	policy, err := ParseNameCollisionPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, NameCollisionHash, policy)
+
+	policy, err = ParseNameCollisionPolicy("reject")
+	assert.NoError(t, err)
+	assert.Equal(t, NameCollisionReject, policy)
+
+	_, err = ParseNameCollisionPolicy("ignore")
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package image
+
+import (
+	"sort"
+	"time"
+
+	images "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+// LastUsed returns when the last release stopped using the node image, or
+// when it was created if that is unknown
+func LastUsed(object *images.NodeImage) time.Time {
+	if lastUsed, err := time.Parse(time.RFC3339, object.Annotations[LastUsedAnnotation]); err == nil {
+		return lastUsed
+	}
+	return object.CreationTimestamp.Time
+}
+
+// ExcessUnusedImages returns the node images awaiting deletion beyond the
+// keep most recently used ones of every provider. Node images that releases
+// use are never returned.
+func ExcessUnusedImages(objects []images.NodeImage, keep int) []*images.NodeImage {
+	unused := map[string][]*images.NodeImage{}
+	for i := range objects {
+		object := &objects[i]
+		if len(object.Status.Releases) > 0 || object.Status.State != images.NodeImageAwaitingDeletion || !object.DeletionTimestamp.IsZero() {
+			continue
+		}
+		unused[object.Spec.Provider] = append(unused[object.Spec.Provider], object)
+	}
+
+	var excess []*images.NodeImage
+	for _, objects := range unused {
+		if len(objects) <= keep {
+			continue
+		}
+		sort.SliceStable(objects, func(i, j int) bool {
+			return LastUsed(objects[i]).After(LastUsed(objects[j]))
+		})
+		excess = append(excess, objects[keep:]...)
+	}
+	sort.Slice(excess, func(i, j int) bool { return excess[i].Name < excess[j].Name })
+	return excess
+}
@@ -0,0 +1,95 @@
+package image
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	images "github.com/giantswarm/image-distribution-operator/api/image/v1alpha1"
+)
+
+func TestExcessUnusedImages(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	nodeImage := func(name string, provider string, lastUsed time.Duration, releases ...string) images.NodeImage {
+		object := images.NodeImage{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Hour * 24 * 365)),
+			},
+			Spec:   images.NodeImageSpec{Provider: provider},
+			Status: images.NodeImageStatus{Releases: releases, State: images.NodeImageAvailable},
+		}
+		if len(releases) == 0 {
+			object.Status.State = images.NodeImageAwaitingDeletion
+			object.Annotations = map[string]string{LastUsedAnnotation: now.Add(-lastUsed).Format(time.RFC3339)}
+		}
+		return object
+	}
+
+	testCases := []struct {
+		name       string
+		nodeImages []images.NodeImage
+		keep       int
+		expected   []string
+	}{
+		{
+			name: "case 0: the most recently used images are kept",
+			nodeImages: []images.NodeImage{
+				nodeImage("capv-a", "capv", 3*time.Hour),
+				nodeImage("capv-b", "capv", time.Hour),
+				nodeImage("capv-c", "capv", 2*time.Hour),
+			},
+			keep:     1,
+			expected: []string{"capv-a", "capv-c"},
+		},
+		{
+			name: "case 1: images are kept per provider",
+			nodeImages: []images.NodeImage{
+				nodeImage("capv-a", "capv", 3*time.Hour),
+				nodeImage("capv-b", "capv", time.Hour),
+				nodeImage("capvcd-a", "capvcd", 3*time.Hour),
+			},
+			keep:     1,
+			expected: []string{"capv-a"},
+		},
+		{
+			name: "case 2: images used by releases are never returned",
+			nodeImages: []images.NodeImage{
+				nodeImage("capv-a", "capv", 0, "v30.0.0"),
+				nodeImage("capv-b", "capv", 0, "v31.0.0"),
+				nodeImage("capv-c", "capv", time.Hour),
+			},
+			keep:     0,
+			expected: []string{"capv-c"},
+		},
+		{
+			name: "case 3: nothing exceeds the limit",
+			nodeImages: []images.NodeImage{
+				nodeImage("capv-a", "capv", 3*time.Hour),
+				nodeImage("capv-b", "capv", time.Hour),
+			},
+			keep: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var names []string
+			for _, object := range ExcessUnusedImages(tc.nodeImages, tc.keep) {
+				names = append(names, object.Name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestLastUsed(t *testing.T) {
+	created := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	object := &images.NodeImage{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created}}
+	assert.Equal(t, created.Time, LastUsed(object))
+
+	object.Annotations = map[string]string{LastUsedAnnotation: "2025-06-01T12:00:00Z"}
+	assert.Equal(t, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), LastUsed(object))
+}
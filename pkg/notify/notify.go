@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Event describes a state transition of a node image
+type Event struct {
+	NodeImage     string `json:"nodeImage"`
+	Namespace     string `json:"namespace"`
+	Provider      string `json:"provider"`
+	Image         string `json:"image"`
+	State         string `json:"state"`
+	PreviousState string `json:"previousState"`
+	// Locations are the results of the distribution to the locations of the
+	// provider, for transitions made by a distribution
+	Locations []LocationResult `json:"locations,omitempty"`
+}
+
+// LocationResult is the result of the distribution of a node image to a
+// single location
+type LocationResult struct {
+	Location string `json:"location"`
+	// ImageName is the name of the image in the location, empty if it
+	// failed
+	ImageName string `json:"imageName,omitempty"`
+	State     string `json:"state"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Notifier sends events to an external system
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// WebhookConfig holds the configuration for the webhook notifier
+type WebhookConfig struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// Webhook posts events as JSON to a URL, e.g. a Slack or PagerDuty webhook
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a new Webhook notifier
+func NewWebhook(c WebhookConfig) (*Webhook, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Webhook{
+		url:    c.URL,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Notify posts the event to the webhook URL
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
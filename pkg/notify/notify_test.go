@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebhook(t *testing.T) {
+	_, err := NewWebhook(WebhookConfig{})
+	assert.Error(t, err)
+
+	w, err := NewWebhook(WebhookConfig{URL: "https://hooks.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultTimeout, w.client.Timeout)
+}
+
+func TestWebhookNotify(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		expectError bool
+	}{
+		{
+			name:   "case 0: event is posted as json",
+			status: http.StatusOK,
+		},
+		{
+			name:        "case 1: non-2xx response is an error",
+			status:      http.StatusInternalServerError,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received Event
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			w, err := NewWebhook(WebhookConfig{URL: server.URL})
+			require.NoError(t, err)
+
+			event := Event{
+				NodeImage:     "capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+				Namespace:     "giantswarm",
+				Provider:      "capv",
+				Image:         "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+				State:         "Error",
+				PreviousState: "Uploading",
+			}
+			err = w.Notify(context.Background(), event)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, event, received)
+		})
+	}
+}
+
+func TestWebhookPayload(t *testing.T) {
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+
+	w, err := NewWebhook(WebhookConfig{URL: server.URL})
+	require.NoError(t, err)
+
+	err = w.Notify(context.Background(), Event{
+		NodeImage:     "capv-test-image",
+		Namespace:     "giantswarm",
+		Provider:      "capv",
+		Image:         "test-image",
+		State:         "Error",
+		PreviousState: "Uploading",
+		Locations: []LocationResult{
+			{Location: "dc1", ImageName: "test-image", State: "Available"},
+			{Location: "dc2", State: "Error", Error: "failed to import image: boom"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"nodeImage":     "capv-test-image",
+		"namespace":     "giantswarm",
+		"provider":      "capv",
+		"image":         "test-image",
+		"state":         "Error",
+		"previousState": "Uploading",
+		"locations": []any{
+			map[string]any{"location": "dc1", "imageName": "test-image", "state": "Available"},
+			map[string]any{"location": "dc2", "state": "Error", "error": "failed to import image: boom"},
+		},
+	}, received)
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultQueueSize is the number of events a Queue buffers
+const DefaultQueueSize = 100
+
+// ErrQueueFull is returned for an event that does not fit into the queue
+var ErrQueueFull = errors.New("notification queue is full")
+
+// Queue sends events to a Notifier in the background, so a slow endpoint
+// doesn't hold up the reconcile that notifies. Events arriving while the
+// queue is full are dropped.
+type Queue struct {
+	notifier Notifier
+	events   chan queuedEvent
+}
+
+// queuedEvent is an event waiting to be sent, with the logger of the
+// context it was queued from
+type queuedEvent struct {
+	event Event
+	log   logr.Logger
+}
+
+// NewQueue creates a Queue sending to notifier that buffers up to size
+// events, DefaultQueueSize if size is not positive
+func NewQueue(notifier Notifier, size int) *Queue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	return &Queue{
+		notifier: notifier,
+		events:   make(chan queuedEvent, size),
+	}
+}
+
+// Notify queues the event to be sent by Start
+func (q *Queue) Notify(ctx context.Context, event Event) error {
+	select {
+	case q.events <- queuedEvent{event: event, log: log.FromContext(ctx)}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Start sends the queued events one after the other until ctx is cancelled,
+// logging the ones that fail. It implements manager.Runnable.
+func (q *Queue) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case queued := <-q.events:
+			if err := q.notifier.Notify(ctx, queued.event); err != nil {
+				queued.log.Error(err, "Failed to send notification", "state", queued.event.State)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingNotifier records the events it is sent, each of them only once
+// release is closed
+type blockingNotifier struct {
+	release chan struct{}
+	sent    chan Event
+}
+
+func (n *blockingNotifier) Notify(ctx context.Context, event Event) error {
+	select {
+	case <-n.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	n.sent <- event
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	notifier := &blockingNotifier{release: make(chan struct{}), sent: make(chan Event, 3)}
+	q := NewQueue(notifier, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// queueing doesn't wait for the notifier, and the events beyond the
+	// queue size are dropped
+	require.NoError(t, q.Notify(ctx, Event{NodeImage: "first"}))
+	require.NoError(t, q.Notify(ctx, Event{NodeImage: "second"}))
+	assert.ErrorIs(t, q.Notify(ctx, Event{NodeImage: "third"}), ErrQueueFull)
+
+	done := make(chan error)
+	go func() { done <- q.Start(ctx) }()
+	close(notifier.release)
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case event := <-notifier.sent:
+			assert.Equal(t, expected, event.NodeImage)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("event %s not sent", expected)
+		}
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
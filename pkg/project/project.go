@@ -0,0 +1,30 @@
+// Package project holds the name and version of the operator, set at build
+// time through -ldflags.
+package project
+
+var (
+	buildTimestamp = "n/a"
+	gitSHA         = "n/a"
+	name           = "image-distribution-operator"
+	version        = "0.13.1-dev"
+)
+
+// BuildTimestamp returns when the binary was built
+func BuildTimestamp() string {
+	return buildTimestamp
+}
+
+// GitSHA returns the commit the binary was built from
+func GitSHA() string {
+	return gitSHA
+}
+
+// Name returns the name of the operator
+func Name() string {
+	return name
+}
+
+// Version returns the version of the operator
+func Version() string {
+	return version
+}
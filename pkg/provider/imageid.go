@@ -0,0 +1,39 @@
+package provider
+
+import "context"
+
+// ImageIDFunc receives the ID the provider gave an image, e.g. the managed
+// object ID of a vSphere template
+type ImageIDFunc func(id string)
+
+type (
+	imageIDReportKey struct{}
+	imageIDKey       struct{}
+)
+
+// WithImageIDReport returns a context that makes providers supporting it
+// pass the ID of the image to fn when Create created it or Exists found it
+func WithImageIDReport(ctx context.Context, fn ImageIDFunc) context.Context {
+	return context.WithValue(ctx, imageIDReportKey{}, fn)
+}
+
+// ReportImageID passes the ID of an image to the ImageIDFunc of the context,
+// if there is one
+func ReportImageID(ctx context.Context, id string) {
+	if fn, ok := ctx.Value(imageIDReportKey{}).(ImageIDFunc); ok && fn != nil && id != "" {
+		fn(id)
+	}
+}
+
+// WithImageID returns a context that lets providers supporting it look the
+// image up by the ID it was reported with instead of its name. Providers fall
+// back to the name if the ID no longer refers to the image.
+func WithImageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, imageIDKey{}, id)
+}
+
+// ImageIDFrom returns the image ID of the context, empty if there is none
+func ImageIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(imageIDKey{}).(string)
+	return id
+}
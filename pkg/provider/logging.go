@@ -0,0 +1,23 @@
+package provider
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Keys of the log fields identifying what an operation works on. The
+// controllers add them to the logger of the context, so providers and the S3
+// client logging with log.FromContext carry them without passing them on.
+const (
+	LogKeyNodeImage = "nodeImage"
+	LogKeyProvider  = "provider"
+	LogKeyLocation  = "location"
+	LogKeyRelease   = "release"
+)
+
+// WithLogValues returns a context whose logger carries the key value pairs
+// in addition to those of the logger of ctx
+func WithLogValues(ctx context.Context, keysAndValues ...any) context.Context {
+	return log.IntoContext(ctx, log.FromContext(ctx).WithValues(keysAndValues...))
+}
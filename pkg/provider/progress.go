@@ -0,0 +1,23 @@
+package provider
+
+import "context"
+
+// ProgressFunc receives the progress of an image transfer in Create: the
+// bytes transferred so far and the size of the image, or 0 if it is unknown
+type ProgressFunc func(transferred int64, total int64)
+
+type progressKey struct{}
+
+// WithProgress returns a context that makes providers supporting progress
+// reporting pass the progress of Create to fn
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress passes the progress of a transfer to the ProgressFunc of the
+// context, if there is one
+func ReportProgress(ctx context.Context, transferred int64, total int64) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(transferred, total)
+	}
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"context"
+	"time"
+)
+
+// Provenance describes where an image created by the operator comes from,
+// so providers can record it on the image for audits
+type Provenance struct {
+	// NodeImage is the name of the NodeImage the image was created for
+	NodeImage string
+	// Image is the name of the image
+	Image string
+	// Releases are the releases referencing the image when it was created
+	Releases []string
+	// OperatorVersion is the version of the operator that created the image
+	OperatorVersion string
+	// Created is when the image was created
+	Created time.Time
+}
+
+type provenanceKey struct{}
+
+// WithProvenance returns a context that makes providers supporting it record
+// p on the image created by Create. Delete is given the provenance of the
+// image it deletes, to find copies of it recorded under other names.
+func WithProvenance(ctx context.Context, p Provenance) context.Context {
+	return context.WithValue(ctx, provenanceKey{}, p)
+}
+
+// ProvenanceFrom returns the provenance of the image created or deleted with
+// the context, if there is one
+func ProvenanceFrom(ctx context.Context) (Provenance, bool) {
+	p, ok := ctx.Value(provenanceKey{}).(Provenance)
+	return p, ok
+}
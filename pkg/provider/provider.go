@@ -2,6 +2,14 @@ package provider
 
 import "context"
 
+// Names of the providers, matching NodeImage.Spec.Provider. They are the keys
+// of the provider registry built at startup.
+const (
+	VSphere       = "capv"
+	CloudDirector = "capvcd"
+	Proxmox       = "capmox"
+)
+
 // Provider defines the interface for image distribution providers
 type Provider interface {
 	// Exists checks if an image already exists in the provider's catalog
@@ -15,6 +23,12 @@ type Provider interface {
 	// loc: the location identifier within the provider
 	Create(ctx context.Context, imageURL string, imageName string, loc string) error
 
+	// Process runs the provider's post-upload steps on a created image, e.g.
+	// marking it as a template. It is called after Create succeeded.
+	// name: the image name
+	// loc: the location identifier within the provider
+	Process(ctx context.Context, name string, loc string) error
+
 	// Delete removes an image from the provider's catalog
 	// name: the image name to delete
 	// loc: the location identifier within the provider
@@ -23,3 +37,61 @@ type Provider interface {
 	// GetLocations returns a map of all configured locations for this provider
 	GetLocations() map[string]interface{}
 }
+
+// NameLimiter is implemented by providers that limit the length of image names
+type NameLimiter interface {
+	// MaxNameLength returns the maximum length of an image name passed to
+	// Exists, Create and Delete for the location, or 0 if there is no limit
+	MaxNameLength(loc string) int
+}
+
+// ImageNamer is implemented by providers that name images differently per
+// location, e.g. by appending a suffix
+type ImageNamer interface {
+	// ImageName returns the name an image passed to Create as name has in
+	// the location
+	ImageName(name string, loc string) string
+}
+
+// Lister is implemented by providers that can list the images in a location
+type Lister interface {
+	// List returns the names of the images in the location following the
+	// operator's naming convention, as they are passed to Exists and Delete.
+	// Anything else in the location is left out.
+	List(ctx context.Context, loc string) ([]string, error)
+}
+
+// ImageSourcer is implemented by providers whose locations can import images
+// from an S3 bucket of their own, e.g. a copy in a bucket close to the location
+type ImageSourcer interface {
+	// ImageSource returns the S3 bucket and region images are imported into
+	// the location from. An empty bucket means the operator's bucket, an
+	// empty region the operator's region.
+	ImageSource(loc string) (bucket string, region string)
+}
+
+// ReadinessChecker is implemented by providers whose images need processing
+// after Create returns before they can be used
+type ReadinessChecker interface {
+	// Ready reports whether the image exists and is ready to be used
+	Ready(ctx context.Context, name string, loc string) (bool, error)
+}
+
+// LocationReloader is implemented by providers whose locations can be
+// replaced while the operator runs, e.g. when the ConfigMap they are loaded
+// from changes
+type LocationReloader interface {
+	// ReloadLocations replaces the locations with the ones in data, the
+	// content of a locations file. Invalid locations are rejected and the
+	// current ones are kept.
+	ReloadLocations(ctx context.Context, data []byte) error
+}
+
+// ConfigCheck is the outcome of a single check of the configuration of a
+// provider, e.g. that its credentials file can be loaded
+type ConfigCheck struct {
+	// Name describes what was checked
+	Name string
+	// Err is why the check failed, nil if it passed
+	Err error
+}
@@ -0,0 +1,23 @@
+package provider
+
+import "context"
+
+// TaskFunc receives the reference of the provider task that Create started
+// for the transfer of an image, e.g. the URL of a Cloud Director task
+type TaskFunc func(ref string)
+
+type taskKey struct{}
+
+// WithTask returns a context that makes providers running the transfer in
+// Create as a task pass the reference of the task to fn
+func WithTask(ctx context.Context, fn TaskFunc) context.Context {
+	return context.WithValue(ctx, taskKey{}, fn)
+}
+
+// ReportTask passes the reference of a task to the TaskFunc of the context,
+// if there is one
+func ReportTask(ctx context.Context, ref string) {
+	if fn, ok := ctx.Value(taskKey{}).(TaskFunc); ok && fn != nil {
+		fn(ref)
+	}
+}
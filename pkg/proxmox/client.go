@@ -23,6 +23,7 @@ type Client struct {
 	authHeader string
 	httpClient *http.Client
 	locations  map[string]*Location
+	dryRun     bool
 }
 
 // Credentials holds the authentication details for the Proxmox API
@@ -48,6 +49,8 @@ type Config struct {
 	Backoff         wait.Backoff
 	CredentialsFile string
 	LocationsFile   string
+	// DryRun makes Create and Delete only log the intended action
+	DryRun bool
 }
 
 const defaultImportStorage = "local"
@@ -115,6 +118,7 @@ func New(c Config, ctx context.Context) (*Client, error) {
 		authHeader: authHeader,
 		httpClient: httpClient,
 		locations:  locations,
+		dryRun:     c.DryRun,
 	}
 
 	var lastErr error
@@ -165,6 +169,11 @@ func (c *Client) Exists(ctx context.Context, name string, loc string) (bool, err
 func (c *Client) Delete(ctx context.Context, name string, loc string) error {
 	log := log.FromContext(ctx)
 
+	if c.dryRun {
+		log.Info("Dry run: would delete template", "name", name)
+		return nil
+	}
+
 	vmid, node, found, err := c.findVMByName(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to find template: %w", err)
@@ -198,9 +207,18 @@ func (c *Client) Delete(ctx context.Context, name string, loc string) error {
 
 // Create imports a qcow2 image and creates a VM template in Proxmox
 func (c *Client) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
+	if c.dryRun {
+		log.FromContext(ctx).Info("Dry run: would create template", "name", imageName, "url", imageURL)
+		return nil
+	}
 	return c.createTemplate(ctx, imageURL, imageName, loc)
 }
 
+// Process is a no-op, Create already converts the VM into a template
+func (c *Client) Process(ctx context.Context, name string, loc string) error {
+	return nil
+}
+
 // doRequest executes an HTTP request against the Proxmox API
 func (c *Client) doRequest(ctx context.Context, method, path string, params url.Values) ([]byte, error) {
 	fullURL := c.baseURL + path
@@ -33,12 +33,17 @@ func TestBuildTags(t *testing.T) {
 			expectedTags: "flatcar_4459.2.4;kubernetes_1.34.5;os-tooling_1.27.0;release-channel_beta",
 		},
 		{
-			name:         "case 2: invalid image name returns empty",
+			name:         "case 2: ubuntu image has no release channel",
+			imageName:    "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs",
+			expectedTags: "ubuntu_2404;kubernetes_1.30.4;os-tooling_1.18.1",
+		},
+		{
+			name:         "case 3: invalid image name returns empty",
 			imageName:    "not-a-valid-image-name",
 			expectedTags: "",
 		},
 		{
-			name:         "case 3: empty image name returns empty",
+			name:         "case 4: empty image name returns empty",
 			imageName:    "",
 			expectedTags: "",
 		},
@@ -540,6 +545,42 @@ func TestDelete(t *testing.T) {
 	})
 }
 
+func TestDryRun(t *testing.T) {
+	var mutations atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			mutations.Add(1)
+		}
+		resp := map[string]interface{}{
+			"data": []map[string]interface{}{
+				{"vmid": 100, "name": "my-template", "node": "pve", "template": 1, "type": "qemu"},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := &Client{
+		baseURL:    server.URL + "/api2/json",
+		authHeader: "PVEAPIToken=test",
+		httpClient: server.Client(),
+		locations: map[string]*Location{
+			"dc1": {Node: "pve", StoragePool: "local-lvm", Bridge: "vmbr0", ImportStorage: defaultImportStorage},
+		},
+		dryRun: true,
+	}
+
+	exists, err := client.Exists(context.Background(), "my-template", "dc1")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.NoError(t, client.Create(context.Background(), "https://example.com/image.qcow2", "new-template", "dc1"))
+	assert.NoError(t, client.Delete(context.Background(), "my-template", "dc1"))
+	assert.Equal(t, int32(0), mutations.Load())
+}
+
 func TestGetLocations(t *testing.T) {
 	client := &Client{
 		locations: map[string]*Location{
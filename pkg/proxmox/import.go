@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
 
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
 )
 
 // createTemplate orchestrates the full Proxmox template creation procedure:
@@ -235,26 +236,21 @@ func (c *Client) cleanupImportFile(ctx context.Context, node string, filename st
 // buildTags constructs Proxmox-compatible tags from an image name.
 // Input:  "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
 // Output: "flatcar_3975.2.0;kubernetes_1.30.4;os-tooling_1.18.1;release-channel_stable"
+// Input:  "ubuntu-2404-kube-1.30.4-tooling-1.18.1-gs"
+// Output: "ubuntu_2404;kubernetes_1.30.4;os-tooling_1.18.1"
 func buildTags(imageName string) string {
-	re := regexp.MustCompile(
-		`^flatcar-([a-z]+)-([0-9.]+)-kube-([0-9.]+)-tooling-([0-9.]+)-gs$`,
-	)
-	matches := re.FindStringSubmatch(imageName)
-	if len(matches) != 5 {
+	components, ok := image.ParseImageName(imageName)
+	if !ok {
 		return ""
 	}
 
-	channel := matches[1]
-	flatcarVersion := matches[2]
-	kubeVersion := matches[3]
-	toolingVersion := matches[4]
-
 	tags := []string{
-		fmt.Sprintf("flatcar_%s", flatcarVersion),
-		fmt.Sprintf("kubernetes_%s", kubeVersion),
-		fmt.Sprintf("os-tooling_%s", toolingVersion),
-		fmt.Sprintf("release-channel_%s", channel),
+		fmt.Sprintf("%s_%s", components.OS, components.OSVersion),
+		fmt.Sprintf("kubernetes_%s", components.KubernetesVersion),
+		fmt.Sprintf("os-tooling_%s", components.ToolingVersion),
+	}
+	if components.Channel != "" {
+		tags = append(tags, fmt.Sprintf("release-channel_%s", components.Channel))
 	}
-
 	return strings.Join(tags, ";")
 }
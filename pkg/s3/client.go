@@ -2,17 +2,26 @@ package s3
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"net/http"
+	"net/url"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/time/rate"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+	"github.com/giantswarm/image-distribution-operator/pkg/storage"
 )
 
 // S3Client wraps the AWS SDK client
@@ -22,6 +31,15 @@ type Client struct {
 	bucketName string
 	region     string
 	timeout    time.Duration
+	directory  string
+	// fallbackDirectory is used if directory is not writable
+	fallbackDirectory string
+	// httpClient is used to look up the region of the bucket
+	httpClient *http.Client
+	// allowedHostPatterns are the hosts images may be imported from besides S3
+	allowedHostPatterns []string
+	// limiter bounds the bandwidth of pulls, unlimited if nil
+	limiter *rate.Limiter
 }
 
 type Config struct {
@@ -29,15 +47,49 @@ type Config struct {
 	BucketName string
 	Region     string
 	Timeout    time.Duration
+	// Directory is where pulled images are stored, defaults to Directory
+	Directory string
+	// FallbackDirectory is used if Directory is not writable, e.g. a read-only volume
+	FallbackDirectory string
+	// AllowedHostPatterns are shell patterns of the hosts, e.g.
+	// "*.cdn.example.com", images are imported from besides S3. Only S3
+	// URLs are valid if empty.
+	AllowedHostPatterns []string
+	// Proxy is the proxy S3 is reached through
+	Proxy download.Proxy
+	// Limiter bounds the bandwidth of pulls, shared with other transfers.
+	// Pulls are unlimited if nil.
+	Limiter *rate.Limiter
 }
 
 const (
 	Directory = "/tmp/images"
 )
 
+const (
+	// RegionMismatchFail makes a region mismatch fail the startup
+	RegionMismatchFail = "fail"
+	// RegionMismatchWarn only logs a region mismatch
+	RegionMismatchWarn = "warn"
+)
+
+// ErrRegionMismatch is returned by CheckRegion if the configured region does
+// not match the region requests are signed for or the bucket is in
+var ErrRegionMismatch = errors.New("S3 region mismatch")
+
+// bucketRegionHeader is set by S3 on every response to a bucket request,
+// including redirects and access denied responses
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
 // New initializes a new S3 client
 func New(c Config, ctx context.Context) (*Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.Region))
+	proxy := c.Proxy.ProxyFunc()
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(c.Region),
+		config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+			t.Proxy = proxy
+		})),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -48,17 +100,95 @@ func New(c Config, ctx context.Context) (*Client, error) {
 		protocol = "http"
 	}
 
+	directory := c.Directory
+	if directory == "" {
+		directory = Directory
+	}
+
+	if err := storage.CheckHostPatterns(c.AllowedHostPatterns); err != nil {
+		return nil, err
+	}
+
 	client := s3.NewFromConfig(cfg)
 	return &Client{
-		s3:         *client,
-		bucketName: c.BucketName,
-		timeout:    c.Timeout,
-		region:     c.Region,
-		protocol:   protocol,
+		s3:                  *client,
+		bucketName:          c.BucketName,
+		timeout:             c.Timeout,
+		region:              c.Region,
+		protocol:            protocol,
+		directory:           directory,
+		fallbackDirectory:   c.FallbackDirectory,
+		allowedHostPatterns: c.AllowedHostPatterns,
+		limiter:             c.Limiter,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: c.Proxy.Transport(),
+			// a bucket in another region answers with a redirect, which
+			// already carries the region header
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
 	}, nil
 }
 
-// Pull fetches an image from S3 and stores it locally
+// ParseRegionMismatchPolicy validates policy, falling back to RegionMismatchFail if empty
+func ParseRegionMismatchPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return RegionMismatchFail, nil
+	case RegionMismatchFail, RegionMismatchWarn:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown S3 region mismatch policy %q, expected %s or %s", policy, RegionMismatchFail, RegionMismatchWarn)
+}
+
+// CheckRegion verifies the configured region is consistent for both ways
+// images are fetched: the plain URLs returned by GetURL, which providers
+// download from, and requests signed by the SDK client, e.g. Pull. A region
+// differing from either the SDK client's or the bucket's is reported as
+// ErrRegionMismatch, other errors mean the bucket region could not be looked up.
+// Without a configured region there is nothing to check, so deployments
+// that never set one keep starting.
+func (c *Client) CheckRegion(ctx context.Context) error {
+	if c.region == "" {
+		return nil
+	}
+	if sdkRegion := c.s3.Options().Region; sdkRegion != c.region {
+		return fmt.Errorf("%w: requests are signed for region %s, but URLs are built for region %s", ErrRegionMismatch, sdkRegion, c.region)
+	}
+
+	bucketRegion, err := c.bucketRegion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to look up region of bucket %s: %w", c.bucketName, err)
+	}
+	if bucketRegion != c.region {
+		return fmt.Errorf("%w: bucket %s is in region %s, but region %s is configured", ErrRegionMismatch, c.bucketName, bucketRegion, c.region)
+	}
+	return nil
+}
+
+// bucketRegion returns the region of the bucket from the region header S3
+// sets on the response to a HEAD request of the bucket URL
+func (c *Client) bucketRegion(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.GetURL(""), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	region := resp.Header.Get(bucketRegionHeader)
+	if region == "" {
+		return "", fmt.Errorf("response with status %s has no %s header", resp.Status, bucketRegionHeader)
+	}
+	return region, nil
+}
+
+// Pull fetches an image from S3 and stores it locally. A pull that failed
+// midway is continued with a ranged request by the next one.
 func (c *Client) Pull(ctx context.Context, imageKey string) (string, error) {
 	log := log.FromContext(ctx)
 
@@ -68,12 +198,24 @@ func (c *Client) Pull(ctx context.Context, imageKey string) (string, error) {
 	childCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
+	// Ensure local directory exists and is writable
+	directory, err := download.Dir(ctx, c.directory, c.fallbackDirectory)
+	if err != nil {
+		return "", fmt.Errorf("failed to ensure local directory %s.\n%w", c.directory, err)
+	}
+
+	// Define local file path
+	localFilePath := filepath.Join(directory, filepath.Base(imageKey))
+
+	partial, err := download.OpenPartial(directory, download.PartialName(imageKey))
+	if err != nil {
+		return "", err
+	}
+
 	// Fetch image from S3
-	resp, err := c.s3.GetObject(childCtx, &s3.GetObjectInput{
-		Bucket: aws.String(c.bucketName),
-		Key:    aws.String(imageKey),
-	})
+	resp, total, err := c.getObject(childCtx, imageKey, partial)
 	if err != nil {
+		_ = partial.Close()
 		return "", fmt.Errorf("failed to pull image %s from S3 bucket %s.\n%w", imageKey, c.bucketName, err)
 	}
 	defer func() {
@@ -82,37 +224,132 @@ func (c *Client) Pull(ctx context.Context, imageKey string) (string, error) {
 		}
 	}()
 
-	// Ensure local directory exists
-	if err := os.MkdirAll(Directory, 0700); err != nil {
-		return "", fmt.Errorf("failed to ensure local directory %s.\n%w", Directory, err)
+	// Stream data from S3 to file, keeping the partial file on failure so
+	// the next pull continues it
+	if _, err := io.Copy(partial, download.LimitReader(childCtx, resp.Body, c.limiter)); err != nil {
+		if closeErr := partial.Close(); closeErr != nil {
+			log.Error(closeErr, "failed to close partial local file", "localFilePath", partial.Name())
+		}
+		return "", fmt.Errorf("failed to write S3 object to file %s.\n%w", partial.Name(), err)
+	}
+	if err := partial.Complete(total, localFilePath); err != nil {
+		return "", fmt.Errorf("failed to write S3 object to file %s.\n%w", localFilePath, err)
 	}
 
-	// Define local file path
-	localFilePath := filepath.Join(Directory, filepath.Base(imageKey))
+	log.Info("Completed download of image from S3", "imageKey", imageKey, "localFilePath", localFilePath, "resumedAt", partial.Offset)
+	return localFilePath, nil
+}
+
+// getObject requests the image from the end of the partial download,
+// restarting it if the partial download is beyond the end of the image or
+// the response is not ranged. It returns the response and the total size of
+// the image.
+func (c *Client) getObject(ctx context.Context, imageKey string, partial *download.Partial) (*s3.GetObjectOutput, int64, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(imageKey),
+	}
+	if partial.Offset > 0 {
+		input.Range = aws.String(download.RangeHeader(partial.Offset))
+	}
 
-	file, err := os.Create(localFilePath) //nolint:gosec
+	resp, err := c.s3.GetObject(ctx, input)
+	var respErr *awshttp.ResponseError
+	if err != nil && partial.Offset > 0 && errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusRequestedRangeNotSatisfiable {
+		if err := partial.Restart(); err != nil {
+			return nil, 0, err
+		}
+		input.Range = nil
+		resp, err = c.s3.GetObject(ctx, input)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to create local file %s.\n%w", localFilePath, err)
+		return nil, 0, err
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Error(err, "failed to close local file")
+
+	total := aws.ToInt64(resp.ContentLength)
+	if partial.Offset == 0 {
+		return resp, total, nil
+	}
+	if resp.ContentRange == nil {
+		// the whole object was returned
+		if err := partial.Restart(); err != nil {
+			_ = resp.Body.Close()
+			return nil, 0, err
 		}
-	}()
+		return resp, total, nil
+	}
 
-	// Stream data from S3 to file
-	_, err = io.Copy(file, resp.Body)
+	start, total, err := download.ParseContentRange(aws.ToString(resp.ContentRange))
+	if err == nil && start != partial.Offset {
+		err = fmt.Errorf("requested range from %d, got range from %d", partial.Offset, start)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to write S3 object to file%s.\n%w", localFilePath, err)
+		_ = resp.Body.Close()
+		return nil, 0, fmt.Errorf("failed to resume download: %w", err)
 	}
+	return resp, total, nil
+}
 
-	log.Info("Completed download of image from S3", "imageKey", imageKey, "localFilePath", localFilePath)
-	return localFilePath, nil
+var _ storage.ObjectStore = &Client{}
+
+// Exists checks with an authenticated request whether the image is in the
+// bucket, which the public URL can't tell for private buckets
+func (c *Client) Exists(ctx context.Context, imageKey string) (bool, error) {
+	return c.BucketExists(ctx, c.bucketName, "", imageKey)
+}
+
+// BucketExists checks whether the image is in another S3 bucket, in the
+// client's region if region is empty
+func (c *Client) BucketExists(ctx context.Context, bucket string, region string, imageKey string) (bool, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	_, err := c.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(imageKey),
+	}, func(o *s3.Options) {
+		if region != "" {
+			o.Region = region
+		}
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check image %s in S3 bucket %s: %w", imageKey, bucket, err)
+	}
+	return true, nil
+}
+
+// Presign returns a URL of the image signed with the client's credentials,
+// which can be downloaded from without credentials until it expires
+func (c *Client) Presign(ctx context.Context, imageKey string, expires time.Duration) (string, error) {
+	req, err := s3.NewPresignClient(&c.s3).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucketName),
+		Key:    aws.String(imageKey),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign image %s in S3 bucket %s: %w", imageKey, c.bucketName, err)
+	}
+	return req.URL, nil
 }
 
 // GetURL returns the URL of an image in S3
 func (c *Client) GetURL(imageKey string) string {
-	return fmt.Sprintf("%s://%s.s3.%s.amazonaws.com/%s", c.protocol, c.bucketName, c.region, imageKey)
+	return c.GetBucketURL(c.bucketName, c.region, imageKey)
+}
+
+// GetBucketURL returns the URL of an image in another S3 bucket, in the
+// client's region if region is empty
+func (c *Client) GetBucketURL(bucket string, region string, imageKey string) string {
+	if region == "" {
+		region = c.region
+	}
+	return fmt.Sprintf("%s://%s.s3.%s.amazonaws.com/%s", c.protocol, bucket, region, storage.EscapeKey(imageKey))
 }
 
 // IsS3URL checks if a URL is an S3 URL
@@ -122,14 +359,42 @@ func (c *Client) IsS3URL(url string) bool {
 	return regexp.MatchString(url)
 }
 
+// URLBucket returns the bucket of an S3 URL in a bucket other than the
+// client's, or "" for any other URL
+func (c *Client) URLBucket(rawURL string) string {
+	if !c.IsS3URL(rawURL) {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	bucket, _, _ := strings.Cut(u.Host, ".")
+	if bucket == c.bucketName {
+		return ""
+	}
+	return bucket
+}
+
+// IsAllowedURL checks if a URL is on a host matching one of the allowed host
+// patterns, using the protocol of the S3 URLs
+func (c *Client) IsAllowedURL(rawURL string) bool {
+	return storage.AllowedURL(rawURL, c.protocol, c.allowedHostPatterns)
+}
+
+// ValidURL returns an error if the URL is neither in an S3 bucket nor on an
+// allowed host
 func (c *Client) ValidURL(url string) error {
 	if url == "" {
 		return fmt.Errorf("URL is empty")
 	}
 
-	// Check that the URL is an s3 bucket
-	if !c.IsS3URL(url) {
+	// Check that the URL is an s3 bucket or on an allowed host
+	if c.IsS3URL(url) || c.IsAllowedURL(url) {
+		return nil
+	}
+	if len(c.allowedHostPatterns) == 0 {
 		return fmt.Errorf("URL is not an S3 bucket")
 	}
-	return nil
+	return fmt.Errorf("URL is neither an S3 bucket nor on a host matching %s", strings.Join(c.allowedHostPatterns, ", "))
 }
@@ -0,0 +1,430 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/download"
+)
+
+// roundTripFunc answers requests without any network access
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCheckRegion(t *testing.T) {
+	testCases := []struct {
+		name             string
+		region           string
+		sdkRegion        string
+		status           int
+		bucketRegion     string
+		requestErr       error
+		expectedRequest  string
+		expectedMismatch bool
+		expectedError    bool
+	}{
+		{
+			name:            "case 0: consistent region",
+			region:          "eu-west-1",
+			sdkRegion:       "eu-west-1",
+			status:          http.StatusOK,
+			bucketRegion:    "eu-west-1",
+			expectedRequest: "HEAD https://images.s3.eu-west-1.amazonaws.com/",
+		},
+		{
+			name:            "case 1: access denied still reports the bucket region",
+			region:          "eu-west-1",
+			sdkRegion:       "eu-west-1",
+			status:          http.StatusForbidden,
+			bucketRegion:    "eu-west-1",
+			expectedRequest: "HEAD https://images.s3.eu-west-1.amazonaws.com/",
+		},
+		{
+			name:             "case 2: bucket in another region",
+			region:           "eu-west-1",
+			sdkRegion:        "eu-west-1",
+			status:           http.StatusMovedPermanently,
+			bucketRegion:     "us-east-1",
+			expectedMismatch: true,
+			expectedError:    true,
+		},
+		{
+			name:             "case 3: signed requests use another region",
+			region:           "eu-west-1",
+			sdkRegion:        "eu-central-1",
+			expectedMismatch: true,
+			expectedError:    true,
+		},
+		{
+			name:      "case 4: no region configured is not checked",
+			sdkRegion: "eu-west-1",
+		},
+		{
+			name:          "case 5: missing region header",
+			region:        "eu-west-1",
+			sdkRegion:     "eu-west-1",
+			status:        http.StatusOK,
+			expectedError: true,
+		},
+		{
+			name:          "case 6: bucket unreachable",
+			region:        "eu-west-1",
+			sdkRegion:     "eu-west-1",
+			requestErr:    errors.New("connection refused"),
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requested string
+			c := &Client{
+				s3:         *s3.New(s3.Options{Region: tc.sdkRegion}),
+				protocol:   "https",
+				bucketName: "images",
+				region:     tc.region,
+				httpClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					requested = req.Method + " " + req.URL.String()
+					if tc.requestErr != nil {
+						return nil, tc.requestErr
+					}
+					header := http.Header{}
+					if tc.bucketRegion != "" {
+						header.Set(bucketRegionHeader, tc.bucketRegion)
+					}
+					return &http.Response{
+						StatusCode: tc.status,
+						Status:     http.StatusText(tc.status),
+						Header:     header,
+						Body:       io.NopCloser(strings.NewReader("")),
+					}, nil
+				})},
+			}
+
+			err := c.CheckRegion(context.TODO())
+			if !tc.expectedError {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedRequest, requested)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tc.expectedMismatch, errors.Is(err, ErrRegionMismatch))
+		})
+	}
+}
+
+func TestExists(t *testing.T) {
+	testCases := []struct {
+		name          string
+		status        int
+		expected      bool
+		expectedError bool
+	}{
+		{
+			name:     "case 0: object exists",
+			status:   http.StatusOK,
+			expected: true,
+		},
+		{
+			name:     "case 1: object is missing",
+			status:   http.StatusNotFound,
+			expected: false,
+		},
+		{
+			name:          "case 2: access denied is an error",
+			status:        http.StatusForbidden,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requested string
+			c := &Client{
+				s3: *s3.New(s3.Options{
+					Region:           "eu-west-1",
+					RetryMaxAttempts: 1,
+					HTTPClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+						requested = req.Method + " " + req.URL.Path
+						return &http.Response{
+							StatusCode: tc.status,
+							Status:     http.StatusText(tc.status),
+							Header:     http.Header{},
+							Body:       io.NopCloser(strings.NewReader("")),
+						}, nil
+					})},
+				}),
+				bucketName: "images",
+				region:     "eu-west-1",
+				timeout:    time.Minute,
+			}
+
+			exists, err := c.Exists(context.TODO(), "capv/image/image.ova")
+			assert.Equal(t, "HEAD /capv/image/image.ova", requested)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, exists)
+		})
+	}
+}
+
+func TestBucketExists(t *testing.T) {
+	testCases := []struct {
+		name         string
+		bucket       string
+		region       string
+		expectedHost string
+	}{
+		{
+			name:         "case 0: object is looked up in the bucket",
+			bucket:       "images-copy",
+			expectedHost: "images-copy.s3.eu-west-1.amazonaws.com",
+		},
+		{
+			name:         "case 1: object is looked up in the region of the bucket",
+			bucket:       "images-asia",
+			region:       "ap-southeast-1",
+			expectedHost: "images-asia.s3.ap-southeast-1.amazonaws.com",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requested string
+			c := &Client{
+				s3: *s3.New(s3.Options{
+					Region:           "eu-west-1",
+					RetryMaxAttempts: 1,
+					HTTPClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+						requested = req.Method + " " + req.URL.Host + req.URL.Path
+						return &http.Response{
+							StatusCode: http.StatusOK,
+							Header:     http.Header{},
+							Body:       io.NopCloser(strings.NewReader("")),
+						}, nil
+					})},
+				}),
+				bucketName: "images",
+				region:     "eu-west-1",
+			}
+
+			exists, err := c.BucketExists(context.TODO(), tc.bucket, tc.region, "capv/image/image.ova")
+			require.NoError(t, err)
+			assert.True(t, exists)
+			assert.Equal(t, "HEAD "+tc.expectedHost+"/capv/image/image.ova", requested)
+		})
+	}
+}
+
+func TestPresign(t *testing.T) {
+	c := &Client{
+		s3: *s3.New(s3.Options{
+			Region:      "eu-west-1",
+			Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		}),
+		bucketName: "images",
+		region:     "eu-west-1",
+	}
+
+	presigned, err := c.Presign(context.TODO(), "capv/image/image.ova", time.Hour)
+	require.NoError(t, err)
+
+	u, err := url.Parse(presigned)
+	require.NoError(t, err)
+	assert.Equal(t, "images.s3.eu-west-1.amazonaws.com", u.Host)
+	assert.Equal(t, "/capv/image/image.ova", u.Path)
+	assert.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+}
+
+func TestGetBucketURL(t *testing.T) {
+	c := &Client{protocol: "https", bucketName: "images", region: "eu-west-1"}
+
+	assert.Equal(t, "https://images.s3.eu-west-1.amazonaws.com/capv/image.ova", c.GetURL("capv/image.ova"))
+	assert.Equal(t, "https://images-asia.s3.ap-southeast-1.amazonaws.com/capv/image.ova", c.GetBucketURL("images-asia", "ap-southeast-1", "capv/image.ova"))
+	// without a region the bucket is in the client's region
+	assert.Equal(t, "https://images-copy.s3.eu-west-1.amazonaws.com/capv/image.ova", c.GetBucketURL("images-copy", "", "capv/image.ova"))
+	// special characters are escaped within their path segment
+	assert.Equal(t, "https://images.s3.eu-west-1.amazonaws.com/capv/image%20%231%3F/image%25.ova", c.GetURL("capv/image #1?/image%.ova"))
+}
+
+func TestURLBucket(t *testing.T) {
+	c := &Client{protocol: "https", bucketName: "images", region: "eu-west-1"}
+
+	assert.Equal(t, "images-staging", c.URLBucket("https://images-staging.s3.ap-southeast-1.amazonaws.com/capv/image.ova"))
+	// the client's own bucket and URLs outside of S3 have no other bucket
+	assert.Equal(t, "", c.URLBucket("https://images.s3.eu-west-1.amazonaws.com/capv/image.ova"))
+	assert.Equal(t, "", c.URLBucket("https://artifacts.example.com/capv/image.ova"))
+}
+
+func TestValidURL(t *testing.T) {
+	testCases := []struct {
+		name                string
+		allowedHostPatterns []string
+		url                 string
+		expectedError       string
+	}{
+		{
+			name: "case 0: S3 URL",
+			url:  "https://images.s3.eu-west-1.amazonaws.com/capv/image.ova",
+		},
+		{
+			name:          "case 1: other URL without allowed hosts",
+			url:           "https://artifacts.example.com/capv/image.ova",
+			expectedError: "URL is not an S3 bucket",
+		},
+		{
+			name:                "case 2: URL on an allowed host",
+			allowedHostPatterns: []string{"artifacts.example.com"},
+			url:                 "https://artifacts.example.com/capv/image.ova",
+		},
+		{
+			name:                "case 3: URL on a host matching a wildcard pattern",
+			allowedHostPatterns: []string{"artifacts.example.com", "*.cdn.example.com"},
+			url:                 "https://eu.cdn.example.com:8443/capv/image.ova",
+		},
+		{
+			name:                "case 4: URL on another host",
+			allowedHostPatterns: []string{"*.cdn.example.com"},
+			url:                 "https://cdn.example.org/capv/image.ova",
+			expectedError:       "URL is neither an S3 bucket nor on a host matching *.cdn.example.com",
+		},
+		{
+			name:                "case 5: URL on an allowed host with another protocol",
+			allowedHostPatterns: []string{"artifacts.example.com"},
+			url:                 "http://artifacts.example.com/capv/image.ova",
+			expectedError:       "URL is neither an S3 bucket nor on a host matching artifacts.example.com",
+		},
+		{
+			name:                "case 6: S3 URL with allowed hosts",
+			allowedHostPatterns: []string{"artifacts.example.com"},
+			url:                 "https://images.s3.eu-west-1.amazonaws.com/capv/image.ova",
+		},
+		{
+			name:          "case 7: empty URL",
+			expectedError: "URL is empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := New(Config{BucketName: "images", Region: "eu-west-1", AllowedHostPatterns: tc.allowedHostPatterns}, context.TODO())
+			require.NoError(t, err)
+
+			err = c.ValidURL(tc.url)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestNewInvalidAllowedHostPattern(t *testing.T) {
+	_, err := New(Config{BucketName: "images", Region: "eu-west-1", AllowedHostPatterns: []string{"[artifacts"}}, context.TODO())
+	assert.ErrorContains(t, err, `invalid allowed host pattern "[artifacts"`)
+}
+
+func TestParseRegionMismatchPolicy(t *testing.T) {
+	policy, err := ParseRegionMismatchPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, RegionMismatchFail, policy)
+
+	policy, err = ParseRegionMismatchPolicy(RegionMismatchWarn)
+	assert.NoError(t, err)
+	assert.Equal(t, RegionMismatchWarn, policy)
+
+	_, err = ParseRegionMismatchPolicy("ignore")
+	assert.Error(t, err)
+}
+
+func TestPullResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	testCases := []struct {
+		name           string
+		partial        []byte
+		expectedRanges []string
+	}{
+		{
+			name:           "case 0: image is pulled",
+			expectedRanges: []string{""},
+		},
+		{
+			name:           "case 1: partial pull is continued",
+			partial:        content[:400],
+			expectedRanges: []string{"bytes=400-"},
+		},
+		{
+			name:           "case 2: partial pull beyond the end of the image is restarted",
+			partial:        append(append([]byte{}, content...), "garbage"...),
+			expectedRanges: []string{"bytes=1007-", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/images/capv/image/image.ova", r.URL.Path)
+				ranges = append(ranges, r.Header.Get("Range"))
+				http.ServeContent(w, r, "image.ova", time.Time{}, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			c := &Client{
+				s3: *s3.New(s3.Options{
+					Region:           "eu-west-1",
+					BaseEndpoint:     aws.String(server.URL),
+					UsePathStyle:     true,
+					Credentials:      aws.AnonymousCredentials{},
+					RetryMaxAttempts: 1,
+				}),
+				bucketName: "images",
+				region:     "eu-west-1",
+				timeout:    time.Minute,
+				directory:  t.TempDir(),
+			}
+			if tc.partial != nil {
+				require.NoError(t, os.WriteFile(filepath.Join(c.directory, download.PartialName("capv/image/image.ova")), tc.partial, 0600))
+			}
+
+			path, err := c.Pull(context.TODO(), "capv/image/image.ova")
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(c.directory, "image.ova"), path)
+			assert.Equal(t, tc.expectedRanges, ranges)
+
+			pulled, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, content, pulled)
+
+			// the partial pull was moved to the image
+			entries, err := os.ReadDir(c.directory)
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
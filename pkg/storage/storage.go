@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Kinds of object stores node images are imported from
+const (
+	// S3 stores the images in an S3 bucket
+	S3 = "s3"
+	// GCS stores the images in a Google Cloud Storage bucket
+	GCS = "gcs"
+)
+
+// ObjectStore is the bucket node images are imported from. Providers only
+// need a URL they can download the image from, the store builds it from the
+// key of the image.
+type ObjectStore interface {
+	// GetURL returns the URL of the image with the key in the bucket
+	GetURL(key string) string
+	// ValidURL returns an error if the URL is neither an image in the store
+	// nor on one of the hosts images may be imported from besides it
+	ValidURL(url string) error
+	// Exists checks with an authenticated request whether the image with the
+	// key is in the bucket, which the URL can't tell for private buckets
+	Exists(ctx context.Context, key string) (bool, error)
+	// Pull downloads the image with the key and returns the path of the
+	// local file
+	Pull(ctx context.Context, key string) (string, error)
+	// Presign returns a URL the image with the key can be downloaded from
+	// without credentials until it expires
+	Presign(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// BucketStore is implemented by object stores whose provider locations can
+// import images from a bucket of their own, e.g. an S3 bucket close to them
+type BucketStore interface {
+	// GetBucketURL returns the URL of the image with the key in another
+	// bucket, in the store's region if region is empty
+	GetBucketURL(bucket string, region string, key string) string
+	// BucketExists is Exists for the image with the key in another bucket,
+	// in the store's region if region is empty
+	BucketExists(ctx context.Context, bucket string, region string, key string) (bool, error)
+	// URLBucket returns the bucket of a URL in a bucket other than the
+	// store's, or "" for any other URL
+	URLBucket(url string) string
+}
+
+// ParseKind validates kind, falling back to S3 if empty
+func ParseKind(kind string) (string, error) {
+	switch kind {
+	case "":
+		return S3, nil
+	case S3, GCS:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown image store %q, expected %s or %s", kind, S3, GCS)
+}
+
+// CheckHostPatterns returns an error for the first pattern of allowed hosts
+// that is not a valid shell pattern
+func CheckHostPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed host pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// EscapeKey escapes each path segment of an object key for use in a URL, so
+// characters like spaces, "#" and "?" in names from custom templates end up
+// in the object key and not in the fragment or query of the URL
+func EscapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// AllowedURL checks if a URL with the scheme is on a host matching one of
+// the allowed host patterns
+func AllowedURL(rawURL string, scheme string, patterns []string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != scheme || u.Hostname() == "" || u.Path == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, u.Hostname()); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -2,53 +2,185 @@ package vsphere
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 	"gopkg.in/yaml.v3"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/image"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
 )
 
+// defaultMaxConcurrentImports bounds the number of OVA imports running against
+// a single vCenter when Config.MaxConcurrentImports is left unset.
+const defaultMaxConcurrentImports = 2
+
 // Client wraps the govmomi client
 type Client struct {
-	vsphere   *govmomi.Client
-	url       string
+	vsphere *govmomi.Client
+	url     string
+	// userinfo holds the credentials used to log in again if the session expired
+	userinfo *url.Userinfo
+	// credentialsFile is reread before every operation, so rotated
+	// credentials are logged in with without a restart
+	credentialsFile string
+	// sessionMu serializes session checks, so an expired session is only
+	// renewed once by concurrent operations. It guards userinfo, which is
+	// replaced when the credentials file changes.
+	sessionMu sync.Mutex
 	pullMode  bool
-	locations map[string]*Location
+	dryRun    bool
+	// verifyChecksum verifies imported files against the OVA manifest
+	verifyChecksum bool
+	// pullRetries is how often a failed pull task is retried in pull mode
+	pullRetries       int
+	pullRetryInterval time.Duration
+	// locationsMu guards locations, which are replaced when they are reloaded
+	locationsMu sync.RWMutex
+	locations   map[string]*Location
+
+	// importSlots is a semaphore gating importImage, so concurrent reconciles
+	// can't exhaust the vCenter NFC lease pool.
+	importSlots chan struct{}
+
+	// tagCategory is the category of the tag attached to imported templates,
+	// tagging is disabled if empty
+	tagCategory string
+	tagMu       sync.Mutex
+
+	// maxImageSize is the size in bytes images may have, unlimited if 0
+	maxImageSize int64
+
+	// hostMu guards the state of the host selection: the index of the next
+	// round-robin host per location and the running imports per host
+	hostMu      sync.Mutex
+	nextHost    map[string]int
+	hostImports map[string]int
 }
 
 type Credentials struct {
 	VCenter  string `yaml:"vcenter"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// Insecure skips the verification of the vCenter certificate
+	Insecure bool `yaml:"insecure"`
+	// Thumbprint is the SHA1 thumbprint of the vCenter certificate, which is
+	// trusted without verifying its chain, e.g. for a self-signed certificate
+	Thumbprint string `yaml:"thumbprint"`
 }
 
+// Location is a vSphere location images are distributed to. The datacenter,
+// datastore, folder, host, cluster, resource pool and networks are given by
+// name or by the ID of their managed object, e.g. datacenter-2 or domain-c8,
+// which is resolved directly instead of searching the inventory.
 type Location struct {
-	Datacenter   string `yaml:"datacenter"`
-	Datastore    string `yaml:"datastore"`
-	Folder       string `yaml:"folder"`
-	Host         string `yaml:"host"`
+	Datacenter string `yaml:"datacenter"`
+	Datastore  string `yaml:"datastore"`
+	Folder     string `yaml:"folder"`
+	Host       string `yaml:"host"`
+	// Hosts is a prioritized list of hosts to import on instead of Host.
+	// Unusable hosts are skipped, and an import failing because its host
+	// became unusable, e.g. entered maintenance mode, moves on to the next.
+	Hosts []string `yaml:"hosts"`
+	// HostSelection picks the host of an import from Hosts: priority (the
+	// default), roundrobin or leastloaded
+	HostSelection string `yaml:"hostselection"`
+	// Resourcepool is the name of a resource pool below the cluster, or the
+	// full inventory path of a resource pool if it starts with a slash, e.g.
+	// /dc/host/cluster/Resources/parent/child. Without it the root pool of
+	// the cluster is used.
 	Resourcepool string `yaml:"resourcepool"`
 	Network      string `yaml:"network"`
 	Cluster      string `yaml:"cluster"`
-	ImageSuffix  string `yaml:"imagesuffix"`
+	// Standalone imports into the root resource pool of the import host
+	// instead of a pool of the cluster, for standalone hosts and clusters
+	// without DRS. It requires host or hosts and is implied if cluster is
+	// empty.
+	Standalone bool `yaml:"standalone"`
+	// ImageSuffix is appended to the template name in the location, e.g.
+	// <image>-<suffix>
+	ImageSuffix string `yaml:"imagesuffix"`
+	// SourceSuffix makes the location import the OVA stored in S3 with the
+	// image suffix, e.g. <ova>-<suffix>.ova, instead of the shared one
+	SourceSuffix bool   `yaml:"sourcesuffix"`
+	Firmware     string `yaml:"firmware"`
+	// NetworkMapping maps the network names of the OVF envelope to vCenter
+	// networks. Without it the first NIC is attached to Network.
+	NetworkMapping map[string]string `yaml:"networkmapping"`
+	// Properties override the defaults of the OVF properties on import, e.g.
+	// guestinfo keys the image expects. Properties the OVF doesn't declare
+	// are ignored.
+	Properties map[string]string `yaml:"properties"`
+	// CreateFolder creates Folder and its missing parents on import if they
+	// don't exist yet
+	CreateFolder bool `yaml:"createfolder"`
+	// ContentLibrary is the name of a published content library the template
+	// is copied to once processed, so libraries subscribed to it, e.g. in
+	// other vCenters, sync it. The copy is deleted with the template.
+	ContentLibrary string `yaml:"contentlibrary"`
+	// S3Bucket and S3Region override the S3 bucket and region images are
+	// imported from, e.g. to use a copy of the images in the location's region
+	S3Bucket string `yaml:"s3bucket"`
+	S3Region string `yaml:"s3region"`
 }
 
+const (
+	firmwareBIOS = "bios"
+	firmwareEFI  = "efi"
+)
+
 // Config holds the configuration for the vSphere client
 type Config struct {
 	Backoff         wait.Backoff
 	CredentialsFile string
 	LocationsFile   string
-	PullMode        bool
+	// Locations is the content of a locations file, e.g. of a ConfigMap, used
+	// instead of LocationsFile if set
+	Locations []byte
+	PullMode  bool
+	// MaxConcurrentImports is the number of imports allowed to run against the
+	// vCenter at the same time. Defaults to 2.
+	MaxConcurrentImports int
+	// DryRun makes Create and Delete only log the intended action
+	DryRun bool
+	// VerifyChecksum verifies every imported file against the checksums in
+	// the OVA manifest. Images are pushed even if PullMode is set, as only
+	// then the operator sees their content.
+	VerifyChecksum bool
+	// PullRetries is the number of times a failed pull task is retried with
+	// a new lease in pull mode, 0 disables retries
+	PullRetries int
+	// CACertFile is a PEM bundle of the CAs the vCenter certificate is
+	// verified against, instead of the system's CAs
+	CACertFile string
+	// TagCategory is the tag category of the tag attached to every imported
+	// template, created if it doesn't exist. Templates are not tagged if empty.
+	TagCategory string
+	// MaxImageSizeBytes fails imports of images larger than it before they
+	// start, unlimited if 0
+	MaxImageSizeBytes int64
 }
 
+// defaultPullRetryInterval is the time waited before a failed pull task is retried
+const defaultPullRetryInterval = 30 * time.Second
+
 // New initializes a new vSphere client
 func New(c Config, ctx context.Context) (*Client, error) {
 	log := log.FromContext(ctx)
@@ -58,21 +190,16 @@ func New(c Config, ctx context.Context) (*Client, error) {
 		return nil, fmt.Errorf("failed to load credentials:\n%w", err)
 	}
 
-	log.Info("Connecting to vSphere", "vSphereURL", creds.VCenter)
+	log.Info("Connecting to vSphere", "vSphereURL", creds.VCenter, "insecure", creds.Insecure)
 
-	u := &url.URL{
-		Scheme: "https",
-		Host:   creds.VCenter,
-		Path:   "/sdk",
-		User:   url.UserPassword(creds.Username, creds.Password),
-	}
+	u := sdkURL(creds)
 
 	var client *govmomi.Client
 	var lastErr error
 
 	err = wait.ExponentialBackoff(c.Backoff,
 		func() (done bool, err error) {
-			client, lastErr = govmomi.NewClient(ctx, u, true)
+			client, lastErr = newGovmomiClient(ctx, u, creds, c.CACertFile)
 
 			// Return if client was successfully created, otherwise retry
 			if lastErr == nil {
@@ -90,60 +217,475 @@ func New(c Config, ctx context.Context) (*Client, error) {
 
 	log.Info("Successfully connected to vSphere", "vSphereURL", creds.VCenter)
 
-	locations, err := loadLocations(c.LocationsFile)
+	var locations map[string]*Location
+	if c.Locations != nil {
+		locations, err = parseLocations(c.Locations)
+	} else {
+		locations, err = loadLocations(c.LocationsFile)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load locations file:\n%w", err)
 	}
 
-	return &Client{
-		vsphere:   client,
-		url:       creds.VCenter,
-		locations: locations,
-		pullMode:  c.PullMode,
-	}, nil
+	if c.PullMode && c.VerifyChecksum {
+		log.Info("Checksum verification is enabled, images are pushed instead of pulled")
+	}
+
+	maxConcurrentImports := c.MaxConcurrentImports
+	if maxConcurrentImports <= 0 {
+		maxConcurrentImports = defaultMaxConcurrentImports
+	}
+
+	vsphereClient := &Client{
+		vsphere:           client,
+		url:               creds.VCenter,
+		userinfo:          u.User,
+		credentialsFile:   c.CredentialsFile,
+		locations:         locations,
+		pullMode:          c.PullMode,
+		dryRun:            c.DryRun,
+		verifyChecksum:    c.VerifyChecksum,
+		pullRetries:       c.PullRetries,
+		pullRetryInterval: defaultPullRetryInterval,
+		importSlots:       make(chan struct{}, maxConcurrentImports),
+		tagCategory:       c.TagCategory,
+		maxImageSize:      c.MaxImageSizeBytes,
+	}
+
+	if err := vsphereClient.validateNetworkMappings(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load locations file:\n%w", err)
+	}
+	if err := vsphereClient.validateResourcePools(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load locations file:\n%w", err)
+	}
+
+	return vsphereClient, nil
+}
+
+// sdkURL returns the URL of the vCenter API, carrying the credentials
+func sdkURL(creds *Credentials) *url.URL {
+	return &url.URL{
+		Scheme: "https",
+		Host:   creds.VCenter,
+		Path:   "/sdk",
+		User:   url.UserPassword(creds.Username, creds.Password),
+	}
+}
+
+// newGovmomiClient connects and logs in to the vCenter at u, verifying its
+// certificate unless the credentials are insecure
+func newGovmomiClient(ctx context.Context, u *url.URL, creds *Credentials, caCertFile string) (*govmomi.Client, error) {
+	soapClient := soap.NewClient(u, creds.Insecure)
+	if caCertFile != "" {
+		if err := soapClient.SetRootCAs(caCertFile); err != nil {
+			return nil, fmt.Errorf("failed to load CA certificates from %s: %w", caCertFile, err)
+		}
+	}
+	if creds.Thumbprint != "" {
+		soapClient.SetThumbprint(u.Host, creds.Thumbprint)
+	}
+
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+	if err := client.Login(ctx, u.User); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// validateNetworkMappings checks that the networks the locations map OVF
+// networks to exist, so a typo fails at startup instead of every import
+func (c *Client) validateNetworkMappings(ctx context.Context) error {
+	for loc, location := range c.locationMap() {
+		if len(location.NetworkMapping) == 0 {
+			continue
+		}
+
+		finder := find.NewFinder(c.vsphere.Client, true)
+		dc, err := c.getDatacenter(ctx, finder, location)
+		if err != nil {
+			return fmt.Errorf("failed to get datacenter of location %s: %w", loc, err)
+		}
+		finder.SetDatacenter(dc)
+
+		for ovfNetwork, network := range location.NetworkMapping {
+			if _, err := c.getNetwork(ctx, network, finder); err != nil {
+				return fmt.Errorf("network %s mapped from OVF network %s in location %s: %w", network, ovfNetwork, loc, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ensureSession logs in to vSphere again with the stored credentials if the
+// session is no longer valid, e.g. after it expired during a long upload or
+// vCenter was restarted. Credentials changed in the credentials file, e.g.
+// after the password was rotated, are stored for that login: the session is
+// shared by all operations, logging it out would fail the ones in flight.
+func (c *Client) ensureSession(ctx context.Context) error {
+	log := log.FromContext(ctx)
+
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.reloadCredentials(ctx) {
+		log.Info("vSphere credentials changed, using them for the next login", "vSphereURL", c.url)
+	}
+
+	userSession, err := c.vsphere.SessionManager.UserSession(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check vSphere session: %w", err)
+	}
+	if userSession != nil {
+		return nil
+	}
+	log.Info("vSphere session expired, logging in again", "vSphereURL", c.url)
+
+	if err := c.vsphere.Login(ctx, c.userinfo); err != nil {
+		return fmt.Errorf("failed to log in to vSphere: %w", err)
+	}
+	return nil
+}
+
+// reloadCredentials rereads the credentials file and replaces the stored
+// credentials if the username or password changed. A file that can't be
+// loaded, e.g. while the secret is updated, keeps the current credentials. A
+// changed vCenter needs a restart. The caller holds sessionMu.
+func (c *Client) reloadCredentials(ctx context.Context) bool {
+	if c.credentialsFile == "" {
+		return false
+	}
+
+	creds, err := loadCredentials(c.credentialsFile)
+	if err == nil {
+		err = checkCredentials(creds)
+	}
+	if err != nil {
+		log.FromContext(ctx).Info("Failed to reload vSphere credentials, keeping the current ones", "error", err.Error())
+		return false
+	}
+
+	password, _ := c.userinfo.Password()
+	if creds.Username == c.userinfo.Username() && creds.Password == password {
+		return false
+	}
+	c.userinfo = url.UserPassword(creds.Username, creds.Password)
+	return true
+}
+
+// currentUserinfo returns the stored credentials
+func (c *Client) currentUserinfo() *url.Userinfo {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.userinfo
+}
+
+// maxVMNameLength is the longest VM name vSphere accepts
+const maxVMNameLength = 80
+
+// MaxNameLength returns the maximum length of an image name in the location,
+// leaving room for the location's image suffix
+func (c *Client) MaxNameLength(loc string) int {
+	limit := maxVMNameLength
+	if location := c.location(loc); location != nil && location.ImageSuffix != "" {
+		limit -= len(location.ImageSuffix) + 1
+	}
+	return limit
+}
+
+// ImageName returns the name of the template for the image in the location,
+// with the location's image suffix appended
+func (c *Client) ImageName(name string, loc string) string {
+	return c.location(loc).imageName(name)
+}
+
+// imageName returns the name of the template for the image in the location,
+// the name itself if the location is nil
+func (l *Location) imageName(name string) string {
+	if l != nil && l.ImageSuffix != "" {
+		return fmt.Sprintf("%s-%s", name, l.ImageSuffix)
+	}
+	return name
+}
+
+// sourceURL returns the URL of the OVA imported into the location, with the
+// image suffix inserted before the extension if the location's source
+// objects are suffixed
+func (c *Client) sourceURL(imageURL string, location *Location) string {
+	if location == nil || !location.SourceSuffix || location.ImageSuffix == "" {
+		return imageURL
+	}
+	ext := path.Ext(imageURL)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(imageURL, ext), location.ImageSuffix, ext)
+}
+
+// ImageSource returns the S3 bucket and region the location imports images
+// from, empty if it uses the operator's bucket
+func (c *Client) ImageSource(loc string) (string, string) {
+	location := c.location(loc)
+	if location == nil {
+		return "", ""
+	}
+	return location.S3Bucket, location.S3Region
 }
 
 // GetLocations returns all configured vSphere locations
 func (c *Client) GetLocations() map[string]interface{} {
 	locations := make(map[string]interface{})
-	for k, v := range c.locations {
+	for k, v := range c.locationMap() {
 		locations[k] = v
 	}
 	return locations
 }
 
-// Exists checks if an image already exists in vSphere
+// location returns the configuration of the location, nil if it is not
+// configured
+func (c *Client) location(loc string) *Location {
+	c.locationsMu.RLock()
+	defer c.locationsMu.RUnlock()
+	return c.locations[loc]
+}
+
+// lookupLocation returns the configuration of the location, or an error if it
+// is not configured. Operations look their location up once, so a location
+// removed by ReloadLocations while they run is still used until they are done.
+func (c *Client) lookupLocation(loc string) (*Location, error) {
+	location := c.location(loc)
+	if location == nil {
+		return nil, fmt.Errorf("unknown location %s", loc)
+	}
+	return location, nil
+}
+
+// locationMap returns the configured locations. The map is replaced instead
+// of changed when the locations are reloaded, so it can be read without
+// holding the lock.
+func (c *Client) locationMap() map[string]*Location {
+	c.locationsMu.RLock()
+	defer c.locationsMu.RUnlock()
+	return c.locations
+}
+
+// ReloadLocations replaces the locations with the ones in data, the content
+// of a locations file, e.g. of a changed ConfigMap. The new locations are
+// validated against the vCenter like at startup, invalid ones are rejected
+// and the current locations are kept.
+func (c *Client) ReloadLocations(ctx context.Context, data []byte) error {
+	locations, err := parseLocations(data)
+	if err != nil {
+		return fmt.Errorf("failed to load locations:\n%w", err)
+	}
+	if err := c.ensureSession(ctx); err != nil {
+		return err
+	}
+
+	candidate := &Client{vsphere: c.vsphere, locations: locations}
+	if err := candidate.validateNetworkMappings(ctx); err != nil {
+		return fmt.Errorf("failed to load locations:\n%w", err)
+	}
+	if err := candidate.validateResourcePools(ctx); err != nil {
+		return fmt.Errorf("failed to load locations:\n%w", err)
+	}
+
+	c.locationsMu.Lock()
+	c.locations = locations
+	c.locationsMu.Unlock()
+
+	log.FromContext(ctx).Info("Reloaded vSphere locations", "locations", len(locations))
+	return nil
+}
+
+// Exists checks if an image already exists in vSphere. The VM of the image
+// ID in the context is checked first, and the ID of the VM found is reported.
 func (c *Client) Exists(ctx context.Context, name string, loc string) (bool, error) {
+	location, err := c.lookupLocation(loc)
+	if err != nil {
+		return false, err
+	}
+	if err := c.ensureSession(ctx); err != nil {
+		return false, err
+	}
+
+	if vm, ok := c.vmByID(ctx, location.imageName(name)); ok {
+		provider.ReportImageID(ctx, vm.Reference().Value)
+		return true, nil
+	}
+
 	finder := find.NewFinder(c.vsphere.Client, true)
 
-	dc, err := c.getDatacenter(ctx, finder, loc)
+	dc, err := c.getDatacenter(ctx, finder, location)
 	if err != nil {
 		return false, fmt.Errorf("failed to get datacenter: %w", err)
 	}
 	finder.SetDatacenter(dc)
 
-	_, err = finder.VirtualMachine(ctx, c.GetVMPath(name, loc))
+	vmPath, err := c.vmPath(ctx, location.imageName(name), location)
+	if err != nil {
+		return false, err
+	}
+	vm, err := finder.VirtualMachine(ctx, vmPath)
 	if err != nil {
 		return false, nil
 	}
+	provider.ReportImageID(ctx, vm.Reference().Value)
 	return true, nil
 }
 
+// vmByID returns the VM of the image ID in the context if it still has the
+// given name. The second return value is false if the image has to be looked
+// up by name, e.g. because there is no ID or its VM was deleted.
+func (c *Client) vmByID(ctx context.Context, name string) (*object.VirtualMachine, bool) {
+	ref, ok := parseMoref(provider.ImageIDFrom(ctx))
+	if !ok || ref.Type != "VirtualMachine" {
+		return nil, false
+	}
+
+	vm := object.NewVirtualMachine(c.vsphere.Client, ref)
+	var managedVM mo.VirtualMachine
+	if err := vm.Properties(ctx, ref, []string{"name"}, &managedVM); err != nil || managedVM.Name != name {
+		return nil, false
+	}
+	return vm, true
+}
+
+// Ready reports whether the image exists and has been marked as a template
+func (c *Client) Ready(ctx context.Context, name string, loc string) (bool, error) {
+	location, err := c.lookupLocation(loc)
+	if err != nil {
+		return false, err
+	}
+	if err := c.ensureSession(ctx); err != nil {
+		return false, err
+	}
+
+	finder := find.NewFinder(c.vsphere.Client, true)
+
+	dc, err := c.getDatacenter(ctx, finder, location)
+	if err != nil {
+		return false, fmt.Errorf("failed to get datacenter: %w", err)
+	}
+	finder.SetDatacenter(dc)
+
+	vmPath, err := c.vmPath(ctx, location.imageName(name), location)
+	if err != nil {
+		return false, err
+	}
+	vm, err := finder.VirtualMachine(ctx, vmPath)
+	if err != nil {
+		return false, nil
+	}
+
+	var managedVM mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.template"}, &managedVM); err != nil {
+		return false, fmt.Errorf("failed to retrieve VM properties: %w", err)
+	}
+	return managedVM.Config != nil && managedVM.Config.Template, nil
+}
+
+// List returns the names of the node image templates in the location's folder
+func (c *Client) List(ctx context.Context, loc string) ([]string, error) {
+	location, err := c.lookupLocation(loc)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ensureSession(ctx); err != nil {
+		return nil, err
+	}
+
+	finder := find.NewFinder(c.vsphere.Client, true)
+
+	dc, err := c.getDatacenter(ctx, finder, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datacenter: %w", err)
+	}
+	finder.SetDatacenter(dc)
+
+	vmPath, err := c.vmPath(ctx, "*", location)
+	if err != nil {
+		return nil, err
+	}
+	vms, err := finder.VirtualMachineList(ctx, vmPath)
+	if err != nil {
+		var notFound *find.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+
+	refs := make([]types.ManagedObjectReference, 0, len(vms))
+	for _, vm := range vms {
+		refs = append(refs, vm.Reference())
+	}
+
+	var managedVMs []mo.VirtualMachine
+	pc := property.DefaultCollector(c.vsphere.Client)
+	if err := pc.Retrieve(ctx, refs, []string{"name", "config.template"}, &managedVMs); err != nil {
+		return nil, fmt.Errorf("failed to retrieve VM properties: %w", err)
+	}
+
+	// only templates named like node images are images, anything else in the
+	// folder is left alone. Names are returned without the location's image
+	// suffix, as passed to Exists and Delete.
+	suffix := ""
+	if s := location.ImageSuffix; s != "" {
+		suffix = "-" + s
+	}
+	var names []string
+	for _, vm := range managedVMs {
+		if vm.Config == nil || !vm.Config.Template {
+			continue
+		}
+		name, ok := strings.CutSuffix(vm.Name, suffix)
+		if !ok || name == "" {
+			continue
+		}
+		if _, ok := image.ParseImageName(name); !ok {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
 // Delete deletes an image from vSphere
 func (c *Client) Delete(ctx context.Context, name string, loc string) error {
 	log := log.FromContext(ctx)
 
-	finder := find.NewFinder(c.vsphere.Client, true)
+	if c.dryRun {
+		log.Info("Dry run: would delete VM", "name", name)
+		return nil
+	}
 
-	dc, err := c.getDatacenter(ctx, finder, loc)
+	location, err := c.lookupLocation(loc)
 	if err != nil {
-		return fmt.Errorf("failed to get datacenter: %w", err)
+		return err
+	}
+	if err := c.ensureSession(ctx); err != nil {
+		return err
+	}
+
+	// the published copy goes first, a retry finds nothing to delete once
+	// the template is gone
+	if location.ContentLibrary != "" {
+		if err := c.unpublishImage(ctx, location.imageName(name), location); err != nil {
+			return err
+		}
 	}
-	finder.SetDatacenter(dc)
 
-	vm, err := finder.VirtualMachine(ctx, c.GetVMPath(name, loc))
+	vm, err := c.findImageVM(ctx, name, location)
 	if err != nil {
-		// If the VM doesn't exist, return nil
+		return err
+	}
+	// If the VM doesn't exist, there is nothing to delete
+	if vm == nil {
 		return nil
 	}
 
@@ -162,20 +704,152 @@ func (c *Client) Delete(ctx context.Context, name string, loc string) error {
 	return nil
 }
 
-// Create imports and processes an OVF image to vSphere
+// findImageVM returns the VM of the image, looked up by the image ID in the
+// context or else by name, or nil if there is none
+func (c *Client) findImageVM(ctx context.Context, name string, location *Location) (*object.VirtualMachine, error) {
+	if vm, ok := c.vmByID(ctx, location.imageName(name)); ok {
+		return vm, nil
+	}
+
+	finder := find.NewFinder(c.vsphere.Client, true)
+
+	dc, err := c.getDatacenter(ctx, finder, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datacenter: %w", err)
+	}
+	finder.SetDatacenter(dc)
+
+	vmPath, err := c.vmPath(ctx, location.imageName(name), location)
+	if err != nil {
+		return nil, err
+	}
+	vm, err := finder.VirtualMachine(ctx, vmPath)
+	if err != nil {
+		var notFound *find.NotFoundError
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find VM %s: %w", name, err)
+	}
+	return vm, nil
+}
+
+// Create imports an OVF image to vSphere and reports the ID of the imported VM
 func (c *Client) Create(ctx context.Context, imageURL string, imageName string, loc string) error {
-	object, err := c.importImage(ctx, imageURL, imageName, loc)
+	if c.dryRun {
+		log.FromContext(ctx).Info("Dry run: would import OVA", "name", imageName, "url", imageURL)
+		return nil
+	}
+
+	location, err := c.lookupLocation(loc)
+	if err != nil {
+		return err
+	}
+	err = c.withImportSlot(ctx, func() error {
+		// the session may have expired while waiting for the slot
+		if err := c.ensureSession(ctx); err != nil {
+			return err
+		}
+		ref, err := c.importImage(ctx, c.sourceURL(imageURL, location), imageName, loc, location)
+		if err != nil {
+			return err
+		}
+		provider.ReportImageID(ctx, ref.Value)
+
+		// the tag only serves audits, a template failing to be tagged is
+		// still usable
+		if c.tagCategory != "" {
+			if err := c.tagImage(ctx, *ref); err != nil {
+				log.FromContext(ctx).Error(err, "Failed to tag imported VM", "name", imageName, "category", c.tagCategory)
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to import OVA: %w", err)
 	}
-	return c.processImage(ctx, *object)
+	return nil
+}
+
+// Process applies the location's firmware to the imported VM and marks it as
+// a template. VMs already marked as a template are left alone. The template is
+// then published to the location's content library, if it has one.
+func (c *Client) Process(ctx context.Context, name string, loc string) error {
+	if c.dryRun {
+		log.FromContext(ctx).Info("Dry run: would mark VM as template", "name", name)
+		return nil
+	}
+
+	location, err := c.lookupLocation(loc)
+	if err != nil {
+		return err
+	}
+	if err := c.ensureSession(ctx); err != nil {
+		return err
+	}
+
+	finder := find.NewFinder(c.vsphere.Client, true)
+
+	dc, err := c.getDatacenter(ctx, finder, location)
+	if err != nil {
+		return fmt.Errorf("failed to get datacenter: %w", err)
+	}
+	finder.SetDatacenter(dc)
+
+	// the VM is imported with the location's image suffix
+	name = location.imageName(name)
+
+	vmPath, err := c.vmPath(ctx, name, location)
+	if err != nil {
+		return err
+	}
+	vm, err := finder.VirtualMachine(ctx, vmPath)
+	if err != nil {
+		return fmt.Errorf("failed to find imported vm %s: %w", name, err)
+	}
+
+	var managedVM mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"config.template"}, &managedVM); err != nil {
+		return fmt.Errorf("failed to retrieve VM properties: %w", err)
+	}
+	if managedVM.Config == nil || !managedVM.Config.Template {
+		if err := c.processImage(ctx, vm.Reference(), location); err != nil {
+			return err
+		}
+	}
+
+	// the published copy only serves subscribed libraries, the template is
+	// usable without it
+	if location.ContentLibrary != "" {
+		if err := c.publishImage(ctx, vm.Reference(), name, location); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to publish template", "name", name, "library", location.ContentLibrary)
+		}
+	}
+	return nil
+}
+
+// withImportSlot runs fn once one of the client's import slots is free,
+// waiting until then or until ctx is done.
+func (c *Client) withImportSlot(ctx context.Context, fn func() error) error {
+	select {
+	case c.importSlots <- struct{}{}:
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for a free import slot: %w", ctx.Err())
+	}
+	defer func() { <-c.importSlots }()
+
+	return fn()
 }
 
 // Process processes the OVF image
-func (c *Client) processImage(ctx context.Context, ref types.ManagedObjectReference) error {
+func (c *Client) processImage(ctx context.Context, ref types.ManagedObjectReference, location *Location) error {
 	log := log.FromContext(ctx)
 	vm := object.NewVirtualMachine(c.vsphere.Client, ref)
 
+	if err := c.setFirmware(ctx, vm, location.Firmware); err != nil {
+		return err
+	}
+
 	err := vm.MarkAsTemplate(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to mark vm as template: %w", err)
@@ -185,42 +859,126 @@ func (c *Client) processImage(ctx context.Context, ref types.ManagedObjectRefere
 	return nil
 }
 
+// setFirmware reconfigures the vm with the given boot firmware. An empty
+// firmware keeps whatever the OVF declared.
+func (c *Client) setFirmware(ctx context.Context, vm *object.VirtualMachine, firmware string) error {
+	if firmware == "" {
+		return nil
+	}
+
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{Firmware: firmware})
+	if err != nil {
+		return fmt.Errorf("failed to set firmware %s: %w", firmware, err)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return fmt.Errorf("failed to wait for firmware reconfigure task: %w", err)
+	}
+
+	log.FromContext(ctx).Info("Set vm firmware", "vm", vm.Name(), "firmware", firmware)
+	return nil
+}
+
 // getDatacenter returns the datacenter object
-func (c *Client) getDatacenter(ctx context.Context, finder *find.Finder, loc string) (*object.Datacenter, error) {
-	dc, err := finder.DatacenterOrDefault(ctx, c.locations[loc].Datacenter)
+func (c *Client) getDatacenter(ctx context.Context, finder *find.Finder, location *Location) (*object.Datacenter, error) {
+	if dc, ok, err := lookupMoref[*object.Datacenter](ctx, c.vsphere.Client, location.Datacenter); ok {
+		if err != nil {
+			return nil, fmt.Errorf("failed to find datacenter %s:\n%w", location.Datacenter, err)
+		}
+		return dc, nil
+	}
+
+	dc, err := finder.DatacenterOrDefault(ctx, location.Datacenter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find datacenter %s:\n%w", c.locations[loc].Datacenter, err)
+		return nil, fmt.Errorf("failed to find datacenter %s:\n%w", location.Datacenter, err)
 	}
 	return dc, nil
 }
 
 // getDatastore returns the datastore object
-func (c *Client) getDatastore(ctx context.Context, finder *find.Finder, loc string) (*object.Datastore, error) {
-	datastore, err := finder.DatastoreOrDefault(ctx, c.locations[loc].Datastore)
+func (c *Client) getDatastore(ctx context.Context, finder *find.Finder, location *Location) (*object.Datastore, error) {
+	if datastore, ok, err := lookupMoref[*object.Datastore](ctx, c.vsphere.Client, location.Datastore); ok {
+		if err != nil {
+			return nil, fmt.Errorf("failed to find datastore %s: %w", location.Datastore, err)
+		}
+		return datastore, nil
+	}
+
+	datastore, err := finder.DatastoreOrDefault(ctx, location.Datastore)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find datastore %s: %w", c.locations[loc].Datastore, err)
+		return nil, fmt.Errorf("failed to find datastore %s: %w", location.Datastore, err)
 	}
 	return datastore, nil
 }
 
-// getFolder returns the folder object
-func (c *Client) getFolder(ctx context.Context, folder string, finder *find.Finder) (*object.Folder, error) {
-	folderObj, err := finder.FolderOrDefault(ctx, folder)
+// getFolder returns the folder of the location, creating it first if it is
+// missing and the location is configured to create it. A folder referenced by
+// its ID exists already, so it is never created.
+func (c *Client) getFolder(ctx context.Context, location *Location, finder *find.Finder) (*object.Folder, error) {
+	if folder, ok, err := lookupMoref[*object.Folder](ctx, c.vsphere.Client, location.Folder); ok {
+		if err != nil {
+			return nil, fmt.Errorf("failed to find folder %s: %w", location.Folder, err)
+		}
+		return folder, nil
+	}
+	if location.CreateFolder {
+		return c.ensureFolder(ctx, location.Folder, finder)
+	}
+
+	folderObj, err := finder.FolderOrDefault(ctx, location.Folder)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find folder %s: %w", folder, err)
+		return nil, fmt.Errorf("failed to find folder %s: %w", location.Folder, err)
 	}
 	return folderObj, nil
 }
 
+// ensureFolder returns the folder at folderPath, creating it and its missing
+// parents. Relative paths are resolved against the datacenter like the
+// finder does, e.g. vm/images.
+func (c *Client) ensureFolder(ctx context.Context, folderPath string, finder *find.Finder) (*object.Folder, error) {
+	log := log.FromContext(ctx)
+
+	folder, err := finder.Folder(ctx, folderPath)
+	if err == nil {
+		return folder, nil
+	}
+	var notFound *find.NotFoundError
+	if !errors.As(err, &notFound) {
+		return nil, fmt.Errorf("failed to find folder %s: %w", folderPath, err)
+	}
+
+	parentPath, name := path.Split(strings.TrimSuffix(folderPath, "/"))
+	parentPath = strings.TrimSuffix(parentPath, "/")
+
+	// never create datacenters or their root folders
+	if name == "" || parentPath == "" || path.Clean(parentPath) == "/" {
+		return nil, fmt.Errorf("failed to find folder %s: %w", folderPath, err)
+	}
+
+	parent, err := c.ensureFolder(ctx, parentPath, finder)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err = parent.CreateFolder(ctx, name)
+	if err != nil {
+		// another import may have created it in the meantime
+		if existing, findErr := finder.Folder(ctx, folderPath); findErr == nil {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("failed to create folder %s: %w", folderPath, err)
+	}
+	log.Info("Created folder", "folder", folderPath)
+	return folder, nil
+}
+
 // getHost returns the host object
 func (c *Client) getHost(ctx context.Context, hostName string, finder *find.Finder) (*object.HostSystem, error) {
-	log := log.FromContext(ctx)
 	var host *object.HostSystem
 	var err error
 	if hostName != "" {
-		host, err = finder.HostSystemOrDefault(ctx, hostName)
+		host, err = c.findHost(ctx, hostName, finder)
 		if err != nil {
-			return nil, fmt.Errorf("failed to find host %s: %w", hostName, err)
+			return nil, err
 		}
 		// Validate the specified host is in a usable state
 		if err := c.validateHostState(ctx, host); err != nil {
@@ -240,7 +998,6 @@ func (c *Client) getHost(ctx context.Context, hostName string, finder *find.Find
 			return nil, err
 		}
 	}
-	log.Info("Using host for import", "host", host.Name())
 	return host, nil
 }
 
@@ -287,21 +1044,98 @@ func (c *Client) findUsableHost(ctx context.Context, hosts []*object.HostSystem)
 	)
 }
 
-// getResourcePool returns the resource pool object
-func (c *Client) getResourcePool(ctx context.Context, rp string, finder *find.Finder) (*object.ResourcePool, error) {
-	pool, err := finder.ResourcePoolOrDefault(ctx, rp)
+// validateResourcePools checks that the resource pools of the locations
+// exist, so a wrong path fails at startup instead of every import
+func (c *Client) validateResourcePools(ctx context.Context) error {
+	for loc, location := range c.locationMap() {
+		if location.Resourcepool == "" {
+			continue
+		}
+
+		finder := find.NewFinder(c.vsphere.Client, true)
+		if _, err := c.getResourcePool(ctx, location, finder); err != nil {
+			return fmt.Errorf("location %s: %w", loc, err)
+		}
+	}
+	return nil
+}
+
+// standalone reports whether images are imported into the root resource pool
+// of their import host, as the location has no cluster or one without DRS
+func (l *Location) standalone() bool {
+	return l.Standalone || l.Cluster == ""
+}
+
+// hostResourcePool returns the root resource pool of the host, the one of
+// its cluster if it is part of one
+func hostResourcePool(ctx context.Context, host *object.HostSystem) (*object.ResourcePool, error) {
+	pool, err := host.ResourcePool(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get root resource pool of host %s: %w", host.Name(), err)
+	}
+	return pool, nil
+}
+
+// getResourcePool returns the resource pool of the location, the root pool of
+// its cluster if none is configured
+func (c *Client) getResourcePool(ctx context.Context, location *Location, finder *find.Finder) (*object.ResourcePool, error) {
+	if pool, ok, err := lookupMoref[*object.ResourcePool](ctx, c.vsphere.Client, location.Resourcepool); ok {
+		if err != nil {
+			return nil, fmt.Errorf("failed to find resource pool %s: %w", location.Resourcepool, err)
+		}
+		return pool, nil
+	}
+
+	// a full inventory path is used as is
+	poolPath := location.Resourcepool
+	if !strings.HasPrefix(poolPath, "/") {
+		clusterPath, err := c.clusterPath(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		if poolPath == "" {
+			cluster, err := finder.ClusterComputeResource(ctx, clusterPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find cluster %s: %w", clusterPath, err)
+			}
+			pool, err := cluster.ResourcePool(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get root resource pool of cluster %s: %w", clusterPath, err)
+			}
+			return pool, nil
+		}
+		poolPath = clusterPath + "/" + poolPath
+	}
+
+	pool, err := finder.ResourcePoolOrDefault(ctx, poolPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find resource pool %s: %w", rp, err)
+		return nil, fmt.Errorf("failed to find resource pool %s: %w", poolPath, err)
 	}
 	return pool, nil
 }
 
+// clusterPath returns the inventory path of the cluster of the location
+func (c *Client) clusterPath(ctx context.Context, location *Location) (string, error) {
+	if _, ok := parseMoref(location.Cluster); ok {
+		return c.inventoryPath(ctx, location.Cluster)
+	}
+	dcPath, err := c.inventoryPath(ctx, location.Datacenter)
+	if err != nil {
+		return "", err
+	}
+	return path.Join("/", dcPath, "host", location.Cluster), nil
+}
+
 // getNetwork returns the network object
 func (c *Client) getNetwork(ctx context.Context, n string, finder *find.Finder) (types.ManagedObjectReference, error) {
 	var network object.NetworkReference
 	var err error
 	if n != "" {
-		network, err = finder.NetworkOrDefault(ctx, n)
+		var ok bool
+		network, ok, err = lookupMoref[object.NetworkReference](ctx, c.vsphere.Client, n)
+		if !ok {
+			network, err = finder.NetworkOrDefault(ctx, n)
+		}
 		if err != nil {
 			return types.ManagedObjectReference{}, fmt.Errorf("failed to find network %s: %w", n, err)
 		}
@@ -318,19 +1152,29 @@ func (c *Client) getNetwork(ctx context.Context, n string, finder *find.Finder)
 	return network.Reference(), nil
 }
 
-func (c *Client) GetVMPath(name string, loc string) string {
-	return fmt.Sprintf("%s/%s", c.locations[loc].Folder, name)
+// vmPath returns the inventory path of the VM name in the folder of the
+// location
+func (c *Client) vmPath(ctx context.Context, name string, location *Location) (string, error) {
+	folder, err := c.inventoryPath(ctx, location.Folder)
+	if err != nil {
+		return "", fmt.Errorf("failed to get folder: %w", err)
+	}
+	return fmt.Sprintf("%s/%s", folder, name), nil
 }
 
 func loadLocations(path string) (map[string]*Location, error) {
-	locations := make(map[string]*Location)
-
 	file, err := os.ReadFile(path) // nolint:gosec
 	if err != nil {
 		return nil, fmt.Errorf("failed to read locations file:\n%w", err)
 	}
+	return parseLocations(file)
+}
+
+// parseLocations unmarshals and checks the content of a locations file
+func parseLocations(data []byte) (map[string]*Location, error) {
+	locations := make(map[string]*Location)
 
-	if err := yaml.Unmarshal(file, locations); err != nil {
+	if err := yaml.Unmarshal(data, locations); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal locations file:\n%w", err)
 	}
 
@@ -344,14 +1188,82 @@ func loadLocations(path string) (map[string]*Location, error) {
 		if v.Folder == "" {
 			return nil, fmt.Errorf("folder is required for location %s", k)
 		}
-		if v.Cluster == "" {
-			return nil, fmt.Errorf("cluster is required for location %s", k)
+		if v.standalone() {
+			if v.Host == "" && len(v.Hosts) == 0 {
+				return nil, fmt.Errorf("host or hosts is required for standalone location %s", k)
+			}
+			if v.Resourcepool != "" {
+				return nil, fmt.Errorf("resourcepool of location %s can't be set for a standalone location", k)
+			}
+		}
+		switch v.Firmware {
+		case "", firmwareBIOS, firmwareEFI:
+		default:
+			return nil, fmt.Errorf("firmware must be %q or %q for location %s, got %q", firmwareBIOS, firmwareEFI, k, v.Firmware)
+		}
+		for ovfNetwork, network := range v.NetworkMapping {
+			if ovfNetwork == "" || network == "" {
+				return nil, fmt.Errorf("network mapping of location %s must map OVF networks to networks, got %q: %q", k, ovfNetwork, network)
+			}
+		}
+		for key := range v.Properties {
+			if key == "" {
+				return nil, fmt.Errorf("properties of location %s must not have an empty key", k)
+			}
+		}
+		if v.Host != "" && len(v.Hosts) > 0 {
+			return nil, fmt.Errorf("host and hosts of location %s are mutually exclusive", k)
+		}
+		switch v.HostSelection {
+		case "", hostSelectionPriority, hostSelectionRoundRobin, hostSelectionLeastLoaded:
+		default:
+			return nil, fmt.Errorf("hostselection must be %q, %q or %q for location %s, got %q",
+				hostSelectionPriority, hostSelectionRoundRobin, hostSelectionLeastLoaded, k, v.HostSelection)
+		}
+		if v.HostSelection != "" && len(v.Hosts) == 0 {
+			return nil, fmt.Errorf("hostselection of location %s requires hosts", k)
+		}
+		if v.S3Region != "" && v.S3Bucket == "" {
+			return nil, fmt.Errorf("s3region of location %s requires s3bucket", k)
+		}
+		if err := checkLocationMorefs(v); err != nil {
+			return nil, fmt.Errorf("location %s: %w", k, err)
 		}
-		locations[k].Resourcepool = fmt.Sprintf("/%s/host/%s/%s", v.Datacenter, v.Cluster, v.Resourcepool)
 	}
 	return locations, nil
 }
 
+// checkLocationMorefs checks that the fields of the location given as IDs of
+// managed objects, e.g. datacenter-2, refer to objects of the right type
+func checkLocationMorefs(location *Location) error {
+	type field struct {
+		name  string
+		value string
+		kinds []string
+	}
+	fields := []field{
+		{"datacenter", location.Datacenter, []string{"Datacenter"}},
+		{"datastore", location.Datastore, []string{"Datastore"}},
+		{"folder", location.Folder, []string{"Folder"}},
+		{"host", location.Host, []string{"HostSystem"}},
+		{"cluster", location.Cluster, []string{"ClusterComputeResource"}},
+		{"resourcepool", location.Resourcepool, []string{"ResourcePool"}},
+		{"network", location.Network, []string{"Network", "DistributedVirtualPortgroup"}},
+	}
+	for _, network := range location.NetworkMapping {
+		fields = append(fields, field{"networkmapping", network, []string{"Network", "DistributedVirtualPortgroup"}})
+	}
+	for _, host := range location.Hosts {
+		fields = append(fields, field{"hosts", host, []string{"HostSystem"}})
+	}
+	for _, f := range fields {
+		if err := checkMoref(f.value, f.kinds...); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
 func loadCredentials(path string) (*Credentials, error) {
 	file, err := os.ReadFile(path) // nolint:gosec
 	if err != nil {
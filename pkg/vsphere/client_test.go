@@ -0,0 +1,970 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+func TestProcessImageFirmware(t *testing.T) {
+	testCases := []struct {
+		name             string
+		firmware         string
+		expectedFirmware string
+	}{
+		{
+			name:             "case 0: efi firmware is applied",
+			firmware:         firmwareEFI,
+			expectedFirmware: firmwareEFI,
+		},
+		{
+			name:             "case 1: bios firmware is applied",
+			firmware:         firmwareBIOS,
+			expectedFirmware: firmwareBIOS,
+		},
+		{
+			name:             "case 2: empty firmware keeps the vcsim default",
+			firmware:         "",
+			expectedFirmware: firmwareBIOS,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				c := newTestClient(vc, map[string]*Location{
+					"loc": {Datacenter: "DC0", Firmware: tc.firmware},
+				})
+
+				vm := poweredOffVM(ctx, t, vc, "/DC0/vm/DC0_H0_VM0")
+
+				require.NoError(t, c.processImage(ctx, vm.Reference(), c.location("loc")))
+
+				var managedVM mo.VirtualMachine
+				require.NoError(t, vm.Properties(ctx, vm.Reference(), []string{"config"}, &managedVM))
+				assert.Equal(t, tc.expectedFirmware, managedVM.Config.Firmware)
+				assert.True(t, managedVM.Config.Template)
+			})
+		})
+	}
+}
+
+func TestLoadLocationsNetworkMapping(t *testing.T) {
+	testCases := []struct {
+		name           string
+		networkMapping string
+		expected       map[string]string
+		expectError    bool
+	}{
+		{
+			name: "case 0: network mapping unset",
+		},
+		{
+			name:           "case 1: network mapping",
+			networkMapping: "{public: VM Network, private: DC0_DVPG0}",
+			expected:       map[string]string{"public": "VM Network", "private": "DC0_DVPG0"},
+		},
+		{
+			name:           "case 2: empty target network is rejected",
+			networkMapping: `{public: ""}`,
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := `loc:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0`
+			if tc.networkMapping != "" {
+				content += "\n  networkmapping: " + tc.networkMapping
+			}
+
+			locations, err := loadLocations(writeTempFile(t, "locations-*.yaml", content))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, locations["loc"].NetworkMapping)
+		})
+	}
+}
+
+func TestLoadLocationsProperties(t *testing.T) {
+	testCases := []struct {
+		name        string
+		properties  string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name: "case 0: properties unset",
+		},
+		{
+			name:       "case 1: properties",
+			properties: `{guestinfo.hostname: node, guestinfo.dns: ""}`,
+			expected:   map[string]string{"guestinfo.hostname": "node", "guestinfo.dns": ""},
+		},
+		{
+			name:        "case 2: empty property key is rejected",
+			properties:  `{"": node}`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := `loc:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0`
+			if tc.properties != "" {
+				content += "\n  properties: " + tc.properties
+			}
+
+			locations, err := loadLocations(writeTempFile(t, "locations-*.yaml", content))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, locations["loc"].Properties)
+		})
+	}
+}
+
+func TestNewGovmomiClientTLS(t *testing.T) {
+	model := simulator.VPX()
+	defer model.Remove()
+	require.NoError(t, model.Create())
+
+	model.Service.TLS = new(tls.Config)
+	server := model.Service.NewServer()
+	defer server.Close()
+
+	caCertFile, err := server.CertificateFile()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		creds       Credentials
+		caCertFile  string
+		expectError bool
+	}{
+		{
+			name:        "case 0: untrusted certificate is rejected",
+			expectError: true,
+		},
+		{
+			name:       "case 1: certificate signed by the CA bundle",
+			caCertFile: caCertFile,
+		},
+		{
+			name:  "case 2: certificate with the configured thumbprint",
+			creds: Credentials{Thumbprint: server.CertificateInfo().ThumbprintSHA1},
+		},
+		{
+			name:  "case 3: insecure skips the verification",
+			creds: Credentials{Insecure: true},
+		},
+		{
+			name:        "case 4: missing CA bundle",
+			caCertFile:  filepath.Join(t.TempDir(), "missing.pem"),
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := newGovmomiClient(context.TODO(), server.URL, &tc.creds, tc.caCertFile)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, client.Logout(context.TODO()))
+		})
+	}
+}
+
+func TestLoadLocationsImageSource(t *testing.T) {
+	testCases := []struct {
+		name           string
+		source         string
+		expectedBucket string
+		expectedRegion string
+		expectError    bool
+	}{
+		{
+			name: "case 0: operator's bucket",
+		},
+		{
+			name:           "case 1: bucket and region of the location",
+			source:         "\n  s3bucket: images-asia\n  s3region: ap-southeast-1",
+			expectedBucket: "images-asia",
+			expectedRegion: "ap-southeast-1",
+		},
+		{
+			name:           "case 2: bucket in the operator's region",
+			source:         "\n  s3bucket: images-copy",
+			expectedBucket: "images-copy",
+		},
+		{
+			name:        "case 3: region without bucket is rejected",
+			source:      "\n  s3region: ap-southeast-1",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := `loc:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0` + tc.source
+
+			locations, err := loadLocations(writeTempFile(t, "locations-*.yaml", content))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			c := &Client{locations: locations}
+			bucket, region := c.ImageSource("loc")
+			assert.Equal(t, tc.expectedBucket, bucket)
+			assert.Equal(t, tc.expectedRegion, region)
+		})
+	}
+}
+
+func TestGetResourcePool(t *testing.T) {
+	testCases := []struct {
+		name         string
+		resourcepool string
+		expectedPath string
+	}{
+		{
+			name:         "case 0: named resource pool",
+			resourcepool: "Resources/pool1",
+			expectedPath: "/DC0/host/DC0_C0/Resources/pool1",
+		},
+		{
+			name:         "case 1: root resource pool of the cluster by default",
+			expectedPath: "/DC0/host/DC0_C0/Resources",
+		},
+		{
+			name:         "case 2: nested resource pool by inventory path",
+			resourcepool: "/DC0/host/DC0_C0/Resources/pool1/pool2",
+			expectedPath: "/DC0/host/DC0_C0/Resources/pool1/pool2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				finder := find.NewFinder(vc, true)
+				root, err := finder.ResourcePool(ctx, "/DC0/host/DC0_C0/Resources")
+				require.NoError(t, err)
+				pool1, err := root.Create(ctx, "pool1", types.DefaultResourceConfigSpec())
+				require.NoError(t, err)
+				_, err = pool1.Create(ctx, "pool2", types.DefaultResourceConfigSpec())
+				require.NoError(t, err)
+
+				content := `loc:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0
+  resourcepool: "` + tc.resourcepool + `"`
+				locations, err := loadLocations(writeTempFile(t, "locations-*.yaml", content))
+				require.NoError(t, err)
+				c := newTestClient(vc, locations)
+
+				pool, err := c.getResourcePool(ctx, c.location("loc"), finder)
+				require.NoError(t, err)
+
+				expected, err := finder.ResourcePool(ctx, tc.expectedPath)
+				require.NoError(t, err)
+				assert.Equal(t, expected.Reference(), pool.Reference())
+			})
+		})
+	}
+}
+
+func TestGetFolder(t *testing.T) {
+	testCases := []struct {
+		name          string
+		folder        string
+		createFolder  bool
+		expectedPath  string
+		expectedError bool
+	}{
+		{
+			name:         "case 0: existing folder",
+			folder:       "/DC0/vm",
+			expectedPath: "/DC0/vm",
+		},
+		{
+			name:          "case 1: missing folder is not created by default",
+			folder:        "/DC0/vm/images/flatcar",
+			expectedError: true,
+		},
+		{
+			name:         "case 2: missing folder and parents are created",
+			folder:       "/DC0/vm/images/flatcar",
+			createFolder: true,
+			expectedPath: "/DC0/vm/images/flatcar",
+		},
+		{
+			name:         "case 3: relative folder is created below the datacenter",
+			folder:       "vm/images/flatcar",
+			createFolder: true,
+			expectedPath: "/DC0/vm/images/flatcar",
+		},
+		{
+			name:          "case 4: missing datacenter is not created",
+			folder:        "/DC1/vm/images",
+			createFolder:  true,
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				c := newTestClient(vc, map[string]*Location{
+					"loc": {Datacenter: "DC0", Folder: tc.folder, CreateFolder: tc.createFolder},
+				})
+				finder := find.NewFinder(vc, true)
+				dc, err := finder.Datacenter(ctx, "DC0")
+				require.NoError(t, err)
+				finder.SetDatacenter(dc)
+
+				folder, err := c.getFolder(ctx, c.location("loc"), finder)
+				if tc.expectedError {
+					assert.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+
+				expected, err := finder.Folder(ctx, tc.expectedPath)
+				require.NoError(t, err)
+				assert.Equal(t, expected.Reference(), folder.Reference())
+
+				// the folder is found again instead of created twice
+				again, err := c.getFolder(ctx, c.location("loc"), finder)
+				require.NoError(t, err)
+				assert.Equal(t, folder.Reference(), again.Reference())
+			})
+		})
+	}
+}
+
+func TestValidateNetworkMappings(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"plain":  {Datacenter: "DC0"},
+			"mapped": {Datacenter: "DC0", NetworkMapping: map[string]string{"public": "VM Network", "private": "DC0_DVPG0"}},
+		})
+		require.NoError(t, c.validateNetworkMappings(ctx))
+
+		c.locations["missing"] = &Location{Datacenter: "DC0", NetworkMapping: map[string]string{"public": "missing"}}
+		err := c.validateNetworkMappings(ctx)
+		require.ErrorContains(t, err, "network missing mapped from OVF network public in location missing")
+	})
+}
+
+func TestValidateResourcePools(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"default": {Datacenter: "DC0", Cluster: "DC0_C0"},
+			"named":   {Datacenter: "DC0", Cluster: "DC0_C0", Resourcepool: "/DC0/host/DC0_C0/Resources"},
+		})
+		require.NoError(t, c.validateResourcePools(ctx))
+
+		c.locations["missing"] = &Location{Datacenter: "DC0", Cluster: "DC0_C0", Resourcepool: "/DC0/host/DC0_C0/Resources/missing"}
+		err := c.validateResourcePools(ctx)
+		require.ErrorContains(t, err, "location missing: failed to find resource pool /DC0/host/DC0_C0/Resources/missing")
+	})
+}
+
+func TestLoadLocationsFirmware(t *testing.T) {
+	testCases := []struct {
+		name        string
+		firmware    string
+		expectError bool
+	}{
+		{
+			name:     "case 0: firmware unset",
+			firmware: "",
+		},
+		{
+			name:     "case 1: bios firmware",
+			firmware: "bios",
+		},
+		{
+			name:     "case 2: efi firmware",
+			firmware: "efi",
+		},
+		{
+			name:        "case 3: unknown firmware is rejected",
+			firmware:    "uefi",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := `loc:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0
+  firmware: "` + tc.firmware + `"`
+
+			locations, err := loadLocations(writeTempFile(t, "locations-*.yaml", content))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.firmware, locations["loc"].Firmware)
+		})
+	}
+}
+
+func TestWithImportSlot(t *testing.T) {
+	const slots = 2
+
+	c := &Client{importSlots: make(chan struct{}, slots)}
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	started := make(chan int, slots+1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < slots+1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.withImportSlot(ctx, func() error {
+				started <- i
+				<-release
+				return nil
+			})
+		}()
+	}
+
+	// the first N imports start right away
+	for i := 0; i < slots; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("import %d did not start", i)
+		}
+	}
+
+	// the N+1th import is blocked until one of the running imports completes
+	select {
+	case <-started:
+		t.Fatal("import started although all slots are taken")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("blocked import did not start after a slot was freed")
+	}
+
+	close(release)
+	wg.Wait()
+}
+
+func TestWithImportSlotContextCancelled(t *testing.T) {
+	c := &Client{importSlots: make(chan struct{}, 1)}
+	c.importSlots <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	called := false
+	err := c.withImportSlot(ctx, func() error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, called)
+}
+
+func TestDryRun(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc": {Datacenter: "DC0", Folder: "/DC0/vm"},
+		})
+		c.dryRun = true
+
+		// Exists still queries vSphere
+		exists, err := c.Exists(ctx, "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		// Delete leaves the VM in place
+		require.NoError(t, c.Delete(ctx, "DC0_H0_VM0", "loc"))
+		exists, err = c.Exists(ctx, "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		// Create succeeds without importing anything
+		require.NoError(t, c.Create(ctx, "https://example.invalid/image.ova", "new-image", "loc"))
+		exists, err = c.Exists(ctx, "new-image", "loc")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		// Process leaves the VM untouched
+		require.NoError(t, c.Process(ctx, "DC0_H0_VM0", "loc"))
+		ready, err := c.Ready(ctx, "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.False(t, ready)
+	})
+}
+
+func TestProcess(t *testing.T) {
+	testCases := []struct {
+		name          string
+		vm            string
+		imageName     string
+		imageSuffix   string
+		expectedError bool
+	}{
+		{
+			name:      "case 0: imported vm is marked as template",
+			vm:        "DC0_H0_VM0",
+			imageName: "DC0_H0_VM0",
+		},
+		{
+			name:        "case 1: vm is looked up with the image suffix",
+			vm:          "image-suffix",
+			imageName:   "image",
+			imageSuffix: "suffix",
+		},
+		{
+			name:          "case 2: missing vm",
+			vm:            "DC0_H0_VM0",
+			imageName:     "missing",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				c := newTestClient(vc, map[string]*Location{
+					"loc": {Datacenter: "DC0", Folder: "/DC0/vm", ImageSuffix: tc.imageSuffix},
+				})
+
+				vm := poweredOffVM(ctx, t, vc, "/DC0/vm/DC0_H0_VM0")
+				if tc.vm != "DC0_H0_VM0" {
+					task, err := vm.Rename(ctx, tc.vm)
+					require.NoError(t, err)
+					require.NoError(t, task.Wait(ctx))
+				}
+
+				err := c.Process(ctx, tc.imageName, "loc")
+				if tc.expectedError {
+					assert.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+
+				var managedVM mo.VirtualMachine
+				require.NoError(t, vm.Properties(ctx, vm.Reference(), []string{"config.template"}, &managedVM))
+				assert.True(t, managedVM.Config.Template)
+
+				// processing a template again is a no-op
+				require.NoError(t, c.Process(ctx, tc.imageName, "loc"))
+			})
+		})
+	}
+}
+
+func TestMaxNameLength(t *testing.T) {
+	c := &Client{locations: map[string]*Location{
+		"plain":    {Datacenter: "DC0"},
+		"suffixed": {Datacenter: "DC0", ImageSuffix: "efi"},
+	}}
+
+	assert.Equal(t, 80, c.MaxNameLength("plain"))
+	// room is left for the "-efi" suffix appended on import
+	assert.Equal(t, 76, c.MaxNameLength("suffixed"))
+}
+
+func TestList(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc":   {Datacenter: "DC0", Folder: "/DC0/vm"},
+			"empty": {Datacenter: "DC0", Folder: "/DC0/vm/missing"},
+		})
+
+		const name = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
+		for vmName, newName := range map[string]string{"DC0_H0_VM0": name, "DC0_H0_VM1": "my-own-template"} {
+			vm := poweredOffVM(ctx, t, vc, "/DC0/vm/"+vmName)
+			task, err := vm.Rename(ctx, newName)
+			require.NoError(t, err)
+			require.NoError(t, task.Wait(ctx))
+			require.NoError(t, vm.MarkAsTemplate(ctx))
+		}
+
+		// plain VMs and templates not named like node images are not listed
+		names, err := c.List(ctx, "loc")
+		require.NoError(t, err)
+		assert.Equal(t, []string{name}, names)
+
+		names, err = c.List(ctx, "empty")
+		require.NoError(t, err)
+		assert.Empty(t, names)
+	})
+}
+
+func TestImageSuffix(t *testing.T) {
+	const image = "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs"
+
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc-a": {Datacenter: "DC0", Folder: "/DC0/vm", ImageSuffix: "a"},
+			"loc-b": {Datacenter: "DC0", Folder: "/DC0/vm", ImageSuffix: "b"},
+			"plain": {Datacenter: "DC0", Folder: "/DC0/vm"},
+		})
+		assert.Equal(t, image+"-a", c.ImageName(image, "loc-a"))
+		assert.Equal(t, image+"-b", c.ImageName(image, "loc-b"))
+		assert.Equal(t, image, c.ImageName(image, "plain"))
+
+		for vmName, name := range map[string]string{"DC0_H0_VM0": image + "-a", "DC0_H0_VM1": image + "-b"} {
+			vm := poweredOffVM(ctx, t, vc, "/DC0/vm/"+vmName)
+			task, err := vm.Rename(ctx, name)
+			require.NoError(t, err)
+			require.NoError(t, task.Wait(ctx))
+			require.NoError(t, vm.MarkAsTemplate(ctx))
+		}
+
+		for _, loc := range []string{"loc-a", "loc-b"} {
+			exists, err := c.Exists(ctx, image, loc)
+			require.NoError(t, err)
+			assert.True(t, exists, loc)
+
+			ready, err := c.Ready(ctx, image, loc)
+			require.NoError(t, err)
+			assert.True(t, ready, loc)
+
+			// names are listed without the suffix of the location
+			names, err := c.List(ctx, loc)
+			require.NoError(t, err)
+			assert.Equal(t, []string{image}, names, loc)
+		}
+
+		exists, err := c.Exists(ctx, image, "plain")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		// deleting an image missing in the location is not an error
+		require.NoError(t, c.Delete(ctx, image, "plain"))
+
+		// deleting in one location leaves the other one alone
+		require.NoError(t, c.Delete(ctx, image, "loc-b"))
+		exists, err = c.Exists(ctx, image, "loc-b")
+		require.NoError(t, err)
+		assert.False(t, exists)
+		exists, err = c.Exists(ctx, image, "loc-a")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
+func TestSourceURL(t *testing.T) {
+	c := &Client{locations: map[string]*Location{
+		"plain":    {},
+		"suffixed": {ImageSuffix: "efi"},
+		"source":   {ImageSuffix: "efi", SourceSuffix: true},
+	}}
+	url := "https://images.s3.eu-west-1.amazonaws.com/capv/flatcar-stable-4152.2.3-kube-1.31.7-tooling-1.26.0-gs/flatcar-stable-4152.2.3-kube-v1.31.7.ova"
+
+	assert.Equal(t, url, c.sourceURL(url, c.location("plain")))
+	assert.Equal(t, url, c.sourceURL(url, c.location("suffixed")))
+	assert.Equal(t, strings.TrimSuffix(url, ".ova")+"-efi.ova", c.sourceURL(url, c.location("source")))
+}
+
+func TestReady(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc": {Datacenter: "DC0", Folder: "/DC0/vm"},
+		})
+
+		vm := poweredOffVM(ctx, t, vc, "/DC0/vm/DC0_H0_VM0")
+
+		ready, err := c.Ready(ctx, "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.False(t, ready, "a VM not yet marked as template is not ready")
+
+		require.NoError(t, vm.MarkAsTemplate(ctx))
+		ready, err = c.Ready(ctx, "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.True(t, ready)
+
+		ready, err = c.Ready(ctx, "missing", "loc")
+		require.NoError(t, err)
+		assert.False(t, ready)
+	})
+}
+
+func TestImageID(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc": {Datacenter: "DC0", Folder: "/DC0/vm", Cluster: "DC0_C0", Datastore: "LocalDS_0"},
+			// the folder doesn't hold the VMs, so they are only found by ID
+			"moved": {Datacenter: "DC0", Folder: "/DC0/vm/moved"},
+		})
+		c.importSlots = make(chan struct{}, 1)
+		vm0 := poweredOffVM(ctx, t, vc, "/DC0/vm/DC0_H0_VM0")
+		vm1 := poweredOffVM(ctx, t, vc, "/DC0/vm/DC0_H0_VM1")
+
+		var reported string
+		ctx = provider.WithImageIDReport(ctx, func(id string) { reported = id })
+
+		// the ID of the imported VM is reported
+		require.NoError(t, c.Create(ctx, writeOVA(t, map[string]string{"image.ovf": minimalOVF}), "image", "loc"))
+		created, err := find.NewFinder(vc, true).VirtualMachine(ctx, "/DC0/vm/image")
+		require.NoError(t, err)
+		assert.Equal(t, created.Reference().Value, reported)
+
+		// the ID of the VM found by name is reported
+		exists, err := c.Exists(ctx, "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, vm0.Reference().Value, reported)
+
+		exists, err = c.Exists(ctx, "DC0_H0_VM0", "moved")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		idCtx := provider.WithImageID(ctx, vm0.Reference().Value)
+		exists, err = c.Exists(idCtx, "DC0_H0_VM0", "moved")
+		require.NoError(t, err)
+		assert.True(t, exists, "found by ID")
+
+		// an ID of a VM with another name falls back to the name
+		reported = ""
+		exists, err = c.Exists(provider.WithImageID(ctx, vm1.Reference().Value), "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, vm0.Reference().Value, reported)
+
+		require.NoError(t, c.Delete(idCtx, "DC0_H0_VM0", "moved"))
+		exists, err = c.Exists(ctx, "DC0_H0_VM0", "loc")
+		require.NoError(t, err)
+		assert.False(t, exists, "deleted by ID")
+
+		// an ID of a deleted VM falls back to the name
+		exists, err = c.Exists(idCtx, "DC0_H0_VM0", "moved")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestExpiredSession(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc1": {Datacenter: "DC0", Folder: "/DC0/vm"},
+		})
+
+		// the session expires, e.g. during a long upload
+		require.NoError(t, c.vsphere.SessionManager.Logout(ctx))
+		userSession, err := c.vsphere.SessionManager.UserSession(ctx)
+		require.NoError(t, err)
+		require.Nil(t, userSession)
+
+		// the next operation logs in again transparently
+		exists, err := c.Exists(ctx, "DC0_H0_VM0", "loc1")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		userSession, err = c.vsphere.SessionManager.UserSession(ctx)
+		require.NoError(t, err)
+		assert.NotNil(t, userSession)
+	})
+}
+
+func TestReloadLocations(t *testing.T) {
+	testCases := []struct {
+		name              string
+		locations         string
+		expectedError     string
+		expectedLocations []string
+	}{
+		{
+			name: "case 0: new location is added",
+			locations: `
+loc1: {datacenter: DC0, datastore: LocalDS_0, folder: /DC0/vm, cluster: DC0_C0}
+loc2: {datacenter: DC0, datastore: LocalDS_0, folder: /DC0/vm, cluster: DC0_C0, resourcepool: /DC0/host/DC0_C0/Resources}
+`,
+			expectedLocations: []string{"loc1", "loc2"},
+		},
+		{
+			name:              "case 1: invalid locations are rejected",
+			locations:         `loc2: {datacenter: DC0, folder: /DC0/vm, cluster: DC0_C0}`,
+			expectedError:     "datastore is required for location loc2",
+			expectedLocations: []string{"loc1"},
+		},
+		{
+			name:              "case 2: locations with missing objects are rejected",
+			locations:         `loc2: {datacenter: DC0, datastore: LocalDS_0, folder: /DC0/vm, cluster: DC0_C0, resourcepool: missing}`,
+			expectedError:     "location loc2: failed to find resource pool /DC0/host/DC0_C0/missing",
+			expectedLocations: []string{"loc1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				c := newTestClient(vc, map[string]*Location{
+					"loc1": {Datacenter: "DC0", Datastore: "LocalDS_0", Folder: "/DC0/vm"},
+				})
+
+				err := c.ReloadLocations(ctx, []byte(tc.locations))
+				if tc.expectedError != "" {
+					assert.ErrorContains(t, err, tc.expectedError)
+				} else {
+					assert.NoError(t, err)
+				}
+				assert.ElementsMatch(t, tc.expectedLocations, slices.Collect(maps.Keys(c.GetLocations())))
+			})
+		})
+	}
+}
+
+func TestRemovedLocation(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc1": {Datacenter: "DC0", Datastore: "LocalDS_0", Folder: "/DC0/vm"},
+			"loc2": {Datacenter: "DC0", Datastore: "LocalDS_0", Folder: "/DC0/vm", ContentLibrary: "published"},
+		})
+		require.NoError(t, c.ReloadLocations(ctx, []byte(`loc1: {datacenter: DC0, datastore: LocalDS_0, folder: /DC0/vm, cluster: DC0_C0}`)))
+
+		// operations still queued for the removed location fail instead of
+		// panicking
+		_, err := c.Exists(ctx, "DC0_H0_VM0", "loc2")
+		assert.ErrorContains(t, err, "unknown location loc2")
+		_, err = c.Ready(ctx, "DC0_H0_VM0", "loc2")
+		assert.ErrorContains(t, err, "unknown location loc2")
+		_, err = c.List(ctx, "loc2")
+		assert.ErrorContains(t, err, "unknown location loc2")
+		assert.ErrorContains(t, c.Delete(ctx, "DC0_H0_VM0", "loc2"), "unknown location loc2")
+		assert.ErrorContains(t, c.Create(ctx, "https://example.com/image.ova", "image", "loc2"), "unknown location loc2")
+		assert.ErrorContains(t, c.Process(ctx, "DC0_H0_VM0", "loc2"), "unknown location loc2")
+	})
+}
+
+func TestRotatedCredentials(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc1": {Datacenter: "DC0", Folder: "/DC0/vm"},
+		})
+		c.credentialsFile = writeTempFile(t, "credentials", "vcenter: vcenter\nusername: user\npassword: pass\n")
+
+		// unchanged credentials keep the session
+		_, err := c.Exists(ctx, "DC0_H0_VM0", "loc1")
+		require.NoError(t, err)
+		userSession, err := c.vsphere.SessionManager.UserSession(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, userSession)
+		sessionKey := userSession.Key
+
+		// the password is rotated, the session of the operations in flight is
+		// kept and the new credentials are stored
+		require.NoError(t, os.WriteFile(c.credentialsFile, []byte("vcenter: vcenter\nusername: rotated\npassword: new\n"), 0600))
+		exists, err := c.Exists(ctx, "DC0_H0_VM0", "loc1")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		userSession, err = c.vsphere.SessionManager.UserSession(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, userSession)
+		assert.Equal(t, sessionKey, userSession.Key)
+		password, _ := c.userinfo.Password()
+		assert.Equal(t, "new", password)
+
+		// the next login uses them
+		require.NoError(t, c.vsphere.SessionManager.Logout(ctx))
+		exists, err = c.Exists(ctx, "DC0_H0_VM0", "loc1")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		userSession, err = c.vsphere.SessionManager.UserSession(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, userSession)
+		assert.Equal(t, "rotated", userSession.UserName)
+		assert.NotEqual(t, sessionKey, userSession.Key)
+
+		// a broken credentials file keeps the rotated credentials
+		require.NoError(t, os.WriteFile(c.credentialsFile, []byte("username: \n"), 0600))
+		_, err = c.Exists(ctx, "DC0_H0_VM0", "loc1")
+		require.NoError(t, err)
+		assert.Equal(t, "rotated", c.userinfo.Username())
+	})
+}
+
+// newTestClient returns a Client wired to a vcsim vim25 client, bypassing the
+// credentials handling in New.
+func newTestClient(vc *vim25.Client, locations map[string]*Location) *Client {
+	return &Client{
+		vsphere:   &govmomi.Client{Client: vc, SessionManager: session.NewManager(vc)},
+		userinfo:  simulator.DefaultLogin,
+		locations: locations,
+	}
+}
+
+// poweredOffVM returns one of vcsim's sample VMs powered off, since an imported
+// OVF is never powered on before it is marked as a template.
+func poweredOffVM(ctx context.Context, t *testing.T, vc *vim25.Client, path string) *object.VirtualMachine {
+	t.Helper()
+	vm, err := find.NewFinder(vc, true).VirtualMachine(ctx, path)
+	require.NoError(t, err)
+
+	task, err := vm.PowerOff(ctx)
+	require.NoError(t, err)
+	require.NoError(t, task.Wait(ctx))
+	return vm
+}
+
+func writeTempFile(t *testing.T, pattern, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), pattern)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
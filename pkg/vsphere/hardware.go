@@ -0,0 +1,113 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/ovf/importer"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ovfHardwareVersions returns the virtual hardware versions the OVF declares,
+// e.g. vmx-19. Any of them can be used to create the VM.
+func ovfHardwareVersions(e *ovf.Envelope) []string {
+	if e.VirtualSystem == nil {
+		return nil
+	}
+	var versions []string
+	for _, hw := range e.VirtualSystem.VirtualHardware {
+		if hw.System == nil || hw.System.VirtualSystemType == nil {
+			continue
+		}
+		// the type is a space or comma separated list, e.g. "vmx-13 vmx-14"
+		for _, version := range strings.FieldsFunc(*hw.System.VirtualSystemType, func(r rune) bool {
+			return r == ' ' || r == ','
+		}) {
+			if strings.HasPrefix(version, "vmx-") && !slices.Contains(versions, version) {
+				versions = append(versions, version)
+			}
+		}
+	}
+	return versions
+}
+
+// hostHardwareVersions returns the virtual hardware versions VMs can be
+// created with on the host, as reported by the environment browser of its
+// cluster or standalone compute resource
+func (c *Client) hostHardwareVersions(ctx context.Context, host *object.HostSystem) ([]string, error) {
+	var hs mo.HostSystem
+	if err := host.Properties(ctx, host.Reference(), []string{"parent"}, &hs); err != nil {
+		return nil, fmt.Errorf("failed to retrieve host parent: %w", err)
+	}
+	if hs.Parent == nil {
+		return nil, fmt.Errorf("host %s has no compute resource", host.Name())
+	}
+
+	var cr mo.ComputeResource
+	pc := property.DefaultCollector(c.vsphere.Client)
+	if err := pc.RetrieveOne(ctx, *hs.Parent, []string{"environmentBrowser"}, &cr); err != nil {
+		return nil, fmt.Errorf("failed to retrieve environment browser: %w", err)
+	}
+	if cr.EnvironmentBrowser == nil {
+		return nil, fmt.Errorf("compute resource of host %s has no environment browser", host.Name())
+	}
+
+	descriptors, err := object.NewEnvironmentBrowser(c.vsphere.Client, *cr.EnvironmentBrowser).QueryConfigOptionDescriptor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query config option descriptors: %w", err)
+	}
+
+	var versions []string
+	for _, d := range descriptors {
+		if !d.CreateSupported || !strings.HasPrefix(d.Key, "vmx-") {
+			continue
+		}
+		if len(d.Host) > 0 && !slices.Contains(d.Host, host.Reference()) {
+			continue
+		}
+		versions = append(versions, d.Key)
+	}
+	return versions, nil
+}
+
+// checkHardwareVersion reads the OVF descriptor and fails early if the host
+// supports none of the virtual hardware versions it declares, instead of
+// failing late during the import or on power-on. OVFs without a hardware
+// version are not checked, nor are hosts whose versions can't be queried.
+func (c *Client) checkHardwareVersion(ctx context.Context, imp *importer.Importer, host *object.HostSystem) error {
+	log := log.FromContext(ctx)
+
+	o, err := importer.ReadOvf("*.ovf", imp.Archive)
+	if err != nil {
+		return fmt.Errorf("failed to read ovf: %w", err)
+	}
+	e, err := importer.ReadEnvelope(o)
+	if err != nil {
+		return fmt.Errorf("failed to parse ovf: %w", err)
+	}
+
+	required := ovfHardwareVersions(e)
+	if len(required) == 0 {
+		return nil
+	}
+
+	supported, err := c.hostHardwareVersions(ctx, host)
+	if err != nil {
+		log.Info("Unable to check the hardware version of the OVF against the host", "host", host.Name(), "error", err.Error())
+		return nil
+	}
+
+	for _, version := range required {
+		if slices.Contains(supported, version) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %s does not support the virtual hardware %s declared by the OVF, supported versions: %s",
+		host.Name(), strings.Join(required, ", "), strings.Join(supported, ", "))
+}
@@ -0,0 +1,134 @@
+package vsphere
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Strategies selecting the host of an import from the hosts of a location
+const (
+	// hostSelectionPriority imports on the first usable host of the list
+	hostSelectionPriority = "priority"
+	// hostSelectionRoundRobin starts every import on the usable host after
+	// the one the previous import of the location started on
+	hostSelectionRoundRobin = "roundrobin"
+	// hostSelectionLeastLoaded imports on the usable host running the fewest
+	// imports of the operator, the first of the list on a tie
+	hostSelectionLeastLoaded = "leastloaded"
+)
+
+// importHosts returns the hosts an import into the location is tried on, in
+// order. Locations without a host list import on Host, or the first usable
+// host of the datacenter, without failing over.
+func (c *Client) importHosts(ctx context.Context, loc string, location *Location, finder *find.Finder) ([]*object.HostSystem, error) {
+	log := log.FromContext(ctx)
+
+	if len(location.Hosts) == 0 {
+		host, err := c.getHost(ctx, location.Host, finder)
+		if err != nil {
+			return nil, err
+		}
+		return []*object.HostSystem{host}, nil
+	}
+
+	var hosts []*object.HostSystem
+	for _, name := range location.Hosts {
+		host, err := c.findHost(ctx, name, finder)
+		if err == nil {
+			err = c.validateHostState(ctx, host)
+		}
+		if err != nil {
+			log.Info("Skipping host due to unusable state", "host", name, "reason", err.Error())
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no usable hosts found among the hosts of location %s", loc)
+	}
+	return c.orderHosts(loc, location.HostSelection, hosts), nil
+}
+
+// orderHosts orders the usable hosts of the location by its host selection
+func (c *Client) orderHosts(loc string, selection string, hosts []*object.HostSystem) []*object.HostSystem {
+	c.hostMu.Lock()
+	defer c.hostMu.Unlock()
+
+	switch selection {
+	case hostSelectionRoundRobin:
+		if c.nextHost == nil {
+			c.nextHost = make(map[string]int)
+		}
+		start := c.nextHost[loc] % len(hosts)
+		c.nextHost[loc] = start + 1
+		return slices.Concat(hosts[start:], hosts[:start])
+	case hostSelectionLeastLoaded:
+		slices.SortStableFunc(hosts, func(a, b *object.HostSystem) int {
+			return cmp.Compare(c.hostImports[a.Reference().Value], c.hostImports[b.Reference().Value])
+		})
+	}
+	return hosts
+}
+
+// trackHostImport counts an import running on the host until the returned
+// function is called
+func (c *Client) trackHostImport(host *object.HostSystem) func() {
+	c.hostMu.Lock()
+	defer c.hostMu.Unlock()
+
+	if c.hostImports == nil {
+		c.hostImports = make(map[string]int)
+	}
+	ref := host.Reference().Value
+	c.hostImports[ref]++
+	return func() {
+		c.hostMu.Lock()
+		defer c.hostMu.Unlock()
+		c.hostImports[ref]--
+	}
+}
+
+// importWithFailover runs the import on the first of the hosts. If it fails
+// because the host became unusable in the meantime, e.g. it entered
+// maintenance mode, the import is retried on the next host.
+func (c *Client) importWithFailover(ctx context.Context, hosts []*object.HostSystem,
+	importOn func(host *object.HostSystem) (*types.ManagedObjectReference, error)) (*types.ManagedObjectReference, error) {
+
+	log := log.FromContext(ctx)
+
+	for i, host := range hosts {
+		done := c.trackHostImport(host)
+		entity, err := importOn(host)
+		done()
+		if err == nil || i == len(hosts)-1 || ctx.Err() != nil {
+			return entity, err
+		}
+
+		hostErr := c.validateHostState(ctx, host)
+		if hostErr == nil {
+			return nil, err
+		}
+		log.Info("Import host became unusable, retrying on the next host", "host", host.Name(),
+			"reason", hostErr.Error(), "error", err.Error())
+	}
+	return nil, fmt.Errorf("no hosts to import on")
+}
+
+// findHost returns the host with the given name, inventory path or ID
+func (c *Client) findHost(ctx context.Context, hostName string, finder *find.Finder) (*object.HostSystem, error) {
+	host, ok, err := lookupMoref[*object.HostSystem](ctx, c.vsphere.Client, hostName)
+	if !ok {
+		host, err = finder.HostSystemOrDefault(ctx, hostName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find host %s: %w", hostName, err)
+	}
+	return host, nil
+}
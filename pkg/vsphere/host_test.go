@@ -0,0 +1,293 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	hostH0 = "/DC0/host/DC0_C0/DC0_C0_H0"
+	hostH1 = "/DC0/host/DC0_C0/DC0_C0_H1"
+	hostH2 = "/DC0/host/DC0_C0/DC0_C0_H2"
+)
+
+func TestLoadLocationsHosts(t *testing.T) {
+	testCases := []struct {
+		name        string
+		hosts       string
+		expected    []string
+		expectError bool
+	}{
+		{
+			name: "case 0: no host list",
+		},
+		{
+			name:     "case 1: host list with a selection",
+			hosts:    "\n  hosts: [esx-1, esx-2]\n  hostselection: roundrobin",
+			expected: []string{"esx-1", "esx-2"},
+		},
+		{
+			name:        "case 2: host and hosts are rejected",
+			hosts:       "\n  host: esx-1\n  hosts: [esx-2]",
+			expectError: true,
+		},
+		{
+			name:        "case 3: unknown selection",
+			hosts:       "\n  hosts: [esx-1]\n  hostselection: random",
+			expectError: true,
+		},
+		{
+			name:        "case 4: selection without hosts",
+			hosts:       "\n  hostselection: leastloaded",
+			expectError: true,
+		},
+		{
+			name:        "case 5: moref of another type",
+			hosts:       "\n  hosts: [datastore-1]",
+			expectError: true,
+		},
+		{
+			name:        "case 6: standalone without host",
+			hosts:       "\n  standalone: true",
+			expectError: true,
+		},
+		{
+			name:        "case 7: standalone with a resource pool",
+			hosts:       "\n  standalone: true\n  host: esx-1\n  resourcepool: pool",
+			expectError: true,
+		},
+		{
+			name:     "case 8: standalone with hosts",
+			hosts:    "\n  standalone: true\n  hosts: [esx-1]",
+			expected: []string{"esx-1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content := `loc:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0` + tc.hosts
+
+			locations, err := loadLocations(writeTempFile(t, "locations-*.yaml", content))
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, locations["loc"].Hosts)
+		})
+	}
+}
+
+func TestImportHosts(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		finder := find.NewFinder(vc, true)
+		dc, err := finder.Datacenter(ctx, "DC0")
+		require.NoError(t, err)
+		finder.SetDatacenter(dc)
+
+		hosts := map[string]*object.HostSystem{}
+		for _, path := range []string{hostH0, hostH1, hostH2} {
+			host, err := finder.HostSystem(ctx, path)
+			require.NoError(t, err)
+			hosts[path] = host
+		}
+		enterMaintenanceMode(ctx, t, hosts[hostH1])
+
+		list := []string{hostH0, hostH1, hostH2, "/DC0/host/DC0_C0/missing"}
+		c := newTestClient(vc, map[string]*Location{
+			"single":      {Datacenter: "DC0", Host: hostH2},
+			"priority":    {Datacenter: "DC0", Hosts: list},
+			"roundrobin":  {Datacenter: "DC0", Hosts: list, HostSelection: hostSelectionRoundRobin},
+			"leastloaded": {Datacenter: "DC0", Hosts: list, HostSelection: hostSelectionLeastLoaded},
+			"unusable":    {Datacenter: "DC0", Hosts: []string{hostH1}},
+		})
+
+		names := func(loc string) []string {
+			t.Helper()
+			selected, err := c.importHosts(ctx, loc, c.location(loc), finder)
+			require.NoError(t, err, loc)
+			var names []string
+			for _, host := range selected {
+				names = append(names, host.Name())
+			}
+			return names
+		}
+
+		// hosts in maintenance mode and missing hosts are skipped
+		assert.Equal(t, []string{"DC0_C0_H2"}, names("single"))
+		assert.Equal(t, []string{"DC0_C0_H0", "DC0_C0_H2"}, names("priority"))
+		assert.Equal(t, []string{"DC0_C0_H0", "DC0_C0_H2"}, names("priority"))
+
+		assert.Equal(t, []string{"DC0_C0_H0", "DC0_C0_H2"}, names("roundrobin"))
+		assert.Equal(t, []string{"DC0_C0_H2", "DC0_C0_H0"}, names("roundrobin"))
+		assert.Equal(t, []string{"DC0_C0_H0", "DC0_C0_H2"}, names("roundrobin"))
+
+		assert.Equal(t, []string{"DC0_C0_H0", "DC0_C0_H2"}, names("leastloaded"))
+		done := c.trackHostImport(hosts[hostH0])
+		assert.Equal(t, []string{"DC0_C0_H2", "DC0_C0_H0"}, names("leastloaded"))
+		done()
+		assert.Equal(t, []string{"DC0_C0_H0", "DC0_C0_H2"}, names("leastloaded"))
+
+		_, err = c.importHosts(ctx, "unusable", c.location("unusable"), finder)
+		assert.ErrorContains(t, err, "no usable hosts found")
+	})
+}
+
+func TestImportWithFailover(t *testing.T) {
+	testCases := []struct {
+		name          string
+		maintenance   bool
+		expectedHosts []string
+		expectError   bool
+	}{
+		{
+			name:          "case 0: host entering maintenance mode fails over",
+			maintenance:   true,
+			expectedHosts: []string{"DC0_C0_H0", "DC0_C0_H1"},
+		},
+		{
+			name:          "case 1: failure on a usable host is not retried",
+			expectedHosts: []string{"DC0_C0_H0"},
+			expectError:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				c := newTestClient(vc, nil)
+				finder := find.NewFinder(vc, true)
+				var hosts []*object.HostSystem
+				for _, path := range []string{hostH0, hostH1} {
+					host, err := finder.HostSystem(ctx, path)
+					require.NoError(t, err)
+					hosts = append(hosts, host)
+				}
+
+				var tried []string
+				entity, err := c.importWithFailover(ctx, hosts, func(host *object.HostSystem) (*types.ManagedObjectReference, error) {
+					tried = append(tried, host.Name())
+					if len(tried) > 1 {
+						return &types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"}, nil
+					}
+					if tc.maintenance {
+						enterMaintenanceMode(ctx, t, host)
+					}
+					return nil, errors.New("lease failed")
+				})
+				assert.Equal(t, tc.expectedHosts, tried)
+				if tc.expectError {
+					assert.ErrorContains(t, err, "lease failed")
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, "vm-1", entity.Value)
+			})
+		})
+	}
+}
+
+func TestCreateRoundRobin(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc": {
+				Datacenter: "DC0", Folder: "/DC0/vm", Cluster: "DC0_C0", Datastore: "LocalDS_0",
+				Hosts: []string{hostH0, hostH1}, HostSelection: hostSelectionRoundRobin,
+			},
+		})
+		c.importSlots = make(chan struct{}, 1)
+
+		ova := writeOVA(t, map[string]string{"image.ovf": minimalOVF})
+		for _, name := range []string{"image-a", "image-b"} {
+			require.NoError(t, c.Create(ctx, ova, name, "loc"))
+		}
+
+		// the imports are spread across the hosts
+		for name, expected := range map[string]string{"image-a": "DC0_C0_H0", "image-b": "DC0_C0_H1"} {
+			vm, err := find.NewFinder(vc, true).VirtualMachine(ctx, "/DC0/vm/"+name)
+			require.NoError(t, err)
+			host, err := vm.HostSystem(ctx)
+			require.NoError(t, err)
+			var h mo.HostSystem
+			require.NoError(t, host.Properties(ctx, host.Reference(), []string{"name"}, &h))
+			assert.Equal(t, expected, h.Name, name)
+		}
+	})
+}
+
+func TestCreateStandalone(t *testing.T) {
+	testCases := []struct {
+		name         string
+		location     *Location
+		disableDRS   bool
+		expectedPool string
+	}{
+		{
+			name:         "case 0: standalone host without a cluster",
+			location:     &Location{Host: "/DC0/host/DC0_H0/DC0_H0"},
+			expectedPool: "/DC0/host/DC0_H0/Resources",
+		},
+		{
+			name:         "case 1: host of a cluster without DRS",
+			location:     &Location{Cluster: "DC0_C0", Standalone: true, Hosts: []string{hostH1}},
+			disableDRS:   true,
+			expectedPool: "/DC0/host/DC0_C0/Resources",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				finder := find.NewFinder(vc, true)
+				if tc.disableDRS {
+					cluster, err := finder.ClusterComputeResource(ctx, "/DC0/host/DC0_C0")
+					require.NoError(t, err)
+					task, err := cluster.Reconfigure(ctx, &types.ClusterConfigSpecEx{
+						DrsConfig: &types.ClusterDrsConfigInfo{Enabled: types.NewBool(false)},
+					}, true)
+					require.NoError(t, err)
+					require.NoError(t, task.Wait(ctx))
+				}
+
+				tc.location.Datacenter = "DC0"
+				tc.location.Folder = "/DC0/vm"
+				tc.location.Datastore = "LocalDS_0"
+				c := newTestClient(vc, map[string]*Location{"loc": tc.location})
+				c.importSlots = make(chan struct{}, 1)
+
+				ova := writeOVA(t, map[string]string{"image.ovf": minimalOVF})
+				require.NoError(t, c.Create(ctx, ova, "image", "loc"))
+
+				vm, err := finder.VirtualMachine(ctx, "/DC0/vm/image")
+				require.NoError(t, err)
+				pool, err := vm.ResourcePool(ctx)
+				require.NoError(t, err)
+				poolPath, err := find.InventoryPath(ctx, vc, pool.Reference())
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedPool, poolPath)
+			})
+		})
+	}
+}
+
+// enterMaintenanceMode puts the host into maintenance mode
+func enterMaintenanceMode(ctx context.Context, t *testing.T, host *object.HostSystem) {
+	t.Helper()
+	task, err := host.EnterMaintenanceMode(ctx, 0, false, nil)
+	require.NoError(t, err)
+	require.NoError(t, task.Wait(ctx))
+}
@@ -4,21 +4,33 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/tls"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"net"
 	"net/url"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/nfc"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/ovf"
 	"github.com/vmware/govmomi/ovf/importer"
+	"github.com/vmware/govmomi/task"
+	"github.com/vmware/govmomi/vapi/library"
 	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/progress"
 	"github.com/vmware/govmomi/vim25/types"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
 )
 
 // ImporterConfig holds the configuration for the OVF importer
@@ -35,83 +47,229 @@ type ImporterConfig struct {
 }
 
 // importImage imports an OVF image to vSphere
-func (c *Client) importImage(ctx context.Context, imageURL string, imageName string, loc string) (
+func (c *Client) importImage(ctx context.Context, imageURL string, imageName string, loc string, location *Location) (
 	*types.ManagedObjectReference, error) {
 
-	log := log.FromContext(ctx)
-
 	finder := find.NewFinder(c.vsphere.Client, true)
 
-	dc, err := c.getDatacenter(ctx, finder, loc)
+	dc, err := c.getDatacenter(ctx, finder, location)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get datacenter: %w", err)
 	}
 	finder.SetDatacenter(dc)
 
-	datastore, err := c.getDatastore(ctx, finder, loc)
+	datastore, err := c.getDatastore(ctx, finder, location)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get datastore: %w", err)
 	}
 
-	folder, err := c.getFolder(ctx, c.locations[loc].Folder, finder)
+	folder, err := c.getFolder(ctx, location, finder)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get folder: %w", err)
 	}
 
-	pool, err := c.getResourcePool(ctx, c.locations[loc].Resourcepool, finder)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get resource pool: %w", err)
+	// standalone locations import into the pool of the host chosen below
+	var pool *object.ResourcePool
+	if !location.standalone() {
+		pool, err = c.getResourcePool(ctx, location, finder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get resource pool: %w", err)
+		}
 	}
 
-	host, err := c.getHost(ctx, c.locations[loc].Host, finder)
+	hosts, err := c.importHosts(ctx, loc, location, finder)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get host: %w", err)
 	}
 
-	network, err := c.getNetwork(ctx, c.locations[loc].Network, finder)
+	networks, err := c.networkMapping(ctx, location, finder)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get network: %w", err)
 	}
 
-	imageSuffix := c.locations[loc].ImageSuffix
-	if len(imageSuffix) > 0 {
-		imageName = fmt.Sprintf("%s-%s", imageName, imageSuffix)
-	}
+	imageName = location.imageName(imageName)
 
-	options := &importer.Options{
+	options := importer.Options{
 		Name:             &imageName,
+		Annotation:       provenanceAnnotation(ctx),
 		DiskProvisioning: "thin",
-		NetworkMapping: []importer.Network{
-			{
-				Name:    "nic0",
-				Network: network.String(),
-			},
-		},
+		NetworkMapping:   networks,
 	}
 
-	importer := c.getImporter(
-		ImporterConfig{
+	return c.importWithFailover(ctx, hosts, func(host *object.HostSystem) (*types.ManagedObjectReference, error) {
+		hostPool := pool
+		if hostPool == nil {
+			var err error
+			if hostPool, err = hostResourcePool(ctx, host); err != nil {
+				return nil, err
+			}
+		}
+		return c.importOnHost(ctx, ImporterConfig{
 			Name:         imageName,
 			Datacenter:   dc,
 			Datastore:    datastore,
 			Folder:       folder,
 			Host:         host,
-			ResourcePool: pool,
+			ResourcePool: hostPool,
 			Finder:       finder,
 			Path:         imageURL,
-		},
-	)
+		}, options, location)
+	})
+}
+
+// importOnHost imports the OVF of the config on its host
+func (c *Client) importOnHost(ctx context.Context, config ImporterConfig, options importer.Options, location *Location) (
+	*types.ManagedObjectReference, error) {
+
+	log := log.FromContext(ctx)
+
+	importer := c.getImporter(ctx, config)
+	imageURL, host := config.Path, config.Host
 
-	log.Info("Importing OVF", "imageURL", imageURL, "imageName", imageName)
+	log.Info("Importing OVF", "imageURL", imageURL, "imageName", config.Name, "host", host.Name())
 
-	if c.pullMode {
+	if err := c.checkHardwareVersion(ctx, importer, host); err != nil {
+		return nil, err
+	}
+	if err := c.checkImageSize(importer, imageURL); err != nil {
+		return nil, err
+	}
+	if err := checkDatastoreSpace(ctx, importer, config.Datastore, imageURL); err != nil {
+		return nil, err
+	}
+	var err error
+	options.PropertyMapping, err = c.propertyMapping(ctx, importer, location)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.usePullMode() {
 		log.Info("Pull mode enabled")
-		return pullImport(ctx, "*.ovf", *options, importer, imageURL)
+		return pullImport(ctx, "*.ovf", options, importer, imageURL, c.pullRetries, c.pullRetryInterval)
+	}
+	if err := checkArchiveProperties(importer, options.PropertyMapping); err != nil {
+		return nil, err
+	}
+	if c.verifyChecksum {
+		if err := verifyChecksums(importer, "*.ovf"); err != nil {
+			return nil, err
+		}
+	}
+	return importer.Import(ctx, "*.ovf", options)
+}
+
+// networkMapping maps the networks of the OVF to the networks configured for
+// the location. Without a network mapping nic0 is attached to the location's
+// network, or the first network of the datacenter if none is set.
+func (c *Client) networkMapping(ctx context.Context, location *Location, finder *find.Finder) ([]importer.Network, error) {
+	mapping := location.NetworkMapping
+	if len(mapping) == 0 {
+		network, err := c.getNetwork(ctx, location.Network, finder)
+		if err != nil {
+			return nil, err
+		}
+		return []importer.Network{{Name: "nic0", Network: network.String()}}, nil
+	}
+
+	ovfNetworks := make([]string, 0, len(mapping))
+	for ovfNetwork := range mapping {
+		ovfNetworks = append(ovfNetworks, ovfNetwork)
+	}
+	sort.Strings(ovfNetworks)
+
+	networks := make([]importer.Network, 0, len(mapping))
+	for _, ovfNetwork := range ovfNetworks {
+		network, err := c.getNetwork(ctx, mapping[ovfNetwork], finder)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, importer.Network{Name: ovfNetwork, Network: network.String()})
+	}
+	return networks, nil
+}
+
+// usePullMode reports whether images are imported in pull mode. In pull mode
+// vSphere fetches the image itself and the operator never sees its bytes, so
+// checksum verification falls back to push mode.
+func (c *Client) usePullMode() bool {
+	return c.pullMode && !c.verifyChecksum
+}
+
+// verifyChecksums reads the manifest of the OVA and makes the importer verify
+// every file it uploads against it while streaming
+func verifyChecksums(imp *importer.Importer, fpath string) error {
+	if err := imp.ReadManifest(fpath); err != nil {
+		return fmt.Errorf("failed to read manifest for checksum verification: %w", err)
+	}
+	imp.Archive = &checksumArchive{Archive: imp.Archive, manifest: imp.Manifest}
+	return nil
+}
+
+// checksumArchive computes the checksum of the files read from the archive
+// and fails the read once a file's checksum does not match the manifest
+type checksumArchive struct {
+	importer.Archive
+	manifest map[string]*library.Checksum
+}
+
+func (a *checksumArchive) Open(name string) (io.ReadCloser, int64, error) {
+	r, size, err := a.Archive.Open(name)
+	if err != nil {
+		return nil, 0, err
 	}
-	return importer.Import(ctx, "*.ovf", *options)
+
+	// the descriptor and the manifest itself are looked up by pattern
+	if strings.ContainsAny(name, "*?[") {
+		return r, size, nil
+	}
+
+	sum, ok := a.manifest[name]
+	if !ok {
+		_ = r.Close()
+		return nil, 0, fmt.Errorf("missing checksum for %s in manifest", name)
+	}
+	h, err := newChecksumHash(sum.Algorithm)
+	if err != nil {
+		_ = r.Close()
+		return nil, 0, fmt.Errorf("failed to verify %s: %w", name, err)
+	}
+
+	return &checksumReader{ReadCloser: r, name: name, hash: h, expected: sum.Checksum}, size, nil
+}
+
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case "SHA1":
+		return sha1.New(), nil // #nosec G401 -- only used to compare against the manifest
+	case "SHA256":
+		return sha256.New(), nil
+	case "SHA512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %s", algorithm)
+}
+
+// checksumReader hashes everything read and compares the checksum when the
+// end of the file is reached
+type checksumReader struct {
+	io.ReadCloser
+	name     string
+	hash     hash.Hash
+	expected string
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		if actual := hex.EncodeToString(r.hash.Sum(nil)); !strings.EqualFold(actual, r.expected) {
+			return n, fmt.Errorf("checksum mismatch for %s: manifest has %s, got %s", r.name, r.expected, actual)
+		}
+	}
+	return n, err
 }
 
-func (c *Client) getImporter(config ImporterConfig) *importer.Importer {
+func (c *Client) getImporter(ctx context.Context, config ImporterConfig) *importer.Importer {
 	archive := &importer.TapeArchive{Path: config.Path}
 	archive.Client = c.vsphere.Client
 
@@ -124,16 +282,19 @@ func (c *Client) getImporter(config ImporterConfig) *importer.Importer {
 		Host:           config.Host,
 		ResourcePool:   config.ResourcePool,
 		Finder:         config.Finder,
-		Log:            func(msg string) (int, error) { return fmt.Print(msg) },
+		Log:            importerLog(ctx),
 		Archive:        archive,
 		Manifest:       nil, // Placeholder, update if needed
 		VerifyManifest: false,
 	}
 }
 
-// based on upstream importer package except we use pull instead of push
+// based on upstream importer package except we use pull instead of push.
+// A failed pull task is retried up to retries times, each time with a fresh
+// lease, waiting twice as long before every further retry.
 func pullImport(ctx context.Context,
-	fpath string, opts importer.Options, imp *importer.Importer, url string) (*types.ManagedObjectReference, error) {
+	fpath string, opts importer.Options, imp *importer.Importer, url string,
+	retries int, retryInterval time.Duration) (*types.ManagedObjectReference, error) {
 
 	o, err := importer.ReadOvf(fpath, imp.Archive)
 	if err != nil {
@@ -144,6 +305,9 @@ func pullImport(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse ovf: %s", err)
 	}
+	if err := checkRequiredProperties(e, opts.PropertyMapping); err != nil {
+		return nil, err
+	}
 
 	if e.VirtualSystem != nil {
 		if e.VirtualSystem != nil {
@@ -213,19 +377,116 @@ func pullImport(ctx context.Context,
 		}
 	}
 
+	var entity *types.ManagedObjectReference
+	err = retryPull(ctx, retries, retryInterval, func() error {
+		var err error
+		entity, err = pullLease(ctx, imp, spec, url)
+		return err
+	})
+	return entity, err
+}
+
+// errPullFailed marks a failed pull task, which is worth retrying as it
+// usually fails because of a transient issue during the transfer, e.g. the
+// network or a busy datastore
+var errPullFailed = errors.New("pull task failed")
+
+// maxPullRetryInterval caps the time waited between retries of a pull task
+const maxPullRetryInterval = 5 * time.Minute
+
+// retryPull runs pull until it succeeds, fails with an error other than
+// errPullFailed or was retried retries times. The interval between retries
+// doubles with every attempt, up to maxPullRetryInterval.
+func retryPull(ctx context.Context, retries int, interval time.Duration, pull func() error) error {
+	log := log.FromContext(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := pull()
+		if err == nil || !errors.Is(err, errPullFailed) || attempt > retries || ctx.Err() != nil {
+			return err
+		}
+
+		log.Info("Pull task failed, retrying with a new lease", "attempt", attempt, "retries", retries,
+			"delay", interval.String(), "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+		interval = min(2*interval, maxPullRetryInterval)
+	}
+}
+
+// waitPullTask waits for the pull task to finish. A failed task is marked
+// with errPullFailed to be retried, unless its fault fails every attempt.
+func waitPullTask(ctx context.Context, t *object.Task, total int64) error {
+	_, err := t.WaitForResultEx(ctx, pullProgress(ctx, total))
+	if err == nil {
+		return nil
+	}
+	var taskErr task.Error
+	if errors.As(err, &taskErr) && permanentPullFault(taskErr.Fault()) {
+		return fmt.Errorf("pull task failed permanently: %w", err)
+	}
+	return fmt.Errorf("%w: %w", errPullFailed, err)
+}
+
+// permanentPullFault reports whether the fault of a pull task is caused by
+// the OVF or the session rather than the transfer, so a retry with a new
+// lease fails the same way
+func permanentPullFault(fault types.BaseMethodFault) bool {
+	switch fault.(type) {
+	case types.BaseOvfFault, *types.InvalidLogin, *types.NotAuthenticated, *types.NoPermission, *types.InvalidArgument:
+		return true
+	}
+	return false
+}
+
+// leaseAbortTimeout bounds aborting a lease, which runs with a fresh context
+const leaseAbortTimeout = 30 * time.Second
+
+// abortLease aborts the lease, making vSphere remove the partial import. The
+// abort runs with a fresh context, as it is often caused by ctx being
+// cancelled, e.g. when the operator shuts down.
+func abortLease(ctx context.Context, lease *nfc.Lease) {
+	abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), leaseAbortTimeout)
+	defer cancel()
+	if err := lease.Abort(abortCtx, nil); err != nil {
+		log.FromContext(ctx).Info("Failed to abort import lease", "error", err.Error())
+		return
+	}
+	log.FromContext(ctx).Info("Import lease aborted", "lease", lease.Reference().Value)
+}
+
+// importerLog passes the messages of the importer to the logger of ctx, so
+// they carry the fields of the reconciler
+func importerLog(ctx context.Context) func(string) (int, error) {
+	return func(msg string) (int, error) {
+		log.FromContext(ctx).Info(strings.TrimSpace(msg))
+		return len(msg), nil
+	}
+}
+
+// pullLease imports the spec with a new lease, letting vSphere pull the
+// files from url. The lease is aborted on failure, removing the partial import.
+func pullLease(ctx context.Context, imp *importer.Importer, spec *types.OvfCreateImportSpecResult, url string) (
+	*types.ManagedObjectReference, error) {
+
 	lease, err := imp.ResourcePool.ImportVApp(ctx, spec.ImportSpec, imp.Folder, imp.Host)
 	if err != nil {
 		return nil, err
 	}
 
-	thumbprint, err := getSSLFingerprint(url)
+	thumbprint, err := getSSLFingerprint(ctx, url)
 	if err != nil {
-		_ = lease.Abort(ctx, nil)
+		abortLease(ctx, lease)
 		return nil, fmt.Errorf("failed to get SSL fingerprint: %w", err)
 	}
 
+	var total int64
 	sourceFiles := make([]types.HttpNfcLeaseSourceFile, len(spec.FileItem))
 	for i, fileItem := range spec.FileItem {
+		total += fileItem.Size
 		sourceFiles[i] = types.HttpNfcLeaseSourceFile{
 			Url:            url,
 			TargetDeviceId: fileItem.DeviceId,
@@ -239,7 +500,7 @@ func pullImport(ctx context.Context,
 	// Wait for lease to be ready
 	info, err := lease.Wait(ctx, spec.FileItem)
 	if err != nil {
-		_ = lease.Abort(ctx, nil)
+		abortLease(ctx, lease)
 		return nil, fmt.Errorf("failed to wait for lease: %w", err)
 	}
 
@@ -248,22 +509,40 @@ func pullImport(ctx context.Context,
 		Files: sourceFiles,
 	})
 	if err != nil {
-		_ = lease.Abort(ctx, nil)
+		abortLease(ctx, lease)
 		return nil, fmt.Errorf("failed to start pull task: %w", err)
 	}
 
 	// Wait for task completion
-	task := object.NewTask(imp.Client, t.Returnval)
-	if err := task.WaitEx(ctx); err != nil {
-		_ = lease.Abort(ctx, nil)
-		return nil, fmt.Errorf("pull task failed: %w", err)
+	if err := waitPullTask(ctx, object.NewTask(imp.Client, t.Returnval), total); err != nil {
+		abortLease(ctx, lease)
+		return nil, err
 	}
 
 	// Complete the lease
 	return &info.Entity, lease.Complete(ctx)
 }
 
-func getSSLFingerprint(imageURL string) (string, error) {
+// pullProgress passes the progress of a pull task to the progress function of
+// the context as the share of total transferred. Nothing is reported if the
+// size of the files is unknown.
+func pullProgress(ctx context.Context, total int64) progress.Sinker {
+	return progress.SinkFunc(func() chan<- progress.Report {
+		ch := make(chan progress.Report)
+		go func() {
+			for report := range ch {
+				if total <= 0 {
+					continue
+				}
+				transferred := int64(float64(report.Percentage()) * float64(total) / 100)
+				provider.ReportProgress(ctx, transferred, total)
+			}
+		}()
+		return ch
+	})
+}
+
+func getSSLFingerprint(ctx context.Context, imageURL string) (string, error) {
 	u, err := url.Parse(imageURL)
 	if err != nil {
 		return "", fmt.Errorf("invalid URL: %w", err)
@@ -285,7 +564,7 @@ func getSSLFingerprint(imageURL string) (string, error) {
 	}
 	defer func() {
 		if cerr := conn.Close(); cerr != nil {
-			fmt.Printf("failed to close connection: %v\n", cerr)
+			log.FromContext(ctx).Error(cerr, "failed to close connection")
 		}
 	}()
 
@@ -0,0 +1,476 @@
+package vsphere
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf/importer"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+func TestUsePullMode(t *testing.T) {
+	testCases := []struct {
+		name           string
+		pullMode       bool
+		verifyChecksum bool
+		expected       bool
+	}{
+		{
+			name:     "case 0: push mode",
+			expected: false,
+		},
+		{
+			name:     "case 1: pull mode",
+			pullMode: true,
+			expected: true,
+		},
+		{
+			name:           "case 2: checksum verification falls back to push mode",
+			pullMode:       true,
+			verifyChecksum: true,
+			expected:       false,
+		},
+		{
+			name:           "case 3: checksum verification in push mode",
+			verifyChecksum: true,
+			expected:       false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{pullMode: tc.pullMode, verifyChecksum: tc.verifyChecksum}
+			assert.Equal(t, tc.expected, c.usePullMode())
+		})
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	disk := "disk content"
+	sum := sha256.Sum256([]byte(disk))
+
+	testCases := []struct {
+		name          string
+		manifest      string
+		expectedError string
+		openError     string
+		readError     string
+	}{
+		{
+			name:     "case 0: matching checksum",
+			manifest: fmt.Sprintf("SHA256(disk.vmdk)= %s\n", hex.EncodeToString(sum[:])),
+		},
+		{
+			name:     "case 1: uppercase checksum",
+			manifest: fmt.Sprintf("SHA256(disk.vmdk)= %X\n", sum[:]),
+		},
+		{
+			name:      "case 2: checksum mismatch",
+			manifest:  fmt.Sprintf("SHA256(disk.vmdk)= %s\n", hex.EncodeToString(make([]byte, 32))),
+			readError: "checksum mismatch for disk.vmdk",
+		},
+		{
+			name:      "case 3: file missing in manifest",
+			manifest:  "SHA256(other.vmdk)= 00\n",
+			openError: "missing checksum for disk.vmdk",
+		},
+		{
+			name:      "case 4: unsupported algorithm",
+			manifest:  "MD5(disk.vmdk)= 00\n",
+			openError: "unsupported checksum algorithm MD5",
+		},
+		{
+			name:          "case 5: no manifest",
+			expectedError: "failed to read manifest",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string]string{"image.ovf": "<Envelope/>", "disk.vmdk": disk}
+			if tc.manifest != "" {
+				files["image.mf"] = tc.manifest
+			}
+			imp := &importer.Importer{Archive: &importer.TapeArchive{Path: writeOVA(t, files)}}
+
+			err := verifyChecksums(imp, "*.ovf")
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			// the descriptor is opened by pattern and not verified
+			r, _, err := imp.Archive.Open("*.ovf")
+			require.NoError(t, err)
+			_ = r.Close()
+
+			r, size, err := imp.Archive.Open("disk.vmdk")
+			if tc.openError != "" {
+				require.ErrorContains(t, err, tc.openError)
+				return
+			}
+			require.NoError(t, err)
+			defer func() { _ = r.Close() }()
+			assert.Equal(t, int64(len(disk)), size)
+
+			content, err := io.ReadAll(r)
+			if tc.readError != "" {
+				require.ErrorContains(t, err, tc.readError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, disk, string(content))
+		})
+	}
+}
+
+func TestRetryPull(t *testing.T) {
+	transient := fmt.Errorf("%w: connection reset by peer", errPullFailed)
+
+	testCases := []struct {
+		name           string
+		retries        int
+		errs           []error
+		expectedLeases int
+		expectedError  bool
+	}{
+		{
+			name:           "case 0: successful pull",
+			retries:        2,
+			expectedLeases: 1,
+		},
+		{
+			name:           "case 1: transient task failure followed by success",
+			retries:        2,
+			errs:           []error{transient},
+			expectedLeases: 2,
+		},
+		{
+			name:           "case 2: retries exhausted",
+			retries:        2,
+			errs:           []error{transient, transient, transient, transient},
+			expectedLeases: 3,
+			expectedError:  true,
+		},
+		{
+			name:           "case 3: retries disabled",
+			errs:           []error{transient},
+			expectedLeases: 1,
+			expectedError:  true,
+		},
+		{
+			name:           "case 4: other failures are not retried",
+			retries:        2,
+			errs:           []error{errors.New("failed to wait for lease")},
+			expectedLeases: 1,
+			expectedError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// every call of pull stands for a fresh lease
+			leases := 0
+			err := retryPull(context.TODO(), tc.retries, 0, func() error {
+				leases++
+				if leases <= len(tc.errs) {
+					return tc.errs[leases-1]
+				}
+				return nil
+			})
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedLeases, leases)
+		})
+	}
+}
+
+func TestRetryPullContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	leases := 0
+	err := retryPull(ctx, 2, time.Hour, func() error {
+		leases++
+		cancel()
+		return errPullFailed
+	})
+	assert.ErrorIs(t, err, errPullFailed)
+	assert.Equal(t, 1, leases)
+}
+
+func TestRetryPullTask(t *testing.T) {
+	testCases := []struct {
+		name             string
+		faults           []types.BaseMethodFault
+		expectedAttempts int
+		expectedError    string
+	}{
+		{
+			name:             "case 0: busy datastore followed by success",
+			faults:           []types.BaseMethodFault{&types.ResourceInUse{}},
+			expectedAttempts: 2,
+		},
+		{
+			name:             "case 1: transient failures exhaust the retries",
+			faults:           []types.BaseMethodFault{&types.ResourceInUse{}, &types.HostCommunication{}, &types.ResourceInUse{}},
+			expectedAttempts: 3,
+			expectedError:    errPullFailed.Error(),
+		},
+		{
+			name:             "case 2: invalid OVF is not retried",
+			faults:           []types.BaseMethodFault{&types.OvfImportFailed{}},
+			expectedAttempts: 1,
+			expectedError:    "failed permanently",
+		},
+		{
+			name:             "case 3: missing permission is not retried",
+			faults:           []types.BaseMethodFault{&types.NoPermission{}},
+			expectedAttempts: 1,
+			expectedError:    "failed permanently",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			model := simulator.VPX()
+			defer model.Remove()
+
+			require.NoError(t, model.Run(func(ctx context.Context, vc *vim25.Client) error {
+				attempts := 0
+				err := retryPull(ctx, 2, time.Millisecond, func() error {
+					attempts++
+					var fault types.BaseMethodFault
+					if attempts <= len(tc.faults) {
+						fault = tc.faults[attempts-1]
+					}
+					// a pull task failing with the fault of the attempt
+					ref := simulator.CreateTask(vc.ServiceContent.RootFolder, "pullFromUrls",
+						func(*simulator.Task) (types.AnyType, types.BaseMethodFault) {
+							return nil, fault
+						}).Run(model.Service.Context)
+					return waitPullTask(ctx, object.NewTask(vc, ref), 0)
+				})
+				if tc.expectedError != "" {
+					assert.ErrorContains(t, err, tc.expectedError)
+				} else {
+					assert.NoError(t, err)
+				}
+				assert.Equal(t, tc.expectedAttempts, attempts)
+				return nil
+			}))
+		})
+	}
+}
+
+// pullReport is a progress report of a pull task
+type pullReport float32
+
+func (r pullReport) Percentage() float32 { return float32(r) }
+func (r pullReport) Detail() string      { return "" }
+func (r pullReport) Error() error        { return nil }
+
+func TestPullProgress(t *testing.T) {
+	testCases := []struct {
+		name     string
+		total    int64
+		reports  []pullReport
+		expected [][2]int64
+	}{
+		{
+			name:     "case 0: percentage is reported as bytes of the total",
+			total:    6_000_000_000,
+			reports:  []pullReport{10, 70, 100},
+			expected: [][2]int64{{600_000_000, 6_000_000_000}, {4_200_000_000, 6_000_000_000}, {6_000_000_000, 6_000_000_000}},
+		},
+		{
+			name:    "case 1: nothing is reported for an unknown size",
+			reports: []pullReport{50},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var reported [][2]int64
+			ctx := provider.WithProgress(context.TODO(), func(transferred int64, total int64) {
+				mu.Lock()
+				defer mu.Unlock()
+				reported = append(reported, [2]int64{transferred, total})
+			})
+
+			ch := pullProgress(ctx, tc.total).Sink()
+			for _, report := range tc.reports {
+				ch <- report
+			}
+			close(ch)
+
+			assert.Eventually(t, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(reported) == len(tc.expected)
+			}, time.Second, time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tc.expected, reported)
+		})
+	}
+}
+
+func TestNetworkMapping(t *testing.T) {
+	testCases := []struct {
+		name          string
+		location      *Location
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:     "case 0: nic0 is attached to the location's network without a mapping",
+			location: &Location{Datacenter: "DC0", Network: "DC0_DVPG0"},
+			expected: []string{"nic0=DC0_DVPG0"},
+		},
+		{
+			name: "case 1: mapped OVF networks are attached to their networks",
+			location: &Location{Datacenter: "DC0", Network: "VM Network", NetworkMapping: map[string]string{
+				"public":  "VM Network",
+				"private": "DC0_DVPG0",
+			}},
+			expected: []string{"private=DC0_DVPG0", "public=VM Network"},
+		},
+		{
+			name:          "case 2: missing mapped network",
+			location:      &Location{Datacenter: "DC0", NetworkMapping: map[string]string{"public": "missing"}},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				c := newTestClient(vc, map[string]*Location{"loc": tc.location})
+				finder := find.NewFinder(vc, true)
+				dc, err := finder.Datacenter(ctx, "DC0")
+				require.NoError(t, err)
+				finder.SetDatacenter(dc)
+
+				networks, err := c.networkMapping(ctx, c.location("loc"), finder)
+				if tc.expectedError {
+					assert.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+
+				// resolve the references back to names for comparison
+				var mapped []string
+				for _, network := range networks {
+					var ref types.ManagedObjectReference
+					require.True(t, ref.FromString(network.Network))
+					name, err := object.NewCommon(vc, ref).ObjectName(ctx)
+					require.NoError(t, err)
+					mapped = append(mapped, network.Name+"="+name)
+				}
+				assert.Equal(t, tc.expected, mapped)
+			})
+		})
+	}
+}
+
+// ovfEnvelope is a minimal OVF descriptor declaring the virtual system type
+const ovfEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <VirtualSystem ovf:id="image" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+    <VirtualHardwareSection>
+      <System>
+        <vssd:VirtualSystemType>%s</vssd:VirtualSystemType>
+      </System>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestCheckHardwareVersion(t *testing.T) {
+	testCases := []struct {
+		name          string
+		systemType    string
+		expectedError string
+	}{
+		{
+			name:       "case 0: supported hardware version",
+			systemType: "vmx-13",
+		},
+		{
+			name:          "case 1: hardware version newer than the host supports",
+			systemType:    "vmx-99",
+			expectedError: "does not support the virtual hardware vmx-99",
+		},
+		{
+			name:       "case 2: one of several hardware versions is supported",
+			systemType: "vmx-99 vmx-13",
+		},
+		{
+			name: "case 3: no hardware version declared",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				c := newTestClient(vc, nil)
+				host, err := find.NewFinder(vc, true).HostSystem(ctx, "/DC0/host/DC0_C0/DC0_C0_H0")
+				require.NoError(t, err)
+
+				path := writeOVA(t, map[string]string{"image.ovf": fmt.Sprintf(ovfEnvelope, tc.systemType)})
+				imp := &importer.Importer{Archive: &importer.TapeArchive{Path: path}}
+
+				err = c.checkHardwareVersion(ctx, imp, host)
+				if tc.expectedError != "" {
+					require.ErrorContains(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		})
+	}
+}
+
+// writeOVA writes files into an OVA, the descriptor first
+func writeOVA(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "image.ova")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	w := tar.NewWriter(f)
+	for _, name := range []string{"image.ovf", "image.mf", "disk.vmdk"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		require.NoError(t, w.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}))
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	return path
+}
@@ -0,0 +1,78 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/project"
+)
+
+// publishImage copies the template into the published content library of
+// the location, from which subscribed libraries, e.g. in other vCenters, sync
+// it. A library item with the name of the template is kept as is.
+func (c *Client) publishImage(ctx context.Context, ref types.ManagedObjectReference, name string, location *Location) error {
+	libraryName := location.ContentLibrary
+
+	return c.withRestClient(ctx, func(restClient *rest.Client) error {
+		manager := library.NewManager(restClient)
+		lib, err := manager.GetLibraryByName(ctx, libraryName)
+		if err != nil {
+			return fmt.Errorf("failed to find content library %s: %w", libraryName, err)
+		}
+
+		items, err := manager.FindLibraryItems(ctx, library.FindItem{LibraryID: lib.ID, Name: name})
+		if err != nil {
+			return fmt.Errorf("failed to find items of content library %s: %w", libraryName, err)
+		}
+		if len(items) > 0 {
+			log.FromContext(ctx).Info("Template already published", "name", name, "library", libraryName)
+			return nil
+		}
+
+		if _, err := vcenter.NewManager(restClient).CreateOVF(ctx, vcenter.OVF{
+			Spec: vcenter.CreateSpec{
+				Name:        name,
+				Description: fmt.Sprintf("Node image published by %s", project.Name()),
+			},
+			Source: vcenter.ResourceID{Value: ref.Value},
+			Target: vcenter.LibraryTarget{LibraryID: lib.ID},
+		}); err != nil {
+			return fmt.Errorf("failed to publish template %s to content library %s: %w", name, libraryName, err)
+		}
+		log.FromContext(ctx).Info("Published template", "name", name, "library", libraryName)
+		return nil
+	})
+}
+
+// unpublishImage deletes the copy of the template from the published content
+// library of the location, so subscribers drop it too. A missing item is not
+// an error.
+func (c *Client) unpublishImage(ctx context.Context, name string, location *Location) error {
+	libraryName := location.ContentLibrary
+
+	return c.withRestClient(ctx, func(restClient *rest.Client) error {
+		manager := library.NewManager(restClient)
+		lib, err := manager.GetLibraryByName(ctx, libraryName)
+		if err != nil {
+			return fmt.Errorf("failed to find content library %s: %w", libraryName, err)
+		}
+
+		ids, err := manager.FindLibraryItems(ctx, library.FindItem{LibraryID: lib.ID, Name: name})
+		if err != nil {
+			return fmt.Errorf("failed to find items of content library %s: %w", libraryName, err)
+		}
+		for _, id := range ids {
+			if err := manager.DeleteLibraryItem(ctx, &library.Item{ID: id}); err != nil {
+				return fmt.Errorf("failed to delete template %s from content library %s: %w", name, libraryName, err)
+			}
+			log.FromContext(ctx).Info("Deleted published template", "name", name, "library", libraryName)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/rest"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestPublishImage(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		datastore, err := find.NewFinder(vc, true).Datastore(ctx, "/DC0/datastore/LocalDS_0")
+		require.NoError(t, err)
+
+		restClient := rest.NewClient(vc)
+		require.NoError(t, restClient.Login(ctx, simulator.DefaultLogin))
+		manager := library.NewManager(restClient)
+		libraryID, err := manager.CreateLibrary(ctx, library.Library{
+			Name:        "published",
+			Type:        "LOCAL",
+			Storage:     []library.StorageBacking{{DatastoreID: datastore.Reference().Value, Type: "DATASTORE"}},
+			Publication: &library.Publication{Published: types.NewBool(true)},
+		})
+		require.NoError(t, err)
+
+		c := newTestClient(vc, map[string]*Location{
+			"published": {Datacenter: "DC0", Folder: "/DC0/vm", ContentLibrary: "published"},
+			"plain":     {Datacenter: "DC0", Folder: "/DC0/vm"},
+		})
+		poweredOffVM(ctx, t, vc, "/DC0/vm/DC0_H0_VM0")
+		poweredOffVM(ctx, t, vc, "/DC0/vm/DC0_H0_VM1")
+
+		items := func(name string) []string {
+			ids, err := manager.FindLibraryItems(ctx, library.FindItem{LibraryID: libraryID, Name: name})
+			require.NoError(t, err)
+			return ids
+		}
+
+		// the template is published once, even if processed again
+		require.NoError(t, c.Process(ctx, "DC0_H0_VM0", "published"))
+		require.NoError(t, c.Process(ctx, "DC0_H0_VM0", "published"))
+		assert.Len(t, items("DC0_H0_VM0"), 1)
+
+		// locations without a content library don't publish
+		require.NoError(t, c.Process(ctx, "DC0_H0_VM1", "plain"))
+		assert.Empty(t, items("DC0_H0_VM1"))
+
+		// the published copy is deleted with the template
+		require.NoError(t, c.Delete(ctx, "DC0_H0_VM0", "published"))
+		assert.Empty(t, items("DC0_H0_VM0"))
+		exists, err := c.Exists(ctx, "DC0_H0_VM0", "published")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		// a missing library fails the deletion, so it is retried
+		c.locations["missing"] = &Location{Datacenter: "DC0", Folder: "/DC0/vm", ContentLibrary: "missing"}
+		assert.ErrorContains(t, c.Delete(ctx, "DC0_H0_VM1", "missing"), "failed to find content library missing")
+	})
+}
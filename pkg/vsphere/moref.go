@@ -0,0 +1,92 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// morefTypes maps the IDs vCenter gives managed objects, e.g. datacenter-2 or
+// domain-c8, to the type of the object
+var morefTypes = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`^datacenter-\d+$`), "Datacenter"},
+	{regexp.MustCompile(`^datastore-\d+$`), "Datastore"},
+	{regexp.MustCompile(`^group-[a-z]?\d+$`), "Folder"},
+	{regexp.MustCompile(`^domain-c\d+$`), "ClusterComputeResource"},
+	{regexp.MustCompile(`^resgroup-\d+$`), "ResourcePool"},
+	{regexp.MustCompile(`^host-\d+$`), "HostSystem"},
+	{regexp.MustCompile(`^network-\d+$`), "Network"},
+	{regexp.MustCompile(`^dvportgroup-\d+$`), "DistributedVirtualPortgroup"},
+	{regexp.MustCompile(`^vm-\d+$`), "VirtualMachine"},
+}
+
+// parseMoref returns the managed object reference value stands for if it is
+// the ID of a managed object instead of a name or inventory path
+func parseMoref(value string) (types.ManagedObjectReference, bool) {
+	for _, t := range morefTypes {
+		if t.pattern.MatchString(value) {
+			return types.ManagedObjectReference{Type: t.kind, Value: value}, true
+		}
+	}
+	return types.ManagedObjectReference{}, false
+}
+
+// checkMoref returns an error if value is the ID of a managed object whose
+// type is none of kinds, e.g. a folder configured as datastore
+func checkMoref(value string, kinds ...string) error {
+	ref, ok := parseMoref(value)
+	if !ok || slices.Contains(kinds, ref.Type) {
+		return nil
+	}
+	return fmt.Errorf("%s refers to a %s, expected %s", value, ref.Type, strings.Join(kinds, " or "))
+}
+
+// lookupMoref returns the object value refers to if it is the ID of a managed
+// object, without searching the inventory for it. The second return value is
+// false if value is a name, which is left to the finder.
+func lookupMoref[T object.Reference](ctx context.Context, c *vim25.Client, value string) (T, bool, error) {
+	var zero T
+	ref, ok := parseMoref(value)
+	if !ok {
+		return zero, false, nil
+	}
+	obj, ok := object.NewReference(c, ref).(T)
+	if !ok {
+		return zero, true, fmt.Errorf("%s refers to a %s", value, ref.Type)
+	}
+
+	// the inventory path names the object in logs and fails for objects
+	// that don't exist
+	inventoryPath, err := find.InventoryPath(ctx, c, ref)
+	if err != nil {
+		return zero, true, err
+	}
+	if common, ok := any(obj).(interface{ SetInventoryPath(string) }); ok {
+		common.SetInventoryPath(inventoryPath)
+	}
+	return obj, true, nil
+}
+
+// inventoryPath returns the inventory path of the object value refers to if
+// it is the ID of a managed object, value itself otherwise
+func (c *Client) inventoryPath(ctx context.Context, value string) (string, error) {
+	ref, ok := parseMoref(value)
+	if !ok {
+		return value, nil
+	}
+	inventoryPath, err := find.InventoryPath(ctx, c.vsphere.Client, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s: %w", value, err)
+	}
+	return inventoryPath, nil
+}
@@ -0,0 +1,178 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestParseMoref(t *testing.T) {
+	testCases := []struct {
+		name         string
+		value        string
+		expectedType string
+	}{
+		{name: "case 0: datacenter", value: "datacenter-2", expectedType: "Datacenter"},
+		{name: "case 1: vm folder", value: "group-v4", expectedType: "Folder"},
+		{name: "case 2: cluster", value: "domain-c8", expectedType: "ClusterComputeResource"},
+		{name: "case 3: distributed port group", value: "dvportgroup-12", expectedType: "DistributedVirtualPortgroup"},
+		{name: "case 4: name", value: "DC0"},
+		{name: "case 5: inventory path", value: "/DC0/host/DC0_C0"},
+		{name: "case 6: name with a moref prefix", value: "host-esx01.example.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ref, ok := parseMoref(tc.value)
+			assert.Equal(t, tc.expectedType != "", ok)
+			if ok {
+				assert.Equal(t, types.ManagedObjectReference{Type: tc.expectedType, Value: tc.value}, ref)
+			}
+		})
+	}
+}
+
+func TestLoadLocationsMorefs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		content       string
+		expectedError string
+	}{
+		{
+			name: "case 0: names and morefs can be mixed",
+			content: `loc:
+  datacenter: datacenter-2
+  datastore: LocalDS_0
+  folder: group-v4
+  cluster: domain-c8
+  resourcepool: resgroup-9
+  network: dvportgroup-12`,
+		},
+		{
+			name: "case 1: moref of the wrong type",
+			content: `loc:
+  datacenter: DC0
+  datastore: group-v4
+  folder: /DC0/vm
+  cluster: DC0_C0`,
+			expectedError: "location loc: datastore: group-v4 refers to a Folder, expected Datastore",
+		},
+		{
+			name: "case 2: network mapping to a moref of the wrong type",
+			content: `loc:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0
+  networkmapping:
+    public: datastore-3`,
+			expectedError: "location loc: networkmapping: datastore-3 refers to a Datastore, expected Network or DistributedVirtualPortgroup",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadLocations(writeTempFile(t, "locations-*.yaml", tc.content))
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMorefLocation(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		finder := find.NewFinder(vc, true)
+		dc, err := finder.Datacenter(ctx, "DC0")
+		require.NoError(t, err)
+		finder.SetDatacenter(dc)
+		datastore, err := finder.Datastore(ctx, "LocalDS_0")
+		require.NoError(t, err)
+		folder, err := finder.Folder(ctx, "/DC0/vm")
+		require.NoError(t, err)
+		cluster, err := finder.ClusterComputeResource(ctx, "/DC0/host/DC0_C0")
+		require.NoError(t, err)
+		root, err := cluster.ResourcePool(ctx)
+		require.NoError(t, err)
+		pool, err := root.Create(ctx, "pool1", types.DefaultResourceConfigSpec())
+		require.NoError(t, err)
+		host, err := finder.HostSystem(ctx, "/DC0/host/DC0_C0/DC0_C0_H0")
+		require.NoError(t, err)
+		network, err := finder.Network(ctx, "DC0_DVPG0")
+		require.NoError(t, err)
+
+		c := newTestClient(vc, map[string]*Location{
+			"names": {
+				Datacenter: "DC0", Datastore: "LocalDS_0", Folder: "/DC0/vm", Cluster: "DC0_C0",
+				Host: "/DC0/host/DC0_C0/DC0_C0_H0", Network: "DC0_DVPG0",
+			},
+			"morefs": {
+				Datacenter: dc.Reference().Value, Datastore: datastore.Reference().Value,
+				Folder: folder.Reference().Value, Cluster: cluster.Reference().Value,
+				Host: host.Reference().Value, Network: network.Reference().Value,
+			},
+			"pool-below-moref-cluster": {
+				Datacenter: dc.Reference().Value, Cluster: cluster.Reference().Value, Resourcepool: "Resources/pool1",
+			},
+			"pool-moref": {Datacenter: "DC0", Cluster: "DC0_C0", Resourcepool: pool.Reference().Value},
+			"missing":    {Datacenter: "datacenter-999", Datastore: "datastore-999", Folder: "group-999"},
+		})
+
+		// both locations resolve to the same objects
+		for _, loc := range []string{"names", "morefs"} {
+			finder := find.NewFinder(vc, true)
+			gotDC, err := c.getDatacenter(ctx, finder, c.location(loc))
+			require.NoError(t, err, loc)
+			assert.Equal(t, dc.Reference(), gotDC.Reference(), loc)
+			assert.Equal(t, "DC0", gotDC.Name(), loc)
+			finder.SetDatacenter(gotDC)
+
+			gotDatastore, err := c.getDatastore(ctx, finder, c.location(loc))
+			require.NoError(t, err, loc)
+			assert.Equal(t, datastore.Reference(), gotDatastore.Reference(), loc)
+
+			gotFolder, err := c.getFolder(ctx, c.location(loc), finder)
+			require.NoError(t, err, loc)
+			assert.Equal(t, folder.Reference(), gotFolder.Reference(), loc)
+
+			gotPool, err := c.getResourcePool(ctx, c.location(loc), finder)
+			require.NoError(t, err, loc)
+			assert.Equal(t, root.Reference(), gotPool.Reference(), loc)
+
+			gotHost, err := c.getHost(ctx, c.locations[loc].Host, finder)
+			require.NoError(t, err, loc)
+			assert.Equal(t, host.Reference(), gotHost.Reference(), loc)
+
+			gotNetwork, err := c.getNetwork(ctx, c.locations[loc].Network, finder)
+			require.NoError(t, err, loc)
+			assert.Equal(t, network.Reference(), gotNetwork, loc)
+
+			exists, err := c.Exists(ctx, "DC0_H0_VM0", loc)
+			require.NoError(t, err, loc)
+			assert.True(t, exists, loc)
+		}
+
+		for _, loc := range []string{"pool-below-moref-cluster", "pool-moref"} {
+			gotPool, err := c.getResourcePool(ctx, c.location(loc), find.NewFinder(vc, true))
+			require.NoError(t, err, loc)
+			assert.Equal(t, pool.Reference(), gotPool.Reference(), loc)
+		}
+
+		// morefs of missing objects fail instead of falling back to names
+		finder = find.NewFinder(vc, true)
+		_, err = c.getDatacenter(ctx, finder, c.location("missing"))
+		assert.ErrorContains(t, err, "failed to find datacenter datacenter-999")
+		_, err = c.getDatastore(ctx, finder, c.location("missing"))
+		assert.ErrorContains(t, err, "failed to find datastore datastore-999")
+		_, err = c.Exists(ctx, "DC0_H0_VM0", "missing")
+		assert.Error(t, err)
+	})
+}
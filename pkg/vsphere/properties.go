@@ -0,0 +1,121 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/ovf/importer"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ovfPropertyKeys returns the keys of the properties the OVF declares, e.g.
+// guestinfo.hostname, prefixed with the class and suffixed with the instance
+// of their product section if it has one
+func ovfPropertyKeys(e *ovf.Envelope) []string {
+	if e.VirtualSystem == nil {
+		return nil
+	}
+	var keys []string
+	for _, product := range e.VirtualSystem.Product {
+		for _, p := range product.Property {
+			keys = append(keys, ovfPropertyKey(product, p))
+		}
+	}
+	return keys
+}
+
+// ovfPropertyKey returns the key of a property of the product section, as
+// returned by ovfPropertyKeys
+func ovfPropertyKey(product ovf.ProductSection, p ovf.Property) string {
+	key := p.Key
+	if product.Class != nil && *product.Class != "" {
+		key = *product.Class + "." + key
+	}
+	if product.Instance != nil && *product.Instance != "" {
+		key = key + "." + *product.Instance
+	}
+	return key
+}
+
+// checkArchiveProperties runs checkRequiredProperties on the OVF of the
+// importer's archive, for imports that don't read the envelope themselves
+func checkArchiveProperties(imp *importer.Importer, mapping []importer.Property) error {
+	o, err := importer.ReadOvf("*.ovf", imp.Archive)
+	if err != nil {
+		return fmt.Errorf("failed to read ovf: %w", err)
+	}
+	e, err := importer.ReadEnvelope(o)
+	if err != nil {
+		return fmt.Errorf("failed to parse ovf: %w", err)
+	}
+	return checkRequiredProperties(e, mapping)
+}
+
+// checkRequiredProperties fails if the OVF declares user configurable
+// properties without a default that the mapping doesn't set. The VM of an
+// image imported without them doesn't power on. An empty ovf:value is a
+// default.
+func checkRequiredProperties(e *ovf.Envelope, mapping []importer.Property) error {
+	if e.VirtualSystem == nil {
+		return nil
+	}
+	var missing []string
+	for _, product := range e.VirtualSystem.Product {
+		for _, p := range product.Property {
+			if p.UserConfigurable == nil || !*p.UserConfigurable || p.Default != nil {
+				continue
+			}
+			key := ovfPropertyKey(product, p)
+			if !slices.ContainsFunc(mapping, func(m importer.Property) bool { return m.Key == key }) {
+				missing = append(missing, key)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("OVF properties %s are required and have no default, set them in the properties of the location", strings.Join(missing, ", "))
+}
+
+// propertyMapping returns the OVF properties configured for the location,
+// overriding the defaults of the envelope on import. Properties the OVF
+// doesn't declare are logged and left out.
+func (c *Client) propertyMapping(ctx context.Context, imp *importer.Importer, location *Location) ([]importer.Property, error) {
+	log := log.FromContext(ctx)
+
+	properties := location.Properties
+	if len(properties) == 0 {
+		return nil, nil
+	}
+
+	o, err := importer.ReadOvf("*.ovf", imp.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ovf: %w", err)
+	}
+	e, err := importer.ReadEnvelope(o)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ovf: %w", err)
+	}
+	declared := ovfPropertyKeys(e)
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mapping := make([]importer.Property, 0, len(keys))
+	for _, key := range keys {
+		if !slices.Contains(declared, key) {
+			log.Info("Ignoring OVF property not declared by the image", "property", key, "declared", declared)
+			continue
+		}
+		mapping = append(mapping, importer.Property{KeyValue: importer.KeyValue{Key: key, Value: properties[key]}})
+	}
+	return mapping, nil
+}
@@ -0,0 +1,129 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/ovf/importer"
+)
+
+const propertyEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <VirtualSystem ovf:id="image">
+    <ProductSection>
+      <Property ovf:key="guestinfo.hostname" ovf:type="string" ovf:value=""/>
+      <Property ovf:key="guestinfo.dns" ovf:type="string" ovf:value="8.8.8.8"/>
+    </ProductSection>
+    <ProductSection ovf:class="vami" ovf:instance="image">
+      <Property ovf:key="ip0" ovf:type="string"/>
+    </ProductSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestPropertyMapping(t *testing.T) {
+	path := writeOVA(t, map[string]string{"image.ovf": propertyEnvelope})
+
+	testCases := []struct {
+		name       string
+		properties map[string]string
+		expected   []importer.Property
+	}{
+		{
+			name: "case 0: no properties configured",
+		},
+		{
+			name:       "case 1: declared properties are mapped",
+			properties: map[string]string{"guestinfo.hostname": "node", "vami.ip0.image": "10.0.0.1"},
+			expected: []importer.Property{
+				{KeyValue: importer.KeyValue{Key: "guestinfo.hostname", Value: "node"}},
+				{KeyValue: importer.KeyValue{Key: "vami.ip0.image", Value: "10.0.0.1"}},
+			},
+		},
+		{
+			name:       "case 2: undeclared properties are left out",
+			properties: map[string]string{"guestinfo.dns": "1.1.1.1", "guestinfo.unknown": "value"},
+			expected: []importer.Property{
+				{KeyValue: importer.KeyValue{Key: "guestinfo.dns", Value: "1.1.1.1"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{locations: map[string]*Location{"loc": {Properties: tc.properties}}}
+			imp := &importer.Importer{Archive: &importer.TapeArchive{Path: path}}
+
+			mapping, err := c.propertyMapping(context.TODO(), imp, c.location("loc"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, mapping)
+		})
+	}
+}
+
+const requiredPropertyEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <VirtualSystem ovf:id="image">
+    <ProductSection>
+      <Property ovf:key="guestinfo.hostname" ovf:type="string" ovf:userConfigurable="true"/>
+      <Property ovf:key="guestinfo.dns" ovf:type="string" ovf:userConfigurable="true" ovf:value="8.8.8.8"/>
+      <Property ovf:key="guestinfo.domain" ovf:type="string" ovf:userConfigurable="true" ovf:value=""/>
+      <Property ovf:key="guestinfo.internal" ovf:type="string"/>
+    </ProductSection>
+    <ProductSection ovf:class="vami" ovf:instance="image">
+      <Property ovf:key="ip0" ovf:type="string" ovf:userConfigurable="true"/>
+    </ProductSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestCheckRequiredProperties(t *testing.T) {
+	testCases := []struct {
+		name          string
+		properties    map[string]string
+		expectedError string
+	}{
+		{
+			name:          "case 0: required properties without a value fail the import",
+			expectedError: "OVF properties guestinfo.hostname, vami.ip0.image are required and have no default, set them in the properties of the location",
+		},
+		{
+			name:          "case 1: properties of the location satisfy some requirements",
+			properties:    map[string]string{"guestinfo.hostname": "node"},
+			expectedError: "OVF properties vami.ip0.image are required",
+		},
+		{
+			name:       "case 2: properties of the location satisfy all requirements",
+			properties: map[string]string{"guestinfo.hostname": "node", "vami.ip0.image": "10.0.0.1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeOVA(t, map[string]string{"image.ovf": requiredPropertyEnvelope})
+			c := &Client{locations: map[string]*Location{"loc": {Properties: tc.properties}}}
+			imp := &importer.Importer{Archive: &importer.TapeArchive{Path: path}}
+
+			mapping, err := c.propertyMapping(context.TODO(), imp, c.location("loc"))
+			require.NoError(t, err)
+
+			err = checkArchiveProperties(imp, mapping)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.expectedError)
+
+			// the pull import fails before anything is created in vSphere
+			_, err = pullImport(context.TODO(), "*.ovf", importer.Options{PropertyMapping: mapping}, imp, "https://example.com/image.ova", 0, 0)
+			require.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestCheckRequiredPropertiesWithoutRequirements(t *testing.T) {
+	path := writeOVA(t, map[string]string{"image.ovf": propertyEnvelope})
+	imp := &importer.Importer{Archive: &importer.TapeArchive{Path: path}}
+
+	assert.NoError(t, checkArchiveProperties(imp, nil))
+}
@@ -0,0 +1,124 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/project"
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// provenanceAnnotation returns the annotation (notes) of the template imported
+// with the context, recording where it comes from, or an empty string if the
+// context has no provenance
+func provenanceAnnotation(ctx context.Context) string {
+	p, ok := provider.ProvenanceFrom(ctx)
+	if !ok {
+		return ""
+	}
+	return strings.Join([]string{
+		"managed-by: " + project.Name(),
+		"operator-version: " + p.OperatorVersion,
+		"node-image: " + p.NodeImage,
+		"image: " + p.Image,
+		"releases: " + strings.Join(p.Releases, ", "),
+		"created: " + p.Created.UTC().Format(time.RFC3339),
+	}, "\n")
+}
+
+// tagImage attaches the operator's tag from the tag category to the imported
+// VM, creating the category and the tag if they don't exist yet
+func (c *Client) tagImage(ctx context.Context, ref types.ManagedObjectReference) error {
+	// serialize the tagging, so concurrent imports don't create the category
+	// or the tag twice
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+
+	return c.withRestClient(ctx, func(restClient *rest.Client) error {
+		manager := tags.NewManager(restClient)
+
+		categoryID, err := ensureTagCategory(ctx, manager, c.tagCategory)
+		if err != nil {
+			return err
+		}
+		tagID, err := ensureTag(ctx, manager, categoryID, project.Name())
+		if err != nil {
+			return err
+		}
+		if err := manager.AttachTag(ctx, tagID, ref); err != nil {
+			return fmt.Errorf("failed to attach tag %s: %w", project.Name(), err)
+		}
+		return nil
+	})
+}
+
+// withRestClient runs fn with a client of the vSphere API logged in with the
+// stored credentials, logging out again afterwards
+func (c *Client) withRestClient(ctx context.Context, fn func(*rest.Client) error) error {
+	restClient := rest.NewClient(c.vsphere.Client)
+	if err := restClient.Login(ctx, c.currentUserinfo()); err != nil {
+		return fmt.Errorf("failed to log in to the vSphere API: %w", err)
+	}
+	defer func() {
+		if err := restClient.Logout(ctx); err != nil {
+			log.FromContext(ctx).Info("Failed to log out of the vSphere API", "error", err.Error())
+		}
+	}()
+	return fn(restClient)
+}
+
+// ensureTagCategory returns the ID of the tag category with the name,
+// creating it for VMs if it doesn't exist
+func ensureTagCategory(ctx context.Context, manager *tags.Manager, name string) (string, error) {
+	categories, err := manager.GetCategories(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tag categories: %w", err)
+	}
+	for _, category := range categories {
+		if category.Name == name {
+			return category.ID, nil
+		}
+	}
+
+	id, err := manager.CreateCategory(ctx, &tags.Category{
+		Name:            name,
+		Description:     fmt.Sprintf("Created by %s", project.Name()),
+		Cardinality:     "MULTIPLE",
+		AssociableTypes: []string{"VirtualMachine"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create tag category %s: %w", name, err)
+	}
+	return id, nil
+}
+
+// ensureTag returns the ID of the tag with the name in the category, creating
+// it if it doesn't exist
+func ensureTag(ctx context.Context, manager *tags.Manager, categoryID string, name string) (string, error) {
+	existing, err := manager.GetTagsForCategory(ctx, categoryID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+	for _, tag := range existing {
+		if tag.Name == name {
+			return tag.ID, nil
+		}
+	}
+
+	id, err := manager.CreateTag(ctx, &tags.Tag{
+		Name:        name,
+		Description: "Node image imported by the operator",
+		CategoryID:  categoryID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create tag %s: %w", name, err)
+	}
+	return id, nil
+}
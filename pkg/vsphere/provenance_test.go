@@ -0,0 +1,102 @@
+package vsphere
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/rest"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// minimalOVF describes a VM without disks, which vcsim imports without
+// uploading any files
+const minimalOVF = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1"
+  xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData"
+  xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <References/>
+  <VirtualSystem ovf:id="image">
+    <Info>A virtual machine</Info>
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemType>vmx-13</vssd:VirtualSystemType>
+      </System>
+      <Item>
+        <rasd:ElementName>1 virtual CPU(s)</rasd:ElementName>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>1</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:ElementName>32MB of memory</rasd:ElementName>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>32</rasd:VirtualQuantity>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>`
+
+func TestCreateProvenance(t *testing.T) {
+	simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+		c := newTestClient(vc, map[string]*Location{
+			"loc": {Datacenter: "DC0", Folder: "/DC0/vm", Cluster: "DC0_C0", Datastore: "LocalDS_0"},
+		})
+		c.importSlots = make(chan struct{}, 1)
+		c.tagCategory = "node-images"
+
+		ctx = provider.WithProvenance(ctx, provider.Provenance{
+			NodeImage:       "capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			Image:           "flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs",
+			Releases:        []string{"v30.0.0", "v30.1.0"},
+			OperatorVersion: "1.2.3",
+			Created:         time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+		})
+		ova := writeOVA(t, map[string]string{"image.ovf": minimalOVF})
+		for _, name := range []string{"image-a", "image-b"} {
+			require.NoError(t, c.Create(ctx, ova, name, "loc"))
+		}
+
+		vm, err := find.NewFinder(vc, true).VirtualMachine(ctx, "/DC0/vm/image-a")
+		require.NoError(t, err)
+		var managedVM mo.VirtualMachine
+		require.NoError(t, vm.Properties(ctx, vm.Reference(), []string{"config.annotation"}, &managedVM))
+		assert.Equal(t, `managed-by: image-distribution-operator
+operator-version: 1.2.3
+node-image: capv-flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs
+image: flatcar-stable-3975.2.0-kube-1.30.4-tooling-1.18.1-gs
+releases: v30.0.0, v30.1.0
+created: 2025-06-01T12:00:00Z`, managedVM.Config.Annotation)
+
+		// both VMs carry the tag, which was created only once
+		restClient := rest.NewClient(vc)
+		require.NoError(t, restClient.Login(ctx, simulator.DefaultLogin))
+		manager := tags.NewManager(restClient)
+		category, err := manager.GetCategory(ctx, "node-images")
+		require.NoError(t, err)
+		tagIDs, err := manager.ListTagsForCategory(ctx, category.ID)
+		require.NoError(t, err)
+		require.Len(t, tagIDs, 1)
+		attached, err := manager.ListAttachedObjects(ctx, tagIDs[0])
+		require.NoError(t, err)
+		assert.Len(t, attached, 2)
+	})
+}
+
+func TestProvenanceAnnotationWithoutProvenance(t *testing.T) {
+	assert.Empty(t, provenanceAnnotation(context.TODO()))
+}
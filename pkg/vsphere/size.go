@@ -0,0 +1,145 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/ovf/importer"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// checkImageSize fails early if the image exceeds the maximum image size, so
+// a broken build can't fill up the datastore. Pushed images are checked by
+// the size of the OVA the operator streams to vCenter, pulled ones by the
+// capacity of the disks the OVF declares, as the OVA never passes through
+// the operator. No maximum image size disables the check.
+func (c *Client) checkImageSize(imp *importer.Importer, imageURL string) error {
+	if c.maxImageSize <= 0 {
+		return nil
+	}
+
+	if c.usePullMode() {
+		o, err := importer.ReadOvf("*.ovf", imp.Archive)
+		if err != nil {
+			return fmt.Errorf("failed to read ovf: %w", err)
+		}
+		e, err := importer.ReadEnvelope(o)
+		if err != nil {
+			return fmt.Errorf("failed to parse ovf: %w", err)
+		}
+		capacity, err := ovfDiskCapacity(e)
+		if err != nil {
+			return err
+		}
+		if capacity > c.maxImageSize {
+			return fmt.Errorf("disks of image %s declare %d bytes, exceeding the maximum image size of %d bytes", imageURL, capacity, c.maxImageSize)
+		}
+		return nil
+	}
+
+	archive, ok := imp.Archive.(*importer.TapeArchive)
+	if !ok {
+		return nil
+	}
+	f, size, err := archive.OpenFile(archive.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open image %s: %w", imageURL, err)
+	}
+	_ = f.Close()
+	if size > c.maxImageSize {
+		return fmt.Errorf("image %s is %d bytes, exceeding the maximum image size of %d bytes", imageURL, size, c.maxImageSize)
+	}
+	return nil
+}
+
+// checkDatastoreSpace fails early if the datastore has less free space than
+// the disks of the image take up, instead of failing partway through the
+// import and leaving partial disks behind. Images are imported thin
+// provisioned, so disks declaring their populated size need only that much.
+func checkDatastoreSpace(ctx context.Context, imp *importer.Importer, datastore *object.Datastore, imageURL string) error {
+	o, err := importer.ReadOvf("*.ovf", imp.Archive)
+	if err != nil {
+		return fmt.Errorf("failed to read ovf: %w", err)
+	}
+	e, err := importer.ReadEnvelope(o)
+	if err != nil {
+		return fmt.Errorf("failed to parse ovf: %w", err)
+	}
+	required, err := ovfDiskUsage(e)
+	if err != nil {
+		return err
+	}
+	if required == 0 {
+		return nil
+	}
+
+	var ds mo.Datastore
+	if err := datastore.Properties(ctx, datastore.Reference(), []string{"summary"}, &ds); err != nil {
+		return fmt.Errorf("failed to get free space of datastore %s: %w", datastore.Name(), err)
+	}
+	if required > ds.Summary.FreeSpace {
+		return fmt.Errorf("datastore %s has %d bytes free, too little for the %d bytes of the disks of image %s",
+			ds.Summary.Name, ds.Summary.FreeSpace, required, imageURL)
+	}
+	return nil
+}
+
+// ovfDiskCapacity returns the total capacity in bytes of the disks the OVF
+// declares
+func ovfDiskCapacity(e *ovf.Envelope) (int64, error) {
+	if e.Disk == nil {
+		return 0, nil
+	}
+
+	var total int64
+	for _, disk := range e.Disk.Disks {
+		capacity, err := diskCapacity(disk)
+		if err != nil {
+			return 0, err
+		}
+		total += capacity
+	}
+	return total, nil
+}
+
+// ovfDiskUsage returns the total bytes the disks the OVF declares take up
+// when imported thin provisioned: their populated size if declared, and their
+// capacity otherwise
+func ovfDiskUsage(e *ovf.Envelope) (int64, error) {
+	if e.Disk == nil {
+		return 0, nil
+	}
+
+	var total int64
+	for _, disk := range e.Disk.Disks {
+		if disk.PopulatedSize != nil {
+			total += int64(*disk.PopulatedSize)
+			continue
+		}
+		capacity, err := diskCapacity(disk)
+		if err != nil {
+			return 0, err
+		}
+		total += capacity
+	}
+	return total, nil
+}
+
+// diskCapacity returns the capacity in bytes of the disk
+func diskCapacity(disk ovf.VirtualDiskDesc) (int64, error) {
+	capacity, err := strconv.ParseInt(disk.Capacity, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid capacity %q of disk %s: %w", disk.Capacity, disk.DiskID, err)
+	}
+	units := int64(1)
+	if disk.CapacityAllocationUnits != nil {
+		units = ovf.ParseCapacityAllocationUnits(*disk.CapacityAllocationUnits)
+		if units == 0 {
+			return 0, fmt.Errorf("invalid capacity allocation units %q of disk %s", *disk.CapacityAllocationUnits, disk.DiskID)
+		}
+	}
+	return capacity * units, nil
+}
@@ -0,0 +1,140 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/ovf/importer"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+)
+
+const diskEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <DiskSection>
+    <Disk ovf:diskId="vmdisk1" ovf:capacity="20" ovf:capacityAllocationUnits="byte * 2^30"/>
+    <Disk ovf:diskId="vmdisk2" ovf:capacity="1073741824"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="image"/>
+</Envelope>`
+
+func TestCheckImageSize(t *testing.T) {
+	path := writeOVA(t, map[string]string{"image.ovf": diskEnvelope})
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		pullMode      bool
+		maxImageSize  int64
+		expectedError string
+	}{
+		{
+			name: "case 0: no maximum image size",
+		},
+		{
+			name:         "case 1: pushed image within the maximum image size",
+			maxImageSize: info.Size(),
+		},
+		{
+			name:          "case 2: pushed image exceeding the maximum image size",
+			maxImageSize:  info.Size() - 1,
+			expectedError: "exceeding the maximum image size",
+		},
+		{
+			name:         "case 3: pulled image with disks within the maximum image size",
+			pullMode:     true,
+			maxImageSize: 21 << 30,
+		},
+		{
+			name:          "case 4: pulled image with disks exceeding the maximum image size",
+			pullMode:      true,
+			maxImageSize:  20 << 30,
+			expectedError: "declare 22548578304 bytes, exceeding the maximum image size of 21474836480 bytes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Client{pullMode: tc.pullMode, maxImageSize: tc.maxImageSize}
+			imp := &importer.Importer{Archive: &importer.TapeArchive{Path: path}}
+
+			err := c.checkImageSize(imp, path)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+const populatedDiskEnvelope = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <DiskSection>
+    <Disk ovf:diskId="vmdisk1" ovf:capacity="20" ovf:capacityAllocationUnits="byte * 2^30" ovf:populatedSize="1073741824"/>
+  </DiskSection>
+  <VirtualSystem ovf:id="image"/>
+</Envelope>`
+
+func TestCheckDatastoreSpace(t *testing.T) {
+	testCases := []struct {
+		name          string
+		envelope      string
+		freeSpace     int64
+		expectedError string
+	}{
+		{
+			name:      "case 0: disks fit into the datastore",
+			envelope:  diskEnvelope,
+			freeSpace: 22 << 30,
+		},
+		{
+			name:          "case 1: oversized import is rejected",
+			envelope:      diskEnvelope,
+			freeSpace:     10 << 30,
+			expectedError: "datastore LocalDS_0 has 10737418240 bytes free, too little for the 22548578304 bytes of the disks of image https://example.com/image.ova",
+		},
+		{
+			name:      "case 2: thin provisioned disks need their populated size only",
+			envelope:  populatedDiskEnvelope,
+			freeSpace: 10 << 30,
+		},
+		{
+			name:          "case 3: populated size exceeding the free space is rejected",
+			envelope:      populatedDiskEnvelope,
+			freeSpace:     1 << 29,
+			expectedError: "too little for the 1073741824 bytes",
+		},
+		{
+			name:      "case 4: image without disks is not checked",
+			envelope:  fmt.Sprintf(ovfEnvelope, "vmx-13"),
+			freeSpace: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			simulator.Test(func(ctx context.Context, vc *vim25.Client) {
+				datastore, err := find.NewFinder(vc, true).Datastore(ctx, "/DC0/datastore/LocalDS_0")
+				require.NoError(t, err)
+				simulator.Map(ctx).Get(datastore.Reference()).(*simulator.Datastore).Summary.FreeSpace = tc.freeSpace
+
+				path := writeOVA(t, map[string]string{"image.ovf": tc.envelope})
+				imp := &importer.Importer{Archive: &importer.TapeArchive{Path: path}}
+
+				err = checkDatastoreSpace(ctx, imp, datastore, "https://example.com/image.ova")
+				if tc.expectedError != "" {
+					require.ErrorContains(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		})
+	}
+}
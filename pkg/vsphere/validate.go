@@ -0,0 +1,119 @@
+package vsphere
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/vmware/govmomi/find"
+
+	"github.com/giantswarm/image-distribution-operator/pkg/provider"
+)
+
+// ValidateConfig checks the credentials and locations files of the
+// configuration, logs in to the vCenter and resolves the objects of every
+// location, without changing anything in the vCenter. Checks depending on a
+// failed one are skipped.
+func ValidateConfig(ctx context.Context, c Config) []provider.ConfigCheck {
+	var checks []provider.ConfigCheck
+	check := func(name string, err error) bool {
+		checks = append(checks, provider.ConfigCheck{Name: name, Err: err})
+		return err == nil
+	}
+
+	creds, err := loadCredentials(c.CredentialsFile)
+	if err == nil {
+		err = checkCredentials(creds)
+	}
+	credsValid := check(fmt.Sprintf("credentials file %s", c.CredentialsFile), err)
+
+	locations, err := loadLocations(c.LocationsFile)
+	locationsValid := check(fmt.Sprintf("locations file %s", c.LocationsFile), err)
+
+	if !credsValid {
+		return checks
+	}
+	client, err := newGovmomiClient(ctx, sdkURL(creds), creds, c.CACertFile)
+	if !check(fmt.Sprintf("login to vCenter %s as %s", creds.VCenter, creds.Username), err) {
+		return checks
+	}
+	defer func() { _ = client.Logout(ctx) }()
+
+	if !locationsValid {
+		return checks
+	}
+	vc := &Client{vsphere: client, locations: locations}
+	names := make([]string, 0, len(locations))
+	for loc := range locations {
+		names = append(names, loc)
+	}
+	slices.Sort(names)
+	for _, loc := range names {
+		check(fmt.Sprintf("location %s", loc), vc.checkLocation(ctx, loc))
+	}
+	return checks
+}
+
+// checkCredentials fails for credentials missing the fields needed to log in
+func checkCredentials(creds *Credentials) error {
+	switch {
+	case creds.VCenter == "":
+		return errors.New("vcenter is required")
+	case creds.Username == "":
+		return errors.New("username is required")
+	case creds.Password == "":
+		return errors.New("password is required")
+	}
+	return nil
+}
+
+// checkLocation resolves the datacenter, datastore, folder, hosts, resource
+// pool and networks of the location. A folder the operator creates on demand
+// may be missing.
+func (c *Client) checkLocation(ctx context.Context, loc string) error {
+	location := c.location(loc)
+
+	finder := find.NewFinder(c.vsphere.Client, true)
+	dc, err := c.getDatacenter(ctx, finder, location)
+	if err != nil {
+		return err
+	}
+	finder.SetDatacenter(dc)
+
+	if _, err := c.getDatastore(ctx, finder, location); err != nil {
+		return err
+	}
+	if !location.CreateFolder {
+		if _, err := c.getFolder(ctx, location, finder); err != nil {
+			return err
+		}
+	}
+	if location.Host != "" {
+		if _, err := c.getHost(ctx, location.Host, finder); err != nil {
+			return err
+		}
+	}
+	// hosts of the list may be unusable for a while, e.g. in maintenance
+	for _, host := range location.Hosts {
+		if _, err := c.findHost(ctx, host, finder); err != nil {
+			return err
+		}
+	}
+	if !location.standalone() {
+		if _, err := c.getResourcePool(ctx, location, finder); err != nil {
+			return err
+		}
+	}
+	if location.Network != "" {
+		if _, err := c.getNetwork(ctx, location.Network, finder); err != nil {
+			return err
+		}
+	}
+	for ovfNetwork, network := range location.NetworkMapping {
+		if _, err := c.getNetwork(ctx, network, finder); err != nil {
+			return fmt.Errorf("network %s mapped from OVF network %s: %w", network, ovfNetwork, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmware/govmomi/simulator"
+)
+
+func TestValidateConfig(t *testing.T) {
+	model := simulator.VPX()
+	defer model.Remove()
+	require.NoError(t, model.Create())
+	model.Service.TLS = new(tls.Config)
+	server := model.Service.NewServer()
+	defer server.Close()
+
+	password, _ := server.URL.User.Password()
+	validCreds := fmt.Sprintf("vcenter: %s\nusername: %s\npassword: %s\ninsecure: true", server.URL.Host, server.URL.User.Username(), password)
+	validLocations := `dc1:
+  datacenter: DC0
+  datastore: LocalDS_0
+  folder: /DC0/vm
+  cluster: DC0_C0
+  network: VM Network`
+
+	testCases := []struct {
+		name           string
+		credentials    string
+		locations      string
+		expectedChecks []string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "case 0: valid configuration",
+			credentials:    validCreds,
+			locations:      validLocations,
+			expectedChecks: []string{"credentials file", "locations file", "login to vCenter", "location dc1"},
+		},
+		{
+			name:        "case 1: location with a missing datastore",
+			credentials: validCreds,
+			locations: validLocations + `
+dc2:
+  datacenter: DC0
+  datastore: missing
+  folder: /DC0/vm
+  cluster: DC0_C0`,
+			expectedChecks: []string{"credentials file", "locations file", "login to vCenter", "location dc1", "location dc2"},
+			expectedErrors: map[string]string{"location dc2": "failed to find datastore missing"},
+		},
+		{
+			name:           "case 2: unreachable vCenter skips the locations",
+			credentials:    "vcenter: 127.0.0.1:1\nusername: user\npassword: pass\ninsecure: true",
+			locations:      validLocations,
+			expectedChecks: []string{"credentials file", "locations file", "login to vCenter"},
+			expectedErrors: map[string]string{"login to vCenter": "connection refused"},
+		},
+		{
+			name:           "case 3: incomplete credentials skip the login",
+			credentials:    "username: user\npassword: pass",
+			locations:      "dc1:\n  datacenter: DC0",
+			expectedChecks: []string{"credentials file", "locations file"},
+			expectedErrors: map[string]string{
+				"credentials file": "vcenter is required",
+				"locations file":   "datastore",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checks := ValidateConfig(context.TODO(), Config{
+				CredentialsFile: writeTempFile(t, "credentials-*.yaml", tc.credentials),
+				LocationsFile:   writeTempFile(t, "locations-*.yaml", tc.locations),
+			})
+
+			require.Len(t, checks, len(tc.expectedChecks))
+			for i, prefix := range tc.expectedChecks {
+				assert.Contains(t, checks[i].Name, prefix)
+				if expectedErr, ok := tc.expectedErrors[prefix]; ok {
+					assert.ErrorContains(t, checks[i].Err, expectedErr, checks[i].Name)
+				} else {
+					assert.NoError(t, checks[i].Err, checks[i].Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package window
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+// Window is a set of daily time ranges, in UTC, during which an action is
+// allowed. A nil Window is always open.
+type Window struct {
+	ranges []timeRange
+}
+
+// timeRange is a daily range given as offsets since midnight. A range whose
+// end is before its start wraps around midnight, e.g. 22:00-06:00.
+type timeRange struct {
+	start time.Duration
+	end   time.Duration
+}
+
+// Parse parses a comma separated list of HH:MM-HH:MM ranges, e.g.
+// "22:00-06:00" or "01:00-05:00,12:00-13:00". An empty spec returns a nil
+// Window, which is always open.
+func Parse(spec string) (*Window, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	w := &Window{}
+	for _, r := range strings.Split(spec, ",") {
+		bounds := strings.Split(strings.TrimSpace(r), "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid range %q, expected HH:MM-HH:MM", r)
+		}
+		start, err := parseTimeOfDay(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of range %q: %w", r, err)
+		}
+		end, err := parseTimeOfDay(bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of range %q: %w", r, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("invalid range %q, start and end must differ", r)
+		}
+		w.ranges = append(w.ranges, timeRange{start: start, end: end})
+	}
+	return w, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns true if t falls into one of the ranges of the window.
+func (w *Window) Contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	offset := sinceMidnight(t)
+	for _, r := range w.ranges {
+		if r.start < r.end {
+			if offset >= r.start && offset < r.end {
+				return true
+			}
+		} else if offset >= r.start || offset < r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// UntilOpen returns how long it takes from t until the window opens next.
+// It returns 0 if the window is open at t.
+func (w *Window) UntilOpen(t time.Time) time.Duration {
+	if w.Contains(t) {
+		return 0
+	}
+	offset := sinceMidnight(t)
+	next := day
+	for _, r := range w.ranges {
+		d := r.start - offset
+		if d < 0 {
+			d += day
+		}
+		if d < next {
+			next = d
+		}
+	}
+	return next
+}
+
+// String returns the window in the format accepted by Parse.
+func (w *Window) String() string {
+	if w == nil {
+		return ""
+	}
+	ranges := make([]string, 0, len(w.ranges))
+	for _, r := range w.ranges {
+		ranges = append(ranges, fmt.Sprintf("%s-%s", formatTimeOfDay(r.start), formatTimeOfDay(r.end)))
+	}
+	return strings.Join(ranges, ",")
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	t = t.UTC()
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second +
+		time.Duration(t.Nanosecond())
+}
@@ -0,0 +1,129 @@
+package window
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2025, 6, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "case 0: empty spec is always open",
+			spec:     "",
+			expected: "",
+		},
+		{
+			name:     "case 1: single range",
+			spec:     "22:00-06:00",
+			expected: "22:00-06:00",
+		},
+		{
+			name:     "case 2: multiple ranges with whitespace",
+			spec:     " 01:00-05:00 , 12:30-13:00",
+			expected: "01:00-05:00,12:30-13:00",
+		},
+		{
+			name:        "case 3: missing end",
+			spec:        "22:00",
+			expectError: true,
+		},
+		{
+			name:        "case 4: invalid time",
+			spec:        "25:00-06:00",
+			expectError: true,
+		},
+		{
+			name:        "case 5: empty range",
+			spec:        "06:00-06:00",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := Parse(tc.spec)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, w.String())
+		})
+	}
+}
+
+func TestContainsAndUntilOpen(t *testing.T) {
+	testCases := []struct {
+		name          string
+		spec          string
+		now           time.Time
+		expectedOpen  bool
+		expectedUntil time.Duration
+	}{
+		{
+			name:         "case 0: nil window is always open",
+			spec:         "",
+			now:          at(12, 0),
+			expectedOpen: true,
+		},
+		{
+			name:         "case 1: inside a range",
+			spec:         "01:00-05:00",
+			now:          at(3, 0),
+			expectedOpen: true,
+		},
+		{
+			name:          "case 2: end of a range is exclusive",
+			spec:          "01:00-05:00",
+			now:           at(5, 0),
+			expectedUntil: 20 * time.Hour,
+		},
+		{
+			name:         "case 3: inside a range wrapping midnight",
+			spec:         "22:00-06:00",
+			now:          at(23, 30),
+			expectedOpen: true,
+		},
+		{
+			name:          "case 4: outside a range wrapping midnight",
+			spec:          "22:00-06:00",
+			now:           at(12, 0),
+			expectedUntil: 10 * time.Hour,
+		},
+		{
+			name:          "case 5: the next of multiple ranges is used",
+			spec:          "01:00-05:00,12:30-13:00",
+			now:           at(6, 0),
+			expectedUntil: 6*time.Hour + 30*time.Minute,
+		},
+		{
+			name:          "case 6: times are compared in UTC",
+			spec:          "01:00-05:00",
+			now:           time.Date(2025, 6, 1, 3, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)),
+			expectedUntil: 0,
+			expectedOpen:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := Parse(tc.spec)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedOpen, w.Contains(tc.now))
+			assert.Equal(t, tc.expectedUntil, w.UntilOpen(tc.now))
+		})
+	}
+}
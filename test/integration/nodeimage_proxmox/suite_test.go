@@ -131,27 +131,31 @@ var _ = BeforeSuite(func() {
 	fakeProxmox = testutil.StartFakeProxmox()
 
 	By("seeding the in-process S3 bucket with a qcow2 fixture")
-	imageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	imageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	qcow2 := testutil.BuildQCOW2()
 	fakeS3, err = testutil.StartFakeS3(imageKey, qcow2)
 	Expect(err).NotTo(HaveOccurred())
 
-	deleteImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	deleteImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testDeleteImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(deleteImageKey, qcow2)).To(Succeed())
 
-	idempotentImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	idempotentImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testIdempotentImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(idempotentImageKey, qcow2)).To(Succeed())
 
 	// Seeded with bytes that are not a valid qcow2 so the download validation fails.
-	errorImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	errorImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testErrorImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(errorImageKey, []byte("not a valid qcow2"))).To(Succeed())
 
 	// testMissingImageName is intentionally left unseeded.
@@ -182,9 +186,9 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 
 	reconciler = &imagectrl.NodeImageReconciler{
-		Client:    k8sClient,
-		S3Client:  s3Client,
-		Providers: map[string]provider.Provider{testProvider: proxmoxClient},
+		Client:      k8sClient,
+		ObjectStore: s3Client,
+		Providers:   map[string]provider.Provider{testProvider: proxmoxClient},
 	}
 })
 
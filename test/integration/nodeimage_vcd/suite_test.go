@@ -50,7 +50,7 @@ const (
 	// testProvider is "capvcd" (not "cloud-director") because that is the value
 	// the release controller writes into NodeImage.Spec.Provider for VCD
 	// releases, and pkg/image.GetImageKey derives the OVA S3 key from it (capvcd
-	// images live under the capv/ prefix). Using it keeps the seeded key and the
+	// images live under the capvcd/ prefix). Using it keeps the seeded key and the
 	// reconciler's lookup on the realistic path.
 	testProvider     = "capvcd"
 	testResourceName = "vcd-test-image"
@@ -132,28 +132,32 @@ var _ = BeforeSuite(func() {
 	fakeVCD = testutil.StartFakeVCD()
 
 	By("seeding the in-process S3 bucket with an OVA fixture")
-	imageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	imageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	ova, err := testutil.BuildOVA()
 	Expect(err).NotTo(HaveOccurred())
 	fakeS3, err = testutil.StartFakeS3(imageKey, ova)
 	Expect(err).NotTo(HaveOccurred())
 
-	deleteImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	deleteImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testDeleteImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(deleteImageKey, ova)).To(Succeed())
 
-	idempotentImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	idempotentImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testIdempotentImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(idempotentImageKey, ova)).To(Succeed())
 
 	// Seeded with bytes that are not a valid OVA so the OVF unpack fails.
-	errorImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	errorImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testErrorImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(errorImageKey, []byte("not a valid ova"))).To(Succeed())
 
 	// testMissingImageName is intentionally left unseeded.
@@ -185,9 +189,9 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 
 	reconciler = &imagectrl.NodeImageReconciler{
-		Client:    k8sClient,
-		S3Client:  s3Client,
-		Providers: map[string]provider.Provider{testProvider: vcdClient},
+		Client:      k8sClient,
+		ObjectStore: s3Client,
+		Providers:   map[string]provider.Provider{testProvider: vcdClient},
 	}
 })
 
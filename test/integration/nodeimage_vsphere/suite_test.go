@@ -128,28 +128,32 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 
 	By("seeding the in-process S3 bucket with an OVA fixture")
-	imageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	imageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	ova, err := testutil.BuildOVA()
 	Expect(err).NotTo(HaveOccurred())
 	fakeS3, err = testutil.StartFakeS3(imageKey, ova)
 	Expect(err).NotTo(HaveOccurred())
 
-	deleteImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	deleteImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testDeleteImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(deleteImageKey, ova)).To(Succeed())
 
-	idempotentImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	idempotentImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testIdempotentImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(idempotentImageKey, ova)).To(Succeed())
 
 	// Seeded with bytes that are not a valid OVA so the vSphere import fails.
-	errorImageKey := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
+	errorImageKey, err := imagekey.GetImageKey(&imagev1alpha1.NodeImage{
 		Spec: imagev1alpha1.NodeImageSpec{Name: testErrorImageName, Provider: testProvider},
 	})
+	Expect(err).NotTo(HaveOccurred())
 	Expect(fakeS3.Seed(errorImageKey, []byte("not a valid ova"))).To(Succeed())
 
 	// testMissingImageName is intentionally left unseeded.
@@ -181,9 +185,9 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 
 	reconciler = &imagectrl.NodeImageReconciler{
-		Client:    k8sClient,
-		S3Client:  s3Client,
-		Providers: map[string]provider.Provider{testProvider: vsphereClient},
+		Client:      k8sClient,
+		ObjectStore: s3Client,
+		Providers:   map[string]provider.Provider{testProvider: vsphereClient},
 	}
 })
 
@@ -61,7 +61,8 @@ func (v *VCSim) Host() string {
 // expects, wired to this simulator and its default inventory, and returns their
 // paths.
 func (v *VCSim) WriteConfig(dir string) (credentialsPath, locationsPath string, err error) {
-	credentials := fmt.Sprintf("vcenter: %s\nusername: user\npassword: pass\n", v.Host())
+	// the simulator serves a self-signed certificate
+	credentials := fmt.Sprintf("vcenter: %s\nusername: user\npassword: pass\ninsecure: true\n", v.Host())
 	credentialsPath = filepath.Join(dir, "credentials.yaml")
 	if err := os.WriteFile(credentialsPath, []byte(credentials), 0o600); err != nil {
 		return "", "", fmt.Errorf("failed to write credentials file: %w", err)